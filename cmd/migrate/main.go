@@ -0,0 +1,65 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"jarwise-backend/internal/db"
+	"log"
+	"os"
+)
+
+// Usage:
+//
+//	go run ./cmd/migrate [-db transactions.db] [version|status|up]
+//
+// version prints the current schema version, status lists the applied
+// migrations and up applies the pending ones.
+func main() {
+	dbPath := flag.String("db", "transactions.db", "path to the SQLite database")
+	flag.Parse()
+
+	command := "version"
+	if flag.NArg() > 0 {
+		command = flag.Arg(0)
+	}
+
+	dbConn, err := db.Open(*dbPath)
+	if err != nil {
+		log.Fatalf("Failed to open DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	switch command {
+	case "version":
+		current, err := db.CurrentVersion(dbConn)
+		if err != nil {
+			log.Fatalf("Failed to read schema version: %v", err)
+		}
+		fmt.Println(current)
+	case "status":
+		applied, err := db.AppliedMigrations(dbConn)
+		if err != nil {
+			log.Fatalf("Failed to read schema_migrations: %v", err)
+		}
+		for _, migration := range applied {
+			fmt.Printf("%4d  %-28s %s\n", migration.Version, migration.Name, migration.AppliedAt.Format("2006-01-02 15:04:05"))
+		}
+		current := 0
+		if len(applied) > 0 {
+			current = applied[len(applied)-1].Version
+		}
+		fmt.Printf("current version %d, latest %d\n", current, db.LatestVersion())
+	case "up":
+		if err := db.Migrate(dbConn); err != nil {
+			log.Fatalf("Migration failed: %v", err)
+		}
+		current, err := db.CurrentVersion(dbConn)
+		if err != nil {
+			log.Fatalf("Failed to read schema version: %v", err)
+		}
+		fmt.Printf("migrated to version %d\n", current)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown command %q (want version, status or up)\n", command)
+		os.Exit(2)
+	}
+}
@@ -3,6 +3,8 @@ package main
 import (
 	"fmt"
 	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
 	"log"
 	"math/rand"
 	"time"
@@ -34,8 +36,8 @@ func main() {
 
 	// 1. Seed Wallet
 	walletID := "wallet-1"
-	_, err = dbConn.Exec(`INSERT INTO wallets (id, name, currency, balance, type) VALUES (?, ?, ?, ?, ?)`,
-		walletID, "Main Wallet", "THB", 100000.0, "checking")
+	_, err = dbConn.Exec(`INSERT INTO wallets (id, name, currency, opening_balance, balance, type) VALUES (?, ?, ?, ?, ?, ?)`,
+		walletID, "Main Wallet", "THB", models.Money(10000000), models.Money(10000000), "checking")
 	if err != nil {
 		log.Fatalf("Failed to insert wallet: %v", err)
 	}
@@ -98,7 +100,7 @@ func main() {
 			txDate := time.Date(targetMonth.Year(), targetMonth.Month(), dayOffset+1, 10, 0, 0, 0, time.UTC)
 
 			_, err = dbConn.Exec(`INSERT INTO transactions (id, amount, description, date, type, wallet_id, jar_id) VALUES (?, ?, ?, ?, ?, ?, ?)`,
-				incomeID, amount, inc.name, txDate.Format(time.RFC3339), "income", walletID, inc.jar)
+				incomeID, models.MoneyFromFloat(amount), inc.name, txDate.Format(time.RFC3339), "income", walletID, inc.jar)
 			if err != nil {
 				log.Fatalf("Failed to insert income %s for month %d: %v", inc.name, m, err)
 			}
@@ -117,7 +119,7 @@ func main() {
 			txDate := time.Date(targetMonth.Year(), targetMonth.Month(), dayOffset+1, 12, 0, 0, 0, time.UTC)
 
 			_, err = dbConn.Exec(`INSERT INTO transactions (id, amount, description, date, type, wallet_id, jar_id) VALUES (?, ?, ?, ?, ?, ?, ?)`,
-				txID, amount, fmt.Sprintf("Expense %s #%d", selectedJar.Name, e), txDate.Format(time.RFC3339), "expense", walletID, selectedJar.ID)
+				txID, models.MoneyFromFloat(amount), fmt.Sprintf("Expense %s #%d", selectedJar.Name, e), txDate.Format(time.RFC3339), "expense", walletID, selectedJar.ID)
 			if err != nil {
 				log.Fatalf("Failed to insert expense for month %d, tx %d: %v", m, e, err)
 			}
@@ -125,5 +127,9 @@ func main() {
 		}
 	}
 
+	if err := repository.RefreshWalletBalances(dbConn, models.DefaultLocalUserID); err != nil {
+		log.Fatalf("Failed to refresh wallet balances: %v", err)
+	}
+
 	fmt.Printf("Successfully seeded %d transactions over 10 years!\n", txCount)
 }
@@ -4,6 +4,8 @@ import (
 	"database/sql"
 	"fmt"
 	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
 	"log"
 	"time"
 
@@ -46,8 +48,8 @@ func main() {
 	// 2. Insert Default Wallet
 	fmt.Println("Seeding default wallet...")
 	walletID := "wallet-1"
-	_, err = dbConn.Exec(`INSERT INTO wallets (id, name, currency, balance, type) VALUES (?, ?, ?, ?, ?)`,
-		walletID, "Main Wallet", "THB", 50000.0, "checking")
+	_, err = dbConn.Exec(`INSERT INTO wallets (id, name, currency, opening_balance, balance, type) VALUES (?, ?, ?, ?, ?, ?)`,
+		walletID, "Main Wallet", "THB", models.Money(5000000), models.Money(5000000), "checking")
 	if err != nil {
 		log.Fatalf("Failed to insert wallet: %v", err)
 	}
@@ -119,12 +121,16 @@ func main() {
 		txDate := parseDate(tx.Date, now)
 
 		_, err = dbConn.Exec(`INSERT INTO transactions (id, amount, description, date, type, wallet_id, jar_id) VALUES (?, ?, ?, ?, ?, ?, ?)`,
-			tx.ID, tx.Amount, tx.Description, txDate, txType, walletID, jarID)
+			tx.ID, models.MoneyFromFloat(tx.Amount), tx.Description, txDate, txType, walletID, jarID)
 		if err != nil {
 			log.Fatalf("Failed to insert transaction %s: %v", tx.Description, err)
 		}
 	}
 
+	if err := repository.RefreshWalletBalances(dbConn, models.DefaultLocalUserID); err != nil {
+		log.Fatalf("Failed to refresh wallet balances: %v", err)
+	}
+
 	fmt.Println("Database seeded successfully!")
 }
 
@@ -1,25 +1,57 @@
 package main
 
 import (
+	"context"
 	"jarwise-backend/internal/api"
 	"log"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
 	"time"
 )
 
+const shutdownTimeout = 10 * time.Second
+
 func main() {
-	router := api.NewRouter()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	app := api.NewApp(api.DefaultRouterOptions())
 
 	srv := &http.Server{
 		Addr:         ":8081",
-		Handler:      router,
+		Handler:      app.Handler,
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 15 * time.Second,
 		IdleTimeout:  60 * time.Second,
 	}
 
+	jobsDone := make(chan struct{})
+	go func() {
+		defer close(jobsDone)
+		app.RunJobs(ctx)
+	}()
+
+	serverDone := make(chan struct{})
+	go func() {
+		defer close(serverDone)
+		<-ctx.Done()
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancel()
+		if err := srv.Shutdown(shutdownCtx); err != nil {
+			log.Printf("Server shutdown failed: %v", err)
+		}
+	}()
+
 	log.Println("Server starting on port 8081...")
 	if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 		log.Fatalf("Server failed: %v", err)
 	}
+
+	// Let in-flight requests and background jobs finish before exiting.
+	stop()
+	<-serverDone
+	<-jobsDone
+	log.Println("Server stopped")
 }
@@ -0,0 +1,32 @@
+package api
+
+import (
+	"context"
+	"jarwise-backend/internal/auth"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/service"
+	"net/http"
+)
+
+// AuditMiddleware credits the changes an authenticated write makes to the
+// signed-in user and session in the audit log. It must run after
+// authentication.
+func AuditMiddleware(audit service.AuditService, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, ok := auth.UserFromContext(r.Context())
+		if !ok || !isWriteMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		sessionID, _ := auth.SessionIDFromContext(r.Context())
+		actor := models.AuditActor{UserID: user.ID, SessionID: sessionID, Source: models.AuditSourceAPI}
+		r = r.WithContext(service.ContextWithAuditActor(r.Context(), actor))
+
+		// The handler's own failures are in its response.
+		audit.Attribute(r.Context(), user.ID, actor, func(ctx context.Context) error {
+			next.ServeHTTP(w, r.WithContext(ctx))
+			return nil
+		})
+	})
+}
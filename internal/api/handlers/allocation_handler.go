@@ -0,0 +1,94 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"jarwise-backend/internal/auth"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/service"
+	"net/http"
+	"time"
+)
+
+type AllocationHandler struct {
+	service service.AllocationService
+}
+
+func NewAllocationHandler(service service.AllocationService) *AllocationHandler {
+	return &AllocationHandler{service: service}
+}
+
+// AllocationRulesRequest is the body of PUT /api/v1/allocations/rules.
+type AllocationRulesRequest struct {
+	Rules []models.AllocationRule `json:"rules"`
+}
+
+// Rules handles GET and PUT /api/v1/allocations/rules. PUT replaces the whole
+// rule set; the percentages must add up to 100, or the list may be empty.
+func (h *AllocationHandler) Rules(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var (
+		rules []models.AllocationRule
+		err   error
+	)
+	if r.Method == http.MethodPut {
+		var req AllocationRulesRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		rules, err = h.service.ReplaceRulesForUser(r.Context(), user.ID, req.Rules)
+	} else {
+		rules, err = h.service.ListRulesForUser(r.Context(), user.ID)
+	}
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAllocationRules) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to process allocation rules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(AllocationRulesRequest{Rules: rules})
+}
+
+// Balances handles GET /api/v1/allocations/balances?as_of=YYYY-MM-DD
+func (h *AllocationHandler) Balances(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	asOf, err := parseDateParam(r.URL.Query().Get("as_of"), time.Now().UTC(), true)
+	if err != nil {
+		http.Error(w, "Invalid as_of format. Use YYYY-MM-DD or RFC3339.", http.StatusBadRequest)
+		return
+	}
+
+	balances, err := h.service.JarBalancesForUser(r.Context(), user.ID, asOf)
+	if err != nil {
+		http.Error(w, "Failed to load jar balances", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(balances)
+}
@@ -0,0 +1,250 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"jarwise-backend/internal/auth"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/service"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type BudgetHandler struct {
+	service service.BudgetService
+}
+
+func NewBudgetHandler(service service.BudgetService) *BudgetHandler {
+	return &BudgetHandler{service: service}
+}
+
+// BudgetRequest is the body of POST /api/v1/budgets.
+type BudgetRequest struct {
+	JarID     string                `json:"jar_id"`
+	Period    models.BudgetPeriod   `json:"period"`
+	Amount    models.Money          `json:"amount"`
+	Rollover  models.BudgetRollover `json:"rollover"`
+	StartDate string                `json:"start_date"`
+}
+
+// PatchBudgetRequest only updates the fields that are present.
+type PatchBudgetRequest struct {
+	Amount    *models.Money          `json:"amount"`
+	Rollover  *models.BudgetRollover `json:"rollover"`
+	StartDate *string                `json:"start_date"`
+}
+
+// List handles GET /api/v1/budgets?as_of=YYYY-MM-DD and reports every budget
+// for the period containing as_of (default: today).
+func (h *BudgetHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	asOf, err := parseDateParam(r.URL.Query().Get("as_of"), time.Time{}, false)
+	if err != nil {
+		http.Error(w, "Invalid as_of format. Use YYYY-MM-DD or RFC3339.", http.StatusBadRequest)
+		return
+	}
+
+	statuses, err := h.service.ListStatusForUser(r.Context(), user.ID, asOf)
+	if err != nil {
+		http.Error(w, "Failed to load budgets", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statuses)
+}
+
+// Create handles POST /api/v1/budgets
+func (h *BudgetHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req BudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	budget := &models.Budget{
+		JarID:    req.JarID,
+		Period:   req.Period,
+		Amount:   req.Amount,
+		Rollover: req.Rollover,
+	}
+	if req.StartDate != "" {
+		startDate, err := parseTransactionDate(req.StartDate)
+		if err != nil {
+			http.Error(w, "Invalid start_date format. Use YYYY-MM-DD or RFC3339.", http.StatusBadRequest)
+			return
+		}
+		budget.StartDate = startDate
+	}
+
+	created, err := h.service.CreateForUser(r.Context(), user.ID, budget)
+	if err != nil {
+		writeBudgetError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// Get handles GET /api/v1/budgets/:id?as_of=YYYY-MM-DD
+func (h *BudgetHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := budgetIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid budget ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	asOf, err := parseDateParam(r.URL.Query().Get("as_of"), time.Time{}, false)
+	if err != nil {
+		http.Error(w, "Invalid as_of format. Use YYYY-MM-DD or RFC3339.", http.StatusBadRequest)
+		return
+	}
+
+	status, err := h.service.GetStatusForUser(r.Context(), user.ID, id, asOf)
+	if err != nil {
+		writeBudgetError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(status)
+}
+
+// Patch handles PATCH /api/v1/budgets/:id
+func (h *BudgetHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := budgetIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid budget ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req PatchBudgetRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	current, err := h.service.GetStatusForUser(r.Context(), user.ID, id, time.Time{})
+	if err != nil {
+		writeBudgetError(w, err)
+		return
+	}
+	budget := current.Budget
+	if req.Amount != nil {
+		budget.Amount = *req.Amount
+	}
+	if req.Rollover != nil {
+		budget.Rollover = *req.Rollover
+	}
+	if req.StartDate != nil {
+		startDate, err := parseTransactionDate(*req.StartDate)
+		if err != nil {
+			http.Error(w, "Invalid start_date format. Use YYYY-MM-DD or RFC3339.", http.StatusBadRequest)
+			return
+		}
+		budget.StartDate = startDate
+	}
+
+	updated, err := h.service.UpdateForUser(r.Context(), user.ID, &budget)
+	if err != nil {
+		writeBudgetError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// Delete handles DELETE /api/v1/budgets/:id
+func (h *BudgetHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := budgetIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid budget ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.DeleteForUser(r.Context(), user.ID, id); err != nil {
+		writeBudgetError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeBudgetError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrBudgetNotFound):
+		http.Error(w, "Budget not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidBudget):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrBudgetExists):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Failed to process budget", http.StatusInternalServerError)
+	}
+}
+
+func budgetIDFromPath(path string) (string, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 4 || parts[3] == "" {
+		return "", errors.New("invalid budget path")
+	}
+	return parts[3], nil
+}
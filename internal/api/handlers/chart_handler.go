@@ -48,12 +48,14 @@ func (h *ChartHandler) GetChartData(w http.ResponseWriter, r *http.Request) {
 
 	jarIDs := parseIDsParam(r, "jar_ids", "category_ids")
 	walletIDs := parseIDsParam(r, "wallet_ids", "account_ids")
+	tagIDs := parseIDsParam(r, "tag_ids")
 
 	filter := models.ReportFilter{
 		StartDate: startDate,
 		EndDate:   endDate,
 		JarIDs:    jarIDs,
 		WalletIDs: walletIDs,
+		TagIDs:    tagIDs,
 	}
 
 	user, ok := auth.UserFromContext(r.Context())
@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"jarwise-backend/internal/auth"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/service"
+	"net/http"
+	"strings"
+)
+
+// maxExchangeRateCSVSize caps uploaded exchange-rate files.
+const maxExchangeRateCSVSize = 5 << 20
+
+type CurrencyHandler struct {
+	service service.CurrencyService
+}
+
+func NewCurrencyHandler(service service.CurrencyService) *CurrencyHandler {
+	return &CurrencyHandler{service: service}
+}
+
+// BaseCurrencyRequest is the body of PUT /api/v1/currency/base.
+type BaseCurrencyRequest struct {
+	BaseCurrency string `json:"base_currency"`
+}
+
+// ExchangeRateRequest is the body of POST /api/v1/exchange-rates.
+type ExchangeRateRequest struct {
+	FromCurrency  string  `json:"from_currency"`
+	ToCurrency    string  `json:"to_currency"`
+	Rate          float64 `json:"rate"`
+	EffectiveDate string  `json:"effective_date"`
+}
+
+// BaseCurrency handles GET and PUT /api/v1/currency/base
+func (h *CurrencyHandler) BaseCurrency(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var (
+		currency string
+		err      error
+	)
+	if r.Method == http.MethodPut {
+		var req BaseCurrencyRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		currency, err = h.service.SetBaseCurrencyForUser(r.Context(), user.ID, req.BaseCurrency)
+	} else {
+		currency, err = h.service.GetBaseCurrencyForUser(r.Context(), user.ID)
+	}
+	if err != nil {
+		writeCurrencyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(BaseCurrencyRequest{BaseCurrency: currency})
+}
+
+// ListRates handles GET /api/v1/exchange-rates
+func (h *CurrencyHandler) ListRates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	rates, err := h.service.ListRatesForUser(r.Context(), user.ID)
+	if err != nil {
+		writeCurrencyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rates)
+}
+
+// CreateRate handles POST /api/v1/exchange-rates. A rate for the same pair
+// and date is replaced.
+func (h *CurrencyHandler) CreateRate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req ExchangeRateRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	effectiveDate, err := parseTransactionDate(req.EffectiveDate)
+	if err != nil {
+		http.Error(w, "Invalid effective_date format. Use YYYY-MM-DD or RFC3339.", http.StatusBadRequest)
+		return
+	}
+
+	rate, err := h.service.CreateRateForUser(r.Context(), user.ID, &models.ExchangeRate{
+		FromCurrency:  req.FromCurrency,
+		ToCurrency:    req.ToCurrency,
+		Rate:          req.Rate,
+		EffectiveDate: effectiveDate,
+	})
+	if err != nil {
+		writeCurrencyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(rate)
+}
+
+// ImportRates handles POST /api/v1/exchange-rates/import with a multipart
+// "file" field holding a CSV with date, from_currency, to_currency and rate
+// columns.
+func (h *CurrencyHandler) ImportRates(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxExchangeRateCSVSize)
+	if err := r.ParseMultipartForm(maxExchangeRateCSVSize); err != nil {
+		http.Error(w, "File too large or invalid format", http.StatusBadRequest)
+		return
+	}
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, "Missing file", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	result, err := h.service.ImportRatesCSVForUser(r.Context(), user.ID, file)
+	if err != nil {
+		writeCurrencyError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// DeleteRate handles DELETE /api/v1/exchange-rates/:id
+func (h *CurrencyHandler) DeleteRate(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := exchangeRateIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid exchange rate ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.DeleteRateForUser(r.Context(), user.ID, id); err != nil {
+		writeCurrencyError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeCurrencyError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrExchangeRateNotFound):
+		http.Error(w, "Exchange rate not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidCurrency), errors.Is(err, service.ErrInvalidExchangeRate):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to process currency settings", http.StatusInternalServerError)
+	}
+}
+
+func exchangeRateIDFromPath(path string) (string, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 4 || parts[3] == "" {
+		return "", errors.New("invalid exchange rate path")
+	}
+	return parts[3], nil
+}
@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Wallets, jars and transactions carry a version that goes up with every
+// edit. Its ETag is the quoted version, e.g. "3". Writes must send it back in
+// If-Match so an edit made from a stale copy is refused instead of silently
+// overwriting someone else's change.
+
+// setETag sets the ETag header for a record at version.
+func setETag(w http.ResponseWriter, version int64) {
+	w.Header().Set("ETag", strconv.Quote(strconv.FormatInt(version, 10)))
+}
+
+// ifMatch is a parsed If-Match header.
+type ifMatch struct {
+	any      bool
+	versions []int64
+}
+
+// matches reports whether a record at version satisfies the header. Weak and
+// unrecognised tags never match, as If-Match uses strong comparison.
+func (m ifMatch) matches(version int64) bool {
+	if m.any {
+		return true
+	}
+	for _, v := range m.versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
+// requireIfMatch reads the If-Match header of a write. When it is missing it
+// answers 428 Precondition Required and returns false.
+func requireIfMatch(w http.ResponseWriter, r *http.Request) (ifMatch, bool) {
+	header := strings.TrimSpace(r.Header.Get("If-Match"))
+	if header == "" {
+		http.Error(w, "If-Match header is required", http.StatusPreconditionRequired)
+		return ifMatch{}, false
+	}
+	if header == "*" {
+		return ifMatch{any: true}, true
+	}
+
+	var cond ifMatch
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		version, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64)
+		if err != nil {
+			continue
+		}
+		cond.versions = append(cond.versions, version)
+	}
+	return cond, true
+}
+
+// writePreconditionFailed answers 412 with the record as it is now, so the
+// client can merge its change and retry with the new ETag.
+func writePreconditionFailed(w http.ResponseWriter, current interface{}, version int64) {
+	setETag(w, version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	json.NewEncoder(w).Encode(current)
+}
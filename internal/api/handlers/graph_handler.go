@@ -3,7 +3,6 @@ package handlers
 import (
 	"encoding/json"
 	"jarwise-backend/internal/auth"
-	"jarwise-backend/internal/models"
 	"jarwise-backend/internal/service"
 	"net/http"
 )
@@ -16,10 +15,6 @@ func NewGraphHandler(service service.GraphService) *GraphHandler {
 	return &GraphHandler{service: service}
 }
 
-type GraphResponse struct {
-	Data []models.GraphDataPoint `json:"data"`
-}
-
 func (h *GraphHandler) GetExpenseGraphData(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -40,17 +35,12 @@ func (h *GraphHandler) GetExpenseGraphData(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
-	data, err := h.service.GetExpenseGraphDataForUser(user.ID, jarID, period)
+	graph, err := h.service.GetExpenseGraphDataForUser(user.ID, jarID, period)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	// Ensure non-nil array for JSON
-	if data == nil {
-		data = []models.GraphDataPoint{}
-	}
-
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(GraphResponse{Data: data})
+	json.NewEncoder(w).Encode(graph)
 }
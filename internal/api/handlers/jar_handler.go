@@ -2,19 +2,49 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"jarwise-backend/internal/auth"
+	"jarwise-backend/internal/models"
 	"jarwise-backend/internal/repository"
+	"jarwise-backend/internal/service"
 	"net/http"
+	"strings"
 )
 
 type JarHandler struct {
-	repo repository.JarRepository
+	service service.JarService
 }
 
-func NewJarHandler(repo repository.JarRepository) *JarHandler {
-	return &JarHandler{repo: repo}
+func NewJarHandler(service service.JarService) *JarHandler {
+	return &JarHandler{service: service}
 }
 
+// JarRequest is the body of POST /api/v1/jars.
+type JarRequest struct {
+	Name     string `json:"name"`
+	Type     string `json:"type"`
+	ParentID string `json:"parent_id"`
+	WalletID string `json:"wallet_id"`
+	Icon     string `json:"icon"`
+	Color    string `json:"color"`
+}
+
+// PatchJarRequest only updates the fields that are present.
+type PatchJarRequest struct {
+	Name     *string `json:"name"`
+	Type     *string `json:"type"`
+	ParentID *string `json:"parent_id"`
+	WalletID *string `json:"wallet_id"`
+	Icon     *string `json:"icon"`
+	Color    *string `json:"color"`
+}
+
+// MoveJarRequest re-parents a jar; an empty parent_id moves it to the top level.
+type MoveJarRequest struct {
+	ParentID string `json:"parent_id"`
+}
+
+// List handles GET /api/v1/jars. Pass view=tree to nest sub-jars under their parents.
 func (h *JarHandler) List(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodGet {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -27,12 +57,299 @@ func (h *JarHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	jars, err := h.repo.ListAllForUser(r.Context(), user.ID)
+	var (
+		result interface{}
+		err    error
+	)
+	switch r.URL.Query().Get("view") {
+	case "", "flat":
+		result, err = h.service.ListForUser(r.Context(), user.ID)
+	case "tree":
+		result, err = h.service.TreeForUser(r.Context(), user.ID)
+	default:
+		http.Error(w, "view must be flat or tree", http.StatusBadRequest)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to load jars", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(jars)
+	json.NewEncoder(w).Encode(result)
+}
+
+// Create handles POST /api/v1/jars
+func (h *JarHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req JarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	jar, err := h.service.CreateForUser(r.Context(), user.ID, &models.Jar{
+		Name:     req.Name,
+		Type:     req.Type,
+		ParentID: req.ParentID,
+		WalletID: req.WalletID,
+		Icon:     req.Icon,
+		Color:    req.Color,
+	})
+	if err != nil {
+		writeJarError(w, err)
+		return
+	}
+
+	setETag(w, jar.Version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(jar)
+}
+
+// Get handles GET /api/v1/jars/:id
+func (h *JarHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := jarIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid jar ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	jar, err := h.service.GetForUser(r.Context(), user.ID, id)
+	if err != nil {
+		writeJarError(w, err)
+		return
+	}
+
+	setETag(w, jar.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jar)
+}
+
+// Patch handles PATCH /api/v1/jars/:id. It requires If-Match.
+func (h *JarHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := jarIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid jar ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	cond, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req PatchJarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	jar, err := h.service.GetForUser(r.Context(), user.ID, id)
+	if err != nil {
+		writeJarError(w, err)
+		return
+	}
+	if !cond.matches(jar.Version) {
+		writePreconditionFailed(w, jar, jar.Version)
+		return
+	}
+	if req.Name != nil {
+		jar.Name = *req.Name
+	}
+	if req.Type != nil {
+		jar.Type = *req.Type
+	}
+	if req.ParentID != nil {
+		jar.ParentID = *req.ParentID
+	}
+	if req.WalletID != nil {
+		jar.WalletID = *req.WalletID
+	}
+	if req.Icon != nil {
+		jar.Icon = *req.Icon
+	}
+	if req.Color != nil {
+		jar.Color = *req.Color
+	}
+
+	updated, err := h.service.UpdateForUser(r.Context(), user.ID, jar)
+	if err != nil {
+		h.writeUpdateError(w, r, user.ID, id, err)
+		return
+	}
+
+	setETag(w, updated.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// Move handles POST /api/v1/jars/:id/move. It requires If-Match.
+func (h *JarHandler) Move(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := jarIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid jar ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	cond, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req MoveJarRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	current, ok := h.checkIfMatch(w, r, user.ID, id, cond)
+	if !ok {
+		return
+	}
+	jar, err := h.service.MoveForUser(r.Context(), user.ID, id, req.ParentID, current.Version)
+	if err != nil {
+		h.writeUpdateError(w, r, user.ID, id, err)
+		return
+	}
+
+	setETag(w, jar.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(jar)
+}
+
+// Delete handles DELETE /api/v1/jars/:id. Like wallet deletion it refuses
+// jars that are still in use unless replacement_id or cascade=true is given.
+// It requires If-Match.
+func (h *JarHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := jarIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid jar ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	cond, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+	jar, ok := h.checkIfMatch(w, r, user.ID, id, cond)
+	if !ok {
+		return
+	}
+
+	opts := service.JarDeleteOptions{
+		ReplacementJarID: r.URL.Query().Get("replacement_id"),
+		Cascade:          r.URL.Query().Get("cascade") == "true",
+		Version:          jar.Version,
+	}
+	if err := h.service.DeleteForUser(r.Context(), user.ID, id, opts); err != nil {
+		h.writeUpdateError(w, r, user.ID, id, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// checkIfMatch loads the jar and answers 404 or 412 unless it satisfies cond.
+func (h *JarHandler) checkIfMatch(w http.ResponseWriter, r *http.Request, userID, id string, cond ifMatch) (*models.Jar, bool) {
+	jar, err := h.service.GetForUser(r.Context(), userID, id)
+	if err != nil {
+		writeJarError(w, err)
+		return nil, false
+	}
+	if !cond.matches(jar.Version) {
+		writePreconditionFailed(w, jar, jar.Version)
+		return nil, false
+	}
+	return jar, true
+}
+
+// writeUpdateError reports a failed update. A jar changed by another request
+// since it was checked is answered like a failed If-Match.
+func (h *JarHandler) writeUpdateError(w http.ResponseWriter, r *http.Request, userID, id string, err error) {
+	if !errors.Is(err, repository.ErrVersionMismatch) {
+		writeJarError(w, err)
+		return
+	}
+	current, err := h.service.GetForUser(r.Context(), userID, id)
+	if err != nil {
+		writeJarError(w, err)
+		return
+	}
+	writePreconditionFailed(w, current, current.Version)
+}
+
+func writeJarError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrJarNotFound):
+		http.Error(w, "Jar not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidJar), errors.Is(err, service.ErrJarCycle):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrJarInUse):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Failed to process jar", http.StatusInternalServerError)
+	}
+}
+
+func jarIDFromPath(path string) (string, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 4 || parts[3] == "" {
+		return "", errors.New("invalid jar path")
+	}
+	return parts[3], nil
 }
@@ -27,7 +27,7 @@ func TestMigrationJobWorkflow_PersistsMigratedData(t *testing.T) {
 	}
 	seedTestUser(t, dbConn, "user-1")
 
-	handler := NewMigrationHandler(service.NewMigrationService(dbConn))
+	handler := NewMigrationHandler(service.NewMigrationService(dbConn, nil))
 
 	createBody, contentType := buildMigrationMultipartBody(t)
 	createReq := withAuthenticatedUser(
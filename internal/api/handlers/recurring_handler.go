@@ -0,0 +1,415 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"jarwise-backend/internal/auth"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type RecurringHandler struct {
+	service service.RecurringService
+}
+
+func NewRecurringHandler(service service.RecurringService) *RecurringHandler {
+	return &RecurringHandler{service: service}
+}
+
+// RecurringRuleRequest is the body of POST /api/v1/recurring.
+type RecurringRuleRequest struct {
+	WalletID    string                    `json:"wallet_id"`
+	JarID       string                    `json:"jar_id"`
+	Type        string                    `json:"type"`
+	Amount      models.Money              `json:"amount"`
+	Description string                    `json:"description"`
+	Frequency   models.RecurringFrequency `json:"frequency"`
+	Interval    int                       `json:"interval"`
+	DayOfMonth  int                       `json:"day_of_month"`
+	StartDate   string                    `json:"start_date"`
+	EndDate     string                    `json:"end_date"`
+	Count       *int                      `json:"count"`
+}
+
+// PatchRecurringRuleRequest only updates the fields that are present. An
+// empty end_date or a zero count removes that limit.
+type PatchRecurringRuleRequest struct {
+	WalletID    *string                    `json:"wallet_id"`
+	JarID       *string                    `json:"jar_id"`
+	Type        *string                    `json:"type"`
+	Amount      *models.Money              `json:"amount"`
+	Description *string                    `json:"description"`
+	Frequency   *models.RecurringFrequency `json:"frequency"`
+	Interval    *int                       `json:"interval"`
+	DayOfMonth  *int                       `json:"day_of_month"`
+	StartDate   *string                    `json:"start_date"`
+	EndDate     *string                    `json:"end_date"`
+	Count       *int                       `json:"count"`
+}
+
+// SkipOccurrenceRequest is the body of POST /api/v1/recurring/:id/skip.
+type SkipOccurrenceRequest struct {
+	Date string `json:"date"`
+}
+
+// MaterializeResponse reports how many transactions a manual run recorded.
+type MaterializeResponse struct {
+	Created int `json:"created"`
+}
+
+// List handles GET /api/v1/recurring
+func (h *RecurringHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	rules, err := h.service.ListForUser(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "Failed to load recurring rules", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rules)
+}
+
+// Create handles POST /api/v1/recurring
+func (h *RecurringHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req RecurringRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	rule := &models.RecurringRule{
+		WalletID:    req.WalletID,
+		JarID:       req.JarID,
+		Type:        req.Type,
+		Amount:      req.Amount,
+		Description: req.Description,
+		Frequency:   req.Frequency,
+		Interval:    req.Interval,
+		DayOfMonth:  req.DayOfMonth,
+		Count:       req.Count,
+	}
+	if req.StartDate != "" {
+		startDate, err := parseTransactionDate(req.StartDate)
+		if err != nil {
+			http.Error(w, "Invalid start_date format. Use YYYY-MM-DD or RFC3339.", http.StatusBadRequest)
+			return
+		}
+		rule.StartDate = startDate
+	}
+	if req.EndDate != "" {
+		endDate, err := parseDateParam(req.EndDate, time.Time{}, true)
+		if err != nil {
+			http.Error(w, "Invalid end_date format. Use YYYY-MM-DD or RFC3339.", http.StatusBadRequest)
+			return
+		}
+		rule.EndDate = &endDate
+	}
+
+	created, err := h.service.CreateForUser(r.Context(), user.ID, rule)
+	if err != nil {
+		writeRecurringError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(created)
+}
+
+// Get handles GET /api/v1/recurring/:id
+func (h *RecurringHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := recurringIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid recurring rule ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	rule, err := h.service.GetForUser(r.Context(), user.ID, id)
+	if err != nil {
+		writeRecurringError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rule)
+}
+
+// Patch handles PATCH /api/v1/recurring/:id. Transactions already recorded
+// from the rule are left alone; the change applies to future occurrences.
+func (h *RecurringHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := recurringIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid recurring rule ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req PatchRecurringRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	rule, err := h.service.GetForUser(r.Context(), user.ID, id)
+	if err != nil {
+		writeRecurringError(w, err)
+		return
+	}
+	if req.WalletID != nil {
+		rule.WalletID = *req.WalletID
+	}
+	if req.JarID != nil {
+		rule.JarID = *req.JarID
+	}
+	if req.Type != nil {
+		rule.Type = *req.Type
+	}
+	if req.Amount != nil {
+		rule.Amount = *req.Amount
+	}
+	if req.Description != nil {
+		rule.Description = *req.Description
+	}
+	if req.Frequency != nil {
+		rule.Frequency = *req.Frequency
+	}
+	if req.Interval != nil {
+		rule.Interval = *req.Interval
+	}
+	if req.DayOfMonth != nil {
+		rule.DayOfMonth = *req.DayOfMonth
+	}
+	if req.StartDate != nil {
+		startDate, err := parseTransactionDate(*req.StartDate)
+		if err != nil {
+			http.Error(w, "Invalid start_date format. Use YYYY-MM-DD or RFC3339.", http.StatusBadRequest)
+			return
+		}
+		rule.StartDate = startDate
+	}
+	if req.EndDate != nil {
+		rule.EndDate = nil
+		if *req.EndDate != "" {
+			endDate, err := parseDateParam(*req.EndDate, time.Time{}, true)
+			if err != nil {
+				http.Error(w, "Invalid end_date format. Use YYYY-MM-DD or RFC3339.", http.StatusBadRequest)
+				return
+			}
+			rule.EndDate = &endDate
+		}
+	}
+	if req.Count != nil {
+		rule.Count = nil
+		if *req.Count != 0 {
+			rule.Count = req.Count
+		}
+	}
+
+	updated, err := h.service.UpdateForUser(r.Context(), user.ID, rule)
+	if err != nil {
+		writeRecurringError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// Delete handles DELETE /api/v1/recurring/:id. Recorded transactions are kept.
+func (h *RecurringHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := recurringIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid recurring rule ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.DeleteForUser(r.Context(), user.ID, id); err != nil {
+		writeRecurringError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// Upcoming handles GET /api/v1/recurring/upcoming?until=YYYY-MM-DD&limit=50
+func (h *RecurringHandler) Upcoming(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	until, err := parseDateParam(query.Get("until"), time.Time{}, true)
+	if err != nil {
+		http.Error(w, "Invalid until format. Use YYYY-MM-DD or RFC3339.", http.StatusBadRequest)
+		return
+	}
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+
+	occurrences, err := h.service.UpcomingForUser(r.Context(), user.ID, until, limit)
+	if err != nil {
+		http.Error(w, "Failed to load upcoming occurrences", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(occurrences)
+}
+
+// Skip handles POST /api/v1/recurring/:id/skip
+func (h *RecurringHandler) Skip(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := recurringIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid recurring rule ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req SkipOccurrenceRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+	day, err := parseTransactionDate(req.Date)
+	if err != nil {
+		http.Error(w, "Invalid date format. Use YYYY-MM-DD or RFC3339.", http.StatusBadRequest)
+		return
+	}
+
+	occurrence, err := h.service.SkipForUser(r.Context(), user.ID, id, day)
+	if err != nil {
+		writeRecurringError(w, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(occurrence)
+}
+
+// Materialize handles POST /api/v1/recurring/materialize and records the
+// caller's due occurrences without waiting for the background run.
+func (h *RecurringHandler) Materialize(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	created, err := h.service.MaterializeDueForUser(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "Failed to record recurring transactions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(MaterializeResponse{Created: created})
+}
+
+func writeRecurringError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrRecurringRuleNotFound):
+		http.Error(w, "Recurring rule not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrRecurringOccurrenceNotFound):
+		http.Error(w, err.Error(), http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidRecurringRule):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrRecurringOccurrenceRecorded):
+		http.Error(w, err.Error(), http.StatusConflict)
+	default:
+		http.Error(w, "Failed to process recurring rule", http.StatusInternalServerError)
+	}
+}
+
+func recurringIDFromPath(path string) (string, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 4 || parts[3] == "" {
+		return "", errors.New("invalid recurring rule path")
+	}
+	return parts[3], nil
+}
@@ -100,12 +100,14 @@ func (h *ReportHandler) parseFilter(r *http.Request) (models.ReportFilter, error
 
 	jarIDs := parseIDsParam(r, "jar_ids", "category_ids")
 	walletIDs := parseIDsParam(r, "wallet_ids", "account_ids")
+	tagIDs := parseIDsParam(r, "tag_ids")
 
 	return models.ReportFilter{
 		StartDate: startDate,
 		EndDate:   endDate,
 		JarIDs:    jarIDs,
 		WalletIDs: walletIDs,
+		TagIDs:    tagIDs,
 	}, nil
 }
 
@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"jarwise-backend/internal/auth"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type SearchHandler struct {
+	service service.SearchService
+}
+
+func NewSearchHandler(service service.SearchService) *SearchHandler {
+	return &SearchHandler{service: service}
+}
+
+// Search handles GET /api/v1/search?q=coffee&start_date=&end_date=&jar_ids=&limit=20&offset=0
+func (h *SearchHandler) Search(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	startDate, err := parseDateParam(query.Get("start_date"), time.Time{}, false)
+	if err != nil {
+		http.Error(w, "invalid start_date format. Use YYYY-MM-DD or RFC3339", http.StatusBadRequest)
+		return
+	}
+	endDate, err := parseDateParam(query.Get("end_date"), time.Time{}, true)
+	if err != nil {
+		http.Error(w, "invalid end_date format. Use YYYY-MM-DD or RFC3339", http.StatusBadRequest)
+		return
+	}
+
+	search := models.TransactionSearch{
+		Query:     query.Get("q"),
+		StartDate: startDate,
+		EndDate:   endDate,
+		JarIDs:    parseIDsParam(r, "jar_ids", "category_ids"),
+	}
+	if value := query.Get("limit"); value != "" {
+		search.Limit, err = strconv.Atoi(value)
+		if err != nil || search.Limit <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+	}
+	if value := query.Get("offset"); value != "" {
+		search.Offset, err = strconv.Atoi(value)
+		if err != nil || search.Offset < 0 {
+			http.Error(w, "Invalid offset", http.StatusBadRequest)
+			return
+		}
+	}
+
+	page, err := h.service.SearchForUser(r.Context(), user.ID, search)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidSearch) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, "Failed to search transactions", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"jarwise-backend/internal/auth"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/service"
+	"net/http"
+	"strconv"
+)
+
+type SyncHandler struct {
+	service service.SyncService
+}
+
+func NewSyncHandler(service service.SyncService) *SyncHandler {
+	return &SyncHandler{service: service}
+}
+
+// SyncPushRequest is the body of POST /api/v1/sync/push.
+type SyncPushRequest struct {
+	Mutations []models.SyncMutation `json:"mutations"`
+}
+
+// Changes handles GET /api/v1/sync/changes?since=0&limit=500
+func (h *SyncHandler) Changes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	query := r.URL.Query()
+	var since int64
+	if value := query.Get("since"); value != "" {
+		parsed, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || parsed < 0 {
+			http.Error(w, "Invalid since", http.StatusBadRequest)
+			return
+		}
+		since = parsed
+	}
+	limit := 0
+	if value := query.Get("limit"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil || parsed <= 0 {
+			http.Error(w, "Invalid limit", http.StatusBadRequest)
+			return
+		}
+		limit = parsed
+	}
+
+	page, err := h.service.ChangesForUser(r.Context(), user.ID, since, limit)
+	if err != nil {
+		writeSyncError(w, err, "Failed to load changes")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(page)
+}
+
+// Push handles POST /api/v1/sync/push. Rejected mutations are reported in
+// the body; the request itself still succeeds.
+func (h *SyncHandler) Push(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req SyncPushRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	result, err := h.service.PushForUser(r.Context(), user.ID, req.Mutations)
+	if err != nil {
+		writeSyncError(w, err, "Failed to apply mutations")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+func writeSyncError(w http.ResponseWriter, err error, fallback string) {
+	if errors.Is(err, service.ErrInvalidSync) {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	http.Error(w, fallback, http.StatusInternalServerError)
+}
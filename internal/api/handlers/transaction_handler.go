@@ -2,10 +2,15 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
+	"fmt"
 	"jarwise-backend/internal/auth"
 	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
 	"jarwise-backend/internal/service"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -17,17 +22,60 @@ func NewTransactionHandler(service service.TransactionService) *TransactionHandl
 	return &TransactionHandler{service: service}
 }
 
+// CreateTransferRequest is the body of POST /api/v1/transfers. Amount is the
+// older name for SourceAmount. DestinationAmount defaults to the source amount
+// and only differs for wallets in different currencies.
 type CreateTransferRequest struct {
-	FromWalletID string  `json:"from_wallet_id"`
-	ToWalletID   string  `json:"to_wallet_id"`
-	Amount       float64 `json:"amount"`
-	Date         string  `json:"date"` // IOS8601
-	Notes        string  `json:"notes"`
+	FromWalletID      string       `json:"from_wallet_id"`
+	ToWalletID        string       `json:"to_wallet_id"`
+	Amount            models.Money `json:"amount"`
+	SourceAmount      models.Money `json:"source_amount"`
+	DestinationAmount models.Money `json:"destination_amount"`
+	FeeAmount         models.Money `json:"fee_amount"`
+	FeeJarID          string       `json:"fee_jar_id"`
+	Date              string       `json:"date"` // IOS8601
+	Notes             string       `json:"notes"`
 }
 
-type CreateTransferResponse struct {
-	ExpenseTransaction *models.Transaction `json:"expense_transaction"`
-	IncomeTransaction  *models.Transaction `json:"income_transaction"`
+// TransactionRequest is the body of POST and PUT requests for a single
+// income or expense. Amount is always a positive magnitude. Splits replace
+// JarID when the amount is spread over several jars.
+type TransactionRequest struct {
+	Amount      models.Money              `json:"amount"`
+	Description string                    `json:"description"`
+	Date        string                    `json:"date"`
+	Type        string                    `json:"type"`
+	WalletID    string                    `json:"wallet_id"`
+	JarID       string                    `json:"jar_id"`
+	Splits      []models.TransactionSplit `json:"splits"`
+	TagIDs      []string                  `json:"tag_ids"`
+}
+
+// PatchTransactionRequest only updates the fields that are present. Sending
+// splits or tag_ids replaces all split lines or tags; an empty list removes
+// them.
+type PatchTransactionRequest struct {
+	Amount      *models.Money              `json:"amount"`
+	Description *string                    `json:"description"`
+	Date        *string                    `json:"date"`
+	Type        *string                    `json:"type"`
+	WalletID    *string                    `json:"wallet_id"`
+	JarID       *string                    `json:"jar_id"`
+	Splits      *[]models.TransactionSplit `json:"splits"`
+	TagIDs      *[]string                  `json:"tag_ids"`
+}
+
+// PatchTransferRequest is the body of PATCH /api/v1/transfers/:id. Omitted
+// fields keep their current value; "fee_amount": 0 removes the fee.
+type PatchTransferRequest struct {
+	FromWalletID      *string       `json:"from_wallet_id"`
+	ToWalletID        *string       `json:"to_wallet_id"`
+	SourceAmount      *models.Money `json:"source_amount"`
+	DestinationAmount *models.Money `json:"destination_amount"`
+	FeeAmount         *models.Money `json:"fee_amount"`
+	FeeJarID          *string       `json:"fee_jar_id"`
+	Date              *string       `json:"date"`
+	Notes             *string       `json:"notes"`
 }
 
 func (h *TransactionHandler) List(w http.ResponseWriter, r *http.Request) {
@@ -42,14 +90,81 @@ func (h *TransactionHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	transactions, err := h.service.ListForUser(user.ID)
+	filter, err := parseTransactionListFilter(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Clients that ask for a page get the page object; everyone else keeps
+	// getting the plain array of every matching transaction.
+	var response interface{}
+	query := r.URL.Query()
+	if query.Has("limit") || query.Has("cursor") {
+		response, err = h.service.ListPageForUser(r.Context(), user.ID, filter)
+	} else {
+		response, err = h.service.ListFilteredForUser(r.Context(), user.ID, filter)
+	}
 	if err != nil {
+		if errors.Is(err, service.ErrInvalidTransactionFilter) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		http.Error(w, "Failed to load transactions", http.StatusInternalServerError)
 		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(transactions)
+	json.NewEncoder(w).Encode(response)
+}
+
+func parseTransactionListFilter(r *http.Request) (models.TransactionListFilter, error) {
+	query := r.URL.Query()
+
+	startDate, err := parseDateParam(query.Get("start_date"), time.Time{}, false)
+	if err != nil {
+		return models.TransactionListFilter{}, fmt.Errorf("invalid start_date format. Use YYYY-MM-DD or RFC3339")
+	}
+	endDate, err := parseDateParam(query.Get("end_date"), time.Time{}, true)
+	if err != nil {
+		return models.TransactionListFilter{}, fmt.Errorf("invalid end_date format. Use YYYY-MM-DD or RFC3339")
+	}
+
+	filter := models.TransactionListFilter{
+		StartDate:   startDate,
+		EndDate:     endDate,
+		WalletIDs:   parseIDsParam(r, "wallet_ids", "account_ids"),
+		JarIDs:      parseIDsParam(r, "jar_ids", "category_ids"),
+		Types:       parseIDsParam(r, "type", "types"),
+		Description: strings.TrimSpace(query.Get("q")),
+		SortBy:      query.Get("sort"),
+		SortOrder:   query.Get("order"),
+		Cursor:      query.Get("cursor"),
+	}
+
+	if raw := query.Get("min_amount"); raw != "" {
+		value, err := models.ParseMoney(raw)
+		if err != nil {
+			return models.TransactionListFilter{}, fmt.Errorf("invalid min_amount")
+		}
+		filter.MinAmount = &value
+	}
+	if raw := query.Get("max_amount"); raw != "" {
+		value, err := models.ParseMoney(raw)
+		if err != nil {
+			return models.TransactionListFilter{}, fmt.Errorf("invalid max_amount")
+		}
+		filter.MaxAmount = &value
+	}
+	if raw := query.Get("limit"); raw != "" {
+		value, err := strconv.Atoi(raw)
+		if err != nil || value <= 0 {
+			return models.TransactionListFilter{}, fmt.Errorf("invalid limit")
+		}
+		filter.Limit = value
+	}
+
+	return filter, nil
 }
 
 func (h *TransactionHandler) CreateTransfer(w http.ResponseWriter, r *http.Request) {
@@ -64,20 +179,153 @@ func (h *TransactionHandler) CreateTransfer(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if req.SourceAmount == 0 {
+		req.SourceAmount = req.Amount
+	}
+
 	// Basic validation
-	if req.FromWalletID == "" || req.ToWalletID == "" || req.Amount <= 0 {
+	if req.FromWalletID == "" || req.ToWalletID == "" || req.SourceAmount <= 0 {
 		http.Error(w, "Invalid input parameters", http.StatusBadRequest)
 		return
 	}
 
-	date, err := time.Parse(time.RFC3339, req.Date)
+	date, err := parseTransactionDate(req.Date)
 	if err != nil {
-		// Try short date
-		date, err = time.Parse("2006-01-02", req.Date)
+		http.Error(w, "Invalid date format", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	transfer, err := h.service.CreateTransferForUser(r.Context(), user.ID, models.TransferRequest{
+		FromWalletID:      req.FromWalletID,
+		ToWalletID:        req.ToWalletID,
+		SourceAmount:      req.SourceAmount,
+		DestinationAmount: req.DestinationAmount,
+		Date:              date,
+		Notes:             req.Notes,
+		FeeAmount:         req.FeeAmount,
+		FeeJarID:          req.FeeJarID,
+	})
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidTransfer) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// GetTransfer handles GET /api/v1/transfers/:id, where id is either leg or
+// the fee of the transfer.
+func (h *TransactionHandler) GetTransfer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := transferIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	transfer, err := h.service.GetTransferForUser(r.Context(), user.ID, id)
+	if err != nil {
+		writeTransferError(w, err)
+		return
+	}
+
+	setETag(w, transferVersion(transfer))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// PatchTransfer handles PATCH /api/v1/transfers/:id. Both legs and the fee
+// are rewritten in one database transaction. It requires If-Match.
+func (h *TransactionHandler) PatchTransfer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := transferIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cond, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req PatchTransferRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	patch := models.TransferPatch{
+		FromWalletID:      req.FromWalletID,
+		ToWalletID:        req.ToWalletID,
+		SourceAmount:      req.SourceAmount,
+		DestinationAmount: req.DestinationAmount,
+		Notes:             req.Notes,
+		FeeAmount:         req.FeeAmount,
+		FeeJarID:          req.FeeJarID,
+	}
+	if req.Date != nil {
+		date, err := parseTransactionDate(*req.Date)
 		if err != nil {
 			http.Error(w, "Invalid date format", http.StatusBadRequest)
 			return
 		}
+		patch.Date = &date
+	}
+
+	current, ok := h.checkTransferIfMatch(w, r, user.ID, id, cond)
+	if !ok {
+		return
+	}
+	transfer, err := h.service.UpdateTransferForUser(r.Context(), user.ID, id, patch, transferVersion(current))
+	if err != nil {
+		h.writeTransferUpdateError(w, r, user.ID, id, err)
+		return
+	}
+
+	setETag(w, transferVersion(transfer))
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transfer)
+}
+
+// DeleteTransfer handles DELETE /api/v1/transfers/:id and removes both legs
+// and the fee. It requires If-Match.
+func (h *TransactionHandler) DeleteTransfer(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
 	}
 
 	user, ok := auth.UserFromContext(r.Context())
@@ -86,15 +334,466 @@ func (h *TransactionHandler) CreateTransfer(w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	expense, income, err := h.service.CreateTransferForUser(user.ID, req.FromWalletID, req.ToWalletID, req.Amount, date, req.Notes)
+	id, err := transferIDFromPath(r.URL.Path)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cond, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+	current, ok := h.checkTransferIfMatch(w, r, user.ID, id, cond)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteTransferForUser(r.Context(), user.ID, id, transferVersion(current)); err != nil {
+		h.writeTransferUpdateError(w, r, user.ID, id, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *TransactionHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req TransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	date, err := parseTransactionDate(req.Date)
+	if err != nil {
+		http.Error(w, "Invalid date format", http.StatusBadRequest)
 		return
 	}
 
+	created, err := h.service.CreateForUser(r.Context(), user.ID, &models.Transaction{
+		Amount:      req.Amount,
+		Description: req.Description,
+		Date:        date,
+		Type:        req.Type,
+		WalletID:    req.WalletID,
+		JarID:       req.JarID,
+		Splits:      req.Splits,
+		Tags:        tagRefs(req.TagIDs),
+	})
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+
+	setETag(w, created.Version)
+	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
-	json.NewEncoder(w).Encode(CreateTransferResponse{
-		ExpenseTransaction: expense,
-		IncomeTransaction:  income,
+	json.NewEncoder(w).Encode(created)
+}
+
+func (h *TransactionHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := transactionIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tx, err := h.service.GetForUser(r.Context(), user.ID, id)
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+
+	setETag(w, tx.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tx)
+}
+
+// Update handles PUT /api/v1/transactions/:id. It requires If-Match.
+func (h *TransactionHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := transactionIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cond, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req TransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	date, err := parseTransactionDate(req.Date)
+	if err != nil {
+		http.Error(w, "Invalid date format", http.StatusBadRequest)
+		return
+	}
+
+	existing, ok := h.checkIfMatch(w, r, user.ID, id, cond)
+	if !ok {
+		return
+	}
+	updated, err := h.service.UpdateForUser(r.Context(), user.ID, &models.Transaction{
+		ID:          id,
+		Version:     existing.Version,
+		Amount:      req.Amount,
+		Description: req.Description,
+		Date:        date,
+		Type:        req.Type,
+		WalletID:    req.WalletID,
+		JarID:       req.JarID,
+		Splits:      req.Splits,
+		Tags:        tagRefs(req.TagIDs),
 	})
+	if err != nil {
+		h.writeUpdateError(w, r, user.ID, id, err)
+		return
+	}
+
+	setETag(w, updated.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// Patch handles PATCH /api/v1/transactions/:id. It requires If-Match.
+func (h *TransactionHandler) Patch(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := transactionIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cond, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req PatchTransactionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	tx, ok := h.checkIfMatch(w, r, user.ID, id, cond)
+	if !ok {
+		return
+	}
+
+	if req.Amount != nil {
+		tx.Amount = *req.Amount
+	}
+	if req.Description != nil {
+		tx.Description = *req.Description
+	}
+	if req.Date != nil {
+		date, err := parseTransactionDate(*req.Date)
+		if err != nil {
+			http.Error(w, "Invalid date format", http.StatusBadRequest)
+			return
+		}
+		tx.Date = date
+	}
+	if req.Type != nil {
+		tx.Type = *req.Type
+	}
+	if req.WalletID != nil {
+		tx.WalletID = *req.WalletID
+	}
+	if req.JarID != nil {
+		tx.JarID = *req.JarID
+		tx.Splits = nil
+	}
+	if req.Splits != nil {
+		tx.Splits = *req.Splits
+		if req.JarID == nil && len(tx.Splits) > 0 {
+			tx.JarID = ""
+		}
+	}
+
+	if req.TagIDs != nil {
+		tx.Tags = tagRefs(*req.TagIDs)
+	}
+
+	updated, err := h.service.UpdateForUser(r.Context(), user.ID, tx)
+	if err != nil {
+		h.writeUpdateError(w, r, user.ID, id, err)
+		return
+	}
+
+	setETag(w, updated.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(updated)
+}
+
+// Delete handles DELETE /api/v1/transactions/:id. It requires If-Match.
+func (h *TransactionHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := transactionIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cond, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+	tx, ok := h.checkIfMatch(w, r, user.ID, id, cond)
+	if !ok {
+		return
+	}
+
+	if err := h.service.DeleteForUser(r.Context(), user.ID, id, tx.Version); err != nil {
+		h.writeUpdateError(w, r, user.ID, id, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RefundRequest is the body of PUT /api/v1/transactions/:id/refund.
+type RefundRequest struct {
+	ExpenseID string `json:"expense_id"`
+}
+
+// Refund handles /api/v1/transactions/:id/refund. PUT links the income to
+// the expense it refunds; DELETE turns it back into a plain income. Both
+// require If-Match.
+func (h *TransactionHandler) Refund(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodDelete {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	id, err := transactionIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	cond, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var tx *models.Transaction
+	if r.Method == http.MethodDelete {
+		current, ok := h.checkIfMatch(w, r, user.ID, id, cond)
+		if !ok {
+			return
+		}
+		tx, err = h.service.UnmarkRefundForUser(r.Context(), user.ID, id, current.Version)
+	} else {
+		var req RefundRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body", http.StatusBadRequest)
+			return
+		}
+		if req.ExpenseID == "" {
+			http.Error(w, "expense_id is required", http.StatusBadRequest)
+			return
+		}
+		current, ok := h.checkIfMatch(w, r, user.ID, id, cond)
+		if !ok {
+			return
+		}
+		tx, err = h.service.MarkRefundForUser(r.Context(), user.ID, id, req.ExpenseID, current.Version)
+	}
+	if err != nil {
+		h.writeUpdateError(w, r, user.ID, id, err)
+		return
+	}
+
+	setETag(w, tx.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tx)
+}
+
+// checkIfMatch loads the transaction and answers 404 or 412 unless it
+// satisfies cond.
+func (h *TransactionHandler) checkIfMatch(w http.ResponseWriter, r *http.Request, userID, id string, cond ifMatch) (*models.Transaction, bool) {
+	tx, err := h.service.GetForUser(r.Context(), userID, id)
+	if err != nil {
+		writeTransactionError(w, err)
+		return nil, false
+	}
+	if !cond.matches(tx.Version) {
+		writePreconditionFailed(w, tx, tx.Version)
+		return nil, false
+	}
+	return tx, true
+}
+
+// writeUpdateError reports a failed update. A transaction changed by another
+// request since it was checked is answered like a failed If-Match.
+func (h *TransactionHandler) writeUpdateError(w http.ResponseWriter, r *http.Request, userID, id string, err error) {
+	if !errors.Is(err, repository.ErrVersionMismatch) {
+		writeTransactionError(w, err)
+		return
+	}
+	current, err := h.service.GetForUser(r.Context(), userID, id)
+	if err != nil {
+		writeTransactionError(w, err)
+		return
+	}
+	writePreconditionFailed(w, current, current.Version)
+}
+
+// checkTransferIfMatch loads the transfer and answers 404 or 412 unless it
+// satisfies cond.
+func (h *TransactionHandler) checkTransferIfMatch(w http.ResponseWriter, r *http.Request, userID, id string, cond ifMatch) (*models.Transfer, bool) {
+	transfer, err := h.service.GetTransferForUser(r.Context(), userID, id)
+	if err != nil {
+		writeTransferError(w, err)
+		return nil, false
+	}
+	if !cond.matches(transferVersion(transfer)) {
+		writePreconditionFailed(w, transfer, transferVersion(transfer))
+		return nil, false
+	}
+	return transfer, true
+}
+
+// writeTransferUpdateError reports a failed transfer edit. A transfer changed
+// by another request since it was checked is answered like a failed If-Match.
+func (h *TransactionHandler) writeTransferUpdateError(w http.ResponseWriter, r *http.Request, userID, id string, err error) {
+	if !errors.Is(err, repository.ErrVersionMismatch) {
+		writeTransferError(w, err)
+		return
+	}
+	current, err := h.service.GetTransferForUser(r.Context(), userID, id)
+	if err != nil {
+		writeTransferError(w, err)
+		return
+	}
+	writePreconditionFailed(w, current, transferVersion(current))
+}
+
+// transferVersion is the version of the outgoing leg. Transfers are only
+// edited as a whole, and every edit rewrites that leg.
+func transferVersion(transfer *models.Transfer) int64 {
+	return transfer.ExpenseTransaction.Version
+}
+
+func writeTransactionError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrTransactionNotFound):
+		http.Error(w, "Transaction not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidTransaction):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	case errors.Is(err, service.ErrTransactionLinked):
+		http.Error(w, "Transaction is part of a transfer; use /api/v1/transfers/{id} instead", http.StatusConflict)
+	default:
+		http.Error(w, "Failed to process transaction", http.StatusInternalServerError)
+	}
+}
+
+func writeTransferError(w http.ResponseWriter, err error) {
+	switch {
+	case errors.Is(err, service.ErrTransferNotFound):
+		http.Error(w, "Transfer not found", http.StatusNotFound)
+	case errors.Is(err, service.ErrInvalidTransfer):
+		http.Error(w, err.Error(), http.StatusBadRequest)
+	default:
+		http.Error(w, "Failed to process transfer", http.StatusInternalServerError)
+	}
+}
+
+func transferIDFromPath(path string) (string, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 4 || parts[3] == "" {
+		return "", errors.New("invalid transfer path")
+	}
+	return parts[3], nil
+}
+
+// tagRefs turns tag IDs from a request into tags for the service to resolve.
+func tagRefs(ids []string) []models.Tag {
+	tags := make([]models.Tag, 0, len(ids))
+	for _, id := range ids {
+		tags = append(tags, models.Tag{ID: id})
+	}
+	return tags
+}
+
+func transactionIDFromPath(path string) (string, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) < 4 || parts[3] == "" {
+		return "", errors.New("invalid transaction path")
+	}
+	return parts[3], nil
+}
+
+func parseTransactionDate(value string) (time.Time, error) {
+	date, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		// Try short date
+		return time.Parse("2006-01-02", value)
+	}
+	return date, nil
 }
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"jarwise-backend/internal/auth"
+	"jarwise-backend/internal/service"
+	"net/http"
+	"strings"
+)
+
+type TrashHandler struct {
+	service service.TrashService
+}
+
+func NewTrashHandler(service service.TrashService) *TrashHandler {
+	return &TrashHandler{service: service}
+}
+
+// List handles GET /api/v1/trash
+func (h *TrashHandler) List(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	deletions, err := h.service.ListForUser(r.Context(), user.ID)
+	if err != nil {
+		http.Error(w, "Failed to load trash", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(deletions)
+}
+
+// Restore handles POST /api/v1/trash/:id/restore
+func (h *TrashHandler) Restore(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, err := deletionIDFromPath(r.URL.Path)
+	if err != nil {
+		http.Error(w, "Invalid deletion ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.service.RestoreForUser(r.Context(), user.ID, id); err != nil {
+		switch {
+		case errors.Is(err, service.ErrDeletionNotFound):
+			http.Error(w, "Deletion not found", http.StatusNotFound)
+		case errors.Is(err, service.ErrDeletionExpired):
+			http.Error(w, err.Error(), http.StatusGone)
+		default:
+			http.Error(w, "Failed to restore deletion", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func deletionIDFromPath(path string) (string, error) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 5 || parts[3] == "" || parts[4] != "restore" {
+		return "", errors.New("invalid deletion path")
+	}
+	return parts[3], nil
+}
@@ -1,13 +1,37 @@
 package handlers
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"jarwise-backend/internal/auth"
+	"jarwise-backend/internal/models"
 	"jarwise-backend/internal/repository"
+	"jarwise-backend/internal/service"
 	"net/http"
 	"strings"
+	"time"
+
+	"github.com/google/uuid"
 )
 
+// WalletRequest is the body accepted by POST /api/v1/wallets.
+type WalletRequest struct {
+	Name           string       `json:"name"`
+	Currency       string       `json:"currency"`
+	Type           string       `json:"type"`
+	OpeningBalance models.Money `json:"opening_balance"`
+}
+
+// PatchWalletRequest carries the fields a PATCH may change; nil fields are left as-is.
+type PatchWalletRequest struct {
+	Name           *string       `json:"name"`
+	Currency       *string       `json:"currency"`
+	Type           *string       `json:"type"`
+	OpeningBalance *models.Money `json:"opening_balance"`
+	Archived       *bool         `json:"archived"`
+}
+
 type WalletHandler struct {
 	repo repository.WalletRepository
 }
@@ -16,6 +40,9 @@ func NewWalletHandler(repo repository.WalletRepository) *WalletHandler {
 	return &WalletHandler{repo: repo}
 }
 
+// HandleDelete handles DELETE /api/v1/wallets/:id. It requires If-Match.
+// With cascade=true it responds with the deletion that can be restored
+// from the trash.
 func (h *WalletHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodDelete {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -35,26 +62,55 @@ func (h *WalletHandler) HandleDelete(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	cond, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+	wallet, ok := h.checkIfMatch(w, user.ID, id, cond)
+	if !ok {
+		return
+	}
+
 	cascade := r.URL.Query().Get("cascade") == "true"
 	replacementID := r.URL.Query().Get("replacement_id")
 
 	if cascade {
-		// Cascade delete
-		err := h.repo.DeleteCascadeForUser(user.ID, id)
+		// Cascade delete moves the wallet and everything in it to the trash,
+		// from where it can be restored for a while.
+		deletion := &models.Deletion{ID: uuid.New().String(), DeletedAt: time.Now().UTC()}
+		err := h.repo.DeleteCascadeForUser(r.Context(), user.ID, id, wallet.Version, deletion)
+		if h.writeDeleteRace(w, user.ID, id, err) {
+			return
+		}
 		if err != nil {
 			http.Error(w, "Failed to cascade delete wallet: "+err.Error(), http.StatusInternalServerError)
 			return
 		}
+		deletion.RestorableUntil = deletion.DeletedAt.Add(service.TrashRetention)
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(deletion)
+		return
 	} else if replacementID == "" {
 		// Attempt direct delete
-		err := h.repo.DeleteForUser(user.ID, id)
+		err := h.repo.DeleteForUser(r.Context(), user.ID, id, wallet.Version)
+		if h.writeDeleteRace(w, user.ID, id, err) {
+			return
+		}
 		if err != nil {
 			http.Error(w, "Failed to delete wallet (it might have transactions): "+err.Error(), http.StatusConflict)
 			return
 		}
 	} else {
 		// Delete with replacement
-		err := h.repo.DeleteWithReplacementForUser(user.ID, id, replacementID)
+		err := h.repo.DeleteWithReplacementForUser(r.Context(), user.ID, id, replacementID, wallet.Version)
+		if h.writeDeleteRace(w, user.ID, id, err) {
+			return
+		}
+		if errors.Is(err, repository.ErrInvalidReplacementWallet) {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
 		if err != nil {
 			http.Error(w, "Failed to delete with replacement: "+err.Error(), http.StatusInternalServerError)
 			return
@@ -82,6 +138,264 @@ func (h *WalletHandler) List(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Archived wallets are hidden from pickers unless explicitly requested.
+	if r.URL.Query().Get("include_archived") != "true" {
+		active := make([]models.Wallet, 0, len(wallets))
+		for _, wallet := range wallets {
+			if !wallet.Archived {
+				active = append(active, wallet)
+			}
+		}
+		wallets = active
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(wallets)
 }
+
+// Create handles POST /api/v1/wallets
+func (h *WalletHandler) Create(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	var req WalletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	wallet := &models.Wallet{
+		ID:             uuid.New().String(),
+		UserID:         user.ID,
+		Name:           req.Name,
+		Currency:       req.Currency,
+		Type:           req.Type,
+		OpeningBalance: req.OpeningBalance,
+	}
+	if msg := service.NormalizeWallet(wallet); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.Create(r.Context(), wallet); err != nil {
+		http.Error(w, "Failed to create wallet", http.StatusInternalServerError)
+		return
+	}
+
+	setETag(w, wallet.Version)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(wallet)
+}
+
+// Get handles GET /api/v1/wallets/:id
+func (h *WalletHandler) Get(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := walletIDFromPath(r.URL.Path)
+	if !ok {
+		http.Error(w, "Invalid wallet ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	wallet, err := h.repo.GetForUser(user.ID, id)
+	if err != nil {
+		http.Error(w, "Failed to load wallet", http.StatusInternalServerError)
+		return
+	}
+	if wallet == nil {
+		http.Error(w, "Wallet not found", http.StatusNotFound)
+		return
+	}
+
+	setETag(w, wallet.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wallet)
+}
+
+// Update handles PATCH /api/v1/wallets/:id. Setting "archived" to true hides
+// the wallet from pickers while keeping its transactions in reports. It
+// requires If-Match.
+func (h *WalletHandler) Update(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPatch {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	id, ok := walletIDFromPath(r.URL.Path)
+	if !ok {
+		http.Error(w, "Invalid wallet ID", http.StatusBadRequest)
+		return
+	}
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	cond, ok := requireIfMatch(w, r)
+	if !ok {
+		return
+	}
+
+	var req PatchWalletRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	wallet, ok := h.checkIfMatch(w, user.ID, id, cond)
+	if !ok {
+		return
+	}
+
+	if req.Name != nil {
+		wallet.Name = *req.Name
+	}
+	if req.Currency != nil {
+		wallet.Currency = *req.Currency
+	}
+	if req.Type != nil {
+		wallet.Type = *req.Type
+	}
+	if req.OpeningBalance != nil {
+		wallet.OpeningBalance = *req.OpeningBalance
+	}
+	if req.Archived != nil && *req.Archived != wallet.Archived {
+		wallet.Archived = *req.Archived
+		wallet.ArchivedAt = nil
+	}
+	if msg := service.NormalizeWallet(wallet); msg != "" {
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	if err := h.repo.Update(r.Context(), wallet); err != nil {
+		switch {
+		case errors.Is(err, repository.ErrVersionMismatch):
+			h.writeChanged(w, user.ID, id)
+		case errors.Is(err, repository.ErrWalletCurrencyLocked):
+			http.Error(w, err.Error(), http.StatusConflict)
+		case errors.Is(err, sql.ErrNoRows):
+			http.Error(w, "Wallet not found", http.StatusNotFound)
+		default:
+			http.Error(w, "Failed to update wallet", http.StatusInternalServerError)
+		}
+		return
+	}
+
+	setETag(w, wallet.Version)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wallet)
+}
+
+// checkIfMatch loads the wallet and answers 404 or 412 unless it satisfies
+// cond.
+func (h *WalletHandler) checkIfMatch(w http.ResponseWriter, userID, id string, cond ifMatch) (*models.Wallet, bool) {
+	wallet, err := h.repo.GetForUser(userID, id)
+	if err != nil {
+		http.Error(w, "Failed to load wallet", http.StatusInternalServerError)
+		return nil, false
+	}
+	if wallet == nil {
+		http.Error(w, "Wallet not found", http.StatusNotFound)
+		return nil, false
+	}
+	if !cond.matches(wallet.Version) {
+		writePreconditionFailed(w, wallet, wallet.Version)
+		return nil, false
+	}
+	return wallet, true
+}
+
+// writeChanged answers like a failed If-Match for a wallet changed by another
+// request since it was checked.
+func (h *WalletHandler) writeChanged(w http.ResponseWriter, userID, id string) {
+	current, err := h.repo.GetForUser(userID, id)
+	if err != nil || current == nil {
+		http.Error(w, "Failed to load wallet", http.StatusInternalServerError)
+		return
+	}
+	writePreconditionFailed(w, current, current.Version)
+}
+
+// writeDeleteRace reports a delete that lost to another request: the wallet
+// was changed or deleted since it was checked. It returns false for any
+// other outcome.
+func (h *WalletHandler) writeDeleteRace(w http.ResponseWriter, userID, id string, err error) bool {
+	switch {
+	case errors.Is(err, repository.ErrVersionMismatch):
+		h.writeChanged(w, userID, id)
+	case errors.Is(err, sql.ErrNoRows):
+		http.Error(w, "Wallet not found", http.StatusNotFound)
+	default:
+		return false
+	}
+	return true
+}
+
+func walletIDFromPath(path string) (string, bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) != 4 || parts[3] == "" {
+		return "", false
+	}
+	return parts[3], true
+}
+
+// GetBalance handles GET /api/v1/wallets/:id/balance?as_of=YYYY-MM-DD
+func (h *WalletHandler) GetBalance(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	pathParts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(pathParts) < 5 || pathParts[3] == "" {
+		http.Error(w, "Invalid wallet ID", http.StatusBadRequest)
+		return
+	}
+	id := pathParts[3]
+
+	user, ok := auth.UserFromContext(r.Context())
+	if !ok {
+		http.Error(w, "authentication required", http.StatusUnauthorized)
+		return
+	}
+
+	asOf, err := parseDateParam(r.URL.Query().Get("as_of"), time.Now().UTC(), true)
+	if err != nil {
+		http.Error(w, "Invalid as_of format. Use YYYY-MM-DD or RFC3339.", http.StatusBadRequest)
+		return
+	}
+
+	balance, err := h.repo.BalanceAsOfForUser(user.ID, id, asOf)
+	if err != nil {
+		http.Error(w, "Failed to calculate wallet balance", http.StatusInternalServerError)
+		return
+	}
+	if balance == nil {
+		http.Error(w, "Wallet not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(balance)
+}
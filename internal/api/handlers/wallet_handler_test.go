@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWalletHandler_RequiresIfMatch(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to initialize database: %v", err)
+	}
+	defer dbConn.Close()
+
+	repo := repository.NewSQLiteWalletRepository(dbConn)
+	handler := NewWalletHandler(repo)
+	if err := repo.Create(context.Background(), &models.Wallet{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB", Type: "cash"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+
+	send := func(method, ifMatch, body string, serve http.HandlerFunc) *httptest.ResponseRecorder {
+		req := withAuthenticatedUser(httptest.NewRequest(method, "/api/v1/wallets/wallet-1", strings.NewReader(body)), "user-1")
+		if ifMatch != "" {
+			req.Header.Set("If-Match", ifMatch)
+		}
+		recorder := httptest.NewRecorder()
+		serve(recorder, req)
+		return recorder
+	}
+
+	get := send(http.MethodGet, "", "", handler.Get)
+	if get.Code != http.StatusOK || get.Header().Get("ETag") != `"1"` {
+		t.Fatalf("expected 200 with ETag \"1\", got %d %q", get.Code, get.Header().Get("ETag"))
+	}
+
+	if rec := send(http.MethodPatch, "", `{"name":"Pocket"}`, handler.Update); rec.Code != http.StatusPreconditionRequired {
+		t.Errorf("expected 428 without If-Match, got %d", rec.Code)
+	}
+
+	patched := send(http.MethodPatch, `"1"`, `{"name":"Pocket"}`, handler.Update)
+	if patched.Code != http.StatusOK || patched.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected 200 with ETag \"2\", got %d %q: %s", patched.Code, patched.Header().Get("ETag"), patched.Body.String())
+	}
+
+	// The other device still holds version 1.
+	stale := send(http.MethodPatch, `"1"`, `{"name":"Wallet"}`, handler.Update)
+	if stale.Code != http.StatusPreconditionFailed || stale.Header().Get("ETag") != `"2"` {
+		t.Fatalf("expected 412 with ETag \"2\", got %d %q", stale.Code, stale.Header().Get("ETag"))
+	}
+	var current models.Wallet
+	if err := json.Unmarshal(stale.Body.Bytes(), &current); err != nil || current.Name != "Pocket" || current.Version != 2 {
+		t.Errorf("expected the current wallet in the 412 body, got %s", stale.Body.String())
+	}
+
+	if rec := send(http.MethodDelete, `W/"2"`, "", handler.HandleDelete); rec.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected a weak ETag not to match, got %d", rec.Code)
+	}
+	if rec := send(http.MethodDelete, `"1", "2"`, "", handler.HandleDelete); rec.Code != http.StatusNoContent {
+		t.Errorf("expected 204 when one of the ETags matches, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := send(http.MethodDelete, "*", "", handler.HandleDelete); rec.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a deleted wallet, got %d", rec.Code)
+	}
+}
@@ -0,0 +1,150 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"jarwise-backend/internal/auth"
+	"jarwise-backend/internal/service"
+	"log"
+	"net/http"
+)
+
+const (
+	IdempotencyKeyHeader     = "Idempotency-Key"
+	IdempotentReplayedHeader = "Idempotent-Replayed"
+	// maxIdempotentRequestBytes covers the largest upload, a Money Manager
+	// backup of up to 60 MB.
+	maxIdempotentRequestBytes = 64 << 20
+)
+
+// IdempotencyMiddleware makes writes sent with an Idempotency-Key safe to
+// retry. The first request runs and its response is stored; a retry with the
+// same key and the same request gets that response again. It must run after
+// authentication, since keys are scoped to the user.
+func IdempotencyMiddleware(idempotency service.IdempotencyService, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(IdempotencyKeyHeader)
+		user, ok := auth.UserFromContext(r.Context())
+		if key == "" || !ok || !isWriteMethod(r.Method) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxIdempotentRequestBytes+1))
+		if err != nil {
+			http.Error(w, "Failed to read request body", http.StatusBadRequest)
+			return
+		}
+		if len(body) > maxIdempotentRequestBytes {
+			http.Error(w, "Request body is too large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		requestHash := hashRequest(r, body)
+
+		stored, claim, err := idempotency.Begin(r.Context(), user.ID, key, requestHash)
+		if err != nil {
+			switch {
+			case errors.Is(err, service.ErrInvalidIdempotencyKey):
+				http.Error(w, err.Error(), http.StatusBadRequest)
+			case errors.Is(err, service.ErrIdempotencyKeyInUse):
+				http.Error(w, err.Error(), http.StatusConflict)
+			case errors.Is(err, service.ErrIdempotencyKeyReused):
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			default:
+				http.Error(w, "Failed to check Idempotency-Key", http.StatusInternalServerError)
+			}
+			return
+		}
+		if stored != nil {
+			for header, value := range map[string]string{
+				"Content-Type": stored.ContentType,
+				"ETag":         stored.ETag,
+				"Location":     stored.Location,
+			} {
+				if value != "" {
+					w.Header().Set(header, value)
+				}
+			}
+			w.Header().Set(IdempotentReplayedHeader, "true")
+			w.WriteHeader(stored.StatusCode)
+			w.Write(stored.Body)
+			return
+		}
+
+		// The key is settled even when the client has gone away.
+		ctx := context.WithoutCancel(r.Context())
+		release := func() {
+			if err := idempotency.Release(ctx, claim); err != nil {
+				log.Printf("idempotency: %v", err)
+			}
+		}
+		// A handler that panics has no response to keep, so the key is freed
+		// for a retry before the panic goes on.
+		defer func() {
+			if p := recover(); p != nil {
+				release()
+				panic(p)
+			}
+		}()
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+		recorder := &responseRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+
+		// Server errors are not kept, so the client can retry them.
+		if recorder.statusCode >= http.StatusInternalServerError {
+			release()
+			return
+		}
+		claim.StatusCode = recorder.statusCode
+		claim.ContentType = recorder.Header().Get("Content-Type")
+		claim.ETag = recorder.Header().Get("ETag")
+		claim.Location = recorder.Header().Get("Location")
+		claim.Body = recorder.body.Bytes()
+		if err := idempotency.Complete(ctx, claim); err != nil {
+			log.Printf("idempotency: %v", err)
+		}
+	})
+}
+
+func isWriteMethod(method string) bool {
+	switch method {
+	case http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// hashRequest identifies a request by its method, URL and body.
+func hashRequest(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	io.WriteString(hash, r.Method+" "+r.URL.RequestURI()+"\n")
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(statusCode int) {
+	if !r.wroteHeader {
+		r.statusCode = statusCode
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (r *responseRecorder) Write(data []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(data)
+	return r.ResponseWriter.Write(data)
+}
@@ -0,0 +1,98 @@
+package api
+
+import (
+	"io"
+	"jarwise-backend/internal/auth"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"jarwise-backend/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestIdempotencyMiddleware(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	calls, panics := 0, 1
+	handler := IdempotencyMiddleware(
+		service.NewIdempotencyService(repository.NewSQLiteIdempotencyRepository(dbConn)),
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			body, _ := io.ReadAll(r.Body)
+			if strings.Contains(string(body), "fail") {
+				http.Error(w, "boom", http.StatusInternalServerError)
+				return
+			}
+			if strings.Contains(string(body), "panic") && panics > 0 {
+				panics--
+				panic("boom")
+			}
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("ETag", `"`+strconv.Itoa(calls)+`"`)
+			w.Header().Set("Location", "/api/v1/transfers/"+strconv.Itoa(calls))
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte(`{"call":` + strconv.Itoa(calls) + `}`))
+		}),
+	)
+	send := func(method, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/api/v1/transfers", strings.NewReader(body))
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		req = req.WithContext(auth.ContextWithUser(req.Context(), &models.User{ID: "user-1"}))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		return rr
+	}
+
+	first := send(http.MethodPost, "transfer-1", `{"amount":100}`)
+	if first.Code != http.StatusCreated || first.Body.String() != `{"call":1}` {
+		t.Fatalf("expected the first request to run, got %d %s", first.Code, first.Body.String())
+	}
+	replay := send(http.MethodPost, "transfer-1", `{"amount":100}`)
+	if replay.Code != http.StatusCreated || replay.Body.String() != `{"call":1}` || calls != 1 {
+		t.Fatalf("expected the stored response without a second call, got %d %s after %d calls", replay.Code, replay.Body.String(), calls)
+	}
+	if replay.Header().Get(IdempotentReplayedHeader) != "true" || replay.Header().Get("Content-Type") != "application/json" ||
+		replay.Header().Get("ETag") != `"1"` || replay.Header().Get("Location") != "/api/v1/transfers/1" {
+		t.Errorf("expected replay headers, got %v", replay.Header())
+	}
+	if rr := send(http.MethodPost, "transfer-1", `{"amount":200}`); rr.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected a changed body to get 422, got %d", rr.Code)
+	}
+
+	// Server errors are not stored, so a retry runs again.
+	send(http.MethodPost, "transfer-2", `{"fail":true}`)
+	send(http.MethodPost, "transfer-2", `{"fail":true}`)
+	if calls != 3 {
+		t.Errorf("expected failed requests to be retried, got %d calls", calls)
+	}
+
+	// A handler that panics frees its key, so the retry runs.
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("expected the panic to reach the server")
+			}
+		}()
+		send(http.MethodPost, "transfer-3", `{"panic":true}`)
+	}()
+	if rr := send(http.MethodPost, "transfer-3", `{"panic":true}`); rr.Code != http.StatusCreated || calls != 5 {
+		t.Errorf("expected the retry after a panic to run, got %d after %d calls", rr.Code, calls)
+	}
+
+	// Reads and requests without a key pass straight through.
+	send(http.MethodGet, "transfer-1", "")
+	send(http.MethodPost, "", `{"amount":100}`)
+	if calls != 7 {
+		t.Errorf("expected reads and keyless writes to run, got %d calls", calls)
+	}
+}
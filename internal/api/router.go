@@ -1,6 +1,7 @@
 package api
 
 import (
+	"context"
 	"database/sql"
 	"jarwise-backend/internal/api/handlers"
 	"jarwise-backend/internal/auth"
@@ -10,6 +11,8 @@ import (
 	"net/http"
 	"os"
 	"strings"
+	"sync"
+	"time"
 )
 
 var allowedOriginSet = map[string]struct{}{
@@ -22,17 +25,89 @@ type RouterOptions struct {
 	GoogleClientID string
 	SecureCookies  bool
 	Verifier       auth.GoogleTokenVerifier
+
+	// RecurringInterval is how often due recurring transactions are recorded
+	// in the background. Zero disables the background run.
+	RecurringInterval time.Duration
+
+	// AttachmentStorage keeps uploaded attachments. When nil they are stored
+	// as files below AttachmentDir (default "attachments").
+	AttachmentStorage service.AttachmentStorage
+	AttachmentDir     string
+	// AttachmentCleanupInterval is how often attachments of transactions
+	// deleted with their wallet or jar are removed. Zero disables the sweep.
+	AttachmentCleanupInterval time.Duration
+
+	// IdempotencyCleanupInterval is how often expired Idempotency-Key
+	// responses are removed. Zero disables the sweep.
+	IdempotencyCleanupInterval time.Duration
+
+	// TrashPurgeInterval is how often deleted wallets past the restore
+	// window are purged. Zero disables the purge.
+	TrashPurgeInterval time.Duration
 }
 
-func NewRouter() http.Handler {
-	return NewRouterWithOptions(RouterOptions{
+const (
+	defaultRecurringInterval          = 15 * time.Minute
+	defaultAttachmentCleanupInterval  = time.Hour
+	defaultIdempotencyCleanupInterval = time.Hour
+	defaultTrashPurgeInterval         = time.Hour
+)
+
+// App is the API handler together with the jobs that keep its data tidy in
+// the background. Building it starts nothing; the jobs only run under
+// RunJobs.
+type App struct {
+	Handler http.Handler
+
+	jobs []func(ctx context.Context)
+}
+
+// RunJobs runs the background jobs until ctx is cancelled and returns once
+// all of them have stopped.
+func (a *App) RunJobs(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, job := range a.jobs {
+		wg.Add(1)
+		go func(job func(ctx context.Context)) {
+			defer wg.Done()
+			job(ctx)
+		}(job)
+	}
+	wg.Wait()
+}
+
+// DefaultRouterOptions reads the configuration from the environment and
+// schedules every background job at its default interval.
+func DefaultRouterOptions() RouterOptions {
+	return RouterOptions{
 		GoogleClientID: os.Getenv("JARWISE_GOOGLE_CLIENT_ID"),
 		SecureCookies:  strings.EqualFold(os.Getenv("JARWISE_SECURE_COOKIES"), "true"),
-	})
+
+		RecurringInterval: defaultRecurringInterval,
+
+		AttachmentDir:             os.Getenv("JARWISE_ATTACHMENT_DIR"),
+		AttachmentCleanupInterval: defaultAttachmentCleanupInterval,
+
+		IdempotencyCleanupInterval: defaultIdempotencyCleanupInterval,
+
+		TrashPurgeInterval: defaultTrashPurgeInterval,
+	}
+}
+
+func NewRouter() http.Handler {
+	return NewRouterWithOptions(DefaultRouterOptions())
 }
 
+// NewRouterWithOptions builds the API handler alone; its background jobs are
+// left to NewApp.
 func NewRouterWithOptions(options RouterOptions) http.Handler {
+	return NewApp(options).Handler
+}
+
+func NewApp(options RouterOptions) *App {
 	mux := http.NewServeMux()
+	app := &App{}
 
 	dbConn := options.DB
 	if dbConn == nil {
@@ -50,28 +125,74 @@ func NewRouterWithOptions(options RouterOptions) http.Handler {
 	authService := auth.NewService(dbConn, googleVerifier, options.GoogleClientID, options.SecureCookies)
 	authHandler := handlers.NewAuthHandler(authService)
 
-	migrationSvc := service.NewMigrationService(dbConn)
+	auditService := service.NewAuditService(repository.NewSQLiteAuditRepository(dbConn))
+	auditHandler := handlers.NewAuditHandler(auditService)
+
+	migrationSvc := service.NewMigrationService(dbConn, auditService)
 	migrationHandler := handlers.NewMigrationHandler(migrationSvc)
 
 	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
 	walletHandler := handlers.NewWalletHandler(walletRepo)
+	trashService := service.NewTrashService(repository.NewSQLiteTrashRepository(dbConn), auditService)
+	trashHandler := handlers.NewTrashHandler(trashService)
+	if options.TrashPurgeInterval > 0 {
+		app.schedule(trashService.Run, options.TrashPurgeInterval)
+	}
 
 	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
-	txService := service.NewTransactionService(txRepo, walletRepo)
-	txHandler := handlers.NewTransactionHandler(txService)
 	jarRepo := repository.NewSQLiteJarRepository(dbConn)
-	jarHandler := handlers.NewJarHandler(jarRepo)
-	reportService := service.NewReportService(txRepo, jarRepo, walletRepo)
+	tagRepo := repository.NewSQLiteTagRepository(dbConn)
+	attachmentStorage := options.AttachmentStorage
+	if attachmentStorage == nil {
+		attachmentDir := options.AttachmentDir
+		if attachmentDir == "" {
+			attachmentDir = "attachments"
+		}
+		attachmentStorage = service.NewLocalAttachmentStorage(attachmentDir)
+	}
+	attachmentService := service.NewAttachmentService(repository.NewSQLiteAttachmentRepository(dbConn), txRepo, attachmentStorage)
+	attachmentHandler := handlers.NewAttachmentHandler(attachmentService)
+	if options.AttachmentCleanupInterval > 0 {
+		app.schedule(attachmentService.Run, options.AttachmentCleanupInterval)
+	}
+	txService := service.NewTransactionService(txRepo, walletRepo, jarRepo, tagRepo, attachmentService)
+	txHandler := handlers.NewTransactionHandler(txService)
+	tagHandler := handlers.NewTagHandler(service.NewTagService(tagRepo))
+	searchHandler := handlers.NewSearchHandler(service.NewSearchService(repository.NewSQLiteSearchRepository(dbConn)))
+	jarService := service.NewJarService(jarRepo, walletRepo)
+	jarHandler := handlers.NewJarHandler(jarService)
+	syncHandler := handlers.NewSyncHandler(service.NewSyncService(repository.NewSQLiteSyncRepository(dbConn), walletRepo, jarRepo, txRepo, jarService, txService))
+	exchangeRateRepo := repository.NewSQLiteExchangeRateRepository(dbConn)
+	budgetService := service.NewBudgetService(repository.NewSQLiteBudgetRepository(dbConn), jarRepo, exchangeRateRepo)
+	budgetHandler := handlers.NewBudgetHandler(budgetService)
+	allocationService := service.NewAllocationService(repository.NewSQLiteAllocationRepository(dbConn), jarRepo)
+	allocationHandler := handlers.NewAllocationHandler(allocationService)
+	recurringService := service.NewRecurringService(repository.NewSQLiteRecurringRepository(dbConn), walletRepo, jarRepo, auditService)
+	recurringHandler := handlers.NewRecurringHandler(recurringService)
+	if options.RecurringInterval > 0 {
+		app.schedule(recurringService.Run, options.RecurringInterval)
+	}
+	currencyService := service.NewCurrencyService(exchangeRateRepo)
+	currencyHandler := handlers.NewCurrencyHandler(currencyService)
+
+	reportService := service.NewReportService(txRepo, jarRepo, walletRepo, exchangeRateRepo)
 	reportHandler := handlers.NewReportHandler(reportService)
 
-	graphService := service.NewGraphService(txRepo)
+	graphService := service.NewGraphService(txRepo, exchangeRateRepo)
 	graphHandler := handlers.NewGraphHandler(graphService)
 
-	chartService := service.NewChartService(txRepo)
+	chartService := service.NewChartService(txRepo, exchangeRateRepo)
 	chartHandler := handlers.NewChartHandler(chartService)
 
+	idempotencyService := service.NewIdempotencyService(repository.NewSQLiteIdempotencyRepository(dbConn))
+	if options.IdempotencyCleanupInterval > 0 {
+		app.schedule(idempotencyService.Run, options.IdempotencyCleanupInterval)
+	}
+
+	// Every authenticated write honours an Idempotency-Key header and is
+	// credited to its session in the audit log.
 	requireAuth := func(next http.HandlerFunc) http.Handler {
-		return auth.RequireAuth(authService, http.HandlerFunc(next))
+		return auth.RequireAuth(authService, IdempotencyMiddleware(idempotencyService, AuditMiddleware(auditService, http.HandlerFunc(next))))
 	}
 
 	mux.HandleFunc("/api/v1/auth/google", authHandler.SignInWithGoogle)
@@ -87,22 +208,186 @@ func NewRouterWithOptions(options RouterOptions) http.Handler {
 		migrationHandler.GetJob(w, r)
 	}))
 
-	mux.Handle("/api/v1/transactions", requireAuth(txHandler.List))
+	mux.Handle("/api/v1/transactions", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			txHandler.Create(w, r)
+			return
+		}
+		txHandler.List(w, r)
+	}))
+	mux.Handle("/api/v1/transactions/", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/refund") {
+			txHandler.Refund(w, r)
+			return
+		}
+		if strings.HasSuffix(r.URL.Path, "/attachments") {
+			attachmentHandler.TransactionAttachments(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodPut:
+			txHandler.Update(w, r)
+		case http.MethodPatch:
+			txHandler.Patch(w, r)
+		case http.MethodDelete:
+			txHandler.Delete(w, r)
+		default:
+			txHandler.Get(w, r)
+		}
+	}))
+	mux.Handle("/api/v1/attachments/", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			attachmentHandler.Delete(w, r)
+			return
+		}
+		attachmentHandler.Download(w, r)
+	}))
 	mux.Handle("/api/v1/transfers", requireAuth(txHandler.CreateTransfer))
-	mux.Handle("/api/v1/wallets", requireAuth(walletHandler.List))
+	mux.Handle("/api/v1/transfers/", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPatch:
+			txHandler.PatchTransfer(w, r)
+		case http.MethodDelete:
+			txHandler.DeleteTransfer(w, r)
+		default:
+			txHandler.GetTransfer(w, r)
+		}
+	}))
+	mux.Handle("/api/v1/wallets", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			walletHandler.Create(w, r)
+			return
+		}
+		walletHandler.List(w, r)
+	}))
 	mux.Handle("/api/v1/reports", requireAuth(reportHandler.GetReport))
 	mux.Handle("/api/v1/reports/export", requireAuth(reportHandler.ExportReport))
 	mux.Handle("/api/v1/graph/expenses", requireAuth(graphHandler.GetExpenseGraphData))
 	mux.Handle("/api/v1/charts", requireAuth(chartHandler.GetChartData))
-	mux.Handle("/api/v1/jars", requireAuth(jarHandler.List))
-	mux.Handle("/api/v1/wallets/", requireAuth(walletHandler.HandleDelete))
+	mux.Handle("/api/v1/currency/base", requireAuth(currencyHandler.BaseCurrency))
+	mux.Handle("/api/v1/exchange-rates", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			currencyHandler.CreateRate(w, r)
+			return
+		}
+		currencyHandler.ListRates(w, r)
+	}))
+	mux.Handle("/api/v1/exchange-rates/import", requireAuth(currencyHandler.ImportRates))
+	mux.Handle("/api/v1/exchange-rates/", requireAuth(currencyHandler.DeleteRate))
+	mux.Handle("/api/v1/jars", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			jarHandler.Create(w, r)
+			return
+		}
+		jarHandler.List(w, r)
+	}))
+	mux.Handle("/api/v1/jars/", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/move") {
+			jarHandler.Move(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodPatch:
+			jarHandler.Patch(w, r)
+		case http.MethodDelete:
+			jarHandler.Delete(w, r)
+		default:
+			jarHandler.Get(w, r)
+		}
+	}))
+	mux.Handle("/api/v1/budgets", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			budgetHandler.Create(w, r)
+			return
+		}
+		budgetHandler.List(w, r)
+	}))
+	mux.Handle("/api/v1/budgets/", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPatch:
+			budgetHandler.Patch(w, r)
+		case http.MethodDelete:
+			budgetHandler.Delete(w, r)
+		default:
+			budgetHandler.Get(w, r)
+		}
+	}))
+	mux.Handle("/api/v1/tags", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			tagHandler.Create(w, r)
+			return
+		}
+		tagHandler.List(w, r)
+	}))
+	mux.Handle("/api/v1/tags/", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodPatch:
+			tagHandler.Patch(w, r)
+		case http.MethodDelete:
+			tagHandler.Delete(w, r)
+		default:
+			tagHandler.Get(w, r)
+		}
+	}))
+	mux.Handle("/api/v1/search", requireAuth(searchHandler.Search))
+	mux.Handle("/api/v1/audit", requireAuth(auditHandler.List))
+	mux.Handle("/api/v1/trash", requireAuth(trashHandler.List))
+	mux.Handle("/api/v1/trash/", requireAuth(trashHandler.Restore))
+	mux.Handle("/api/v1/sync/changes", requireAuth(syncHandler.Changes))
+	mux.Handle("/api/v1/sync/push", requireAuth(syncHandler.Push))
+	mux.Handle("/api/v1/allocations/rules", requireAuth(allocationHandler.Rules))
+	mux.Handle("/api/v1/allocations/balances", requireAuth(allocationHandler.Balances))
+	mux.Handle("/api/v1/recurring", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			recurringHandler.Create(w, r)
+			return
+		}
+		recurringHandler.List(w, r)
+	}))
+	mux.Handle("/api/v1/recurring/upcoming", requireAuth(recurringHandler.Upcoming))
+	mux.Handle("/api/v1/recurring/materialize", requireAuth(recurringHandler.Materialize))
+	mux.Handle("/api/v1/recurring/", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/skip") {
+			recurringHandler.Skip(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodPatch:
+			recurringHandler.Patch(w, r)
+		case http.MethodDelete:
+			recurringHandler.Delete(w, r)
+		default:
+			recurringHandler.Get(w, r)
+		}
+	}))
+	mux.Handle("/api/v1/wallets/", requireAuth(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, "/balance") {
+			walletHandler.GetBalance(w, r)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			walletHandler.Get(w, r)
+		case http.MethodPatch:
+			walletHandler.Update(w, r)
+		default:
+			walletHandler.HandleDelete(w, r)
+		}
+	}))
 
 	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("OK"))
 	})
 
-	return CORSMiddleware(mux)
+	app.Handler = CORSMiddleware(mux)
+	return app
+}
+
+func (a *App) schedule(run func(ctx context.Context, interval time.Duration), interval time.Duration) {
+	a.jobs = append(a.jobs, func(ctx context.Context) {
+		run(ctx, interval)
+	})
 }
 
 func CORSMiddleware(next http.Handler) http.Handler {
@@ -114,8 +399,9 @@ func CORSMiddleware(next http.Handler) http.Handler {
 			w.Header().Set("Access-Control-Allow-Origin", "http://localhost:5173")
 		}
 		w.Header().Set("Access-Control-Allow-Credentials", "true")
-		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, DELETE")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
+		w.Header().Set("Access-Control-Allow-Methods", "POST, GET, OPTIONS, PUT, PATCH, DELETE")
+		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With, Idempotency-Key, If-Match")
+		w.Header().Set("Access-Control-Expose-Headers", "Idempotent-Replayed, ETag")
 
 		if r.Method == http.MethodOptions {
 			w.WriteHeader(http.StatusNoContent)
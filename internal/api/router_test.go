@@ -0,0 +1,42 @@
+package api
+
+import (
+	"context"
+	"jarwise-backend/internal/db"
+	"testing"
+	"time"
+)
+
+func TestAppRunJobsStopsWithContext(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	app := NewApp(RouterOptions{
+		DB:                         dbConn,
+		AttachmentDir:              t.TempDir(),
+		RecurringInterval:          time.Hour,
+		AttachmentCleanupInterval:  time.Hour,
+		IdempotencyCleanupInterval: time.Hour,
+		TrashPurgeInterval:         time.Hour,
+	})
+	if len(app.jobs) != 4 {
+		t.Fatalf("expected four background jobs, got %d", len(app.jobs))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		app.RunJobs(ctx)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the background jobs to stop once the context is cancelled")
+	}
+}
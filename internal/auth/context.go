@@ -7,7 +7,10 @@ import (
 
 type contextKey string
 
-const userContextKey contextKey = "authenticated-user"
+const (
+	userContextKey    contextKey = "authenticated-user"
+	sessionContextKey contextKey = "authenticated-session"
+)
 
 func ContextWithUser(ctx context.Context, user *models.User) context.Context {
 	return context.WithValue(ctx, userContextKey, user)
@@ -17,3 +20,14 @@ func UserFromContext(ctx context.Context) (*models.User, bool) {
 	user, ok := ctx.Value(userContextKey).(*models.User)
 	return user, ok && user != nil
 }
+
+// ContextWithSession stores the ID of the session a request was
+// authenticated with. It is the session row's ID, not its token.
+func ContextWithSession(ctx context.Context, sessionID string) context.Context {
+	return context.WithValue(ctx, sessionContextKey, sessionID)
+}
+
+func SessionIDFromContext(ctx context.Context) (string, bool) {
+	sessionID, ok := ctx.Value(sessionContextKey).(string)
+	return sessionID, ok && sessionID != ""
+}
@@ -13,7 +13,7 @@ func RequireAuth(service *Service, next http.Handler) http.Handler {
 			return
 		}
 
-		user, err := service.AuthenticateRequest(r.Context(), cookie.Value)
+		user, sessionID, err := service.AuthenticateRequest(r.Context(), cookie.Value)
 		if err != nil {
 			if errors.Is(err, ErrUnauthorized) {
 				service.ClearSessionCookie(w)
@@ -24,6 +24,7 @@ func RequireAuth(service *Service, next http.Handler) http.Handler {
 			return
 		}
 
-		next.ServeHTTP(w, r.WithContext(ContextWithUser(r.Context(), user)))
+		ctx := ContextWithSession(ContextWithUser(r.Context(), user), sessionID)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -92,21 +92,23 @@ func (s *Service) AuthenticateWithGoogle(ctx context.Context, idToken string) (*
 	return user, rawToken, nil
 }
 
-func (s *Service) AuthenticateRequest(ctx context.Context, rawToken string) (*models.User, error) {
+// AuthenticateRequest returns the user a session token belongs to and the
+// session's ID.
+func (s *Service) AuthenticateRequest(ctx context.Context, rawToken string) (*models.User, string, error) {
 	if rawToken == "" {
-		return nil, ErrUnauthorized
+		return nil, "", ErrUnauthorized
 	}
 
-	user, err := s.lookupSessionUser(ctx, rawToken)
+	user, sessionID, err := s.lookupSessionUser(ctx, rawToken)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	if err := s.refreshSession(ctx, rawToken); err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
-	return user, nil
+	return user, sessionID, nil
 }
 
 func (s *Service) Logout(ctx context.Context, rawToken string) error {
@@ -180,14 +182,16 @@ func (s *Service) createSession(ctx context.Context, userID, rawToken string) er
 	return err
 }
 
-func (s *Service) lookupSessionUser(ctx context.Context, rawToken string) (*models.User, error) {
+func (s *Service) lookupSessionUser(ctx context.Context, rawToken string) (*models.User, string, error) {
 	user := &models.User{}
+	var sessionID string
 	err := s.db.QueryRowContext(ctx, `
-		SELECT u.id, u.google_sub, u.email, u.name, u.avatar_url, u.created_at, u.updated_at
+		SELECT s.id, u.id, u.google_sub, u.email, u.name, u.avatar_url, u.created_at, u.updated_at
 		FROM user_sessions s
 		INNER JOIN users u ON u.id = s.user_id
 		WHERE s.token_hash = ? AND s.expires_at > ?
 	`, hashToken(rawToken), s.now()).Scan(
+		&sessionID,
 		&user.ID,
 		&user.GoogleSub,
 		&user.Email,
@@ -198,12 +202,12 @@ func (s *Service) lookupSessionUser(ctx context.Context, rawToken string) (*mode
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
-			return nil, ErrUnauthorized
+			return nil, "", ErrUnauthorized
 		}
-		return nil, err
+		return nil, "", err
 	}
 
-	return user, nil
+	return user, sessionID, nil
 }
 
 func (s *Service) refreshSession(ctx context.Context, rawToken string) error {
@@ -0,0 +1,145 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// auditEntrySQL appends one audit entry for a row of {{table}}. The owner
+// expression and snapshots read the row through NEW or OLD. The actor is
+// whoever audit_actors names for the owner while the write runs; writes
+// nobody claimed are recorded as made by the system.
+const auditEntrySQL = `
+		INSERT INTO audit_log (user_id, actor_user_id, actor_session_id, source, entity, entity_id, op, before_json, after_json, created_at)
+			SELECT o.user_id, a.actor_user_id, a.session_id, COALESCE(a.source, 'system'), '{{entity}}', {{id}}, '{{op}}', {{before}}, {{after}},
+				strftime('%Y-%m-%d %H:%M:%f', 'now')
+			FROM (SELECT {{owner}} AS user_id) AS o
+			LEFT JOIN audit_actors AS a ON a.user_id = o.user_id
+			WHERE o.user_id IS NOT NULL;`
+
+// auditedTables lists the tables whose writes are audited, with the columns
+// kept in the before and after snapshots. Derived values are left out: the
+// cached wallet balance, record versions, jar allocations and how far a
+// recurring rule was materialized all follow from audited writes. updateOf
+// limits which updates count; empty means all of them.
+var auditedTables = []struct {
+	table, entity, id, owner string
+	columns                  []string
+	updateOf                 string
+}{
+	{"wallets", "wallet", "{{row}}.id", "{{row}}.user_id",
+		[]string{"id", "name", "currency", "type", "opening_balance", "archived_at"},
+		"name, currency, type, opening_balance, archived_at"},
+	{"jars", "jar", "{{row}}.id", "{{row}}.user_id",
+		[]string{"id", "name", "type", "parent_id", "wallet_id", "icon", "color"},
+		"name, type, parent_id, wallet_id, icon, color"},
+	{"transactions", "transaction", "{{row}}.id", "{{row}}.user_id",
+		[]string{"id", "amount", "description", "date", "type", "wallet_id", "jar_id", "related_transaction_id"},
+		"amount, description, date, type, wallet_id, jar_id, related_transaction_id"},
+	{"transaction_splits", "transaction_split", "{{row}}.id", "{{row}}.user_id",
+		[]string{"id", "transaction_id", "jar_id", "amount", "description", "position"}, ""},
+	// Tag links have no owner of their own, and a link removed by deleting
+	// its transaction or tag falls back to the other side.
+	{"transaction_tags", "transaction_tag", "{{row}}.transaction_id",
+		"COALESCE((SELECT user_id FROM transactions WHERE id = {{row}}.transaction_id), (SELECT user_id FROM tags WHERE id = {{row}}.tag_id))",
+		[]string{"transaction_id", "tag_id"}, ""},
+	{"tags", "tag", "{{row}}.id", "{{row}}.user_id",
+		[]string{"id", "name", "color"}, ""},
+	{"budgets", "budget", "{{row}}.id", "{{row}}.user_id",
+		[]string{"id", "jar_id", "period", "amount", "rollover", "start_date"}, ""},
+	{"allocation_rules", "allocation_rule", "{{row}}.jar_id", "{{row}}.user_id",
+		[]string{"jar_id", "percent"}, ""},
+	{"recurring_rules", "recurring_rule", "{{row}}.id", "{{row}}.user_id",
+		[]string{"id", "wallet_id", "jar_id", "type", "amount", "description", "frequency", "interval_count", "day_of_month", "start_date", "end_date", "occurrence_count"},
+		"wallet_id, jar_id, type, amount, description, frequency, interval_count, day_of_month, start_date, end_date, occurrence_count"},
+	{"recurring_occurrences", "recurring_occurrence", "{{row}}.rule_id", "{{row}}.user_id",
+		[]string{"rule_id", "occurrence_date", "status", "transaction_id"}, ""},
+	{"attachments", "attachment", "{{row}}.id", "{{row}}.user_id",
+		[]string{"id", "transaction_id", "file_name", "content_type", "size"}, ""},
+	{"exchange_rates", "exchange_rate", "{{row}}.id", "{{row}}.user_id",
+		[]string{"id", "from_currency", "to_currency", "rate", "effective_date", "source"}, ""},
+}
+
+// auditSnapshot builds a JSON object of columns as read through row.
+func auditSnapshot(columns []string, row string) string {
+	pairs := make([]string, 0, len(columns))
+	for _, column := range columns {
+		pairs = append(pairs, "'"+column+"', "+row+"."+column)
+	}
+	return "json_object(" + strings.Join(pairs, ", ") + ")"
+}
+
+func auditEntryStatement(entity, id, owner, op, before, after, row string) string {
+	return strings.NewReplacer(
+		"{{entity}}", entity, "{{op}}", op, "{{before}}", before, "{{after}}", after,
+		"{{id}}", strings.ReplaceAll(id, "{{row}}", row),
+		"{{owner}}", strings.ReplaceAll(owner, "{{row}}", row),
+	).Replace(auditEntrySQL)
+}
+
+// createAuditLog builds the append-only audit log and the triggers that
+// feed it from every write to the audited tables, cascades and imports
+// included. audit_actors holds who is writing a user's data; a write
+// transaction names its actor there and removes it again before it commits,
+// so the row is only ever seen by that transaction's own triggers.
+func createAuditLog(tx *sql.Tx) error {
+	statements := []string{`
+	CREATE TABLE IF NOT EXISTS audit_log (
+	        id INTEGER PRIMARY KEY AUTOINCREMENT,
+	        user_id TEXT NOT NULL,
+	        actor_user_id TEXT,
+	        actor_session_id TEXT,
+	        source TEXT NOT NULL,
+	        entity TEXT NOT NULL,
+	        entity_id TEXT NOT NULL,
+	        op TEXT NOT NULL,
+	        before_json TEXT,
+	        after_json TEXT,
+	        created_at DATETIME NOT NULL
+	)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_user ON audit_log(user_id, id)`,
+		`CREATE INDEX IF NOT EXISTS idx_audit_log_user_entity ON audit_log(user_id, entity, entity_id, id)`,
+		`CREATE TRIGGER IF NOT EXISTS audit_log_no_update BEFORE UPDATE ON audit_log BEGIN
+		SELECT RAISE(ABORT, 'audit_log is append-only');
+	END`,
+		`CREATE TRIGGER IF NOT EXISTS audit_log_no_delete BEFORE DELETE ON audit_log BEGIN
+		SELECT RAISE(ABORT, 'audit_log is append-only');
+	END`,
+		`CREATE TABLE IF NOT EXISTS audit_actors (
+	        user_id TEXT PRIMARY KEY,
+	        actor_user_id TEXT,
+	        session_id TEXT,
+	        source TEXT NOT NULL
+	)`,
+	}
+
+	for _, t := range auditedTables {
+		before := auditSnapshot(t.columns, "OLD")
+		after := auditSnapshot(t.columns, "NEW")
+		update := "AFTER UPDATE ON " + t.table
+		if t.updateOf != "" {
+			update = "AFTER UPDATE OF " + t.updateOf + " ON " + t.table
+		}
+		statements = append(statements,
+			"CREATE TRIGGER IF NOT EXISTS "+t.table+"_audit_insert AFTER INSERT ON "+t.table+" BEGIN"+
+				auditEntryStatement(t.entity, t.id, t.owner, "create", "NULL", after, "NEW")+"\n\tEND",
+			// Updates that leave the snapshot as it was are not recorded.
+			"CREATE TRIGGER IF NOT EXISTS "+t.table+"_audit_update "+update+" WHEN "+before+" IS NOT "+after+" BEGIN"+
+				auditEntryStatement(t.entity, t.id, t.owner, "update", before, after, "NEW")+"\n\tEND",
+			"CREATE TRIGGER IF NOT EXISTS "+t.table+"_audit_delete AFTER DELETE ON "+t.table+" BEGIN"+
+				auditEntryStatement(t.entity, t.id, t.owner, "delete", before, "NULL", "OLD")+"\n\tEND",
+		)
+	}
+	// The base currency is the only user setting written through the API.
+	statements = append(statements, `CREATE TRIGGER IF NOT EXISTS users_audit_update AFTER UPDATE OF base_currency ON users
+	WHEN OLD.base_currency IS NOT NEW.base_currency BEGIN`+
+		auditEntryStatement("settings", "{{row}}.id", "{{row}}.id", "update",
+			auditSnapshot([]string{"base_currency"}, "OLD"), auditSnapshot([]string{"base_currency"}, "NEW"), "NEW")+"\n\tEND")
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
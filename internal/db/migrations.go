@@ -0,0 +1,463 @@
+package db
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// Migration is one numbered step of the schema history. Up runs inside a
+// transaction together with the schema_migrations bookkeeping, so a failed
+// step leaves the database at the previous version.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *sql.Tx) error
+}
+
+// AppliedMigration is a row of schema_migrations.
+type AppliedMigration struct {
+	Version   int
+	Name      string
+	AppliedAt time.Time
+}
+
+// migrations must stay ordered by Version; append new steps at the end and
+// never edit one that has shipped.
+//
+// Versions 1-7 reproduce what the old idempotent start-up script built, so
+// they also run cleanly against databases created before schema_migrations
+// existed and simply record them as applied.
+var migrations = []Migration{
+	{Version: 1, Name: "core_schema", Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS users (
+	        id TEXT PRIMARY KEY,
+	        google_sub TEXT NOT NULL UNIQUE,
+	        email TEXT NOT NULL,
+	        name TEXT NOT NULL,
+	        avatar_url TEXT,
+	        created_at DATETIME NOT NULL,
+	        updated_at DATETIME NOT NULL
+	);
+
+	CREATE TABLE IF NOT EXISTS user_sessions (
+	        id TEXT PRIMARY KEY,
+	        user_id TEXT NOT NULL,
+	        token_hash TEXT NOT NULL UNIQUE,
+	        expires_at DATETIME NOT NULL,
+	        created_at DATETIME NOT NULL,
+	        last_seen_at DATETIME NOT NULL,
+	        FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions(user_id);
+
+	CREATE TABLE IF NOT EXISTS wallets (
+	        id TEXT PRIMARY KEY,
+	        user_id TEXT NOT NULL DEFAULT 'legacy-local-user',
+	        name TEXT NOT NULL,
+	        currency TEXT NOT NULL,
+	        balance REAL DEFAULT 0.0,
+	        type TEXT
+	);
+
+	CREATE TABLE IF NOT EXISTS jars (
+	        id TEXT PRIMARY KEY,
+	        user_id TEXT NOT NULL DEFAULT 'legacy-local-user',
+	        name TEXT NOT NULL,
+	        type TEXT NOT NULL,
+	        parent_id TEXT,
+	        wallet_id TEXT,
+	        icon TEXT,
+	        color TEXT,
+	        FOREIGN KEY(parent_id) REFERENCES jars(id),
+	        FOREIGN KEY(wallet_id) REFERENCES wallets(id)
+	);
+	CREATE TABLE IF NOT EXISTS transactions (
+	        id TEXT PRIMARY KEY,
+	        user_id TEXT NOT NULL DEFAULT 'legacy-local-user',
+	        amount REAL NOT NULL,
+	        description TEXT,
+	        date DATETIME NOT NULL,
+	        type TEXT NOT NULL,
+	        wallet_id TEXT NOT NULL,
+	        jar_id TEXT,
+	        related_transaction_id TEXT,
+	        FOREIGN KEY(wallet_id) REFERENCES wallets(id),
+	        FOREIGN KEY(jar_id) REFERENCES jars(id),
+	        FOREIGN KEY(related_transaction_id) REFERENCES transactions(id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_related_transaction_id ON transactions(related_transaction_id);
+	CREATE INDEX IF NOT EXISTS idx_wallet_id ON transactions(wallet_id);
+	CREATE INDEX IF NOT EXISTS idx_jar_id ON transactions(jar_id);
+
+	CREATE TABLE IF NOT EXISTS migration_jobs (
+	        id TEXT PRIMARY KEY,
+	        user_id TEXT NOT NULL,
+	        phase TEXT NOT NULL,
+	        message TEXT,
+	        mmbak_path TEXT,
+	        xls_path TEXT,
+	        counts_json TEXT,
+	        validation_errors_json TEXT,
+	        duplicate_summary_json TEXT,
+	        can_confirm_import INTEGER NOT NULL DEFAULT 0,
+	        expires_at DATETIME,
+	        created_at DATETIME NOT NULL,
+	        updated_at DATETIME NOT NULL,
+	        FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_migration_jobs_user_id ON migration_jobs(user_id);
+	CREATE INDEX IF NOT EXISTS idx_migration_jobs_phase ON migration_jobs(phase);
+
+	CREATE TABLE IF NOT EXISTS migration_source_refs (
+	        id TEXT PRIMARY KEY,
+	        user_id TEXT NOT NULL,
+	        source_system TEXT NOT NULL,
+	        entity_type TEXT NOT NULL,
+	        source_id TEXT NOT NULL,
+	        fingerprint TEXT NOT NULL,
+	        display_name TEXT,
+	        imported_record_id TEXT NOT NULL,
+	        created_at DATETIME NOT NULL,
+	        FOREIGN KEY(user_id) REFERENCES users(id)
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_migration_source_refs_source
+	        ON migration_source_refs(user_id, source_system, entity_type, source_id);
+	CREATE INDEX IF NOT EXISTS idx_migration_source_refs_fingerprint
+	        ON migration_source_refs(user_id, entity_type, fingerprint);
+	`)},
+	{Version: 2, Name: "user_ownership", Up: func(tx *sql.Tx) error {
+		if err := ensureLegacyUser(tx); err != nil {
+			return fmt.Errorf("failed to ensure legacy user: %w", err)
+		}
+		if err := ensureUserOwnershipColumns(tx); err != nil {
+			return fmt.Errorf("failed to ensure user ownership columns: %w", err)
+		}
+		if err := ensureUserOwnershipIndexes(tx); err != nil {
+			return fmt.Errorf("failed to ensure user ownership indexes: %w", err)
+		}
+		return nil
+	}},
+	{Version: 3, Name: "wallet_opening_balance", Up: ensureWalletOpeningBalance},
+	{Version: 4, Name: "wallet_archive", Up: func(tx *sql.Tx) error {
+		return ensureColumn(tx, "wallets", "archived_at", "DATETIME")
+	}},
+	{Version: 5, Name: "budgets", Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS budgets (
+	        id TEXT PRIMARY KEY,
+	        user_id TEXT NOT NULL,
+	        jar_id TEXT NOT NULL,
+	        period TEXT NOT NULL,
+	        amount REAL NOT NULL,
+	        rollover TEXT NOT NULL DEFAULT 'none',
+	        start_date DATETIME NOT NULL,
+	        created_at DATETIME NOT NULL,
+	        FOREIGN KEY(user_id) REFERENCES users(id),
+	        FOREIGN KEY(jar_id) REFERENCES jars(id) ON DELETE CASCADE
+	);
+	CREATE UNIQUE INDEX IF NOT EXISTS idx_budgets_user_jar_period ON budgets(user_id, jar_id, period);
+	`)},
+	{Version: 6, Name: "income_allocation", Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS allocation_rules (
+	        user_id TEXT NOT NULL,
+	        jar_id TEXT NOT NULL,
+	        percent REAL NOT NULL,
+	        PRIMARY KEY(user_id, jar_id),
+	        FOREIGN KEY(jar_id) REFERENCES jars(id) ON DELETE CASCADE
+	);
+
+	CREATE TABLE IF NOT EXISTS jar_allocations (
+	        transaction_id TEXT NOT NULL,
+	        jar_id TEXT NOT NULL,
+	        user_id TEXT NOT NULL,
+	        percent REAL NOT NULL,
+	        amount REAL NOT NULL,
+	        date DATETIME NOT NULL,
+	        PRIMARY KEY(transaction_id, jar_id),
+	        FOREIGN KEY(transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+	        FOREIGN KEY(jar_id) REFERENCES jars(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_jar_allocations_user_jar ON jar_allocations(user_id, jar_id);
+	`)},
+	{Version: 7, Name: "recurring_rules", Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS recurring_rules (
+	        id TEXT PRIMARY KEY,
+	        user_id TEXT NOT NULL,
+	        wallet_id TEXT NOT NULL,
+	        jar_id TEXT,
+	        type TEXT NOT NULL,
+	        amount REAL NOT NULL,
+	        description TEXT,
+	        frequency TEXT NOT NULL,
+	        interval_count INTEGER NOT NULL DEFAULT 1,
+	        day_of_month INTEGER NOT NULL DEFAULT 0,
+	        start_date DATETIME NOT NULL,
+	        end_date DATETIME,
+	        occurrence_count INTEGER,
+	        materialized_through DATETIME,
+	        created_at DATETIME NOT NULL,
+	        updated_at DATETIME NOT NULL,
+	        FOREIGN KEY(wallet_id) REFERENCES wallets(id),
+	        FOREIGN KEY(jar_id) REFERENCES jars(id) ON DELETE SET NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_recurring_rules_user_id ON recurring_rules(user_id);
+
+	CREATE TABLE IF NOT EXISTS recurring_occurrences (
+	        rule_id TEXT NOT NULL,
+	        occurrence_date DATETIME NOT NULL,
+	        user_id TEXT NOT NULL,
+	        status TEXT NOT NULL,
+	        transaction_id TEXT,
+	        created_at DATETIME NOT NULL,
+	        PRIMARY KEY(rule_id, occurrence_date),
+	        FOREIGN KEY(rule_id) REFERENCES recurring_rules(id) ON DELETE CASCADE,
+	        FOREIGN KEY(transaction_id) REFERENCES transactions(id) ON DELETE SET NULL
+	);
+	`)},
+	{Version: 8, Name: "money_minor_units", Up: func(tx *sql.Tx) error {
+		columns := []struct{ table, column string }{
+			{"wallets", "balance"},
+			{"wallets", "opening_balance"},
+			{"transactions", "amount"},
+			{"budgets", "amount"},
+			{"jar_allocations", "amount"},
+			{"recurring_rules", "amount"},
+		}
+		for _, c := range columns {
+			if err := convertMoneyColumn(tx, c.table, c.column); err != nil {
+				return fmt.Errorf("failed to convert %s.%s: %w", c.table, c.column, err)
+			}
+		}
+		return nil
+	}},
+	{Version: 9, Name: "exchange_rates", Up: func(tx *sql.Tx) error {
+		if err := ensureColumn(tx, "users", "base_currency", "TEXT NOT NULL DEFAULT 'THB'"); err != nil {
+			return err
+		}
+		return execStatements(`
+		CREATE TABLE IF NOT EXISTS exchange_rates (
+		        id TEXT PRIMARY KEY,
+		        user_id TEXT NOT NULL,
+		        from_currency TEXT NOT NULL,
+		        to_currency TEXT NOT NULL,
+		        rate REAL NOT NULL,
+		        effective_date DATETIME NOT NULL,
+		        source TEXT NOT NULL DEFAULT 'manual',
+		        created_at DATETIME NOT NULL,
+		        FOREIGN KEY(user_id) REFERENCES users(id)
+		);
+		CREATE UNIQUE INDEX IF NOT EXISTS idx_exchange_rates_pair_date
+		        ON exchange_rates(user_id, from_currency, to_currency, effective_date);
+		`)(tx)
+	}},
+	// Transfer legs used to be stored as an expense and an income, which
+	// analytics counted as spending and earning. Legs that still reference
+	// each other become signed "transfer" rows, like imported transfers.
+	{Version: 10, Name: "transfer_type", Up: execStatements(`
+	UPDATE transactions
+	SET amount = CASE type WHEN 'expense' THEN -ABS(amount) ELSE ABS(amount) END,
+	    type = 'transfer'
+	WHERE type IN ('income', 'expense')
+	  AND related_transaction_id IS NOT NULL
+	  AND EXISTS (
+	        SELECT 1 FROM transactions partner
+	        WHERE partner.id = transactions.related_transaction_id
+	          AND partner.related_transaction_id = transactions.id
+	  );
+	`)},
+	{Version: 11, Name: "transaction_splits", Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS transaction_splits (
+	        id TEXT PRIMARY KEY,
+	        transaction_id TEXT NOT NULL,
+	        user_id TEXT NOT NULL,
+	        jar_id TEXT NOT NULL,
+	        amount INTEGER NOT NULL,
+	        description TEXT NOT NULL DEFAULT '',
+	        position INTEGER NOT NULL DEFAULT 0,
+	        FOREIGN KEY(transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+	        FOREIGN KEY(jar_id) REFERENCES jars(id)
+	);
+	CREATE INDEX IF NOT EXISTS idx_transaction_splits_transaction ON transaction_splits(transaction_id);
+	CREATE INDEX IF NOT EXISTS idx_transaction_splits_user_jar ON transaction_splits(user_id, jar_id);
+	`)},
+	{Version: 12, Name: "tags", Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS tags (
+	        id TEXT PRIMARY KEY,
+	        user_id TEXT NOT NULL,
+	        name TEXT NOT NULL,
+	        color TEXT NOT NULL DEFAULT '',
+	        created_at DATETIME NOT NULL,
+	        UNIQUE(user_id, name)
+	);
+	CREATE TABLE IF NOT EXISTS transaction_tags (
+	        transaction_id TEXT NOT NULL,
+	        tag_id TEXT NOT NULL,
+	        PRIMARY KEY(transaction_id, tag_id),
+	        FOREIGN KEY(transaction_id) REFERENCES transactions(id) ON DELETE CASCADE,
+	        FOREIGN KEY(tag_id) REFERENCES tags(id) ON DELETE CASCADE
+	);
+	CREATE INDEX IF NOT EXISTS idx_transaction_tags_tag ON transaction_tags(tag_id);
+	`)},
+	// Attachments deliberately have no foreign key to transactions: the
+	// stored files must be removed too, so rows left behind by a deleted
+	// transaction are swept by the attachment service.
+	{Version: 13, Name: "attachments", Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS attachments (
+	        id TEXT PRIMARY KEY,
+	        user_id TEXT NOT NULL,
+	        transaction_id TEXT NOT NULL,
+	        file_name TEXT NOT NULL,
+	        content_type TEXT NOT NULL,
+	        size INTEGER NOT NULL,
+	        storage_key TEXT NOT NULL,
+	        created_at DATETIME NOT NULL
+	);
+	CREATE INDEX IF NOT EXISTS idx_attachments_transaction ON attachments(transaction_id);
+	`)},
+	{Version: 14, Name: "transaction_search", Up: createSearchIndex},
+	{Version: 15, Name: "sync_changes", Up: createSyncChanges},
+	// A status_code of 0 marks a request that is still being handled.
+	{Version: 16, Name: "idempotency_keys", Up: execStatements(`
+	CREATE TABLE IF NOT EXISTS idempotency_keys (
+	        user_id TEXT NOT NULL,
+	        idempotency_key TEXT NOT NULL,
+	        request_hash TEXT NOT NULL,
+	        status_code INTEGER NOT NULL DEFAULT 0,
+	        content_type TEXT NOT NULL DEFAULT '',
+	        body BLOB,
+	        created_at DATETIME NOT NULL,
+	        expires_at DATETIME NOT NULL,
+	        PRIMARY KEY (user_id, idempotency_key)
+	);
+	CREATE INDEX IF NOT EXISTS idx_idempotency_keys_expires_at ON idempotency_keys(expires_at);
+	`)},
+	{Version: 17, Name: "record_versions", Up: addRecordVersions},
+	{Version: 18, Name: "audit_log", Up: createAuditLog},
+	{Version: 19, Name: "trash", Up: createTrash},
+	{Version: 20, Name: "idempotency_headers", Up: execStatements(`
+	ALTER TABLE idempotency_keys ADD COLUMN etag TEXT NOT NULL DEFAULT '';
+	ALTER TABLE idempotency_keys ADD COLUMN location TEXT NOT NULL DEFAULT '';
+	`)},
+	// Actors are now only named inside the transaction that writes, so rows
+	// committed by earlier versions would be credited with every write.
+	{Version: 21, Name: "audit_actors_per_transaction", Up: execStatements(`
+	DELETE FROM audit_actors;
+	`)},
+	{Version: 22, Name: "idempotency_lease_token", Up: execStatements(`
+	ALTER TABLE idempotency_keys ADD COLUMN lease_token TEXT NOT NULL DEFAULT '';
+	`)},
+}
+
+// Migrate applies every pending migration in order.
+func Migrate(db *sql.DB) error {
+	if err := ensureMigrationsTable(db); err != nil {
+		return err
+	}
+
+	current, err := CurrentVersion(db)
+	if err != nil {
+		return err
+	}
+
+	for _, migration := range migrations {
+		if migration.Version <= current {
+			continue
+		}
+		if err := applyMigration(db, migration); err != nil {
+			return fmt.Errorf("migration %d (%s) failed: %w", migration.Version, migration.Name, err)
+		}
+	}
+	if err := ensureSearchIndex(db); err != nil {
+		return fmt.Errorf("failed to create search index: %w", err)
+	}
+	return nil
+}
+
+// CurrentVersion returns the highest applied migration, or 0 for a database
+// that has never been migrated.
+func CurrentVersion(db *sql.DB) (int, error) {
+	exists, err := migrationsTableExists(db)
+	if err != nil || !exists {
+		return 0, err
+	}
+
+	var version int
+	if err := db.QueryRow("SELECT COALESCE(MAX(version), 0) FROM schema_migrations").Scan(&version); err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// LatestVersion is the version a fully migrated database reports.
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// AppliedMigrations lists the recorded migrations, oldest first.
+func AppliedMigrations(db *sql.DB) ([]AppliedMigration, error) {
+	exists, err := migrationsTableExists(db)
+	if err != nil || !exists {
+		return nil, err
+	}
+
+	rows, err := db.Query("SELECT version, name, applied_at FROM schema_migrations ORDER BY version")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var applied []AppliedMigration
+	for rows.Next() {
+		var migration AppliedMigration
+		if err := rows.Scan(&migration.Version, &migration.Name, &migration.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied = append(applied, migration)
+	}
+	return applied, rows.Err()
+}
+
+func migrationsTableExists(db *sql.DB) (bool, error) {
+	var count int
+	err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&count)
+	return count > 0, err
+}
+
+func ensureMigrationsTable(db *sql.DB) error {
+	_, err := db.Exec(`
+	CREATE TABLE IF NOT EXISTS schema_migrations (
+	        version INTEGER PRIMARY KEY,
+	        name TEXT NOT NULL,
+	        applied_at DATETIME NOT NULL
+	)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+	return nil
+}
+
+func applyMigration(db *sql.DB, migration Migration) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := migration.Up(tx); err != nil {
+		return err
+	}
+	if _, err := tx.Exec(
+		"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, ?)",
+		migration.Version, migration.Name, time.Now().UTC(),
+	); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func execStatements(schema string) func(tx *sql.Tx) error {
+	return func(tx *sql.Tx) error {
+		_, err := tx.Exec(schema)
+		return err
+	}
+}
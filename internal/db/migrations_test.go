@@ -0,0 +1,215 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"jarwise-backend/internal/models"
+	"path/filepath"
+	"testing"
+)
+
+// createLegacyDB builds a database the way releases before multi-user
+// support left it: no user_id columns, no opening_balance and no
+// schema_migrations table.
+func createLegacyDB(t *testing.T) string {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "legacy.db")
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("failed to open legacy DB: %v", err)
+	}
+	defer conn.Close()
+
+	_, err = conn.Exec(`
+	CREATE TABLE wallets (
+	        id TEXT PRIMARY KEY,
+	        name TEXT NOT NULL,
+	        currency TEXT NOT NULL,
+	        balance REAL DEFAULT 0.0,
+	        type TEXT
+	);
+	CREATE TABLE jars (
+	        id TEXT PRIMARY KEY,
+	        name TEXT NOT NULL,
+	        type TEXT NOT NULL,
+	        parent_id TEXT,
+	        wallet_id TEXT,
+	        icon TEXT,
+	        color TEXT
+	);
+	CREATE TABLE transactions (
+	        id TEXT PRIMARY KEY,
+	        amount REAL NOT NULL,
+	        description TEXT,
+	        date DATETIME NOT NULL,
+	        type TEXT NOT NULL,
+	        wallet_id TEXT NOT NULL,
+	        jar_id TEXT,
+	        related_transaction_id TEXT
+	);
+	INSERT INTO wallets (id, name, currency, balance, type) VALUES ('wallet-1', 'Cash', 'THB', 1000, 'cash');
+	INSERT INTO jars (id, name, type, wallet_id) VALUES ('food', 'Food', 'expense', 'wallet-1');
+	INSERT INTO transactions (id, amount, description, date, type, wallet_id, jar_id)
+	VALUES ('tx-1', 250, 'Lunch', '2025-01-10 12:00:00', 'expense', 'wallet-1', 'food');
+	INSERT INTO transactions (id, amount, description, date, type, wallet_id, related_transaction_id) VALUES
+	        ('leg-out', -100, 'Move', '2025-01-11 12:00:00', 'expense', 'wallet-1', 'leg-in'),
+	        ('leg-in', 100, 'Move', '2025-01-11 12:00:00', 'income', 'wallet-1', 'leg-out');
+	`)
+	if err != nil {
+		t.Fatalf("failed to create legacy schema: %v", err)
+	}
+	return path
+}
+
+func TestMigrate_UpgradesLegacyDatabase(t *testing.T) {
+	path := createLegacyDB(t)
+
+	conn, err := InitDB(path)
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer conn.Close()
+
+	version, err := CurrentVersion(conn)
+	if err != nil {
+		t.Fatalf("CurrentVersion failed: %v", err)
+	}
+	if version != LatestVersion() {
+		t.Fatalf("expected version %d, got %d", LatestVersion(), version)
+	}
+
+	for _, table := range []string{"wallets", "jars", "transactions"} {
+		var userID string
+		if err := conn.QueryRow("SELECT user_id FROM " + table + " LIMIT 1").Scan(&userID); err != nil {
+			t.Fatalf("failed to read %s.user_id: %v", table, err)
+		}
+		if userID != models.DefaultLocalUserID {
+			t.Errorf("expected %s rows to belong to %s, got %q", table, models.DefaultLocalUserID, userID)
+		}
+	}
+
+	// Amounts are stored as minor units once money_minor_units has run.
+	var openingBalance, balance models.Money
+	if err := conn.QueryRow("SELECT opening_balance, balance FROM wallets WHERE id = 'wallet-1'").Scan(&openingBalance, &balance); err != nil {
+		t.Fatalf("failed to read wallet: %v", err)
+	}
+	if openingBalance != 100000 || balance != 75000 {
+		t.Errorf("expected opening 1000.00 and balance 750.00, got %s and %s", openingBalance, balance)
+	}
+
+	// Linked legs become signed transfers; ordinary rows keep their type.
+	wantTypes := map[string]struct {
+		txType string
+		amount models.Money
+	}{
+		"tx-1":    {"expense", 25000},
+		"leg-out": {"transfer", -10000},
+		"leg-in":  {"transfer", 10000},
+	}
+	for id, want := range wantTypes {
+		var txType string
+		var amount models.Money
+		if err := conn.QueryRow("SELECT type, amount FROM transactions WHERE id = ?", id).Scan(&txType, &amount); err != nil {
+			t.Fatalf("failed to read %s: %v", id, err)
+		}
+		if txType != want.txType || amount != want.amount {
+			t.Errorf("expected %s to be %s %s, got %s %s", id, want.txType, want.amount, txType, amount)
+		}
+	}
+
+	for _, table := range []string{"users", "budgets", "allocation_rules", "recurring_rules"} {
+		var count int
+		if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", table).Scan(&count); err != nil || count != 1 {
+			t.Errorf("expected table %s to exist (count=%d, err=%v)", table, count, err)
+		}
+	}
+
+	// Running again is a no-op.
+	if err := Migrate(conn); err != nil {
+		t.Fatalf("second Migrate failed: %v", err)
+	}
+	applied, err := AppliedMigrations(conn)
+	if err != nil {
+		t.Fatalf("AppliedMigrations failed: %v", err)
+	}
+	if len(applied) != len(migrations) {
+		t.Errorf("expected %d applied migrations, got %d", len(migrations), len(applied))
+	}
+}
+
+func TestMigrate_FailedStepRollsBack(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "fresh.db")
+	conn, err := InitDB(path)
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer conn.Close()
+
+	original := migrations
+	t.Cleanup(func() { migrations = original })
+	migrations = append(append([]Migration{}, original...), Migration{
+		Version: LatestVersion() + 1,
+		Name:    "broken",
+		Up: func(tx *sql.Tx) error {
+			if _, err := tx.Exec("CREATE TABLE half_done (id TEXT)"); err != nil {
+				return err
+			}
+			return errors.New("boom")
+		},
+	})
+
+	if err := Migrate(conn); err == nil {
+		t.Fatal("expected the broken migration to fail")
+	}
+
+	version, err := CurrentVersion(conn)
+	if err != nil {
+		t.Fatalf("CurrentVersion failed: %v", err)
+	}
+	if version != len(original) {
+		t.Errorf("expected version to stay at %d, got %d", len(original), version)
+	}
+	var count int
+	if err := conn.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'half_done'").Scan(&count); err != nil || count != 0 {
+		t.Errorf("expected the failed step to be rolled back (count=%d, err=%v)", count, err)
+	}
+}
+
+func TestMigrate_ConvertsHalfCentAmountsExactly(t *testing.T) {
+	path := createLegacyDB(t)
+
+	conn, err := sql.Open("sqlite3", path)
+	if err != nil {
+		t.Fatalf("failed to open legacy DB: %v", err)
+	}
+	// As floats these sit just below the half cent (1.005 * 100 is
+	// 100.49999...), so rounding the product would lose a cent.
+	_, err = conn.Exec(`
+	INSERT INTO transactions (id, amount, description, date, type, wallet_id) VALUES
+	        ('half-1', 1.005, 'Half cent', '2025-01-12 12:00:00', 'expense', 'wallet-1'),
+	        ('half-2', 2.675, 'Half cent', '2025-01-12 12:00:00', 'expense', 'wallet-1'),
+	        ('half-3', -1.005, 'Half cent', '2025-01-12 12:00:00', 'expense', 'wallet-1'),
+	        ('under', 0.004, 'Under half', '2025-01-12 12:00:00', 'expense', 'wallet-1');
+	`)
+	conn.Close()
+	if err != nil {
+		t.Fatalf("failed to add amounts: %v", err)
+	}
+
+	migrated, err := InitDB(path)
+	if err != nil {
+		t.Fatalf("InitDB failed: %v", err)
+	}
+	defer migrated.Close()
+
+	for id, want := range map[string]models.Money{"half-1": 101, "half-2": 268, "half-3": -101, "under": 0} {
+		var amount models.Money
+		if err := migrated.QueryRow("SELECT amount FROM transactions WHERE id = ?", id).Scan(&amount); err != nil {
+			t.Fatalf("failed to read %s: %v", id, err)
+		}
+		if amount != want {
+			t.Errorf("expected %s to convert to %d minor units, got %d", id, want, amount)
+		}
+	}
+}
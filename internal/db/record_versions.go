@@ -0,0 +1,53 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// recordVersionSQL bumps the version of the {{table}} row just updated. The
+// trigger's WHEN clause leaves alone writes that set the version themselves.
+const recordVersionSQL = `
+		UPDATE {{table}} SET version = OLD.version + 1, updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;`
+
+// recordStampSQL fills in updated_at for rows inserted without one, such as
+// those written by the importers.
+const recordStampSQL = `
+		UPDATE {{table}} SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now') WHERE id = NEW.id;`
+
+// versionedTables lists the tables clients edit concurrently, with the
+// columns a version counts. As with the sync feed, the cached wallet balance
+// is left out so that adding a transaction does not invalidate the wallet.
+var versionedTables = []struct {
+	name, columns string
+}{
+	{"wallets", "name, currency, type, opening_balance, archived_at"},
+	{"jars", "name, type, parent_id, wallet_id, icon, color"},
+	{"transactions", "amount, description, date, type, wallet_id, jar_id, related_transaction_id"},
+}
+
+// addRecordVersions gives wallets, jars and transactions the version and
+// updated_at columns behind ETags. Triggers maintain both, so every write
+// path, including cascades and imports, moves the version on.
+func addRecordVersions(tx *sql.Tx) error {
+	var statements []string
+	for _, table := range versionedTables {
+		replacer := strings.NewReplacer("{{table}}", table.name)
+		statements = append(statements,
+			"ALTER TABLE "+table.name+" ADD COLUMN version INTEGER NOT NULL DEFAULT 1",
+			"ALTER TABLE "+table.name+" ADD COLUMN updated_at DATETIME",
+			"UPDATE "+table.name+" SET updated_at = strftime('%Y-%m-%d %H:%M:%f', 'now')",
+			"CREATE TRIGGER IF NOT EXISTS "+table.name+"_version_insert AFTER INSERT ON "+table.name+
+				" WHEN NEW.updated_at IS NULL BEGIN"+replacer.Replace(recordStampSQL)+"\n\tEND",
+			"CREATE TRIGGER IF NOT EXISTS "+table.name+"_version_update AFTER UPDATE OF "+table.columns+" ON "+table.name+
+				" WHEN NEW.version = OLD.version BEGIN"+replacer.Replace(recordVersionSQL)+"\n\tEND",
+		)
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package db
+
+import (
+	"database/sql"
+	"log"
+	"strings"
+)
+
+// searchIDsPlaceholder is replaced by a subquery of transaction IDs in
+// reindexSearchSQL.
+const searchIDsPlaceholder = "{{ids}}"
+
+// reindexSearchSQL rebuilds the index entries of the transactions whose IDs
+// the placeholder selects.
+const reindexSearchSQL = `
+		DELETE FROM transactions_fts WHERE rowid IN (
+			SELECT id FROM transaction_search_rows WHERE transaction_id IN ({{ids}})
+		);
+		INSERT OR IGNORE INTO transaction_search_rows (transaction_id)
+			SELECT id FROM transactions WHERE id IN ({{ids}});
+		INSERT INTO transactions_fts (rowid, description, jar_name, wallet_name)
+			SELECT r.id, t.description,
+				COALESCE((SELECT group_concat(j.name, ' ') FROM jars j
+					WHERE j.id = t.jar_id
+					   OR j.id IN (SELECT s.jar_id FROM transaction_splits s WHERE s.transaction_id = t.id)), ''),
+				COALESCE((SELECT w.name FROM wallets w WHERE w.id = t.wallet_id), '')
+			FROM transactions t
+			JOIN transaction_search_rows r ON r.transaction_id = t.id
+			WHERE t.id IN ({{ids}});`
+
+// searchTriggers keep the index in sync: each maps a trigger to the
+// transactions it reindexes.
+var searchTriggers = []struct {
+	name, event, ids string
+}{
+	{"transactions_fts_insert", "AFTER INSERT ON transactions", "NEW.id"},
+	{"transactions_fts_update", "AFTER UPDATE OF description, jar_id, wallet_id ON transactions", "NEW.id"},
+	{"transaction_splits_fts_insert", "AFTER INSERT ON transaction_splits", "NEW.transaction_id"},
+	{"transaction_splits_fts_delete", "AFTER DELETE ON transaction_splits", "OLD.transaction_id"},
+	{"jars_fts_rename", "AFTER UPDATE OF name ON jars",
+		"SELECT id FROM transactions WHERE jar_id = NEW.id UNION SELECT transaction_id FROM transaction_splits WHERE jar_id = NEW.id"},
+	{"wallets_fts_rename", "AFTER UPDATE OF name ON wallets", "SELECT id FROM transactions WHERE wallet_id = NEW.id"},
+}
+
+// fts5Available reports whether the linked SQLite was built with FTS5. The
+// mattn/go-sqlite3 driver only includes it with the sqlite_fts5 build tag.
+func fts5Available(q interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}) bool {
+	var used bool
+	if err := q.QueryRow("SELECT sqlite_compileoption_used('ENABLE_FTS5')").Scan(&used); err != nil {
+		return false
+	}
+	return used
+}
+
+// createSearchIndex builds transactions_fts, the FTS5 index over transaction
+// descriptions and the names of their jars and wallets, with its triggers and
+// initial contents. Its rowids come from transaction_search_rows, whose
+// INTEGER PRIMARY KEY survives VACUUM unlike the implicit rowid of
+// transactions.
+//
+// Without FTS5 it does nothing and search falls back to LIKE matching;
+// ensureSearchIndex creates the index once a build with FTS5 opens the
+// database.
+func createSearchIndex(tx *sql.Tx) error {
+	if !fts5Available(tx) {
+		log.Println("SQLite was built without FTS5; search will use LIKE matching.")
+		return nil
+	}
+
+	statements := []string{`
+	CREATE TABLE IF NOT EXISTS transaction_search_rows (
+	        id INTEGER PRIMARY KEY,
+	        transaction_id TEXT NOT NULL UNIQUE
+	)`, `
+	CREATE VIRTUAL TABLE IF NOT EXISTS transactions_fts USING fts5(
+	        description, jar_name, wallet_name,
+	        tokenize = 'unicode61 remove_diacritics 2'
+	)`, `
+	CREATE TRIGGER IF NOT EXISTS transactions_fts_delete AFTER DELETE ON transactions BEGIN
+		DELETE FROM transactions_fts WHERE rowid IN (
+			SELECT id FROM transaction_search_rows WHERE transaction_id = OLD.id
+		);
+		DELETE FROM transaction_search_rows WHERE transaction_id = OLD.id;
+	END`,
+	}
+	for _, trigger := range searchTriggers {
+		statements = append(statements, "CREATE TRIGGER IF NOT EXISTS "+trigger.name+" "+trigger.event+" BEGIN"+
+			strings.ReplaceAll(reindexSearchSQL, searchIDsPlaceholder, trigger.ids)+"\n\tEND")
+	}
+	statements = append(statements, strings.ReplaceAll(reindexSearchSQL, searchIDsPlaceholder, "SELECT id FROM transactions"))
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ensureSearchIndex creates the search index when the schema is current but
+// was migrated by a build without FTS5.
+func ensureSearchIndex(db *sql.DB) error {
+	if !fts5Available(db) {
+		return nil
+	}
+	var count int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'transactions_fts'").Scan(&count); err != nil || count > 0 {
+		return err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	if err := createSearchIndex(tx); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
@@ -0,0 +1,56 @@
+//go:build sqlite_fts5
+
+package db
+
+import (
+	"testing"
+)
+
+// Run with go test -tags sqlite_fts5; the default build has no FTS5.
+func TestSearchIndexFollowsTransactions(t *testing.T) {
+	conn, err := InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer conn.Close()
+
+	if !fts5Available(conn) {
+		t.Fatal("expected the sqlite_fts5 build to link FTS5")
+	}
+
+	for _, statement := range []string{
+		`INSERT INTO wallets (id, user_id, name, currency) VALUES ('cash', 'user-1', 'Cash', 'THB')`,
+		`INSERT INTO jars (id, user_id, name, type) VALUES ('food', 'user-1', 'Food', 'expense')`,
+		`INSERT INTO transactions (id, user_id, amount, description, date, type, wallet_id, jar_id)
+			VALUES ('lunch', 'user-1', 12000, 'Noodles', '2026-06-01', 'expense', 'cash', 'food')`,
+	} {
+		if _, err := conn.Exec(statement); err != nil {
+			t.Fatalf("failed to seed: %v", err)
+		}
+	}
+
+	matches := func(query string) int {
+		t.Helper()
+		var count int
+		if err := conn.QueryRow("SELECT COUNT(*) FROM transactions_fts WHERE transactions_fts MATCH ?", query).Scan(&count); err != nil {
+			t.Fatalf("MATCH %q failed: %v", query, err)
+		}
+		return count
+	}
+
+	if matches("noodles") != 1 || matches("jar_name:food") != 1 || matches("wallet_name:cash") != 1 {
+		t.Error("expected a new transaction to be indexed with its jar and wallet")
+	}
+	if _, err := conn.Exec("UPDATE jars SET name = 'Meals' WHERE id = 'food'"); err != nil {
+		t.Fatalf("failed to rename jar: %v", err)
+	}
+	if matches("jar_name:food") != 0 || matches("jar_name:meals") != 1 {
+		t.Error("expected renaming a jar to reindex its transactions")
+	}
+	if _, err := conn.Exec("DELETE FROM transactions WHERE id = 'lunch'"); err != nil {
+		t.Fatalf("failed to delete transaction: %v", err)
+	}
+	if matches("noodles") != 0 {
+		t.Error("expected a deleted transaction to leave the index")
+	}
+}
@@ -17,6 +17,21 @@ func contains(s, substr string) bool {
 
 // InitDB initializes the SQLite database and runs migrations
 func InitDB(dataSourceName string) (*sql.DB, error) {
+	db, err := Open(dataSourceName)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := Migrate(db); err != nil {
+		return nil, fmt.Errorf("failed to run migrations: %w", err)
+	}
+
+	log.Println("Database migration completed successfully.")
+	return db, nil
+}
+
+// Open connects to the SQLite database without touching the schema.
+func Open(dataSourceName string) (*sql.DB, error) {
 	// Add query parameter to enable foreign keys if not already there
 	if dataSourceName != ":memory:" && !contains(dataSourceName, "_foreign_keys") {
 		if contains(dataSourceName, "?") {
@@ -35,133 +50,10 @@ func InitDB(dataSourceName string) (*sql.DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	if err := runMigrations(db); err != nil {
-		return nil, fmt.Errorf("failed to run migrations: %w", err)
-	}
-
 	return db, nil
 }
 
-func runMigrations(db *sql.DB) error {
-	schema := `
-	CREATE TABLE IF NOT EXISTS users (
-	        id TEXT PRIMARY KEY,
-	        google_sub TEXT NOT NULL UNIQUE,
-	        email TEXT NOT NULL,
-	        name TEXT NOT NULL,
-	        avatar_url TEXT,
-	        created_at DATETIME NOT NULL,
-	        updated_at DATETIME NOT NULL
-	);
-
-	CREATE TABLE IF NOT EXISTS user_sessions (
-	        id TEXT PRIMARY KEY,
-	        user_id TEXT NOT NULL,
-	        token_hash TEXT NOT NULL UNIQUE,
-	        expires_at DATETIME NOT NULL,
-	        created_at DATETIME NOT NULL,
-	        last_seen_at DATETIME NOT NULL,
-	        FOREIGN KEY(user_id) REFERENCES users(id)
-	);
-	CREATE INDEX IF NOT EXISTS idx_user_sessions_user_id ON user_sessions(user_id);
-
-	CREATE TABLE IF NOT EXISTS wallets (
-	        id TEXT PRIMARY KEY,
-	        user_id TEXT NOT NULL DEFAULT 'legacy-local-user',
-	        name TEXT NOT NULL,
-	        currency TEXT NOT NULL,
-	        balance REAL DEFAULT 0.0,
-	        type TEXT
-	);
-
-	CREATE TABLE IF NOT EXISTS jars (
-	        id TEXT PRIMARY KEY,
-	        user_id TEXT NOT NULL DEFAULT 'legacy-local-user',
-	        name TEXT NOT NULL,
-	        type TEXT NOT NULL,
-	        parent_id TEXT,
-	        wallet_id TEXT,
-	        icon TEXT,
-	        color TEXT,
-	        FOREIGN KEY(parent_id) REFERENCES jars(id),
-	        FOREIGN KEY(wallet_id) REFERENCES wallets(id)
-	);
-	CREATE TABLE IF NOT EXISTS transactions (
-	        id TEXT PRIMARY KEY,
-	        user_id TEXT NOT NULL DEFAULT 'legacy-local-user',
-	        amount REAL NOT NULL,
-	        description TEXT,
-	        date DATETIME NOT NULL,
-	        type TEXT NOT NULL,
-	        wallet_id TEXT NOT NULL,
-	        jar_id TEXT,
-	        related_transaction_id TEXT,
-	        FOREIGN KEY(wallet_id) REFERENCES wallets(id),
-	        FOREIGN KEY(jar_id) REFERENCES jars(id),
-	        FOREIGN KEY(related_transaction_id) REFERENCES transactions(id)
-	);
-	CREATE INDEX IF NOT EXISTS idx_related_transaction_id ON transactions(related_transaction_id);
-	CREATE INDEX IF NOT EXISTS idx_wallet_id ON transactions(wallet_id);
-	CREATE INDEX IF NOT EXISTS idx_jar_id ON transactions(jar_id);
-
-	CREATE TABLE IF NOT EXISTS migration_jobs (
-	        id TEXT PRIMARY KEY,
-	        user_id TEXT NOT NULL,
-	        phase TEXT NOT NULL,
-	        message TEXT,
-	        mmbak_path TEXT,
-	        xls_path TEXT,
-	        counts_json TEXT,
-	        validation_errors_json TEXT,
-	        duplicate_summary_json TEXT,
-	        can_confirm_import INTEGER NOT NULL DEFAULT 0,
-	        expires_at DATETIME,
-	        created_at DATETIME NOT NULL,
-	        updated_at DATETIME NOT NULL,
-	        FOREIGN KEY(user_id) REFERENCES users(id)
-	);
-	CREATE INDEX IF NOT EXISTS idx_migration_jobs_user_id ON migration_jobs(user_id);
-	CREATE INDEX IF NOT EXISTS idx_migration_jobs_phase ON migration_jobs(phase);
-
-	CREATE TABLE IF NOT EXISTS migration_source_refs (
-	        id TEXT PRIMARY KEY,
-	        user_id TEXT NOT NULL,
-	        source_system TEXT NOT NULL,
-	        entity_type TEXT NOT NULL,
-	        source_id TEXT NOT NULL,
-	        fingerprint TEXT NOT NULL,
-	        display_name TEXT,
-	        imported_record_id TEXT NOT NULL,
-	        created_at DATETIME NOT NULL,
-	        FOREIGN KEY(user_id) REFERENCES users(id)
-	);
-	CREATE UNIQUE INDEX IF NOT EXISTS idx_migration_source_refs_source
-	        ON migration_source_refs(user_id, source_system, entity_type, source_id);
-	CREATE INDEX IF NOT EXISTS idx_migration_source_refs_fingerprint
-	        ON migration_source_refs(user_id, entity_type, fingerprint);
-	`
-	_, err := db.Exec(schema)
-	if err != nil {
-		return fmt.Errorf("failed to create schema: %w", err)
-	}
-
-	if err := ensureLegacyUser(db); err != nil {
-		return fmt.Errorf("failed to ensure legacy user: %w", err)
-	}
-
-	if err := ensureUserOwnershipColumns(db); err != nil {
-		return fmt.Errorf("failed to ensure user ownership columns: %w", err)
-	}
-
-	if err := ensureUserOwnershipIndexes(db); err != nil {
-		return fmt.Errorf("failed to ensure user ownership indexes: %w", err)
-	}
-
-	log.Println("Database migration completed successfully.")
-	return nil
-}
-
-func ensureLegacyUser(db *sql.DB) error {
+func ensureLegacyUser(db *sql.Tx) error {
 	now := time.Now().UTC()
 	_, err := db.Exec(`
 		INSERT INTO users (id, google_sub, email, name, avatar_url, created_at, updated_at)
@@ -175,7 +67,7 @@ func ensureLegacyUser(db *sql.DB) error {
 	return err
 }
 
-func ensureUserOwnershipColumns(db *sql.DB) error {
+func ensureUserOwnershipColumns(db *sql.Tx) error {
 	tables := []string{"wallets", "jars", "transactions"}
 	for _, table := range tables {
 		hasColumn, err := tableHasColumn(db, table, "user_id")
@@ -199,11 +91,12 @@ func ensureUserOwnershipColumns(db *sql.DB) error {
 	return nil
 }
 
-func ensureUserOwnershipIndexes(db *sql.DB) error {
+func ensureUserOwnershipIndexes(db *sql.Tx) error {
 	statements := []string{
 		`CREATE INDEX IF NOT EXISTS idx_wallets_user_id ON wallets(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_jars_user_id ON jars(user_id)`,
 		`CREATE INDEX IF NOT EXISTS idx_transactions_user_id ON transactions(user_id)`,
+		`CREATE INDEX IF NOT EXISTS idx_transactions_user_date ON transactions(user_id, date, id)`,
 	}
 	for _, statement := range statements {
 		if _, err := db.Exec(statement); err != nil {
@@ -213,7 +106,104 @@ func ensureUserOwnershipIndexes(db *sql.DB) error {
 	return nil
 }
 
-func tableHasColumn(db *sql.DB, tableName, columnName string) (bool, error) {
+// ensureWalletOpeningBalance upgrades databases created before balances were
+// derived from the ledger. The old static balance becomes the opening balance
+// and the cached balance is recomputed from the transactions.
+func ensureWalletOpeningBalance(tx *sql.Tx) error {
+	hasColumn, err := tableHasColumn(tx, "wallets", "opening_balance")
+	if err != nil {
+		return err
+	}
+	if hasColumn {
+		return nil
+	}
+
+	statements := []string{
+		`ALTER TABLE wallets ADD COLUMN opening_balance REAL NOT NULL DEFAULT 0.0`,
+		`UPDATE wallets SET opening_balance = COALESCE(balance, 0)`,
+		`UPDATE wallets
+		SET balance = opening_balance + COALESCE((
+			SELECT SUM(CASE type WHEN 'income' THEN ABS(amount) WHEN 'expense' THEN -ABS(amount) ELSE amount END)
+			FROM transactions WHERE transactions.wallet_id = wallets.id
+		), 0)`,
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// convertMoneyColumn rewrites a REAL amount column as INTEGER minor units
+// (hundredths), rounding each stored value to the nearest cent. The rounding
+// is done on the shortest decimal form of each value (models.MoneyFromFloat)
+// rather than by SQLite's ROUND, since 1.005 * 100 is 100.4999... as a float
+// and would lose a cent.
+func convertMoneyColumn(tx *sql.Tx, tableName, columnName string) error {
+	scratch := columnName + "_minor"
+	if _, err := tx.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s INTEGER NOT NULL DEFAULT 0", tableName, scratch)); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(fmt.Sprintf("SELECT rowid, %s FROM %s WHERE %s IS NOT NULL", columnName, tableName, columnName))
+	if err != nil {
+		return err
+	}
+	type amount struct {
+		rowID int64
+		value float64
+	}
+	var amounts []amount
+	for rows.Next() {
+		var a amount
+		if err := rows.Scan(&a.rowID, &a.value); err != nil {
+			rows.Close()
+			return err
+		}
+		amounts = append(amounts, a)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	update, err := tx.Prepare(fmt.Sprintf("UPDATE %s SET %s = ? WHERE rowid = ?", tableName, scratch))
+	if err != nil {
+		return err
+	}
+	defer update.Close()
+	for _, a := range amounts {
+		if _, err := update.Exec(int64(models.MoneyFromFloat(a.value)), a.rowID); err != nil {
+			return err
+		}
+	}
+
+	statements := []string{
+		fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s", tableName, columnName),
+		fmt.Sprintf("ALTER TABLE %s RENAME COLUMN %s TO %s", tableName, scratch, columnName),
+	}
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func ensureColumn(db *sql.Tx, tableName, columnName, definition string) error {
+	hasColumn, err := tableHasColumn(db, tableName, columnName)
+	if err != nil {
+		return err
+	}
+	if hasColumn {
+		return nil
+	}
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", tableName, columnName, definition))
+	return err
+}
+
+func tableHasColumn(db *sql.Tx, tableName, columnName string) (bool, error) {
 	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", tableName))
 	if err != nil {
 		return false, err
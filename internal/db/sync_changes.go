@@ -0,0 +1,82 @@
+package db
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// syncChangeSQL records the latest change of the rows the {{rows}} query
+// selects as (user_id, id). Deleting the previous entry first gives each
+// change a fresh version from the AUTOINCREMENT key, so the feed keeps one
+// entry per entity and deletions stay behind as tombstones.
+const syncChangeSQL = `
+		DELETE FROM sync_changes WHERE entity = '{{entity}}' AND entity_id IN (SELECT id FROM ({{rows}}));
+		INSERT INTO sync_changes (user_id, entity, entity_id, op, changed_at)
+			SELECT user_id, '{{entity}}', id, '{{op}}', strftime('%Y-%m-%d %H:%M:%f', 'now') FROM ({{rows}});`
+
+// syncTriggers feed sync_changes from every write to the synced tables,
+// including cascades and imports that bypass the services. Splits and tag
+// links count as changes of their transaction while it exists. The cached
+// wallet balance is left out: it follows from the transactions, and counting
+// it would make every transaction conflict with offline edits of its wallet.
+var syncTriggers = []struct {
+	name, event, entity, op, rows string
+}{
+	{"wallets_sync_insert", "AFTER INSERT ON wallets", "wallet", "upsert", "SELECT NEW.user_id AS user_id, NEW.id AS id"},
+	{"wallets_sync_update", "AFTER UPDATE OF name, currency, type, opening_balance, archived_at ON wallets", "wallet", "upsert", "SELECT NEW.user_id AS user_id, NEW.id AS id"},
+	{"wallets_sync_delete", "AFTER DELETE ON wallets", "wallet", "delete", "SELECT OLD.user_id AS user_id, OLD.id AS id"},
+	{"jars_sync_insert", "AFTER INSERT ON jars", "jar", "upsert", "SELECT NEW.user_id AS user_id, NEW.id AS id"},
+	{"jars_sync_update", "AFTER UPDATE ON jars", "jar", "upsert", "SELECT NEW.user_id AS user_id, NEW.id AS id"},
+	{"jars_sync_delete", "AFTER DELETE ON jars", "jar", "delete", "SELECT OLD.user_id AS user_id, OLD.id AS id"},
+	{"transactions_sync_insert", "AFTER INSERT ON transactions", "transaction", "upsert", "SELECT NEW.user_id AS user_id, NEW.id AS id"},
+	{"transactions_sync_update", "AFTER UPDATE ON transactions", "transaction", "upsert", "SELECT NEW.user_id AS user_id, NEW.id AS id"},
+	{"transactions_sync_delete", "AFTER DELETE ON transactions", "transaction", "delete", "SELECT OLD.user_id AS user_id, OLD.id AS id"},
+	{"transaction_splits_sync_insert", "AFTER INSERT ON transaction_splits", "transaction", "upsert",
+		"SELECT user_id, id FROM transactions WHERE id = NEW.transaction_id"},
+	{"transaction_splits_sync_update", "AFTER UPDATE ON transaction_splits", "transaction", "upsert",
+		"SELECT user_id, id FROM transactions WHERE id = NEW.transaction_id"},
+	{"transaction_splits_sync_delete", "AFTER DELETE ON transaction_splits", "transaction", "upsert",
+		"SELECT user_id, id FROM transactions WHERE id = OLD.transaction_id"},
+	{"transaction_tags_sync_insert", "AFTER INSERT ON transaction_tags", "transaction", "upsert",
+		"SELECT user_id, id FROM transactions WHERE id = NEW.transaction_id"},
+	{"transaction_tags_sync_delete", "AFTER DELETE ON transaction_tags", "transaction", "upsert",
+		"SELECT user_id, id FROM transactions WHERE id = OLD.transaction_id"},
+}
+
+func syncChangeStatement(entity, op, rows string) string {
+	return strings.NewReplacer("{{entity}}", entity, "{{op}}", op, "{{rows}}", rows).Replace(syncChangeSQL)
+}
+
+// createSyncChanges builds the change feed offline clients pull from, with
+// its triggers, and records every existing wallet, jar and transaction as
+// changed so a first pull returns the whole ledger.
+func createSyncChanges(tx *sql.Tx) error {
+	statements := []string{`
+	CREATE TABLE IF NOT EXISTS sync_changes (
+	        version INTEGER PRIMARY KEY AUTOINCREMENT,
+	        user_id TEXT NOT NULL,
+	        entity TEXT NOT NULL,
+	        entity_id TEXT NOT NULL,
+	        op TEXT NOT NULL,
+	        changed_at DATETIME NOT NULL,
+	        UNIQUE(entity, entity_id)
+	)`,
+		`CREATE INDEX IF NOT EXISTS idx_sync_changes_user_version ON sync_changes(user_id, version)`,
+	}
+	for _, trigger := range syncTriggers {
+		statements = append(statements, "CREATE TRIGGER IF NOT EXISTS "+trigger.name+" "+trigger.event+" BEGIN"+
+			syncChangeStatement(trigger.entity, trigger.op, trigger.rows)+"\n\tEND")
+	}
+	for _, table := range []struct{ name, entity string }{
+		{"wallets", "wallet"}, {"jars", "jar"}, {"transactions", "transaction"},
+	} {
+		statements = append(statements, syncChangeStatement(table.entity, "upsert", "SELECT user_id, id FROM "+table.name))
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package db
+
+import (
+	"database/sql"
+)
+
+// trashedTables can hold rows that were moved to the trash. Such rows keep
+// their place, so references stay valid and a restore only clears the mark,
+// but every read leaves them out.
+var trashedTables = []string{"wallets", "jars", "transactions", "recurring_rules"}
+
+// createTrash adds deleted_at and the deletion batch to the trashed tables
+// and the deletions table listing the batches. It also teaches the sync feed
+// and the audit log that trashing a row deletes it and restoring it brings
+// it back.
+func createTrash(tx *sql.Tx) error {
+	statements := []string{`
+	CREATE TABLE IF NOT EXISTS deletions (
+	        id TEXT PRIMARY KEY,
+	        user_id TEXT NOT NULL,
+	        entity TEXT NOT NULL,
+	        entity_id TEXT NOT NULL,
+	        name TEXT NOT NULL DEFAULT '',
+	        deleted_at DATETIME NOT NULL
+	)`,
+		`CREATE INDEX IF NOT EXISTS idx_deletions_user ON deletions(user_id, deleted_at)`,
+		`CREATE INDEX IF NOT EXISTS idx_deletions_deleted_at ON deletions(deleted_at)`,
+	}
+	for _, table := range trashedTables {
+		if err := ensureColumn(tx, table, "deleted_at", "DATETIME"); err != nil {
+			return err
+		}
+		if err := ensureColumn(tx, table, "deletion_id", "TEXT"); err != nil {
+			return err
+		}
+		statements = append(statements, "CREATE INDEX IF NOT EXISTS idx_"+table+"_deletion ON "+table+"(deletion_id)")
+	}
+
+	// Trashed wallets, jars and transactions leave the sync feed as deletes
+	// and come back as upserts when restored.
+	statements = append(statements,
+		`DROP TRIGGER IF EXISTS wallets_sync_update`,
+		`DROP TRIGGER IF EXISTS jars_sync_update`,
+		`DROP TRIGGER IF EXISTS transactions_sync_update`,
+	)
+	for _, trigger := range []struct{ name, event, entity, op string }{
+		{"wallets_sync_update", "AFTER UPDATE OF name, currency, type, opening_balance, archived_at, deleted_at ON wallets WHEN NEW.deleted_at IS NULL", "wallet", "upsert"},
+		{"wallets_sync_trash", "AFTER UPDATE OF deleted_at ON wallets WHEN NEW.deleted_at IS NOT NULL", "wallet", "delete"},
+		{"jars_sync_update", "AFTER UPDATE ON jars WHEN NEW.deleted_at IS NULL", "jar", "upsert"},
+		{"jars_sync_trash", "AFTER UPDATE OF deleted_at ON jars WHEN NEW.deleted_at IS NOT NULL", "jar", "delete"},
+		{"transactions_sync_update", "AFTER UPDATE ON transactions WHEN NEW.deleted_at IS NULL", "transaction", "upsert"},
+		{"transactions_sync_trash", "AFTER UPDATE OF deleted_at ON transactions WHEN NEW.deleted_at IS NOT NULL", "transaction", "delete"},
+	} {
+		statements = append(statements, "CREATE TRIGGER IF NOT EXISTS "+trigger.name+" "+trigger.event+" BEGIN"+
+			syncChangeStatement(trigger.entity, trigger.op, "SELECT NEW.user_id AS user_id, NEW.id AS id")+"\n\tEND")
+	}
+
+	for _, t := range auditedTables {
+		if !isTrashedTable(t.table) {
+			continue
+		}
+		statements = append(statements,
+			"CREATE TRIGGER IF NOT EXISTS "+t.table+"_audit_trash AFTER UPDATE OF deleted_at ON "+t.table+
+				" WHEN OLD.deleted_at IS NULL AND NEW.deleted_at IS NOT NULL BEGIN"+
+				auditEntryStatement(t.entity, t.id, t.owner, "trash", auditSnapshot(t.columns, "OLD"), "NULL", "NEW")+"\n\tEND",
+			"CREATE TRIGGER IF NOT EXISTS "+t.table+"_audit_restore AFTER UPDATE OF deleted_at ON "+t.table+
+				" WHEN OLD.deleted_at IS NOT NULL AND NEW.deleted_at IS NULL BEGIN"+
+				auditEntryStatement(t.entity, t.id, t.owner, "restore", "NULL", auditSnapshot(t.columns, "NEW"), "NEW")+"\n\tEND",
+		)
+	}
+
+	for _, statement := range statements {
+		if _, err := tx.Exec(statement); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func isTrashedTable(table string) bool {
+	for _, trashed := range trashedTables {
+		if trashed == table {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"fmt"
 	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
 	"jarwise-backend/internal/validator"
 	"log"
 	"time"
@@ -65,8 +66,8 @@ func (i *Importer) ImportData(data *models.ParsedData) error {
 	for _, wallet := range wallets {
 		wallet.UserID = models.DefaultLocalUserID
 		if _, err := tx.Exec(
-			`INSERT INTO wallets (id, user_id, name, currency, balance, type) VALUES (?, ?, ?, ?, ?, ?)`,
-			wallet.ID, wallet.UserID, wallet.Name, wallet.Currency, wallet.Balance, wallet.Type,
+			`INSERT INTO wallets (id, user_id, name, currency, opening_balance, balance, type) VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			wallet.ID, wallet.UserID, wallet.Name, wallet.Currency, wallet.OpeningBalance, wallet.Balance, wallet.Type,
 		); err != nil {
 			return fmt.Errorf("failed to insert wallet %s: %w", wallet.ID, err)
 		}
@@ -100,6 +101,10 @@ func (i *Importer) ImportData(data *models.ParsedData) error {
 		}
 	}
 
+	if err := repository.RefreshWalletBalances(tx, models.DefaultLocalUserID); err != nil {
+		return fmt.Errorf("failed to refresh wallet balances: %w", err)
+	}
+
 	if err := tx.Commit(); err != nil {
 		return fmt.Errorf("failed to commit import transaction: %w", err)
 	}
@@ -137,11 +142,12 @@ func mapWallets(mmAccounts []models.AccountDTO) []models.Wallet {
 	var result []models.Wallet
 	for _, acc := range mmAccounts {
 		result = append(result, models.Wallet{
-			ID:       acc.ID, // Keep original ID for mapping logic? Or generate new UUID?
-			Name:     acc.Name,
-			Currency: acc.Currency,
-			Balance:  acc.Balance,
-			Type:     "general", // Default
+			ID:             acc.ID, // Keep original ID for mapping logic? Or generate new UUID?
+			Name:           acc.Name,
+			Currency:       acc.Currency,
+			OpeningBalance: acc.Balance,
+			Balance:        acc.Balance,
+			Type:           "general", // Default
 		})
 	}
 	return result
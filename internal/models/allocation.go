@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// AllocationRule sends a percentage of every recorded income to a jar.
+type AllocationRule struct {
+	JarID   string  `json:"jar_id"`
+	Percent float64 `json:"percent"`
+}
+
+// JarAllocation is the share of one income transaction credited to a jar.
+type JarAllocation struct {
+	TransactionID string    `json:"transaction_id"`
+	JarID         string    `json:"jar_id"`
+	Percent       float64   `json:"percent"`
+	Amount        Money     `json:"amount"`
+	Date          time.Time `json:"date"`
+}
+
+// JarBalance is the virtual balance of a jar: income allocated to it minus
+// the expenses filed under it. Sub-jars are rolled up into their parents.
+type JarBalance struct {
+	JarID     string  `json:"jar_id"`
+	Name      string  `json:"name"`
+	ParentID  string  `json:"parent_id,omitempty"`
+	Percent   float64 `json:"percent"` // Current allocation rule, 0 when the jar has none
+	Allocated Money   `json:"allocated"`
+	Spent     Money   `json:"spent"`
+	Balance   Money   `json:"balance"`
+}
@@ -0,0 +1,18 @@
+package models
+
+import "time"
+
+// Attachment is a file, such as a photo of a receipt, stored with a
+// transaction.
+type Attachment struct {
+	ID            string    `json:"id"`
+	UserID        string    `json:"user_id,omitempty"`
+	TransactionID string    `json:"transaction_id"`
+	FileName      string    `json:"file_name"`
+	ContentType   string    `json:"content_type"`
+	Size          int64     `json:"size"`
+	CreatedAt     time.Time `json:"created_at"`
+
+	// StorageKey locates the content in the attachment storage.
+	StorageKey string `json:"-"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Where an audited write came from.
+const (
+	AuditSourceAPI       = "api"
+	AuditSourceRecurring = "recurring"
+	AuditSourceImport    = "import"
+	// AuditSourceSystem marks writes nobody claimed, such as cleanup jobs.
+	AuditSourceSystem = "system"
+
+	AuditOpCreate = "create"
+	AuditOpUpdate = "update"
+	AuditOpDelete = "delete"
+	// AuditOpTrash and AuditOpRestore mark rows moved to and back from the
+	// trash by a cascading wallet delete.
+	AuditOpTrash   = "trash"
+	AuditOpRestore = "restore"
+)
+
+// AuditActor is who made a change. SessionID is empty for background jobs
+// that are not run on behalf of a session.
+type AuditActor struct {
+	UserID    string `json:"user_id,omitempty"`
+	SessionID string `json:"session_id,omitempty"`
+	Source    string `json:"source"`
+}
+
+// AuditEntry records one write to one row. Before is empty for creates and
+// After for deletes; both hold the row's columns as stored, with amounts in
+// minor units.
+type AuditEntry struct {
+	ID        int64           `json:"id"`
+	Actor     AuditActor      `json:"actor"`
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entity_id"`
+	Op        string          `json:"op"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// AuditFilter narrows the audit log. Before is the cursor of the previous
+// page; zero starts from the newest entry.
+type AuditFilter struct {
+	Entity   string
+	EntityID string
+	Before   int64
+	Limit    int
+}
+
+// AuditPage lists entries newest first. Cursor is the Before of the next
+// page.
+type AuditPage struct {
+	Entries []AuditEntry `json:"entries"`
+	Cursor  int64        `json:"cursor,omitempty"`
+	HasMore bool         `json:"has_more"`
+}
@@ -0,0 +1,63 @@
+package models
+
+import "time"
+
+type BudgetPeriod string
+
+const (
+	BudgetPeriodWeekly  BudgetPeriod = "weekly"
+	BudgetPeriodMonthly BudgetPeriod = "monthly"
+	BudgetPeriodYearly  BudgetPeriod = "yearly"
+)
+
+// BudgetRollover decides what happens to the difference between a period's
+// allowance and its spending when the next period starts.
+type BudgetRollover string
+
+const (
+	// BudgetRolloverNone starts every period from the budget amount.
+	BudgetRolloverNone BudgetRollover = "none"
+	// BudgetRolloverUnderspend carries unspent money forward but forgives overspending.
+	BudgetRolloverUnderspend BudgetRollover = "underspend"
+	// BudgetRolloverFull carries both unspent money and overspending forward.
+	BudgetRolloverFull BudgetRollover = "full"
+)
+
+// Budget caps the spending of a jar (including its sub-jars) per period. The
+// amount is in the owner's base currency; spending in other currencies is
+// converted into it.
+type Budget struct {
+	ID        string         `json:"id"`
+	UserID    string         `json:"user_id,omitempty"`
+	JarID     string         `json:"jar_id"`
+	Period    BudgetPeriod   `json:"period"`
+	Amount    Money          `json:"amount"`
+	Rollover  BudgetRollover `json:"rollover"`
+	StartDate time.Time      `json:"start_date"` // Rollover is accumulated from the period containing this date
+	CreatedAt time.Time      `json:"created_at"`
+}
+
+// BudgetStatus is a budget evaluated for the period containing a given date.
+type BudgetStatus struct {
+	Budget
+	Currency    string    `json:"currency"` // The base currency the figures are in
+	PeriodStart time.Time `json:"period_start"`
+	PeriodEnd   time.Time `json:"period_end"`
+	CarriedOver Money     `json:"carried_over"`
+	Available   Money     `json:"available"` // Amount plus CarriedOver
+	Spent       Money     `json:"spent"`
+	Remaining   Money     `json:"remaining"`
+	Percent     float64   `json:"percent"`   // Spent as a percentage of Available
+	Overspent   bool      `json:"overspent"` // Spent exceeds Available
+	// MissingRates lists currencies left out of Spent for lack of a rate.
+	MissingRates []string `json:"missing_rates,omitempty"`
+}
+
+// BudgetSpending is the net spending of a budget's jars in one currency on
+// one day of a period. Refunds count on the day of the expense they refund.
+type BudgetSpending struct {
+	Period   int // Index of the period in the bounds that were queried
+	Currency string
+	Date     time.Time
+	Amount   Money
+}
@@ -1,48 +1,55 @@
 package models
 
 // ChartData เป็น response struct สำหรับ chart API ที่รวมข้อมูลทั้งหมดที่ frontend ต้องการ
+// ตัวเลขทุกตัวแปลงเป็น BaseCurrency ตามอัตราแลกเปลี่ยน ณ วันที่ของแต่ละ transaction
 type ChartData struct {
-	Summary    ChartSummary     `json:"summary"`
-	Trend      []TrendPoint     `json:"trend"`
-	ByCategory []CategoryAmount `json:"by_category"`
-	ByJar      []JarAmount      `json:"by_jar"`
-	Comparison *ComparisonData  `json:"comparison,omitempty"`
+	BaseCurrency string           `json:"base_currency"`
+	Summary      ChartSummary     `json:"summary"`
+	Trend        []TrendPoint     `json:"trend"`
+	ByCategory   []CategoryAmount `json:"by_category"`
+	ByJar        []JarAmount      `json:"by_jar"`
+	Comparison   *ComparisonData  `json:"comparison,omitempty"`
+
+	// ByCurrency ยอดรวมดิบแยกตามสกุลเงิน (ไม่แปลงค่า)
+	ByCurrency []CurrencyTotal `json:"by_currency"`
+	// MissingRates สกุลเงินที่ไม่มีอัตราแลกเปลี่ยน จึงไม่ถูกรวมในตัวเลขที่แปลงแล้ว
+	MissingRates []string `json:"missing_rates,omitempty"`
 }
 
 // ChartSummary สรุปรายรับ-รายจ่ายรวม
 type ChartSummary struct {
-	Income  float64 `json:"income"`
-	Expense float64 `json:"expense"`
-	Net     float64 `json:"net"`
+	Income  Money `json:"income"`
+	Expense Money `json:"expense"`
+	Net     Money `json:"net"`
 }
 
 // TrendPoint ข้อมูล 1 จุดบน Line chart (แต่ละช่วงเวลา)
 type TrendPoint struct {
-	Date    string  `json:"date"`
-	Income  float64 `json:"income"`
-	Expense float64 `json:"expense"`
+	Date    string `json:"date"`
+	Income  Money  `json:"income"`
+	Expense Money  `json:"expense"`
 }
 
 // CategoryAmount ข้อมูลรายจ่ายแยกตาม Category/Jar
 type CategoryAmount struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Amount      float64 `json:"amount"` // Matches Expense for backward compatibility
-	Income      float64 `json:"income"`
-	Expense     float64 `json:"expense"`
-	PrevIncome  float64 `json:"prev_income"`
-	PrevExpense float64 `json:"prev_expense"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Amount      Money  `json:"amount"` // Matches Expense for backward compatibility
+	Income      Money  `json:"income"`
+	Expense     Money  `json:"expense"`
+	PrevIncome  Money  `json:"prev_income"`
+	PrevExpense Money  `json:"prev_expense"`
 }
 
 // JarAmount ข้อมูลการกระจายตัวตาม Jar
 type JarAmount struct {
-	ID          string  `json:"id"`
-	Name        string  `json:"name"`
-	Amount      float64 `json:"amount"` // Matches Expense for backward compatibility
-	Income      float64 `json:"income"`
-	Expense     float64 `json:"expense"`
-	PrevIncome  float64 `json:"prev_income"`
-	PrevExpense float64 `json:"prev_expense"`
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Amount      Money  `json:"amount"` // Matches Expense for backward compatibility
+	Income      Money  `json:"income"`
+	Expense     Money  `json:"expense"`
+	PrevIncome  Money  `json:"prev_income"`
+	PrevExpense Money  `json:"prev_expense"`
 }
 
 // ComparisonData ข้อมูลเปรียบเทียบ 2 ช่วงเวลา
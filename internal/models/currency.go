@@ -0,0 +1,50 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// DefaultBaseCurrency is the base currency of users who never picked one.
+const DefaultBaseCurrency = "THB"
+
+// ExchangeRate says that one unit of FromCurrency is worth Rate units of
+// ToCurrency from EffectiveDate until the next rate for the same pair.
+type ExchangeRate struct {
+	ID            string    `json:"id"`
+	UserID        string    `json:"user_id,omitempty"`
+	FromCurrency  string    `json:"from_currency"`
+	ToCurrency    string    `json:"to_currency"`
+	Rate          float64   `json:"rate"`
+	EffectiveDate time.Time `json:"effective_date"`
+	Source        string    `json:"source"` // "manual", "csv"
+	CreatedAt     time.Time `json:"created_at"`
+}
+
+// ExchangeRateImport is the result of importing a CSV of exchange rates.
+type ExchangeRateImport struct {
+	Imported int `json:"imported"`
+}
+
+// CurrencyTotal is the unconverted income and expense of one currency.
+type CurrencyTotal struct {
+	Currency string `json:"currency"`
+	Income   Money  `json:"income"`
+	Expense  Money  `json:"expense"`
+	Net      Money  `json:"net"`
+}
+
+// NormalizeCurrency upper-cases a currency code and reports whether it is a
+// 3-letter ISO 4217 code.
+func NormalizeCurrency(code string) (string, bool) {
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if len(code) != 3 {
+		return code, false
+	}
+	for _, c := range code {
+		if c < 'A' || c > 'Z' {
+			return code, false
+		}
+	}
+	return code, true
+}
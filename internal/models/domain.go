@@ -5,12 +5,30 @@ import "time"
 // Core Domain Models for JarWise
 
 type Wallet struct {
-	ID       string  `json:"id"`
-	UserID   string  `json:"user_id,omitempty"`
-	Name     string  `json:"name"`
-	Currency string  `json:"currency"`
-	Balance  float64 `json:"balance"`
-	Type     string  `json:"type"` // e.g. "cash", "bank", "credit_card"
+	ID             string `json:"id"`
+	UserID         string `json:"user_id,omitempty"`
+	Name           string `json:"name"`
+	Currency       string `json:"currency"`
+	OpeningBalance Money  `json:"opening_balance"`
+	Balance        Money  `json:"balance"` // Opening balance plus every transaction in the wallet
+	Type           string `json:"type"`    // e.g. "cash", "bank", "credit_card"
+
+	// Archived wallets keep their history but are hidden from pickers.
+	Archived   bool       `json:"archived"`
+	ArchivedAt *time.Time `json:"archived_at,omitempty"`
+
+	// Version goes up with every edit and is sent as the ETag.
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// WalletBalance is the balance of a wallet replayed up to a point in time.
+type WalletBalance struct {
+	WalletID       string    `json:"wallet_id"`
+	Currency       string    `json:"currency"`
+	OpeningBalance Money     `json:"opening_balance"`
+	Balance        Money     `json:"balance"`
+	AsOf           time.Time `json:"as_of"`
 }
 
 type Jar struct { // Category
@@ -22,11 +40,22 @@ type Jar struct { // Category
 	Type     string `json:"type"` // "income", "expense"
 	Icon     string `json:"icon"`
 	Color    string `json:"color"`
+
+	// Version goes up with every edit and is sent as the ETag.
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// JarNode is a jar together with its nested sub-jars.
+type JarNode struct {
+	Jar
+	Children []JarNode `json:"children"`
 }
+
 type Transaction struct {
 	ID          string    `json:"id"`
 	UserID      string    `json:"user_id,omitempty"`
-	Amount      float64   `json:"amount"`
+	Amount      Money     `json:"amount"`
 	Description string    `json:"description"`
 	Date        time.Time `json:"date"`
 	Type        string    `json:"type"` // "income", "expense", "transfer"
@@ -34,9 +63,108 @@ type Transaction struct {
 	WalletID string `json:"wallet_id"`
 	JarID    string `json:"jar_id"`
 
+	// Currency is the currency of the wallet the transaction belongs to.
+	Currency string `json:"currency,omitempty"`
+
 	// For transfer
 	ToWalletID string `json:"to_wallet_id,omitempty"`
 
 	// New field for linking (e.g., Transfer, Refund)
 	RelatedTransactionID *string `json:"related_transaction_id,omitempty"`
+
+	// Splits spread the amount over several jars. When present they sum to
+	// Amount and JarID is empty.
+	Splits []TransactionSplit `json:"splits,omitempty"`
+
+	// Tags are labels that cut across jars. Only their IDs are stored with
+	// the transaction.
+	Tags []Tag `json:"tags,omitempty"`
+
+	// Version goes up with every edit and is sent as the ETag.
+	Version   int64     `json:"version"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// IsRefund reports whether t is an income that refunds, in part or in full,
+// the expense it links to.
+func (t Transaction) IsRefund() bool {
+	return t.Type == "income" && t.RelatedTransactionID != nil
+}
+
+// TransactionSplit is the part of a transaction's amount filed under one jar.
+type TransactionSplit struct {
+	ID          string `json:"id"`
+	JarID       string `json:"jar_id"`
+	Amount      Money  `json:"amount"`
+	Description string `json:"description,omitempty"`
+}
+
+// JarAmounts lists the jars a transaction's amount is filed under, scaled to
+// amount (the transaction's amount in some other unit, such as a base
+// currency). The last split absorbs any rounding. Unsplit transactions
+// yield their own jar, which may be empty.
+func (t Transaction) JarAmounts(amount Money) []JarPortion {
+	var total Money
+	for _, split := range t.Splits {
+		total += split.Amount
+	}
+	if total == 0 {
+		return []JarPortion{{JarID: t.JarID, Amount: amount}}
+	}
+	portions := make([]JarPortion, len(t.Splits))
+	remaining := amount
+	for i, split := range t.Splits {
+		portion := remaining
+		if i < len(t.Splits)-1 {
+			portion = amount * split.Amount / total
+			remaining -= portion
+		}
+		portions[i] = JarPortion{JarID: split.JarID, Amount: portion}
+	}
+	return portions
+}
+
+// JarPortion is an amount attributed to one jar.
+type JarPortion struct {
+	JarID  string
+	Amount Money
+}
+
+// TransferRequest moves money between two wallets. DestinationAmount is what
+// arrives in the target wallet; it differs from SourceAmount only when the
+// wallets hold different currencies. A non-zero FeeAmount is charged to the
+// source wallet as an expense in FeeJarID.
+type TransferRequest struct {
+	FromWalletID      string
+	ToWalletID        string
+	SourceAmount      Money
+	DestinationAmount Money // Zero means the same as SourceAmount
+	Date              time.Time
+	Notes             string
+	FeeAmount         Money
+	FeeJarID          string
+}
+
+// TransferPatch lists the fields of a transfer to change; nil fields keep
+// their current value. A zero FeeAmount removes the fee.
+type TransferPatch struct {
+	FromWalletID      *string
+	ToWalletID        *string
+	SourceAmount      *Money
+	DestinationAmount *Money
+	Date              *time.Time
+	Notes             *string
+	FeeAmount         *Money
+	FeeJarID          *string
+}
+
+// Transfer is the set of transactions written for one TransferRequest. The
+// outgoing and incoming legs reference each other and the fee references the
+// outgoing leg, whose ID identifies the transfer.
+type Transfer struct {
+	ID                 string       `json:"id"`
+	ExpenseTransaction *Transaction `json:"expense_transaction"`
+	IncomeTransaction  *Transaction `json:"income_transaction"`
+	FeeTransaction     *Transaction `json:"fee_transaction,omitempty"`
+	Rate               float64      `json:"rate"` // Destination units per source unit
 }
@@ -1,6 +1,31 @@
 package models
 
+import "time"
+
+// GraphData is the expense history of one jar in the user's base currency.
+type GraphData struct {
+	BaseCurrency string           `json:"base_currency"`
+	Data         []GraphDataPoint `json:"data"`
+	MissingRates []string         `json:"missing_rates,omitempty"`
+}
+
 type GraphDataPoint struct {
-	Label  string  `json:"label"`
-	Amount float64 `json:"amount"`
+	Label      string           `json:"label"`
+	Amount     Money            `json:"amount"`
+	ByCurrency []CurrencyAmount `json:"by_currency"`
+}
+
+// CurrencyAmount is an unconverted amount in one currency.
+type CurrencyAmount struct {
+	Currency string `json:"currency"`
+	Amount   Money  `json:"amount"`
+}
+
+// GraphDailyTotal is one day's expenses in one currency, labelled with the
+// graph period the day belongs to.
+type GraphDailyTotal struct {
+	Label    string
+	Date     time.Time
+	Currency string
+	Amount   Money
 }
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// IdempotencyRecord remembers the response to a write sent with an
+// Idempotency-Key, so a retry gets the same response instead of repeating
+// the write. StatusCode is 0 while the first request is still running.
+// ETag and Location are the response headers a retry needs to see again.
+// LeaseToken identifies the request that claimed the key, so a request whose
+// lease lapsed cannot settle the claim of the one that took it over.
+type IdempotencyRecord struct {
+	UserID      string
+	Key         string
+	RequestHash string
+	LeaseToken  string
+	StatusCode  int
+	ContentType string
+	ETag        string
+	Location    string
+	Body        []byte
+	CreatedAt   time.Time
+	ExpiresAt   time.Time
+}
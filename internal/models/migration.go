@@ -36,11 +36,11 @@ type MigrationDuplicateSummary struct {
 }
 
 type MigrationJobCounts struct {
-	Wallets      int     `json:"wallets"`
-	Jars         int     `json:"jars"`
-	Transactions int     `json:"transactions"`
-	TotalIncome  float64 `json:"totalIncome"`
-	TotalExpense float64 `json:"totalExpense"`
+	Wallets      int   `json:"wallets"`
+	Jars         int   `json:"jars"`
+	Transactions int   `json:"transactions"`
+	TotalIncome  Money `json:"totalIncome"`
+	TotalExpense Money `json:"totalExpense"`
 }
 
 type MigrationJobStatusResponse struct {
@@ -72,11 +72,11 @@ type MigrationJob struct {
 
 // MigrationStats holds counts of imported items
 type MigrationStats struct {
-	Wallets      int     `json:"wallets"`
-	Jars         int     `json:"jars"`
-	Transactions int     `json:"transactions"`
-	TotalIncome  float64 `json:"total_income"`
-	TotalExpense float64 `json:"total_expense"`
+	Wallets      int   `json:"wallets"`
+	Jars         int   `json:"jars"`
+	Transactions int   `json:"transactions"`
+	TotalIncome  Money `json:"total_income"`
+	TotalExpense Money `json:"total_expense"`
 }
 
 // MigrationRequests holds the uploaded files
@@ -2,10 +2,10 @@ package models
 
 // AccountDTO represents a wallet/account in Money Manager
 type AccountDTO struct {
-	ID       string  `json:"id"`
-	Name     string  `json:"name"`
-	Currency string  `json:"currency"`
-	Balance  float64 `json:"balance"` // Initial or calculated
+	ID       string `json:"id"`
+	Name     string `json:"name"`
+	Currency string `json:"currency"`
+	Balance  Money  `json:"balance"` // Initial or calculated
 }
 
 // CategoryDTO represents a category in Money Manager
@@ -18,14 +18,14 @@ type CategoryDTO struct {
 
 // TransactionDTO represents a transaction record
 type TransactionDTO struct {
-	ID          string  `json:"id"`
-	Date        string  `json:"date"` // YYYY-MM-DD
-	Amount      float64 `json:"amount"`
-	Type        int     `json:"type"`
-	CategoryID  string  `json:"category_id"`
-	AccountID   string  `json:"account_id"`
-	ToAccountID string  `json:"to_account_id"` // For transfers
-	Note        string  `json:"note"`
+	ID          string `json:"id"`
+	Date        string `json:"date"` // YYYY-MM-DD
+	Amount      Money  `json:"amount"`
+	Type        int    `json:"type"`
+	CategoryID  string `json:"category_id"`
+	AccountID   string `json:"account_id"`
+	ToAccountID string `json:"to_account_id"` // For transfers
+	Note        string `json:"note"`
 }
 
 // ParsedData holds all extracted data from the mmbak file
@@ -33,6 +33,6 @@ type ParsedData struct {
 	Accounts     []AccountDTO     `json:"accounts"`
 	Categories   []CategoryDTO    `json:"categories"`
 	Transactions []TransactionDTO `json:"transactions"`
-	TotalIncome  float64          `json:"total_income"`
-	TotalExpense float64          `json:"total_expense"`
+	TotalIncome  Money            `json:"total_income"`
+	TotalExpense Money            `json:"total_expense"`
 }
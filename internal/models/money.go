@@ -0,0 +1,167 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// MinorUnitsPerMajor is the number of minor units in one major unit. JarWise
+// keeps every currency to two decimal places (satang, cents), the precision
+// Money Manager exports.
+const MinorUnitsPerMajor = 100
+
+// Money is an exact amount of money in minor units. Arithmetic on Money never
+// loses a cent.
+//
+// Money deliberately carries no currency. Every amount belongs to a wallet and
+// the wallet's currency is the single source of truth for it: rows store the
+// bare integer, Wallet.Currency and Transaction.Currency (read from the
+// wallet) name the currency, and totals across wallets are converted by the
+// currency service first. A currency on each value would be a second copy
+// that could disagree with the wallet's.
+//
+// On the wire Money is a plain JSON number such as 1234.5, so API clients keep
+// sending and receiving decimal amounts.
+type Money int64
+
+var ErrInvalidMoney = errors.New("invalid money amount")
+
+// maxMoneyExponent bounds the exponent ParseMoney accepts. An int64 of minor
+// units has at most 19 digits, so no larger shift yields a valid amount.
+const maxMoneyExponent = 20
+
+// MoneyFromFloat converts a decimal amount, such as a value parsed from a
+// Money Manager backup, to Money. It uses the shortest decimal form of the
+// float, so 0.1+0.2 becomes 0.30 rather than drifting a cent.
+func MoneyFromFloat(value float64) Money {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return 0
+	}
+	money, err := ParseMoney(strconv.FormatFloat(value, 'f', -1, 64))
+	if err != nil {
+		return Money(math.Round(value * MinorUnitsPerMajor))
+	}
+	return money
+}
+
+// ParseMoney parses a decimal string such as "-12.345" exactly. Digits past
+// the second decimal place are rounded half away from zero.
+func ParseMoney(value string) (Money, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, ErrInvalidMoney
+	}
+
+	negative := false
+	switch value[0] {
+	case '-':
+		negative = true
+		value = value[1:]
+	case '+':
+		value = value[1:]
+	}
+
+	mantissa, exponent := value, 0
+	if i := strings.IndexAny(value, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(value[i+1:])
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, value)
+		}
+		// The digits are padded out to the decimal point below, so bound the
+		// exponent before a tiny string can ask for gigabytes of zeros.
+		if exp > maxMoneyExponent || exp < -maxMoneyExponent {
+			return 0, fmt.Errorf("%w: %q is out of range", ErrInvalidMoney, value)
+		}
+		mantissa, exponent = value[:i], exp
+	}
+
+	whole, fraction := mantissa, ""
+	if i := strings.IndexByte(mantissa, '.'); i >= 0 {
+		whole, fraction = mantissa[:i], mantissa[i+1:]
+	}
+	if whole == "" && fraction == "" {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, value)
+	}
+	digits := whole + fraction
+	for _, r := range digits {
+		if r < '0' || r > '9' {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidMoney, value)
+		}
+	}
+
+	// Shift the decimal point so that digits[:point] is the whole number of
+	// minor units.
+	point := len(whole) + exponent + 2
+	if point < 0 {
+		return 0, nil
+	}
+	if point > len(digits) {
+		digits += strings.Repeat("0", point-len(digits))
+	}
+	minor := strings.TrimLeft(digits[:point], "0")
+	roundUp := point < len(digits) && digits[point] >= '5'
+
+	var units int64
+	if minor != "" {
+		parsed, err := strconv.ParseInt(minor, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q is out of range", ErrInvalidMoney, value)
+		}
+		units = parsed
+	}
+	if roundUp {
+		units++
+	}
+	if negative {
+		units = -units
+	}
+	return Money(units), nil
+}
+
+// Float64 returns the amount in major units. Use it only for display or for
+// ratios, never to add amounts up.
+func (m Money) Float64() float64 {
+	return float64(m) / MinorUnitsPerMajor
+}
+
+func (m Money) Abs() Money {
+	if m < 0 {
+		return -m
+	}
+	return m
+}
+
+// String formats the amount in major units with two decimals, e.g. "-12.30".
+func (m Money) String() string {
+	sign := ""
+	units := int64(m)
+	if units < 0 {
+		sign = "-"
+		units = -units
+	}
+	return fmt.Sprintf("%s%d.%02d", sign, units/MinorUnitsPerMajor, units%MinorUnitsPerMajor)
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	return []byte(m.String()), nil
+}
+
+// UnmarshalJSON accepts a JSON number or a numeric string.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := string(data)
+	if text == "null" {
+		return nil
+	}
+	if unquoted, err := strconv.Unquote(text); err == nil {
+		text = unquoted
+	}
+	parsed, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = parsed
+	return nil
+}
@@ -0,0 +1,103 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"runtime"
+	"testing"
+)
+
+func TestParseMoney(t *testing.T) {
+	cases := []struct {
+		in   string
+		want Money
+	}{
+		{"0", 0},
+		{"12", 1200},
+		{"12.3", 1230},
+		{"-12.30", -1230},
+		{"+0.07", 7},
+		{".5", 50},
+		{"100.005", 10001},
+		{"100.004", 10000},
+		{"-0.015", -2},
+		{"1.5e3", 150000},
+		{"125e-2", 125},
+		{"1e16", 1000000000000000000},
+		{"5e-3", 1},
+	}
+	for _, tc := range cases {
+		got, err := ParseMoney(tc.in)
+		if err != nil {
+			t.Errorf("ParseMoney(%q) failed: %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("ParseMoney(%q) = %d, want %d", tc.in, got, tc.want)
+		}
+	}
+
+	for _, bad := range []string{"", "-", ".", "12,50", "1e", "abc", "99999999999999999999"} {
+		if _, err := ParseMoney(bad); !errors.Is(err, ErrInvalidMoney) {
+			t.Errorf("ParseMoney(%q) expected ErrInvalidMoney, got %v", bad, err)
+		}
+	}
+}
+
+func TestMoneyFromFloat_DoesNotDrift(t *testing.T) {
+	if got := MoneyFromFloat(0.1 + 0.2); got != 30 {
+		t.Errorf("expected 0.1+0.2 to be 30 minor units, got %d", got)
+	}
+	if got := MoneyFromFloat(-100.50); got != -10050 {
+		t.Errorf("expected -100.50 to be -10050 minor units, got %d", got)
+	}
+
+	// Ten thousand 0.10 expenses add up to exactly 1000.00.
+	var total Money
+	for i := 0; i < 10000; i++ {
+		total += MoneyFromFloat(0.1)
+	}
+	if total != 100000 {
+		t.Errorf("expected exact total 1000.00, got %s", total)
+	}
+}
+
+func TestMoney_JSON(t *testing.T) {
+	var payload struct {
+		Amount Money  `json:"amount"`
+		Quoted Money  `json:"quoted"`
+		Empty  *Money `json:"empty"`
+	}
+	if err := json.Unmarshal([]byte(`{"amount": 1234.5, "quoted": "-0.99", "empty": null}`), &payload); err != nil {
+		t.Fatalf("Unmarshal failed: %v", err)
+	}
+	if payload.Amount != 123450 || payload.Quoted != -99 || payload.Empty != nil {
+		t.Fatalf("unexpected decode: %+v", payload)
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+	if string(data) != `{"amount":1234.50,"quoted":-0.99,"empty":null}` {
+		t.Errorf("unexpected encode: %s", data)
+	}
+
+	if err := json.Unmarshal([]byte(`{"amount": "lots"}`), &payload); !errors.Is(err, ErrInvalidMoney) {
+		t.Errorf("expected ErrInvalidMoney, got %v", err)
+	}
+}
+
+func TestParseMoney_HugeExponentIsRefusedCheaply(t *testing.T) {
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	for _, in := range []string{"1e300000000", "-1e-300000000"} {
+		if _, err := ParseMoney(in); !errors.Is(err, ErrInvalidMoney) {
+			t.Errorf("ParseMoney(%q) expected ErrInvalidMoney, got %v", in, err)
+		}
+	}
+	runtime.ReadMemStats(&after)
+	if allocated := after.TotalAlloc - before.TotalAlloc; allocated > 1<<20 {
+		t.Errorf("expected huge exponents to be refused before padding, allocated %d bytes", allocated)
+	}
+}
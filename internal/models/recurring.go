@@ -0,0 +1,57 @@
+package models
+
+import "time"
+
+type RecurringFrequency string
+
+const (
+	RecurringDaily   RecurringFrequency = "daily"
+	RecurringWeekly  RecurringFrequency = "weekly"
+	RecurringMonthly RecurringFrequency = "monthly"
+)
+
+type OccurrenceStatus string
+
+const (
+	OccurrenceScheduled    OccurrenceStatus = "scheduled"
+	OccurrenceSkipped      OccurrenceStatus = "skipped"
+	OccurrenceMaterialized OccurrenceStatus = "materialized"
+)
+
+// RecurringRule describes a repeating income or expense such as rent, a
+// subscription or a salary. Occurrences repeat every Interval days, weeks or
+// months from StartDate until EndDate or until Count occurrences have passed.
+type RecurringRule struct {
+	ID          string             `json:"id"`
+	UserID      string             `json:"user_id,omitempty"`
+	WalletID    string             `json:"wallet_id"`
+	JarID       string             `json:"jar_id,omitempty"`
+	Type        string             `json:"type"` // "income", "expense"
+	Amount      Money              `json:"amount"`
+	Description string             `json:"description"`
+	Frequency   RecurringFrequency `json:"frequency"`
+	Interval    int                `json:"interval"`
+	DayOfMonth  int                `json:"day_of_month,omitempty"` // Monthly only; clamped to the last day of shorter months
+	StartDate   time.Time          `json:"start_date"`
+	EndDate     *time.Time         `json:"end_date,omitempty"`
+	Count       *int               `json:"count,omitempty"`
+
+	// Occurrences up to and including this time have been handled. Edits to
+	// the rule only affect occurrences after it.
+	MaterializedThrough *time.Time `json:"materialized_through,omitempty"`
+	CreatedAt           time.Time  `json:"created_at"`
+	UpdatedAt           time.Time  `json:"updated_at"`
+}
+
+// RecurringOccurrence is one scheduled date of a rule.
+type RecurringOccurrence struct {
+	RuleID        string           `json:"rule_id"`
+	Date          time.Time        `json:"date"`
+	Type          string           `json:"type"`
+	Amount        Money            `json:"amount"`
+	Description   string           `json:"description"`
+	WalletID      string           `json:"wallet_id"`
+	JarID         string           `json:"jar_id,omitempty"`
+	Status        OccurrenceStatus `json:"status"`
+	TransactionID string           `json:"transaction_id,omitempty"`
+}
@@ -8,20 +8,56 @@ type ReportFilter struct {
 	EndDate   time.Time `json:"end_date"`
 	JarIDs    []string  `json:"jar_ids"`
 	WalletIDs []string  `json:"wallet_ids"`
+	// TagIDs keeps transactions carrying at least one of the tags.
+	TagIDs []string `json:"tag_ids"`
 }
 
 // Report represents aggregated report data with the applied filter.
 // It reuses Common chart models (TrendPoint, CategoryAmount, etc.) from chart.go
+// Aggregated figures are in BaseCurrency, converted at the rate effective on
+// each transaction's date; ByCurrency keeps the unconverted totals.
 type Report struct {
-	Summary    ChartSummary     `json:"summary"`
-	Trend      []TrendPoint     `json:"trend"`
-	ByCategory []CategoryAmount `json:"by_category"`
-	ByJar      []JarAmount      `json:"by_jar"`
-	Comparison *ComparisonData  `json:"comparison,omitempty"`
-	FilterUsed ReportFilter     `json:"filter_used"`
+	BaseCurrency string           `json:"base_currency"`
+	Summary      ChartSummary     `json:"summary"`
+	Trend        []TrendPoint     `json:"trend"`
+	ByCategory   []CategoryAmount `json:"by_category"`
+	ByJar        []JarAmount      `json:"by_jar"`
+	Comparison   *ComparisonData  `json:"comparison,omitempty"`
+	FilterUsed   ReportFilter     `json:"filter_used"`
+	ByCurrency   []CurrencyTotal  `json:"by_currency"`
+	ByWallet     []WalletCashFlow `json:"by_wallet"`
+	// ByTag counts a transaction under each of its tags, so the amounts
+	// can add up to more than the summary.
+	ByTag []TagAmount `json:"by_tag"`
+	// Currencies without a usable rate; their transactions are left out of
+	// the converted figures.
+	MissingRates []string `json:"missing_rates,omitempty"`
 
 	// Keep for backward compatibility during transition
-	TotalAmount      float64       `json:"total_amount"`
+	TotalAmount      Money         `json:"total_amount"`
 	TransactionCount int           `json:"transaction_count"`
 	Transactions     []Transaction `json:"transactions"`
 }
+
+// WalletCashFlow is the money that moved in and out of one wallet, in the
+// wallet's own currency. Unlike the summary it includes transfers between
+// the user's wallets.
+type WalletCashFlow struct {
+	ID           string `json:"id"`
+	Name         string `json:"name"`
+	Currency     string `json:"currency"`
+	Income       Money  `json:"income"`
+	Expense      Money  `json:"expense"`
+	TransfersIn  Money  `json:"transfers_in"`
+	TransfersOut Money  `json:"transfers_out"`
+	Net          Money  `json:"net"`
+}
+
+// TagAmount is the income and spending of the transactions carrying a tag.
+type TagAmount struct {
+	ID      string `json:"id"`
+	Name    string `json:"name"`
+	Amount  Money  `json:"amount"` // Matches Expense, like JarAmount
+	Income  Money  `json:"income"`
+	Expense Money  `json:"expense"`
+}
@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// TransactionSearch is a full-text query over transaction descriptions and
+// the names of their jars and wallets. Zero filters mean "no constraint".
+type TransactionSearch struct {
+	Query     string    `json:"query"`
+	StartDate time.Time `json:"start_date"`
+	EndDate   time.Time `json:"end_date"`
+	JarIDs    []string  `json:"jar_ids"`
+	Limit     int       `json:"limit"`
+	Offset    int       `json:"offset"`
+
+	// Terms are the words of Query, lower-cased. Each must prefix a word of
+	// the transaction's text.
+	Terms []string `json:"-"`
+}
+
+// SearchResult is one matching transaction. Score is higher for better
+// matches; it is 0 when the database has no full-text index.
+type SearchResult struct {
+	Transaction Transaction      `json:"transaction"`
+	Score       float64          `json:"score"`
+	Highlights  SearchHighlights `json:"highlights"`
+}
+
+// SearchHighlights is the searched text with matching words wrapped in
+// <mark> tags. Everything else is HTML-escaped.
+type SearchHighlights struct {
+	Description string `json:"description"`
+	JarName     string `json:"jar_name,omitempty"`
+	WalletName  string `json:"wallet_name,omitempty"`
+}
+
+// SearchPage is one offset-paginated page of search results, best first.
+type SearchPage struct {
+	Results    []SearchResult `json:"results"`
+	Total      int            `json:"total"`
+	Limit      int            `json:"limit"`
+	Offset     int            `json:"offset"`
+	HasMore    bool           `json:"has_more"`
+	NextOffset int            `json:"next_offset,omitempty"`
+}
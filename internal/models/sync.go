@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// Entities offline clients sync, and the operations on them.
+const (
+	SyncEntityWallet      = "wallet"
+	SyncEntityJar         = "jar"
+	SyncEntityTransaction = "transaction"
+
+	SyncOpUpsert = "upsert"
+	SyncOpDelete = "delete"
+)
+
+// Reasons a pushed mutation is rejected.
+const (
+	// SyncReasonConflict: the entity changed on the server after the
+	// version the client based its mutation on.
+	SyncReasonConflict = "conflict"
+	// SyncReasonDeleted: the entity was deleted on the server.
+	SyncReasonDeleted  = "deleted"
+	SyncReasonNotFound = "not_found"
+	// SyncReasonInvalid: the mutation fails validation.
+	SyncReasonInvalid = "invalid"
+	// SyncReasonInUse: the entity cannot be deleted while other records
+	// still reference it.
+	SyncReasonInUse = "in_use"
+)
+
+// SyncChange is the latest change of one entity. Version increases with
+// every change of any of the user's entities. Upserts carry the entity as
+// it is now; deletes are tombstones without data.
+type SyncChange struct {
+	Version   int64     `json:"version"`
+	Entity    string    `json:"entity"`
+	ID        string    `json:"id"`
+	Op        string    `json:"op"`
+	ChangedAt time.Time `json:"changed_at"`
+	UserID    string    `json:"-"`
+
+	Wallet      *Wallet      `json:"wallet,omitempty"`
+	Jar         *Jar         `json:"jar,omitempty"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+}
+
+// SyncChangePage lists changes oldest first. Cursor is the version to pull
+// from next.
+type SyncChangePage struct {
+	Changes []SyncChange `json:"changes"`
+	Cursor  int64        `json:"cursor"`
+	HasMore bool         `json:"has_more"`
+}
+
+// SyncMutation is one change made offline. ID is chosen by the client when
+// it creates the entity. BaseVersion is the version of the entity the
+// client last pulled, or 0 for a new entity. Upserts carry the entity in
+// the field named after it.
+type SyncMutation struct {
+	MutationID  string `json:"mutation_id"`
+	Entity      string `json:"entity"`
+	Op          string `json:"op"`
+	ID          string `json:"id"`
+	BaseVersion int64  `json:"base_version"`
+
+	Wallet      *Wallet      `json:"wallet,omitempty"`
+	Jar         *Jar         `json:"jar,omitempty"`
+	Transaction *Transaction `json:"transaction,omitempty"`
+}
+
+// SyncPushResult reports every pushed mutation as either applied or
+// rejected.
+type SyncPushResult struct {
+	Applied  []SyncApplied   `json:"applied"`
+	Rejected []SyncRejection `json:"rejected"`
+}
+
+// SyncApplied is an accepted mutation and the entity's version after it.
+type SyncApplied struct {
+	MutationID string `json:"mutation_id"`
+	Entity     string `json:"entity"`
+	ID         string `json:"id"`
+	Version    int64  `json:"version"`
+}
+
+// SyncRejection is a mutation the server refused. Current is the server's
+// latest change of the entity, when there is one, so the client can replace
+// its copy.
+type SyncRejection struct {
+	MutationID string      `json:"mutation_id"`
+	Entity     string      `json:"entity"`
+	ID         string      `json:"id"`
+	Reason     string      `json:"reason"`
+	Message    string      `json:"message"`
+	Current    *SyncChange `json:"current,omitempty"`
+}
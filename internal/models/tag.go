@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Tag is a user-defined label such as "trip-japan-2026" or "business". A
+// transaction can carry any number of tags, independent of its jar.
+type Tag struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id,omitempty"`
+	Name      string    `json:"name"`
+	Color     string    `json:"color,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
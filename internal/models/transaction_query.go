@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// TransactionListFilter describes a filtered, sorted page of transactions.
+// Zero values mean "no constraint".
+type TransactionListFilter struct {
+	StartDate   time.Time `json:"start_date"`
+	EndDate     time.Time `json:"end_date"`
+	WalletIDs   []string  `json:"wallet_ids"`
+	JarIDs      []string  `json:"jar_ids"`
+	Types       []string  `json:"types"`
+	MinAmount   *Money    `json:"min_amount,omitempty"`
+	MaxAmount   *Money    `json:"max_amount,omitempty"`
+	Description string    `json:"description,omitempty"`
+	SortBy      string    `json:"sort_by"`    // "date" or "amount"
+	SortOrder   string    `json:"sort_order"` // "asc" or "desc"
+	Limit       int       `json:"limit"`
+	Cursor      string    `json:"cursor,omitempty"`
+}
+
+// TransactionPage is one page of a cursor-paginated transaction listing.
+type TransactionPage struct {
+	Transactions []Transaction `json:"transactions"`
+	NextCursor   string        `json:"next_cursor,omitempty"`
+	HasMore      bool          `json:"has_more"`
+}
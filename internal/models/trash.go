@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// Deletion is one batch in the trash: a wallet deleted together with its
+// jars, transactions and recurring rules. The batch can be restored until
+// RestorableUntil and is purged for good afterwards.
+type Deletion struct {
+	ID              string    `json:"id"`
+	UserID          string    `json:"-"`
+	Entity          string    `json:"entity"`
+	EntityID        string    `json:"entity_id"`
+	Name            string    `json:"name"`
+	DeletedAt       time.Time `json:"deleted_at"`
+	RestorableUntil time.Time `json:"restorable_until"`
+}
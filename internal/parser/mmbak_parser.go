@@ -4,7 +4,6 @@ import (
 	"database/sql"
 	"fmt"
 	"jarwise-backend/internal/models"
-	"math"
 
 	_ "github.com/mattn/go-sqlite3"
 )
@@ -111,7 +110,7 @@ func (p *MmbakParser) Parse(filePath string) (*models.ParsedData, error) {
 		}
 
 		t.Date = dateStr.String
-		t.Amount = math.Abs(money.Float64)
+		t.Amount = models.MoneyFromFloat(money.Float64).Abs()
 		t.Note = note.String
 		t.AccountID = assetID.String
 
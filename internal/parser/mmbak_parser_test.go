@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"jarwise-backend/internal/models"
 	"path/filepath"
 	"runtime"
 	"testing"
@@ -41,15 +42,15 @@ func TestParse_ValidFile(t *testing.T) {
 	}
 
 	// Verify totals
-	expectedIncome := 50000.0
-	expectedExpense := 135.50 // 100.50 + 35.00
+	expectedIncome := models.Money(5000000)
+	expectedExpense := models.Money(13550) // 100.50 + 35.00
 
 	if result.TotalIncome != expectedIncome {
-		t.Errorf("Expected TotalIncome %.2f, got %.2f", expectedIncome, result.TotalIncome)
+		t.Errorf("Expected TotalIncome %s, got %s", expectedIncome, result.TotalIncome)
 	}
 
 	if result.TotalExpense != expectedExpense {
-		t.Errorf("Expected TotalExpense %.2f, got %.2f", expectedExpense, result.TotalExpense)
+		t.Errorf("Expected TotalExpense %s, got %s", expectedExpense, result.TotalExpense)
 	}
 }
 
@@ -252,11 +253,11 @@ func TestParse_EmptyFile_NoTransactions(t *testing.T) {
 	}
 
 	if result.TotalIncome != 0 {
-		t.Errorf("Expected TotalIncome 0, got %.2f", result.TotalIncome)
+		t.Errorf("Expected TotalIncome 0, got %s", result.TotalIncome)
 	}
 
 	if result.TotalExpense != 0 {
-		t.Errorf("Expected TotalExpense 0, got %.2f", result.TotalExpense)
+		t.Errorf("Expected TotalExpense 0, got %s", result.TotalExpense)
 	}
 }
 
@@ -336,14 +337,14 @@ func TestParse_TransferTransaction_ExcludedFromTotals(t *testing.T) {
 	}
 
 	// Transfer amount (5000) should NOT be in TotalIncome or TotalExpense
-	expectedIncome := 50000.0
-	expectedExpense := 135.50 // 100.50 + 35.00
+	expectedIncome := models.Money(5000000)
+	expectedExpense := models.Money(13550) // 100.50 + 35.00
 
 	if result.TotalIncome != expectedIncome {
-		t.Errorf("Expected TotalIncome %.2f (transfer excluded), got %.2f", expectedIncome, result.TotalIncome)
+		t.Errorf("Expected TotalIncome %s (transfer excluded), got %s", expectedIncome, result.TotalIncome)
 	}
 
 	if result.TotalExpense != expectedExpense {
-		t.Errorf("Expected TotalExpense %.2f (transfer excluded), got %.2f", expectedExpense, result.TotalExpense)
+		t.Errorf("Expected TotalExpense %s (transfer excluded), got %s", expectedExpense, result.TotalExpense)
 	}
 }
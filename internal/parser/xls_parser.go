@@ -3,7 +3,6 @@ package parser
 import (
 	"fmt"
 	"jarwise-backend/internal/models"
-	"math"
 	"os"
 	"strconv"
 	"strings"
@@ -182,7 +181,7 @@ func (p *XlsParser) processStructuredRow(cols []string, header xlsHeader) (model
 
 	return models.TransactionDTO{
 		Date:       date,
-		Amount:     math.Abs(amount),
+		Amount:     models.MoneyFromFloat(amount).Abs(),
 		Type:       txType,
 		AccountID:  valueAt(cols, header.account),
 		CategoryID: valueAt(cols, header.category),
@@ -221,7 +220,7 @@ func (p *XlsParser) processLegacyRow(cols []string) (models.TransactionDTO, bool
 
 	return models.TransactionDTO{
 		Date:       valueAt(cols, 0),
-		Amount:     math.Abs(amount),
+		Amount:     models.MoneyFromFloat(amount).Abs(),
 		Type:       txType,
 		AccountID:  valueAt(cols, 1),
 		CategoryID: valueAt(cols, 2),
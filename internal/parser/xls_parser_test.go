@@ -23,11 +23,11 @@ func TestXlsParser_ParseStructuredMoneyManagerExport(t *testing.T) {
 	if len(result.Transactions) != 3 {
 		t.Fatalf("expected 3 transactions, got %d", len(result.Transactions))
 	}
-	if result.TotalIncome != 50000 {
-		t.Fatalf("expected total income 50000, got %.2f", result.TotalIncome)
+	if result.TotalIncome != 5000000 {
+		t.Fatalf("expected total income 50000.00, got %s", result.TotalIncome)
 	}
-	if result.TotalExpense != 100.50 {
-		t.Fatalf("expected total expense 100.50, got %.2f", result.TotalExpense)
+	if result.TotalExpense != 10050 {
+		t.Fatalf("expected total expense 100.50, got %s", result.TotalExpense)
 	}
 	if result.Transactions[2].Type != 2 {
 		t.Fatalf("expected transfer row to parse as type 2, got %d", result.Transactions[2].Type)
@@ -49,11 +49,11 @@ func TestXlsParser_ParseLegacySignedRows(t *testing.T) {
 	if len(result.Transactions) != 2 {
 		t.Fatalf("expected 2 transactions, got %d", len(result.Transactions))
 	}
-	if result.TotalIncome != 50000 {
-		t.Fatalf("expected total income 50000, got %.2f", result.TotalIncome)
+	if result.TotalIncome != 5000000 {
+		t.Fatalf("expected total income 50000.00, got %s", result.TotalIncome)
 	}
-	if result.TotalExpense != 100.50 {
-		t.Fatalf("expected total expense 100.50, got %.2f", result.TotalExpense)
+	if result.TotalExpense != 10050 {
+		t.Fatalf("expected total expense 100.50, got %s", result.TotalExpense)
 	}
 }
 
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"jarwise-backend/internal/models"
+	"math"
+	"time"
+)
+
+type AllocationRepository interface {
+	ListRulesForUser(ctx context.Context, userID string) ([]models.AllocationRule, error)
+	// ReplaceRulesForUser swaps the whole rule set in one transaction.
+	ReplaceRulesForUser(ctx context.Context, userID string, rules []models.AllocationRule) error
+	// JarBalancesForUser returns, per jar, the income allocated to it and the
+	// expenses filed directly under it up to and including asOf.
+	JarBalancesForUser(ctx context.Context, userID string, asOf time.Time) ([]models.JarBalance, error)
+}
+
+type sqliteAllocationRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteAllocationRepository(db *sql.DB) AllocationRepository {
+	return &sqliteAllocationRepository{db: db}
+}
+
+func (r *sqliteAllocationRepository) ListRulesForUser(ctx context.Context, userID string) ([]models.AllocationRule, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT r.jar_id, r.percent
+		FROM allocation_rules r
+		JOIN jars j ON j.id = r.jar_id AND j.user_id = r.user_id AND j.deleted_at IS NULL
+		WHERE r.user_id = ?
+		ORDER BY r.percent DESC, r.jar_id
+	`, normalizedUserID(userID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.AllocationRule
+	for rows.Next() {
+		var rule models.AllocationRule
+		if err := rows.Scan(&rule.JarID, &rule.Percent); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (r *sqliteAllocationRepository) ReplaceRulesForUser(ctx context.Context, userID string, rules []models.AllocationRule) error {
+	userID = normalizedUserID(userID)
+
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM allocation_rules WHERE user_id = ?", userID); err != nil {
+		return err
+	}
+	for _, rule := range rules {
+		_, err := tx.ExecContext(ctx, "INSERT INTO allocation_rules (user_id, jar_id, percent) VALUES (?, ?, ?)", userID, rule.JarID, rule.Percent)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqliteAllocationRepository) JarBalancesForUser(ctx context.Context, userID string, asOf time.Time) ([]models.JarBalance, error) {
+	userID = normalizedUserID(userID)
+	asOf = asOf.UTC()
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT j.id, j.name, COALESCE(j.parent_id, ''), COALESCE(rule.percent, 0),
+			COALESCE(allocated.total, 0), COALESCE(spent.total, 0)
+		FROM jars j
+		LEFT JOIN allocation_rules rule ON rule.user_id = j.user_id AND rule.jar_id = j.id
+		LEFT JOIN (
+			SELECT jar_id, SUM(amount) AS total FROM jar_allocations
+			WHERE user_id = ? AND date <= ?
+				AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.id = jar_allocations.transaction_id AND t.deleted_at IS NOT NULL)
+			GROUP BY jar_id
+		) allocated ON allocated.jar_id = j.id
+		LEFT JOIN (
+			SELECT jar_id, SUM(ABS(amount)) AS total FROM (`+jarLinesSQL+`)
+			WHERE user_id = ? AND type = 'expense' AND jar_id IS NOT NULL AND date <= ? GROUP BY jar_id
+		) spent ON spent.jar_id = j.id
+		WHERE j.user_id = ? AND j.type != 'income' AND j.deleted_at IS NULL
+		ORDER BY j.name, j.id
+	`, userID, asOf, userID, asOf, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var balances []models.JarBalance
+	for rows.Next() {
+		var b models.JarBalance
+		if err := rows.Scan(&b.JarID, &b.Name, &b.ParentID, &b.Percent, &b.Allocated, &b.Spent); err != nil {
+			return nil, err
+		}
+		b.Balance = b.Allocated - b.Spent
+		balances = append(balances, b)
+	}
+	return balances, rows.Err()
+}
+
+// allocateIncome (re)computes the jar shares of one transaction inside the
+// caller's database transaction. Only standalone income is allocated. An
+// income that was already allocated keeps its original percentages so that
+// later rule changes do not rewrite history; otherwise the current rules apply.
+func allocateIncome(dbTx *WriteTx, t *models.Transaction) error {
+	rules, err := queryAllocationRules(dbTx, `SELECT jar_id, percent FROM jar_allocations WHERE transaction_id = ? ORDER BY percent DESC, jar_id`, t.ID)
+	if err != nil {
+		return err
+	}
+	if _, err := dbTx.Exec("DELETE FROM jar_allocations WHERE transaction_id = ?", t.ID); err != nil {
+		return err
+	}
+	if t.Type != "income" || t.RelatedTransactionID != nil {
+		return nil
+	}
+
+	if len(rules) == 0 {
+		rules, err = queryAllocationRules(dbTx, `
+			SELECT r.jar_id, r.percent
+			FROM allocation_rules r
+			JOIN jars j ON j.id = r.jar_id AND j.user_id = r.user_id AND j.deleted_at IS NULL
+			WHERE r.user_id = ?
+			ORDER BY r.percent DESC, r.jar_id
+		`, t.UserID)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, share := range splitIncome(t.Amount.Abs(), rules) {
+		_, err := dbTx.Exec(`
+			INSERT INTO jar_allocations (transaction_id, jar_id, user_id, percent, amount, date)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, t.ID, share.JarID, t.UserID, share.Percent, share.Amount, t.Date.UTC())
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func queryAllocationRules(dbTx *WriteTx, query string, args ...interface{}) ([]models.AllocationRule, error) {
+	rows, err := dbTx.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.AllocationRule
+	for rows.Next() {
+		var rule models.AllocationRule
+		if err := rows.Scan(&rule.JarID, &rule.Percent); err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// splitIncome divides amount by the rules, rounding each share to the
+// nearest minor unit. The rounding difference goes to the first (largest)
+// share so the shares always add up to the allocated total.
+func splitIncome(amount models.Money, rules []models.AllocationRule) []models.JarAllocation {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	var (
+		totalPercent float64
+		allocated    models.Money
+	)
+	shares := make([]models.JarAllocation, 0, len(rules))
+	for _, rule := range rules {
+		totalPercent += rule.Percent
+		share := models.Money(math.Round(float64(amount) * rule.Percent / 100))
+		allocated += share
+		shares = append(shares, models.JarAllocation{JarID: rule.JarID, Percent: rule.Percent, Amount: share})
+	}
+
+	target := models.Money(math.Round(float64(amount) * totalPercent / 100))
+	shares[0].Amount += target - allocated
+	return shares
+}
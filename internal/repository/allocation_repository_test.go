@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"jarwise-backend/internal/models"
+	"testing"
+	"time"
+)
+
+func TestSplitIncome_SharesAddUpToAmount(t *testing.T) {
+	rules := []models.AllocationRule{
+		{JarID: "necessities", Percent: 55},
+		{JarID: "play", Percent: 10},
+		{JarID: "education", Percent: 10},
+		{JarID: "long-term", Percent: 10},
+		{JarID: "freedom", Percent: 10},
+		{JarID: "give", Percent: 5},
+	}
+
+	shares := splitIncome(33333, rules)
+	var total models.Money
+	for _, share := range shares {
+		total += share.Amount
+	}
+	if total != 33333 {
+		t.Errorf("Expected shares to add up to 333.33, got %s (%+v)", total, shares)
+	}
+	if shares[1].Amount != 3333 {
+		t.Errorf("Expected 10%% share of 33.33, got %s", shares[1].Amount)
+	}
+}
+
+func TestAllocateIncome_OnCreateUpdateAndDelete(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	jarRepo := NewSQLiteJarRepository(dbConn)
+	txRepo := NewSQLiteTransactionRepository(dbConn)
+	allocationRepo := NewSQLiteAllocationRepository(dbConn)
+
+	if err := NewSQLiteWalletRepository(dbConn).Create(ctx, &models.Wallet{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB"}); err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	for _, id := range []string{"needs", "play"} {
+		if err := jarRepo.Create(ctx, &models.Jar{ID: id, UserID: "user-1", Name: id, Type: "jar"}); err != nil {
+			t.Fatalf("Failed to create jar: %v", err)
+		}
+	}
+	if err := allocationRepo.ReplaceRulesForUser(ctx, "user-1", []models.AllocationRule{{JarID: "needs", Percent: 80}, {JarID: "play", Percent: 20}}); err != nil {
+		t.Fatalf("ReplaceRulesForUser failed: %v", err)
+	}
+
+	date := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	salary := &models.Transaction{ID: "salary", UserID: "user-1", Amount: 1000, Date: date, Type: "income", WalletID: "wallet-1"}
+	if err := txRepo.Create(ctx, salary); err != nil {
+		t.Fatalf("Failed to create income: %v", err)
+	}
+	if err := txRepo.Create(ctx, &models.Transaction{ID: "movie", UserID: "user-1", Amount: 50, Date: date, Type: "expense", WalletID: "wallet-1", JarID: "play"}); err != nil {
+		t.Fatalf("Failed to create expense: %v", err)
+	}
+
+	balanceOf := func(jarID string) models.JarBalance {
+		t.Helper()
+		balances, err := allocationRepo.JarBalancesForUser(ctx, "user-1", date.AddDate(0, 1, 0))
+		if err != nil {
+			t.Fatalf("JarBalancesForUser failed: %v", err)
+		}
+		for _, b := range balances {
+			if b.JarID == jarID {
+				return b
+			}
+		}
+		t.Fatalf("No balance for %s", jarID)
+		return models.JarBalance{}
+	}
+
+	if play := balanceOf("play"); play.Allocated != 200 || play.Spent != 50 || play.Balance != 150 {
+		t.Errorf("Unexpected play balance: %+v", play)
+	}
+
+	// Later rule changes do not rewrite how existing income was split.
+	if err := allocationRepo.ReplaceRulesForUser(ctx, "user-1", []models.AllocationRule{{JarID: "needs", Percent: 100}}); err != nil {
+		t.Fatalf("ReplaceRulesForUser failed: %v", err)
+	}
+	salary.Amount = 2000
+	if err := txRepo.Update(ctx, salary); err != nil {
+		t.Fatalf("Failed to update income: %v", err)
+	}
+	if play := balanceOf("play"); play.Allocated != 400 {
+		t.Errorf("Expected play allocation to scale to 400, got %+v", play)
+	}
+
+	if err := txRepo.DeleteForUser(ctx, "user-1", "salary", 0); err != nil {
+		t.Fatalf("Failed to delete income: %v", err)
+	}
+	if needs := balanceOf("needs"); needs.Allocated != 0 {
+		t.Errorf("Expected allocations to be removed with the income, got %+v", needs)
+	}
+}
@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"jarwise-backend/internal/models"
+)
+
+const attachmentColumns = `a.id, a.user_id, a.transaction_id, a.file_name, a.content_type, a.size, a.storage_key, a.created_at`
+
+type AttachmentRepository interface {
+	Create(ctx context.Context, attachment *models.Attachment) error
+	// GetForUser and ListForTransaction only see attachments whose
+	// transaction still exists.
+	GetForUser(ctx context.Context, userID, id string) (*models.Attachment, error)
+	ListForTransaction(ctx context.Context, userID, transactionID string) ([]models.Attachment, error)
+	DeleteForUser(ctx context.Context, userID, id string) error
+	// DeleteForTransactions removes the user's attachments of the given
+	// transactions once they are deleted and returns their storage keys.
+	DeleteForTransactions(ctx context.Context, userID string, transactionIDs []string) ([]string, error)
+	// DeleteOrphans removes the attachments of deleted transactions and
+	// returns their storage keys.
+	DeleteOrphans(ctx context.Context) ([]string, error)
+}
+
+type sqliteAttachmentRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteAttachmentRepository(db *sql.DB) AttachmentRepository {
+	return &sqliteAttachmentRepository{db: db}
+}
+
+func (r *sqliteAttachmentRepository) Create(ctx context.Context, a *models.Attachment) error {
+	a.UserID = normalizedUserID(a.UserID)
+	_, err := execWrite(ctx, r.db, `
+		INSERT INTO attachments (id, user_id, transaction_id, file_name, content_type, size, storage_key, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, a.ID, a.UserID, a.TransactionID, a.FileName, a.ContentType, a.Size, a.StorageKey, a.CreatedAt.UTC())
+	return err
+}
+
+func (r *sqliteAttachmentRepository) GetForUser(ctx context.Context, userID, id string) (*models.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + `
+		FROM attachments a
+		JOIN transactions t ON t.id = a.transaction_id AND t.user_id = a.user_id AND t.deleted_at IS NULL
+		WHERE a.user_id = ? AND a.id = ?`
+	a, err := scanAttachment(r.db.QueryRowContext(ctx, query, normalizedUserID(userID), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (r *sqliteAttachmentRepository) ListForTransaction(ctx context.Context, userID, transactionID string) ([]models.Attachment, error) {
+	query := `SELECT ` + attachmentColumns + `
+		FROM attachments a
+		JOIN transactions t ON t.id = a.transaction_id AND t.user_id = a.user_id AND t.deleted_at IS NULL
+		WHERE a.user_id = ? AND a.transaction_id = ?
+		ORDER BY a.created_at, a.id`
+	rows, err := r.db.QueryContext(ctx, query, normalizedUserID(userID), transactionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var attachments []models.Attachment
+	for rows.Next() {
+		a, err := scanAttachment(rows)
+		if err != nil {
+			return nil, err
+		}
+		attachments = append(attachments, a)
+	}
+	return attachments, rows.Err()
+}
+
+func (r *sqliteAttachmentRepository) DeleteForUser(ctx context.Context, userID, id string) error {
+	result, err := execWrite(ctx, r.db, "DELETE FROM attachments WHERE user_id = ? AND id = ?", normalizedUserID(userID), id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *sqliteAttachmentRepository) DeleteForTransactions(ctx context.Context, userID string, transactionIDs []string) ([]string, error) {
+	if len(transactionIDs) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{normalizedUserID(userID)}
+	for _, id := range transactionIDs {
+		args = append(args, id)
+	}
+	return r.deleteByQuery(ctx, `
+		DELETE FROM attachments
+		WHERE user_id = ? AND transaction_id IN (`+placeholders(len(transactionIDs))+`) AND NOT EXISTS (
+			SELECT 1 FROM transactions t
+			WHERE t.id = attachments.transaction_id AND t.user_id = attachments.user_id
+		)
+		RETURNING storage_key
+	`, args...)
+}
+
+func (r *sqliteAttachmentRepository) DeleteOrphans(ctx context.Context) ([]string, error) {
+	return r.deleteByQuery(ctx, `
+		DELETE FROM attachments
+		WHERE NOT EXISTS (
+			SELECT 1 FROM transactions t
+			WHERE t.id = attachments.transaction_id AND t.user_id = attachments.user_id
+		)
+		RETURNING storage_key
+	`)
+}
+
+// deleteByQuery runs a DELETE returning storage_key and collects the keys.
+func (r *sqliteAttachmentRepository) deleteByQuery(ctx context.Context, query string, args ...interface{}) ([]string, error) {
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	var keys []string
+	for rows.Next() {
+		var key string
+		if err := rows.Scan(&key); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return keys, tx.Commit()
+}
+
+func scanAttachment(scanner rowScanner) (models.Attachment, error) {
+	var a models.Attachment
+	err := scanner.Scan(&a.ID, &a.UserID, &a.TransactionID, &a.FileName, &a.ContentType, &a.Size, &a.StorageKey, &a.CreatedAt)
+	return a, err
+}
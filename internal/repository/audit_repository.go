@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"jarwise-backend/internal/models"
+)
+
+// AuditRepository reads the audit log, whose entries are written by
+// triggers. Writes made through a context from WithAuditActor are credited
+// to its actor.
+type AuditRepository interface {
+	ListForUser(ctx context.Context, userID string, filter models.AuditFilter) ([]models.AuditEntry, error)
+}
+
+type sqliteAuditRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteAuditRepository(db *sql.DB) AuditRepository {
+	return &sqliteAuditRepository{db: db}
+}
+
+func (r *sqliteAuditRepository) ListForUser(ctx context.Context, userID string, filter models.AuditFilter) ([]models.AuditEntry, error) {
+	query := `SELECT id, actor_user_id, actor_session_id, source, entity, entity_id, op, before_json, after_json, created_at
+		FROM audit_log WHERE user_id = ?`
+	args := []interface{}{normalizedUserID(userID)}
+	if filter.Entity != "" {
+		query += " AND entity = ?"
+		args = append(args, filter.Entity)
+	}
+	if filter.EntityID != "" {
+		query += " AND entity_id = ?"
+		args = append(args, filter.EntityID)
+	}
+	if filter.Before > 0 {
+		query += " AND id < ?"
+		args = append(args, filter.Before)
+	}
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	entries := []models.AuditEntry{}
+	for rows.Next() {
+		var entry models.AuditEntry
+		var actorUserID, sessionID, before, after sql.NullString
+		if err := rows.Scan(&entry.ID, &actorUserID, &sessionID, &entry.Actor.Source, &entry.Entity, &entry.EntityID,
+			&entry.Op, &before, &after, &entry.CreatedAt); err != nil {
+			return nil, err
+		}
+		entry.Actor.UserID = actorUserID.String
+		entry.Actor.SessionID = sessionID.String
+		if before.Valid {
+			entry.Before = []byte(before.String)
+		}
+		if after.Valid {
+			entry.After = []byte(after.String)
+		}
+		entries = append(entries, entry)
+	}
+	return entries, rows.Err()
+}
+
+type auditActorContextKey struct{}
+
+type auditClaim struct {
+	userID string
+	actor  models.AuditActor
+}
+
+// WithAuditActor returns a context whose writes to userID's data are
+// credited to actor in the audit log.
+func WithAuditActor(ctx context.Context, userID string, actor models.AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorContextKey{}, auditClaim{userID: normalizedUserID(userID), actor: actor})
+}
+
+// WriteTx is a database transaction that credits its audited writes to the
+// actor of the context it was begun with. The actor is named in
+// audit_actors inside the transaction and taken out again before it
+// commits, so no other write ever sees it.
+type WriteTx struct {
+	*sql.Tx
+	ctx     context.Context
+	claimed string
+}
+
+// BeginWrite starts a WriteTx. Code outside the repositories that writes
+// audited tables directly, such as the importer, uses it too.
+func BeginWrite(ctx context.Context, db *sql.DB) (*WriteTx, error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	claim, ok := ctx.Value(auditActorContextKey{}).(auditClaim)
+	if !ok {
+		return &WriteTx{Tx: tx, ctx: ctx}, nil
+	}
+	_, err = tx.ExecContext(ctx, `INSERT OR REPLACE INTO audit_actors (user_id, actor_user_id, session_id, source) VALUES (?, ?, ?, ?)`,
+		claim.userID, nullableString(claim.actor.UserID), nullableString(claim.actor.SessionID), claim.actor.Source)
+	if err != nil {
+		tx.Rollback()
+		return nil, fmt.Errorf("failed to set audit actor: %w", err)
+	}
+	return &WriteTx{Tx: tx, ctx: ctx, claimed: claim.userID}, nil
+}
+
+func (tx *WriteTx) Commit() error {
+	if tx.claimed != "" {
+		if _, err := tx.ExecContext(tx.ctx, `DELETE FROM audit_actors WHERE user_id = ?`, tx.claimed); err != nil {
+			return fmt.Errorf("failed to clear audit actor: %w", err)
+		}
+	}
+	return tx.Tx.Commit()
+}
+
+// execWrite runs a single write statement, crediting it like BeginWrite.
+func execWrite(ctx context.Context, db *sql.DB, query string, args ...interface{}) (sql.Result, error) {
+	if _, ok := ctx.Value(auditActorContextKey{}).(auditClaim); !ok {
+		return db.ExecContext(ctx, query, args...)
+	}
+	tx, err := BeginWrite(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	return result, tx.Commit()
+}
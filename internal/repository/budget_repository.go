@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"jarwise-backend/internal/models"
+	"strings"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+const budgetColumns = `b.id, b.user_id, b.jar_id, b.period, b.amount, b.rollover, b.start_date, b.created_at`
+
+// ErrDuplicateBudget is returned when the jar already has a budget for the
+// period.
+var ErrDuplicateBudget = errors.New("budget already exists")
+
+type BudgetRepository interface {
+	// Create fails with ErrDuplicateBudget when the jar already has a budget
+	// for the period.
+	Create(ctx context.Context, budget *models.Budget) error
+	Update(ctx context.Context, budget *models.Budget) error
+	GetForUser(ctx context.Context, userID, id string) (*models.Budget, error)
+	ListForUser(ctx context.Context, userID string) ([]models.Budget, error)
+	DeleteForUser(ctx context.Context, userID, id string) error
+	// SpentForUser sums the expenses filed under a jar or any of its sub-jars
+	// with start <= date < end.
+	SpentForUser(ctx context.Context, userID, jarID string, start, end time.Time) (models.Money, error)
+	// SpentByPeriodForUser sums the spending of consecutive periods in one
+	// query, net of refunds, per period, wallet currency and day. bounds
+	// holds the start of every period followed by the end of the last one.
+	SpentByPeriodForUser(ctx context.Context, userID, jarID string, bounds []time.Time) ([]models.BudgetSpending, error)
+}
+
+type sqliteBudgetRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteBudgetRepository(db *sql.DB) BudgetRepository {
+	return &sqliteBudgetRepository{db: db}
+}
+
+func (r *sqliteBudgetRepository) Create(ctx context.Context, b *models.Budget) error {
+	b.UserID = normalizedUserID(b.UserID)
+	_, err := execWrite(ctx, r.db, `
+		INSERT INTO budgets (id, user_id, jar_id, period, amount, rollover, start_date, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, b.ID, b.UserID, b.JarID, b.Period, b.Amount, b.Rollover, b.StartDate.UTC(), b.CreatedAt.UTC())
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		return ErrDuplicateBudget
+	}
+	return err
+}
+
+// Update saves the amount, rollover and start date of a budget. It returns
+// sql.ErrNoRows when the budget does not exist for that user.
+func (r *sqliteBudgetRepository) Update(ctx context.Context, b *models.Budget) error {
+	b.UserID = normalizedUserID(b.UserID)
+	result, err := execWrite(ctx, r.db, `
+		UPDATE budgets SET amount = ?, rollover = ?, start_date = ?
+		WHERE user_id = ? AND id = ?
+	`, b.Amount, b.Rollover, b.StartDate.UTC(), b.UserID, b.ID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *sqliteBudgetRepository) GetForUser(ctx context.Context, userID, id string) (*models.Budget, error) {
+	query := `SELECT ` + budgetColumns + ` FROM budgets b WHERE b.user_id = ? AND b.id = ?`
+	b, err := scanBudget(r.db.QueryRowContext(ctx, query, normalizedUserID(userID), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &b, nil
+}
+
+func (r *sqliteBudgetRepository) ListForUser(ctx context.Context, userID string) ([]models.Budget, error) {
+	// Joining jars hides budgets left behind when foreign keys are disabled,
+	// and those of jars in the trash.
+	query := `
+		SELECT ` + budgetColumns + `
+		FROM budgets b
+		JOIN jars j ON j.id = b.jar_id AND j.user_id = b.user_id AND j.deleted_at IS NULL
+		WHERE b.user_id = ?
+		ORDER BY j.name, b.period, b.id`
+	rows, err := r.db.QueryContext(ctx, query, normalizedUserID(userID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var budgets []models.Budget
+	for rows.Next() {
+		b, err := scanBudget(rows)
+		if err != nil {
+			return nil, err
+		}
+		budgets = append(budgets, b)
+	}
+	return budgets, rows.Err()
+}
+
+func (r *sqliteBudgetRepository) DeleteForUser(ctx context.Context, userID, id string) error {
+	result, err := execWrite(ctx, r.db, "DELETE FROM budgets WHERE user_id = ? AND id = ?", normalizedUserID(userID), id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *sqliteBudgetRepository) SpentForUser(ctx context.Context, userID, jarID string, start, end time.Time) (models.Money, error) {
+	userID = normalizedUserID(userID)
+
+	var spent models.Money
+	err := r.db.QueryRowContext(ctx, `
+		SELECT COALESCE(SUM(ABS(amount)), 0)
+		FROM (`+jarLinesSQL+`)
+		WHERE user_id = ? AND type = 'expense' AND date >= ? AND date < ?
+			AND jar_id IN (`+jarSubtreeSQL+`)
+	`, userID, start.UTC(), end.UTC(), userID, jarID, userID).Scan(&spent)
+	return spent, err
+}
+
+func (r *sqliteBudgetRepository) SpentByPeriodForUser(ctx context.Context, userID, jarID string, bounds []time.Time) ([]models.BudgetSpending, error) {
+	if len(bounds) < 2 {
+		return nil, nil
+	}
+	userID = normalizedUserID(userID)
+
+	periods := make([]string, 0, len(bounds)-1)
+	args := make([]interface{}, 0, 3*len(bounds)+8)
+	for i := 0; i+1 < len(bounds); i++ {
+		periods = append(periods, "(?, ?, ?)")
+		args = append(args, i, bounds[i].UTC(), bounds[i+1].UTC())
+	}
+	args = append(args, userID, userID, jarID, userID, userID, userID, jarID, userID)
+
+	// A refund counts against the jars of the expense it refunds, on the
+	// expense's date, shared out in proportion to the expense's lines.
+	rows, err := r.db.QueryContext(ctx, `
+		WITH periods(idx, period_start, period_end) AS (VALUES `+strings.Join(periods, ", ")+`)
+		SELECT p.idx, COALESCE(w.currency, ''), date(l.date), SUM(l.amount)
+		FROM periods p
+		JOIN (
+			SELECT date, wallet_id, ABS(amount) AS amount
+			FROM (`+jarLinesSQL+`)
+			WHERE user_id = ? AND type = 'expense'
+				AND jar_id IN (`+jarSubtreeSQL+`)
+			UNION ALL
+			SELECT e.date, refund.wallet_id, -CAST(ROUND(1.0 * ABS(refund.amount) * ABS(e.amount) / ABS(expense.amount)) AS INTEGER)
+			FROM transactions refund
+			JOIN transactions expense ON expense.id = refund.related_transaction_id AND expense.user_id = refund.user_id
+				AND expense.type = 'expense' AND expense.deleted_at IS NULL AND expense.amount != 0
+			JOIN (`+jarLinesSQL+`) e ON e.id = expense.id
+			WHERE refund.user_id = ? AND refund.type = 'income' AND refund.deleted_at IS NULL
+				AND e.jar_id IN (`+jarSubtreeSQL+`)
+		) l ON l.date >= p.period_start AND l.date < p.period_end
+		LEFT JOIN wallets w ON w.id = l.wallet_id
+		GROUP BY p.idx, w.currency, date(l.date)
+		ORDER BY p.idx, date(l.date)
+	`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var spending []models.BudgetSpending
+	for rows.Next() {
+		var s models.BudgetSpending
+		var day string
+		if err := rows.Scan(&s.Period, &s.Currency, &day, &s.Amount); err != nil {
+			return nil, err
+		}
+		if s.Date, err = time.Parse("2006-01-02", day); err != nil {
+			return nil, err
+		}
+		spending = append(spending, s)
+	}
+	return spending, rows.Err()
+}
+
+func scanBudget(scanner rowScanner) (models.Budget, error) {
+	var b models.Budget
+	err := scanner.Scan(&b.ID, &b.UserID, &b.JarID, &b.Period, &b.Amount, &b.Rollover, &b.StartDate, &b.CreatedAt)
+	return b, err
+}
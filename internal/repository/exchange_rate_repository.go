@@ -0,0 +1,136 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"jarwise-backend/internal/models"
+)
+
+const exchangeRateColumns = `id, user_id, from_currency, to_currency, rate, effective_date, source, created_at`
+
+type ExchangeRateRepository interface {
+	// GetBaseCurrency returns "" when the user does not exist.
+	GetBaseCurrency(ctx context.Context, userID string) (string, error)
+	SetBaseCurrency(ctx context.Context, userID, currency string) error
+	ListForUser(ctx context.Context, userID string) ([]models.ExchangeRate, error)
+	GetForUser(ctx context.Context, userID, id string) (*models.ExchangeRate, error)
+	// UpsertForUser stores the rates in one transaction. A rate for a pair and
+	// date that already exists is overwritten and keeps its ID.
+	UpsertForUser(ctx context.Context, userID string, rates []models.ExchangeRate) error
+	DeleteForUser(ctx context.Context, userID, id string) error
+}
+
+type sqliteExchangeRateRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteExchangeRateRepository(db *sql.DB) ExchangeRateRepository {
+	return &sqliteExchangeRateRepository{db: db}
+}
+
+func (r *sqliteExchangeRateRepository) GetBaseCurrency(ctx context.Context, userID string) (string, error) {
+	var currency string
+	err := r.db.QueryRowContext(ctx, "SELECT base_currency FROM users WHERE id = ?", normalizedUserID(userID)).Scan(&currency)
+	if err == sql.ErrNoRows {
+		return "", nil
+	}
+	return currency, err
+}
+
+// SetBaseCurrency returns sql.ErrNoRows when the user does not exist.
+func (r *sqliteExchangeRateRepository) SetBaseCurrency(ctx context.Context, userID, currency string) error {
+	result, err := execWrite(ctx, r.db, "UPDATE users SET base_currency = ? WHERE id = ?", currency, normalizedUserID(userID))
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *sqliteExchangeRateRepository) ListForUser(ctx context.Context, userID string) ([]models.ExchangeRate, error) {
+	query := `SELECT ` + exchangeRateColumns + ` FROM exchange_rates
+		WHERE user_id = ?
+		ORDER BY from_currency, to_currency, effective_date`
+	rows, err := r.db.QueryContext(ctx, query, normalizedUserID(userID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rates []models.ExchangeRate
+	for rows.Next() {
+		rate, err := scanExchangeRate(rows)
+		if err != nil {
+			return nil, err
+		}
+		rates = append(rates, rate)
+	}
+	return rates, rows.Err()
+}
+
+func (r *sqliteExchangeRateRepository) GetForUser(ctx context.Context, userID, id string) (*models.ExchangeRate, error) {
+	query := `SELECT ` + exchangeRateColumns + ` FROM exchange_rates WHERE user_id = ? AND id = ?`
+	rate, err := scanExchangeRate(r.db.QueryRowContext(ctx, query, normalizedUserID(userID), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rate, nil
+}
+
+func (r *sqliteExchangeRateRepository) UpsertForUser(ctx context.Context, userID string, rates []models.ExchangeRate) error {
+	userID = normalizedUserID(userID)
+
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for i := range rates {
+		rate := &rates[i]
+		rate.UserID = userID
+		err := tx.QueryRowContext(ctx, `
+			INSERT INTO exchange_rates (`+exchangeRateColumns+`)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT(user_id, from_currency, to_currency, effective_date)
+			DO UPDATE SET rate = excluded.rate, source = excluded.source
+			RETURNING id, created_at
+		`, rate.ID, userID, rate.FromCurrency, rate.ToCurrency, rate.Rate, rate.EffectiveDate.UTC(), rate.Source, rate.CreatedAt.UTC()).
+			Scan(&rate.ID, &rate.CreatedAt)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqliteExchangeRateRepository) DeleteForUser(ctx context.Context, userID, id string) error {
+	result, err := execWrite(ctx, r.db, "DELETE FROM exchange_rates WHERE user_id = ? AND id = ?", normalizedUserID(userID), id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func scanExchangeRate(scanner rowScanner) (models.ExchangeRate, error) {
+	var rate models.ExchangeRate
+	err := scanner.Scan(&rate.ID, &rate.UserID, &rate.FromCurrency, &rate.ToCurrency, &rate.Rate, &rate.EffectiveDate, &rate.Source, &rate.CreatedAt)
+	return rate, err
+}
@@ -0,0 +1,113 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"jarwise-backend/internal/models"
+	"time"
+)
+
+type IdempotencyRepository interface {
+	// Reserve stores a pending record unless the user has an unexpired one
+	// with the same key. It reports whether the record was stored.
+	Reserve(ctx context.Context, record *models.IdempotencyRecord) (bool, error)
+	GetForUser(ctx context.Context, userID, key string) (*models.IdempotencyRecord, error)
+	// Complete stores the response of a reserved record and keeps it until
+	// record.ExpiresAt. It returns sql.ErrNoRows unless the key is still
+	// reserved under record.LeaseToken.
+	Complete(ctx context.Context, record *models.IdempotencyRecord) error
+	// Release removes a pending record if it is still reserved under
+	// record.LeaseToken for record.RequestHash.
+	Release(ctx context.Context, record *models.IdempotencyRecord) error
+	// DeleteExpired removes records that expired before now and returns how
+	// many were removed.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+type sqliteIdempotencyRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteIdempotencyRepository(db *sql.DB) IdempotencyRepository {
+	return &sqliteIdempotencyRepository{db: db}
+}
+
+func (r *sqliteIdempotencyRepository) Reserve(ctx context.Context, record *models.IdempotencyRecord) (bool, error) {
+	record.UserID = normalizedUserID(record.UserID)
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO idempotency_keys (user_id, idempotency_key, request_hash, lease_token, status_code, content_type, body, created_at, expires_at)
+		VALUES (?, ?, ?, ?, 0, '', NULL, ?, ?)
+		ON CONFLICT(user_id, idempotency_key) DO UPDATE SET
+			request_hash = excluded.request_hash,
+			lease_token = excluded.lease_token,
+			status_code = 0,
+			content_type = '',
+			etag = '',
+			location = '',
+			body = NULL,
+			created_at = excluded.created_at,
+			expires_at = excluded.expires_at
+		WHERE idempotency_keys.expires_at <= excluded.created_at
+	`, record.UserID, record.Key, record.RequestHash, record.LeaseToken, record.CreatedAt.UTC(), record.ExpiresAt.UTC())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (r *sqliteIdempotencyRepository) GetForUser(ctx context.Context, userID, key string) (*models.IdempotencyRecord, error) {
+	var record models.IdempotencyRecord
+	err := r.db.QueryRowContext(ctx, `
+		SELECT user_id, idempotency_key, request_hash, status_code, content_type, etag, location, body, created_at, expires_at
+		FROM idempotency_keys WHERE user_id = ? AND idempotency_key = ?
+	`, normalizedUserID(userID), key).Scan(
+		&record.UserID, &record.Key, &record.RequestHash, &record.StatusCode,
+		&record.ContentType, &record.ETag, &record.Location, &record.Body, &record.CreatedAt, &record.ExpiresAt,
+	)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &record, nil
+}
+
+func (r *sqliteIdempotencyRepository) Complete(ctx context.Context, record *models.IdempotencyRecord) error {
+	result, err := r.db.ExecContext(ctx, `
+		UPDATE idempotency_keys SET status_code = ?, content_type = ?, etag = ?, location = ?, body = ?, expires_at = ?
+		WHERE user_id = ? AND idempotency_key = ? AND request_hash = ? AND lease_token = ? AND status_code = 0
+	`, record.StatusCode, record.ContentType, record.ETag, record.Location, record.Body, record.ExpiresAt.UTC(),
+		normalizedUserID(record.UserID), record.Key, record.RequestHash, record.LeaseToken)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *sqliteIdempotencyRepository) Release(ctx context.Context, record *models.IdempotencyRecord) error {
+	_, err := r.db.ExecContext(ctx, `
+		DELETE FROM idempotency_keys
+		WHERE user_id = ? AND idempotency_key = ? AND request_hash = ? AND lease_token = ? AND status_code = 0
+	`, normalizedUserID(record.UserID), record.Key, record.RequestHash, record.LeaseToken)
+	return err
+}
+
+func (r *sqliteIdempotencyRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM idempotency_keys WHERE expires_at <= ?`, now.UTC())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
@@ -3,12 +3,58 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"fmt"
 	"jarwise-backend/internal/models"
 )
 
+const jarColumns = `id, user_id, name, type, parent_id, wallet_id, COALESCE(icon, ''), COALESCE(color, ''), version, updated_at`
+
+// jarSubtreeSQL selects the ids of a jar and all of its live descendants for
+// one user; sub-jars in the trash are left out. It expects the arguments
+// (userID, jarID, userID).
+const jarSubtreeSQL = `
+	WITH RECURSIVE subtree(id) AS (
+		SELECT id FROM jars WHERE user_id = ? AND id = ?
+		UNION
+		SELECT j.id FROM jars j JOIN subtree s ON j.parent_id = s.id WHERE j.user_id = ? AND j.deleted_at IS NULL
+	)
+	SELECT id FROM subtree`
+
+// ErrJarReferenced is returned when a plain delete hits a jar that still has
+// sub-jars or transactions, or when a delete would leave split lines of a
+// transaction in the trash without a jar.
+var ErrJarReferenced = errors.New("jar is still referenced")
+
+// ErrInvalidReplacementJar is returned when a jar cannot take over the rows
+// of a deleted one: it is the same jar, belongs to someone else, is in the
+// trash, has another type or is nested under the deleted jar.
+var ErrInvalidReplacementJar = errors.New("invalid replacement jar")
+
 type JarRepository interface {
 	ListAll(ctx context.Context) ([]models.Jar, error)
 	ListAllForUser(ctx context.Context, userID string) ([]models.Jar, error)
+	GetForUser(ctx context.Context, userID, id string) (*models.Jar, error)
+	// ListByIDsForUser returns those of the user's jars in ids that exist, in
+	// no particular order.
+	ListByIDsForUser(ctx context.Context, userID string, ids []string) ([]models.Jar, error)
+	Create(ctx context.Context, jar *models.Jar) error
+	Update(ctx context.Context, jar *models.Jar) error
+	// The deletes fail with ErrVersionMismatch unless the jar is at version.
+	// They never remove rows in the trash; the plain and cascade deletes
+	// unlink them from the jar the way a purge does.
+	//
+	// DeleteForUser removes a jar only if nothing references it.
+	DeleteForUser(ctx context.Context, userID, id string, version int64) error
+	// DeleteWithReplacementForUser moves transactions and sub-jars to the
+	// replacement jar before removing the jar. It fails with
+	// ErrInvalidReplacementJar unless the replacement can take them over.
+	DeleteWithReplacementForUser(ctx context.Context, userID, id, replacementJarID string, version int64) error
+	// DeleteCascadeForUser removes the jar, all of its descendants and their transactions.
+	DeleteCascadeForUser(ctx context.Context, userID, id string, version int64) error
+	// CountReferencesForUser reports how many live child jars and
+	// transactions (filed under or allocated to the jar) point at a jar.
+	CountReferencesForUser(ctx context.Context, userID, id string) (childJars int, transactions int, err error)
 }
 
 type sqliteJarRepository struct {
@@ -20,8 +66,27 @@ func NewSQLiteJarRepository(db *sql.DB) JarRepository {
 }
 
 func (r *sqliteJarRepository) ListAll(ctx context.Context) ([]models.Jar, error) {
-	query := `SELECT id, user_id, name, type, parent_id, wallet_id, icon, color FROM jars`
-	rows, err := r.db.QueryContext(ctx, query)
+	return r.listByQuery(ctx, `SELECT `+jarColumns+` FROM jars WHERE deleted_at IS NULL`)
+}
+
+func (r *sqliteJarRepository) ListAllForUser(ctx context.Context, userID string) ([]models.Jar, error) {
+	return r.listByQuery(ctx, `SELECT `+jarColumns+` FROM jars WHERE user_id = ? AND deleted_at IS NULL`, normalizedUserID(userID))
+}
+
+func (r *sqliteJarRepository) ListByIDsForUser(ctx context.Context, userID string, ids []string) ([]models.Jar, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{normalizedUserID(userID)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	return r.listByQuery(ctx, `SELECT `+jarColumns+` FROM jars
+		WHERE user_id = ? AND id IN (`+placeholders(len(ids))+`) AND deleted_at IS NULL`, args...)
+}
+
+func (r *sqliteJarRepository) listByQuery(ctx context.Context, query string, args ...interface{}) ([]models.Jar, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
@@ -29,46 +94,402 @@ func (r *sqliteJarRepository) ListAll(ctx context.Context) ([]models.Jar, error)
 
 	var jars []models.Jar
 	for rows.Next() {
-		var j models.Jar
-		var parentID, walletID sql.NullString
-		err := rows.Scan(&j.ID, &j.UserID, &j.Name, &j.Type, &parentID, &walletID, &j.Icon, &j.Color)
+		j, err := scanJar(rows)
 		if err != nil {
 			return nil, err
 		}
-		if parentID.Valid {
-			j.ParentID = parentID.String
-		}
-		if walletID.Valid {
-			j.WalletID = walletID.String
-		}
 		jars = append(jars, j)
 	}
-	return jars, nil
+	return jars, rows.Err()
 }
 
-func (r *sqliteJarRepository) ListAllForUser(ctx context.Context, userID string) ([]models.Jar, error) {
-	query := `SELECT id, user_id, name, type, parent_id, wallet_id, icon, color FROM jars WHERE user_id = ?`
-	rows, err := r.db.QueryContext(ctx, query, normalizedUserID(userID))
+func (r *sqliteJarRepository) GetForUser(ctx context.Context, userID, id string) (*models.Jar, error) {
+	query := `SELECT ` + jarColumns + ` FROM jars WHERE user_id = ? AND id = ? AND deleted_at IS NULL`
+	j, err := scanJar(r.db.QueryRowContext(ctx, query, normalizedUserID(userID), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
 	if err != nil {
 		return nil, err
 	}
-	defer rows.Close()
+	return &j, nil
+}
 
-	var jars []models.Jar
-	for rows.Next() {
-		var j models.Jar
-		var parentID, walletID sql.NullString
-		err := rows.Scan(&j.ID, &j.UserID, &j.Name, &j.Type, &parentID, &walletID, &j.Icon, &j.Color)
-		if err != nil {
-			return nil, err
+func (r *sqliteJarRepository) Create(ctx context.Context, j *models.Jar) error {
+	j.UserID = normalizedUserID(j.UserID)
+	_, err := execWrite(ctx, r.db,
+		`INSERT INTO jars (id, user_id, name, type, parent_id, wallet_id, icon, color) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+		j.ID, j.UserID, j.Name, j.Type, nullableString(j.ParentID), nullableString(j.WalletID), j.Icon, j.Color,
+	)
+	if err != nil {
+		return err
+	}
+	return readVersion(r.db, "jars", j.ID, &j.Version, &j.UpdatedAt)
+}
+
+// Update saves every editable field of a jar owned by j.UserID. It returns
+// sql.ErrNoRows when the jar does not exist for that user, and
+// ErrVersionMismatch when j.Version is set but no longer current.
+func (r *sqliteJarRepository) Update(ctx context.Context, j *models.Jar) error {
+	j.UserID = normalizedUserID(j.UserID)
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkVersion(tx, "jars", j.UserID, j.ID, j.Version); err != nil {
+		return err
+	}
+	_, err = tx.ExecContext(ctx, `
+		UPDATE jars
+		SET name = ?, type = ?, parent_id = ?, wallet_id = ?, icon = ?, color = ?
+		WHERE user_id = ? AND id = ?
+	`, j.Name, j.Type, nullableString(j.ParentID), nullableString(j.WalletID), j.Icon, j.Color, j.UserID, j.ID)
+	if err != nil {
+		return err
+	}
+	if err := readVersion(tx, "jars", j.ID, &j.Version, &j.UpdatedAt); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (r *sqliteJarRepository) DeleteForUser(ctx context.Context, userID, id string, version int64) error {
+	userID = normalizedUserID(userID)
+
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkVersion(tx, "jars", userID, id, version); err != nil {
+		return err
+	}
+	// Trashed rows are unlinked after the delete, so only check foreign keys
+	// at commit.
+	if _, err := tx.ExecContext(ctx, "PRAGMA defer_foreign_keys = ON"); err != nil {
+		return err
+	}
+
+	// The reference check and the delete run as one statement so a
+	// concurrent write cannot slip a transaction in between. A split line
+	// cannot let go of its jar, so one in the trash still blocks the delete.
+	result, err := tx.ExecContext(ctx, `
+		DELETE FROM jars
+		WHERE user_id = ? AND id = ?
+			AND NOT EXISTS (SELECT 1 FROM jars c WHERE c.user_id = ? AND c.parent_id = jars.id AND c.deleted_at IS NULL)
+			AND NOT EXISTS (SELECT 1 FROM transactions t WHERE t.user_id = ? AND t.jar_id = jars.id AND t.deleted_at IS NULL)
+			AND NOT EXISTS (SELECT 1 FROM transaction_splits s WHERE s.user_id = ? AND s.jar_id = jars.id)
+			AND NOT EXISTS (
+				SELECT 1 FROM jar_allocations a JOIN transactions t ON t.id = a.transaction_id
+				WHERE a.user_id = ? AND a.jar_id = jars.id AND t.deleted_at IS NULL
+			)
+	`, userID, id, userID, userID, userID, userID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		// checkVersion found the jar, so something still references it.
+		return ErrJarReferenced
+	}
+	if err := unlinkTrashedFromJars(ctx, tx, userID, "SELECT ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// unlinkTrashedFromJars lets rows in the trash go of the jars selected by
+// jarIDs, as a purge does for rows outside its batch. Their allocations to
+// those jars are dropped.
+func unlinkTrashedFromJars(ctx context.Context, tx *WriteTx, userID, jarIDs string, args ...interface{}) error {
+	trashedArgs := append([]interface{}{userID}, args...)
+	for _, step := range []struct{ what, query string }{
+		{"transaction jars", "UPDATE transactions SET jar_id = NULL WHERE user_id = ? AND deletion_id IS NOT NULL AND jar_id IN (" + jarIDs + ")"},
+		{"sub-jars", "UPDATE jars SET parent_id = NULL WHERE user_id = ? AND deletion_id IS NOT NULL AND parent_id IN (" + jarIDs + ")"},
+		{"recurring rule jars", "UPDATE recurring_rules SET jar_id = NULL WHERE user_id = ? AND deletion_id IS NOT NULL AND jar_id IN (" + jarIDs + ")"},
+		{"jar allocations", "DELETE FROM jar_allocations WHERE user_id = ? AND jar_id IN (" + jarIDs + ")"},
+	} {
+		if _, err := tx.ExecContext(ctx, step.query, trashedArgs...); err != nil {
+			return fmt.Errorf("failed to unlink trashed %s: %w", step.what, err)
 		}
-		if parentID.Valid {
-			j.ParentID = parentID.String
+	}
+	return nil
+}
+
+func (r *sqliteJarRepository) DeleteWithReplacementForUser(ctx context.Context, userID, id, replacementJarID string, version int64) error {
+	userID = normalizedUserID(userID)
+
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkVersion(tx, "jars", userID, id, version); err != nil {
+		return err
+	}
+	if err := checkReplacementJar(tx, userID, id, replacementJarID); err != nil {
+		return err
+	}
+
+	// 1. Move Transactions to the replacement jar
+	_, err = tx.ExecContext(ctx, "UPDATE transactions SET jar_id = ? WHERE user_id = ? AND jar_id = ?", replacementJarID, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to re-assign transactions: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, "UPDATE transaction_splits SET jar_id = ? WHERE user_id = ? AND jar_id = ?", replacementJarID, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to re-assign transaction splits: %w", err)
+	}
+
+	// 2. Move income allocations and merge the allocation rule
+	// An income split across both jars keeps one allocation with the sum.
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO jar_allocations (transaction_id, jar_id, user_id, percent, amount, date)
+		SELECT transaction_id, ?, user_id, percent, amount, date FROM jar_allocations WHERE user_id = ? AND jar_id = ?
+		ON CONFLICT(transaction_id, jar_id) DO UPDATE SET percent = percent + excluded.percent, amount = amount + excluded.amount
+	`, replacementJarID, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to merge jar allocations: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM jar_allocations WHERE user_id = ? AND jar_id = ?", userID, id); err != nil {
+		return fmt.Errorf("failed to delete jar allocations: %w", err)
+	}
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO allocation_rules (user_id, jar_id, percent)
+		SELECT user_id, ?, percent FROM allocation_rules WHERE user_id = ? AND jar_id = ?
+		ON CONFLICT(user_id, jar_id) DO UPDATE SET percent = percent + excluded.percent
+	`, replacementJarID, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to merge allocation rule: %w", err)
+	}
+	if _, err = tx.ExecContext(ctx, "DELETE FROM allocation_rules WHERE user_id = ? AND jar_id = ?", userID, id); err != nil {
+		return fmt.Errorf("failed to delete allocation rule: %w", err)
+	}
+
+	// 3. Point recurring rules at the replacement jar
+	_, err = tx.ExecContext(ctx, "UPDATE recurring_rules SET jar_id = ? WHERE user_id = ? AND jar_id = ?", replacementJarID, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to re-assign recurring rules: %w", err)
+	}
+
+	// 4. Move child Jars under the replacement jar
+	_, err = tx.ExecContext(ctx, "UPDATE jars SET parent_id = ? WHERE user_id = ? AND parent_id = ?", replacementJarID, userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to re-assign sub-jars: %w", err)
+	}
+
+	// 5. Delete the original jar
+	result, err := tx.ExecContext(ctx, "DELETE FROM jars WHERE user_id = ? AND id = ?", userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to delete jar: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+// checkReplacementJar makes sure the replacement can take over the jar's
+// transactions and sub-jars. It runs inside the delete's transaction so the
+// replacement cannot change in between.
+func checkReplacementJar(tx *WriteTx, userID, id, replacementJarID string) error {
+	if replacementJarID == id {
+		return fmt.Errorf("%w: a jar cannot replace itself", ErrInvalidReplacementJar)
+	}
+
+	var jarType string
+	if err := tx.QueryRow("SELECT type FROM jars WHERE user_id = ? AND id = ? AND deleted_at IS NULL", userID, id).Scan(&jarType); err != nil {
+		return err
+	}
+
+	var replacementType string
+	var trashed, nested bool
+	err := tx.QueryRow(`
+		SELECT type, deleted_at IS NOT NULL, id IN (`+jarSubtreeSQL+`)
+		FROM jars WHERE user_id = ? AND id = ?
+	`, userID, id, userID, userID, replacementJarID).Scan(&replacementType, &trashed, &nested)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: jar %s not found", ErrInvalidReplacementJar, replacementJarID)
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case trashed:
+		// Rows moved under a trashed jar would vanish from every listing.
+		return fmt.Errorf("%w: jar %s is in the trash", ErrInvalidReplacementJar, replacementJarID)
+	case replacementType != jarType:
+		return fmt.Errorf("%w: jar %s is %s, not %s", ErrInvalidReplacementJar, replacementJarID, replacementType, jarType)
+	case nested:
+		return fmt.Errorf("%w: jar %s is nested under the deleted jar", ErrInvalidReplacementJar, replacementJarID)
+	}
+	return nil
+}
+
+func (r *sqliteJarRepository) DeleteCascadeForUser(ctx context.Context, userID, id string, version int64) error {
+	userID = normalizedUserID(userID)
+
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkVersion(tx, "jars", userID, id, version); err != nil {
+		return err
+	}
+
+	// Sub-jars reference each other, so only check foreign keys at commit.
+	if _, err := tx.ExecContext(ctx, "PRAGMA defer_foreign_keys = ON"); err != nil {
+		return err
+	}
+
+	subtreeArgs := []interface{}{userID, id, userID}
+	subtreeFilter := "user_id = ? AND jar_id IN (" + jarSubtreeSQL + ")"
+	subtreeFilterArgs := append([]interface{}{userID}, subtreeArgs...)
+
+	// Split lines in the trash cannot let go of their jars, so refuse rather
+	// than leave the trashed transaction half-restorable.
+	var trashedLines int
+	err = tx.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM transaction_splits s JOIN transactions t ON t.id = s.transaction_id
+		WHERE s.user_id = ? AND t.deletion_id IS NOT NULL AND s.jar_id IN (`+jarSubtreeSQL+`)`, subtreeFilterArgs...).Scan(&trashedLines)
+	if err != nil {
+		return fmt.Errorf("failed to check trashed split lines: %w", err)
+	}
+	if trashedLines > 0 {
+		return ErrJarReferenced
+	}
+
+	// Transfer legs are removed together so no half-transfer is left behind,
+	// and a split transaction goes as a whole when any of its lines does.
+	// Transactions in the trash stay there.
+	transactionFilter := `user_id = ? AND deleted_at IS NULL AND (
+		jar_id IN (` + jarSubtreeSQL + `)
+		OR id IN (SELECT related_transaction_id FROM transactions WHERE user_id = ? AND deleted_at IS NULL AND jar_id IN (` + jarSubtreeSQL + `))
+		OR id IN (SELECT transaction_id FROM transaction_splits WHERE user_id = ? AND jar_id IN (` + jarSubtreeSQL + `))
+	)`
+	transactionArgs := append([]interface{}{userID}, subtreeArgs...)
+	transactionArgs = append(transactionArgs, userID)
+	transactionArgs = append(transactionArgs, subtreeArgs...)
+	transactionArgs = append(transactionArgs, userID)
+	transactionArgs = append(transactionArgs, subtreeArgs...)
+
+	// 1. Remember which wallets lose transactions
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT wallet_id FROM transactions WHERE "+transactionFilter, transactionArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to load affected wallets: %w", err)
+	}
+	var walletIDs []string
+	for rows.Next() {
+		var walletID string
+		if err := rows.Scan(&walletID); err != nil {
+			rows.Close()
+			return err
 		}
-		if walletID.Valid {
-			j.WalletID = walletID.String
+		walletIDs = append(walletIDs, walletID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// 2. Delete associated Transactions, their allocations and the jars' rules
+	// (allocation and recurring). A trashed transfer leg or refund lets go of
+	// the live transaction it points at.
+	if _, err := tx.ExecContext(ctx, "UPDATE transactions SET related_transaction_id = NULL WHERE user_id = ? AND deletion_id IS NOT NULL AND related_transaction_id IN (SELECT id FROM transactions WHERE "+transactionFilter+")", append([]interface{}{userID}, transactionArgs...)...); err != nil {
+		return fmt.Errorf("failed to unlink trashed transactions: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM jar_allocations WHERE transaction_id IN (SELECT id FROM transactions WHERE "+transactionFilter+")", transactionArgs...); err != nil {
+		return fmt.Errorf("failed to cascade delete jar allocations: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM transaction_splits WHERE transaction_id IN (SELECT id FROM transactions WHERE "+transactionFilter+")", transactionArgs...); err != nil {
+		return fmt.Errorf("failed to cascade delete transaction splits: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM transaction_tags WHERE transaction_id IN (SELECT id FROM transactions WHERE "+transactionFilter+")", transactionArgs...); err != nil {
+		return fmt.Errorf("failed to cascade delete transaction tags: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM transactions WHERE "+transactionFilter, transactionArgs...); err != nil {
+		return fmt.Errorf("failed to cascade delete transactions: %w", err)
+	}
+	if err := unlinkTrashedFromJars(ctx, tx, userID, jarSubtreeSQL, subtreeArgs...); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM allocation_rules WHERE "+subtreeFilter, subtreeFilterArgs...); err != nil {
+		return fmt.Errorf("failed to cascade delete allocation rules: %w", err)
+	}
+	ruleFilter := "FROM recurring_rules WHERE deleted_at IS NULL AND " + subtreeFilter
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recurring_occurrences WHERE rule_id IN (SELECT id "+ruleFilter+")", subtreeFilterArgs...); err != nil {
+		return fmt.Errorf("failed to cascade delete recurring occurrences: %w", err)
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE "+ruleFilter, subtreeFilterArgs...); err != nil {
+		return fmt.Errorf("failed to cascade delete recurring rules: %w", err)
+	}
+
+	// 3. Delete the jar and its descendants
+	result, err := tx.ExecContext(ctx, "DELETE FROM jars WHERE user_id = ? AND deleted_at IS NULL AND id IN ("+jarSubtreeSQL+")", subtreeFilterArgs...)
+	if err != nil {
+		return fmt.Errorf("failed to cascade delete jars: %w", err)
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	if len(walletIDs) > 0 {
+		if err := RefreshWalletBalances(tx, userID, walletIDs...); err != nil {
+			return fmt.Errorf("failed to refresh wallet balances: %w", err)
 		}
-		jars = append(jars, j)
 	}
-	return jars, nil
+
+	return tx.Commit()
+}
+
+func (r *sqliteJarRepository) CountReferencesForUser(ctx context.Context, userID, id string) (int, int, error) {
+	userID = normalizedUserID(userID)
+
+	var childJars, transactions int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM jars WHERE user_id = ? AND parent_id = ? AND deleted_at IS NULL", userID, id).Scan(&childJars)
+	if err != nil {
+		return 0, 0, err
+	}
+	// Income allocated to the jar counts as a reference too.
+	err = r.db.QueryRowContext(ctx, `
+		SELECT COUNT(*) FROM transactions
+		WHERE user_id = ? AND deleted_at IS NULL AND (
+			jar_id = ?
+			OR id IN (SELECT transaction_id FROM jar_allocations WHERE user_id = ? AND jar_id = ?)
+			OR id IN (SELECT transaction_id FROM transaction_splits WHERE user_id = ? AND jar_id = ?)
+		)
+	`, userID, id, userID, id, userID, id).Scan(&transactions)
+	if err != nil {
+		return 0, 0, err
+	}
+	return childJars, transactions, nil
+}
+
+func scanJar(scanner rowScanner) (models.Jar, error) {
+	var j models.Jar
+	var parentID, walletID sql.NullString
+	if err := scanner.Scan(&j.ID, &j.UserID, &j.Name, &j.Type, &parentID, &walletID, &j.Icon, &j.Color, &j.Version, &j.UpdatedAt); err != nil {
+		return j, err
+	}
+	if parentID.Valid {
+		j.ParentID = parentID.String
+	}
+	if walletID.Valid {
+		j.WalletID = walletID.String
+	}
+	return j, nil
 }
@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"jarwise-backend/internal/models"
+	"testing"
+	"time"
+)
+
+func seedJarTree(t *testing.T, repo JarRepository, txRepo TransactionRepository, walletRepo WalletRepository) {
+	t.Helper()
+	ctx := context.Background()
+
+	if err := walletRepo.Create(ctx, &models.Wallet{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB", OpeningBalance: 1000}); err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	jars := []models.Jar{
+		{ID: "food", UserID: "user-1", Name: "Food", Type: "expense"},
+		{ID: "dining", UserID: "user-1", Name: "Dining", Type: "expense", ParentID: "food"},
+		{ID: "coffee", UserID: "user-1", Name: "Coffee", Type: "expense", ParentID: "dining"},
+		{ID: "misc", UserID: "user-1", Name: "Misc", Type: "expense"},
+	}
+	for i := range jars {
+		if err := repo.Create(ctx, &jars[i]); err != nil {
+			t.Fatalf("Failed to create jar %s: %v", jars[i].ID, err)
+		}
+	}
+	txs := []models.Transaction{
+		{ID: "tx-food", UserID: "user-1", Amount: 100, Type: "expense", WalletID: "wallet-1", JarID: "food"},
+		{ID: "tx-dining", UserID: "user-1", Amount: 50, Type: "expense", WalletID: "wallet-1", JarID: "dining"},
+		{ID: "tx-coffee", UserID: "user-1", Amount: 5, Type: "expense", WalletID: "wallet-1", JarID: "coffee"},
+	}
+	for i := range txs {
+		txs[i].Date = time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+		if err := txRepo.Create(ctx, &txs[i]); err != nil {
+			t.Fatalf("Failed to create transaction %s: %v", txs[i].ID, err)
+		}
+	}
+}
+
+func TestJarDeleteRefusesReferencedJar(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	repo := NewSQLiteJarRepository(dbConn)
+	seedJarTree(t, repo, NewSQLiteTransactionRepository(dbConn), NewSQLiteWalletRepository(dbConn))
+
+	if err := repo.DeleteForUser(context.Background(), "user-1", "dining", 0); err != ErrJarReferenced {
+		t.Errorf("Expected ErrJarReferenced, got %v", err)
+	}
+	misc, err := repo.GetForUser(context.Background(), "user-1", "misc")
+	if err != nil || misc == nil {
+		t.Fatalf("Failed to load jar: %v", err)
+	}
+	if err := repo.DeleteForUser(context.Background(), "user-1", "misc", misc.Version+1); err != ErrVersionMismatch {
+		t.Errorf("Expected a stale version to be refused, got %v", err)
+	}
+	if err := repo.DeleteForUser(context.Background(), "user-1", "misc", misc.Version); err != nil {
+		t.Errorf("Expected unused jar to be deleted, got %v", err)
+	}
+}
+
+func TestJarDeleteWithReplacement(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	repo := NewSQLiteJarRepository(dbConn)
+	seedJarTree(t, repo, NewSQLiteTransactionRepository(dbConn), NewSQLiteWalletRepository(dbConn))
+
+	if err := repo.DeleteWithReplacementForUser(ctx, "user-1", "dining", "misc", 0); err != nil {
+		t.Fatalf("DeleteWithReplacementForUser failed: %v", err)
+	}
+
+	var jarID string
+	if err := dbConn.QueryRow("SELECT jar_id FROM transactions WHERE id = 'tx-dining'").Scan(&jarID); err != nil || jarID != "misc" {
+		t.Errorf("Expected tx-dining to move to misc, got %q (%v)", jarID, err)
+	}
+	coffee, err := repo.GetForUser(ctx, "user-1", "coffee")
+	if err != nil || coffee == nil || coffee.ParentID != "misc" {
+		t.Errorf("Expected coffee to move under misc, got %+v (%v)", coffee, err)
+	}
+	if dining, _ := repo.GetForUser(ctx, "user-1", "dining"); dining != nil {
+		t.Error("Expected dining to be deleted")
+	}
+}
+
+func TestJarDeleteWithReplacementRechecksReplacement(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	repo := NewSQLiteJarRepository(dbConn)
+	seedJarTree(t, repo, NewSQLiteTransactionRepository(dbConn), NewSQLiteWalletRepository(dbConn))
+	for _, jar := range []models.Jar{
+		{ID: "salary", UserID: "user-1", Name: "Salary", Type: "income"},
+		{ID: "trashed", UserID: "user-1", Name: "Trashed", Type: "expense"},
+		{ID: "theirs", UserID: "user-2", Name: "Theirs", Type: "expense"},
+	} {
+		if err := repo.Create(ctx, &jar); err != nil {
+			t.Fatalf("Failed to create jar %s: %v", jar.ID, err)
+		}
+	}
+	// The service validated these before they changed.
+	if _, err := dbConn.Exec("UPDATE jars SET deleted_at = ?, deletion_id = 'deletion-1' WHERE id = 'trashed'", time.Now().UTC()); err != nil {
+		t.Fatalf("Failed to trash jar: %v", err)
+	}
+
+	for _, replacement := range []string{"dining", "salary", "trashed", "theirs", "coffee", "missing"} {
+		err := repo.DeleteWithReplacementForUser(ctx, "user-1", "dining", replacement, 0)
+		if !errors.Is(err, ErrInvalidReplacementJar) {
+			t.Errorf("Expected replacement %s to be refused, got %v", replacement, err)
+		}
+	}
+
+	var jarID string
+	if err := dbConn.QueryRow("SELECT jar_id FROM transactions WHERE id = 'tx-dining'").Scan(&jarID); err != nil || jarID != "dining" {
+		t.Errorf("Expected tx-dining to stay in dining, got %q (%v)", jarID, err)
+	}
+	if dining, err := repo.GetForUser(ctx, "user-1", "dining"); err != nil || dining == nil {
+		t.Errorf("Expected dining to be kept, got %+v (%v)", dining, err)
+	}
+}
+
+func TestJarDeleteWithReplacementMergesAllocations(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	repo := NewSQLiteJarRepository(dbConn)
+	txRepo := NewSQLiteTransactionRepository(dbConn)
+	seedJarTree(t, repo, txRepo, NewSQLiteWalletRepository(dbConn))
+
+	date := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if err := txRepo.Create(ctx, &models.Transaction{ID: "salary", UserID: "user-1", Amount: 1000, Type: "income", WalletID: "wallet-1", Date: date}); err != nil {
+		t.Fatalf("Failed to create income: %v", err)
+	}
+	for _, a := range []struct {
+		jarID   string
+		percent float64
+		amount  int
+	}{{"dining", 30, 300}, {"misc", 20, 200}} {
+		if _, err := dbConn.Exec(`INSERT INTO jar_allocations (transaction_id, jar_id, user_id, percent, amount, date) VALUES ('salary', ?, 'user-1', ?, ?, ?)`,
+			a.jarID, a.percent, a.amount, date); err != nil {
+			t.Fatalf("Failed to allocate income: %v", err)
+		}
+	}
+
+	if err := repo.DeleteWithReplacementForUser(ctx, "user-1", "dining", "misc", 0); err != nil {
+		t.Fatalf("DeleteWithReplacementForUser failed: %v", err)
+	}
+
+	var rows int
+	var percent float64
+	var amount int
+	err := dbConn.QueryRow("SELECT COUNT(*), SUM(percent), SUM(amount) FROM jar_allocations WHERE transaction_id = 'salary'").Scan(&rows, &percent, &amount)
+	if err != nil || rows != 1 || percent != 50 || amount != 500 {
+		t.Errorf("Expected one merged allocation of 50%% and 500, got %d rows, %v%%, %d (%v)", rows, percent, amount, err)
+	}
+}
+
+func TestJarDeleteCascade(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	repo := NewSQLiteJarRepository(dbConn)
+	walletRepo := NewSQLiteWalletRepository(dbConn)
+	seedJarTree(t, repo, NewSQLiteTransactionRepository(dbConn), walletRepo)
+
+	if err := repo.DeleteCascadeForUser(ctx, "user-2", "food", 0); err == nil {
+		t.Error("Expected another user's cascade delete to fail")
+	}
+	if err := repo.DeleteCascadeForUser(ctx, "user-1", "food", 0); err != nil {
+		t.Fatalf("DeleteCascadeForUser failed: %v", err)
+	}
+
+	jars, err := repo.ListAllForUser(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("ListAllForUser failed: %v", err)
+	}
+	if len(jars) != 1 || jars[0].ID != "misc" {
+		t.Errorf("Expected only misc to remain, got %+v", jars)
+	}
+
+	var count int
+	dbConn.QueryRow("SELECT COUNT(*) FROM transactions").Scan(&count)
+	if count != 0 {
+		t.Errorf("Expected all transactions to be deleted, got %d", count)
+	}
+
+	wallet, _ := walletRepo.Get("wallet-1")
+	if wallet == nil || wallet.Balance != 1000 {
+		t.Errorf("Expected wallet balance back at 1000, got %+v", wallet)
+	}
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	"database/sql"
+	"errors"
+	"time"
+)
+
+// ErrVersionMismatch is returned when an update names a version the record
+// has already moved past, meaning someone else changed it in the meantime.
+var ErrVersionMismatch = errors.New("record was changed since it was read")
+
+type rowQueryer interface {
+	QueryRow(query string, args ...interface{}) *sql.Row
+}
+
+// checkVersion fails with ErrVersionMismatch unless the row id of table is
+// at version. A zero version skips the check, here and wherever a write takes
+// the version it expects. It returns sql.ErrNoRows when
+// the row does not exist for the user or is in the trash.
+func checkVersion(q rowQueryer, table, userID, id string, version int64) error {
+	var current int64
+	err := q.QueryRow("SELECT version FROM "+table+" WHERE user_id = ? AND id = ? AND deleted_at IS NULL", userID, id).Scan(&current)
+	if err != nil {
+		return err
+	}
+	if version != 0 && current != version {
+		return ErrVersionMismatch
+	}
+	return nil
+}
+
+// readVersion loads the version and updated_at that the database triggers
+// maintain, after a write.
+func readVersion(q rowQueryer, table, id string, version *int64, updatedAt *time.Time) error {
+	return q.QueryRow("SELECT version, updated_at FROM "+table+" WHERE id = ?", id).Scan(version, updatedAt)
+}
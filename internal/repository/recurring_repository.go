@@ -0,0 +1,287 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"jarwise-backend/internal/models"
+	"time"
+)
+
+const recurringRuleColumns = `id, user_id, wallet_id, COALESCE(jar_id, ''), type, amount, COALESCE(description, ''),
+	frequency, interval_count, day_of_month, start_date, end_date, occurrence_count, materialized_through,
+	created_at, updated_at`
+
+// ErrOccurrenceMaterialized is returned when skipping an occurrence that has
+// already been turned into a transaction.
+var ErrOccurrenceMaterialized = errors.New("occurrence has already been recorded")
+
+type RecurringRepository interface {
+	Create(ctx context.Context, rule *models.RecurringRule) error
+	Update(ctx context.Context, rule *models.RecurringRule) error
+	GetForUser(ctx context.Context, userID, id string) (*models.RecurringRule, error)
+	ListForUser(ctx context.Context, userID string) ([]models.RecurringRule, error)
+	// ListAll returns every user's rules for the background materializer.
+	ListAll(ctx context.Context) ([]models.RecurringRule, error)
+	DeleteForUser(ctx context.Context, userID, id string) error
+	// OccurrenceStatuses returns the recorded state of a rule's occurrences
+	// keyed by the occurrence date's UnixNano.
+	OccurrenceStatuses(ctx context.Context, ruleID string) (map[int64]models.RecurringOccurrence, error)
+	Skip(ctx context.Context, rule *models.RecurringRule, date time.Time) error
+	// Materialize records one occurrence as a transaction and advances the
+	// rule's materialized_through mark, all in one database transaction. It
+	// returns false without writing anything when the occurrence was already
+	// handled.
+	Materialize(ctx context.Context, rule *models.RecurringRule, date time.Time, tx *models.Transaction) (bool, error)
+	// AdvanceMaterializedThrough moves the mark forward past skipped occurrences.
+	AdvanceMaterializedThrough(ctx context.Context, ruleID string, through time.Time) error
+}
+
+type sqliteRecurringRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteRecurringRepository(db *sql.DB) RecurringRepository {
+	return &sqliteRecurringRepository{db: db}
+}
+
+func (r *sqliteRecurringRepository) Create(ctx context.Context, rule *models.RecurringRule) error {
+	rule.UserID = normalizedUserID(rule.UserID)
+	_, err := execWrite(ctx, r.db, `
+		INSERT INTO recurring_rules (
+			id, user_id, wallet_id, jar_id, type, amount, description, frequency, interval_count,
+			day_of_month, start_date, end_date, occurrence_count, materialized_through, created_at, updated_at
+		) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, rule.ID, rule.UserID, rule.WalletID, nullableString(rule.JarID), rule.Type, rule.Amount, rule.Description,
+		rule.Frequency, rule.Interval, rule.DayOfMonth, rule.StartDate.UTC(), nullableTime(rule.EndDate),
+		rule.Count, nullableTime(rule.MaterializedThrough), rule.CreatedAt.UTC(), rule.UpdatedAt.UTC())
+	return err
+}
+
+// Update saves the rule definition. materialized_through is owned by the
+// materializer and is left untouched. It returns sql.ErrNoRows when the rule
+// does not exist for that user.
+func (r *sqliteRecurringRepository) Update(ctx context.Context, rule *models.RecurringRule) error {
+	rule.UserID = normalizedUserID(rule.UserID)
+	result, err := execWrite(ctx, r.db, `
+		UPDATE recurring_rules
+		SET wallet_id = ?, jar_id = ?, type = ?, amount = ?, description = ?, frequency = ?, interval_count = ?,
+			day_of_month = ?, start_date = ?, end_date = ?, occurrence_count = ?, updated_at = ?
+		WHERE user_id = ? AND id = ? AND deleted_at IS NULL
+	`, rule.WalletID, nullableString(rule.JarID), rule.Type, rule.Amount, rule.Description, rule.Frequency,
+		rule.Interval, rule.DayOfMonth, rule.StartDate.UTC(), nullableTime(rule.EndDate), rule.Count,
+		rule.UpdatedAt.UTC(), rule.UserID, rule.ID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *sqliteRecurringRepository) GetForUser(ctx context.Context, userID, id string) (*models.RecurringRule, error) {
+	query := `SELECT ` + recurringRuleColumns + ` FROM recurring_rules WHERE user_id = ? AND id = ? AND deleted_at IS NULL`
+	rule, err := scanRecurringRule(r.db.QueryRowContext(ctx, query, normalizedUserID(userID), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &rule, nil
+}
+
+func (r *sqliteRecurringRepository) ListForUser(ctx context.Context, userID string) ([]models.RecurringRule, error) {
+	return r.listByQuery(ctx, `SELECT `+recurringRuleColumns+` FROM recurring_rules WHERE user_id = ? AND deleted_at IS NULL ORDER BY start_date, id`, normalizedUserID(userID))
+}
+
+func (r *sqliteRecurringRepository) ListAll(ctx context.Context) ([]models.RecurringRule, error) {
+	return r.listByQuery(ctx, `SELECT `+recurringRuleColumns+` FROM recurring_rules WHERE deleted_at IS NULL ORDER BY user_id, start_date, id`)
+}
+
+func (r *sqliteRecurringRepository) listByQuery(ctx context.Context, query string, args ...interface{}) ([]models.RecurringRule, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var rules []models.RecurringRule
+	for rows.Next() {
+		rule, err := scanRecurringRule(rows)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+// DeleteForUser removes a rule and its occurrence log. Transactions that were
+// already recorded from the rule are kept.
+func (r *sqliteRecurringRepository) DeleteForUser(ctx context.Context, userID, id string) error {
+	userID = normalizedUserID(userID)
+
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM recurring_occurrences WHERE user_id = ? AND rule_id = ?", userID, id); err != nil {
+		return err
+	}
+	result, err := tx.ExecContext(ctx, "DELETE FROM recurring_rules WHERE user_id = ? AND id = ?", userID, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqliteRecurringRepository) OccurrenceStatuses(ctx context.Context, ruleID string) (map[int64]models.RecurringOccurrence, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT occurrence_date, status, COALESCE(transaction_id, '')
+		FROM recurring_occurrences WHERE rule_id = ?
+	`, ruleID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	statuses := make(map[int64]models.RecurringOccurrence)
+	for rows.Next() {
+		occurrence := models.RecurringOccurrence{RuleID: ruleID}
+		if err := rows.Scan(&occurrence.Date, &occurrence.Status, &occurrence.TransactionID); err != nil {
+			return nil, err
+		}
+		occurrence.Date = occurrence.Date.UTC()
+		statuses[occurrence.Date.UnixNano()] = occurrence
+	}
+	return statuses, rows.Err()
+}
+
+func (r *sqliteRecurringRepository) Skip(ctx context.Context, rule *models.RecurringRule, date time.Time) error {
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var status string
+	err = tx.QueryRowContext(ctx, "SELECT status FROM recurring_occurrences WHERE rule_id = ? AND occurrence_date = ?", rule.ID, date.UTC()).Scan(&status)
+	switch {
+	case err == sql.ErrNoRows:
+	case err != nil:
+		return err
+	case status == string(models.OccurrenceMaterialized):
+		return ErrOccurrenceMaterialized
+	default:
+		return nil
+	}
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO recurring_occurrences (rule_id, occurrence_date, user_id, status, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, rule.ID, date.UTC(), rule.UserID, models.OccurrenceSkipped, time.Now().UTC())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqliteRecurringRepository) Materialize(ctx context.Context, rule *models.RecurringRule, date time.Time, t *models.Transaction) (bool, error) {
+	t.UserID = normalizedUserID(t.UserID)
+
+	dbTx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return false, err
+	}
+	defer dbTx.Rollback()
+
+	// The occurrence row is written before the transaction it points at.
+	if _, err := dbTx.ExecContext(ctx, "PRAGMA defer_foreign_keys = ON"); err != nil {
+		return false, err
+	}
+
+	// The primary key on (rule_id, occurrence_date) makes this idempotent:
+	// a second run inserts nothing and records no transaction.
+	result, err := dbTx.ExecContext(ctx, `
+		INSERT INTO recurring_occurrences (rule_id, occurrence_date, user_id, status, transaction_id, created_at)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT(rule_id, occurrence_date) DO NOTHING
+	`, rule.ID, date.UTC(), t.UserID, models.OccurrenceMaterialized, t.ID, time.Now().UTC())
+	if err != nil {
+		return false, err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	if err := insertTransaction(dbTx, t); err != nil {
+		return false, err
+	}
+	if err := advanceMaterializedThrough(dbTx, rule.ID, date); err != nil {
+		return false, err
+	}
+
+	if err := dbTx.Commit(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *sqliteRecurringRepository) AdvanceMaterializedThrough(ctx context.Context, ruleID string, through time.Time) error {
+	return advanceMaterializedThrough(r.db, ruleID, through)
+}
+
+func advanceMaterializedThrough(exec Executor, ruleID string, through time.Time) error {
+	_, err := exec.Exec(`
+		UPDATE recurring_rules SET materialized_through = ?
+		WHERE id = ? AND (materialized_through IS NULL OR materialized_through < ?)
+	`, through.UTC(), ruleID, through.UTC())
+	return err
+}
+
+func scanRecurringRule(scanner rowScanner) (models.RecurringRule, error) {
+	var rule models.RecurringRule
+	var endDate, materializedThrough sql.NullTime
+	var count sql.NullInt64
+	err := scanner.Scan(
+		&rule.ID, &rule.UserID, &rule.WalletID, &rule.JarID, &rule.Type, &rule.Amount, &rule.Description,
+		&rule.Frequency, &rule.Interval, &rule.DayOfMonth, &rule.StartDate, &endDate, &count, &materializedThrough,
+		&rule.CreatedAt, &rule.UpdatedAt,
+	)
+	if err != nil {
+		return rule, err
+	}
+	rule.StartDate = rule.StartDate.UTC()
+	if endDate.Valid {
+		value := endDate.Time.UTC()
+		rule.EndDate = &value
+	}
+	if count.Valid {
+		value := int(count.Int64)
+		rule.Count = &value
+	}
+	if materializedThrough.Valid {
+		value := materializedThrough.Time.UTC()
+		rule.MaterializedThrough = &value
+	}
+	return rule, nil
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"jarwise-backend/internal/models"
+	"strings"
+)
+
+// searchJarNamesSQL and searchWalletNameSQL give the names the LIKE fallback
+// searches, matching what the full-text index stores.
+const (
+	searchJarNamesSQL = `COALESCE((SELECT group_concat(j.name, ' ') FROM jars j
+		WHERE j.id = t.jar_id
+		   OR j.id IN (SELECT s.jar_id FROM transaction_splits s WHERE s.transaction_id = t.id)), '')`
+	searchWalletNameSQL = `COALESCE((SELECT w.name FROM wallets w WHERE w.id = t.wallet_id), '')`
+)
+
+type SearchRepository interface {
+	// SearchForUser returns the page of matches described by search.Terms,
+	// Limit and Offset, best first, and the number of matches overall. The
+	// highlights of each result hold the plain searched text.
+	SearchForUser(ctx context.Context, userID string, search models.TransactionSearch) ([]models.SearchResult, int, error)
+}
+
+type sqliteSearchRepository struct {
+	db           *sql.DB
+	transactions *sqliteTransactionRepository
+}
+
+func NewSQLiteSearchRepository(db *sql.DB) SearchRepository {
+	return &sqliteSearchRepository{db: db, transactions: &sqliteTransactionRepository{db: db}}
+}
+
+func (r *sqliteSearchRepository) SearchForUser(ctx context.Context, userID string, search models.TransactionSearch) ([]models.SearchResult, int, error) {
+	where := []string{"t.user_id = ?", "t.deleted_at IS NULL"}
+	args := []interface{}{normalizedUserID(userID)}
+	if !search.StartDate.IsZero() {
+		where = append(where, "t.date >= ?")
+		args = append(args, search.StartDate.UTC())
+	}
+	if !search.EndDate.IsZero() {
+		where = append(where, "t.date <= ?")
+		args = append(args, search.EndDate.UTC())
+	}
+	if len(search.JarIDs) > 0 {
+		where = append(where, "(t.jar_id IN ("+placeholders(len(search.JarIDs))+") OR t.id IN (SELECT transaction_id FROM transaction_splits WHERE jar_id IN ("+placeholders(len(search.JarIDs))+")))")
+		args = appendStrings(args, search.JarIDs)
+		args = appendStrings(args, search.JarIDs)
+	}
+
+	indexed, err := r.hasSearchIndex(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	// matches yields the id, date, score, jar_name and wallet_name of every
+	// match.
+	var matches string
+	if indexed {
+		// bm25 is lower for better matches and weighs the description most.
+		matches = `
+			SELECT t.id, t.date, -bm25(transactions_fts, 10.0, 3.0, 2.0) AS score,
+				transactions_fts.jar_name, transactions_fts.wallet_name
+			FROM transactions_fts
+			JOIN transaction_search_rows sr ON sr.id = transactions_fts.rowid
+			JOIN transactions t ON t.id = sr.transaction_id
+			WHERE transactions_fts MATCH ? AND ` + strings.Join(where, " AND ")
+		args = append([]interface{}{ftsMatchQuery(search.Terms)}, args...)
+	} else {
+		matches = `
+			SELECT id, date, 0.0 AS score, jar_name, wallet_name FROM (
+				SELECT t.id, t.date, t.description, ` + searchJarNamesSQL + ` AS jar_name, ` + searchWalletNameSQL + ` AS wallet_name
+				FROM transactions t
+				WHERE ` + strings.Join(where, " AND ") + `
+			)`
+		var likes []string
+		for _, term := range search.Terms {
+			likes = append(likes, `(description || ' ' || jar_name || ' ' || wallet_name) LIKE ? ESCAPE '\'`)
+			args = append(args, "%"+escapeLike(term)+"%")
+		}
+		if len(likes) > 0 {
+			matches += " WHERE " + strings.Join(likes, " AND ")
+		}
+	}
+
+	var total int
+	if err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM ("+matches+")", args...).Scan(&total); err != nil {
+		return nil, 0, err
+	}
+	if total == 0 || search.Offset >= total {
+		return nil, total, nil
+	}
+
+	rows, err := r.db.QueryContext(ctx, `SELECT id, score, jar_name, wallet_name FROM (`+matches+`)
+		ORDER BY score DESC, date DESC, id
+		LIMIT ? OFFSET ?`, append(args, search.Limit, search.Offset)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	var (
+		results []models.SearchResult
+		ids     []string
+	)
+	for rows.Next() {
+		var result models.SearchResult
+		if err := rows.Scan(&result.Transaction.ID, &result.Score, &result.Highlights.JarName, &result.Highlights.WalletName); err != nil {
+			rows.Close()
+			return nil, 0, err
+		}
+		results = append(results, result)
+		ids = append(ids, result.Transaction.ID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	transactions, err := r.transactions.listByQuery(`SELECT `+transactionColumns+`
+		FROM transactions WHERE id IN (`+placeholders(len(ids))+`)`, appendStrings(nil, ids)...)
+	if err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[string]models.Transaction, len(transactions))
+	for _, tx := range transactions {
+		byID[tx.ID] = tx
+	}
+	for i := range results {
+		results[i].Transaction = byID[results[i].Transaction.ID]
+		results[i].Highlights.Description = results[i].Transaction.Description
+	}
+	return results, total, nil
+}
+
+func (r *sqliteSearchRepository) hasSearchIndex(ctx context.Context) (bool, error) {
+	var count int
+	err := r.db.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE name = ?", "transactions_fts").Scan(&count)
+	return count > 0, err
+}
+
+// ftsMatchQuery requires every term as a word prefix. Terms only hold
+// letters and digits, so quoting them keeps FTS5 operators out.
+func ftsMatchQuery(terms []string) string {
+	quoted := make([]string, 0, len(terms))
+	for _, term := range terms {
+		quoted = append(quoted, `"`+strings.ReplaceAll(term, `"`, `""`)+`"*`)
+	}
+	return strings.Join(quoted, " ")
+}
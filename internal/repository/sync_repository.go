@@ -0,0 +1,68 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"jarwise-backend/internal/models"
+)
+
+const syncChangeColumns = `version, user_id, entity, entity_id, op, changed_at`
+
+// SyncRepository reads the change feed that triggers keep for wallets, jars
+// and transactions.
+type SyncRepository interface {
+	// ListChangesForUser returns up to limit of the user's changes after
+	// version since, oldest first.
+	ListChangesForUser(ctx context.Context, userID string, since int64, limit int) ([]models.SyncChange, error)
+	// GetChange returns the latest change of an entity, whoever owns it, or
+	// nil when it has never existed.
+	GetChange(ctx context.Context, entity, id string) (*models.SyncChange, error)
+}
+
+type sqliteSyncRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteSyncRepository(db *sql.DB) SyncRepository {
+	return &sqliteSyncRepository{db: db}
+}
+
+func (r *sqliteSyncRepository) ListChangesForUser(ctx context.Context, userID string, since int64, limit int) ([]models.SyncChange, error) {
+	rows, err := r.db.QueryContext(ctx, `SELECT `+syncChangeColumns+`
+		FROM sync_changes
+		WHERE user_id = ? AND version > ?
+		ORDER BY version
+		LIMIT ?`, normalizedUserID(userID), since, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var changes []models.SyncChange
+	for rows.Next() {
+		change, err := scanSyncChange(rows)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, change)
+	}
+	return changes, rows.Err()
+}
+
+func (r *sqliteSyncRepository) GetChange(ctx context.Context, entity, id string) (*models.SyncChange, error) {
+	change, err := scanSyncChange(r.db.QueryRowContext(ctx, `SELECT `+syncChangeColumns+`
+		FROM sync_changes WHERE entity = ? AND entity_id = ?`, entity, id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &change, nil
+}
+
+func scanSyncChange(scanner rowScanner) (models.SyncChange, error) {
+	var change models.SyncChange
+	err := scanner.Scan(&change.Version, &change.UserID, &change.Entity, &change.ID, &change.Op, &change.ChangedAt)
+	return change, err
+}
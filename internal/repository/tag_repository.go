@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"jarwise-backend/internal/models"
+)
+
+const tagColumns = `g.id, g.user_id, g.name, g.color, g.created_at`
+
+type TagRepository interface {
+	Create(ctx context.Context, tag *models.Tag) error
+	// Update renames or recolours a tag. It returns sql.ErrNoRows when the
+	// tag does not exist for that user.
+	Update(ctx context.Context, tag *models.Tag) error
+	GetForUser(ctx context.Context, userID, id string) (*models.Tag, error)
+	ListForUser(ctx context.Context, userID string) ([]models.Tag, error)
+	// DeleteForUser removes the tag from every transaction and then deletes it.
+	DeleteForUser(ctx context.Context, userID, id string) error
+}
+
+type sqliteTagRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteTagRepository(db *sql.DB) TagRepository {
+	return &sqliteTagRepository{db: db}
+}
+
+func (r *sqliteTagRepository) Create(ctx context.Context, tag *models.Tag) error {
+	tag.UserID = normalizedUserID(tag.UserID)
+	_, err := execWrite(ctx, r.db, `
+		INSERT INTO tags (id, user_id, name, color, created_at)
+		VALUES (?, ?, ?, ?, ?)
+	`, tag.ID, tag.UserID, tag.Name, tag.Color, tag.CreatedAt.UTC())
+	return err
+}
+
+func (r *sqliteTagRepository) Update(ctx context.Context, tag *models.Tag) error {
+	tag.UserID = normalizedUserID(tag.UserID)
+	result, err := execWrite(ctx, r.db, `
+		UPDATE tags SET name = ?, color = ?
+		WHERE user_id = ? AND id = ?
+	`, tag.Name, tag.Color, tag.UserID, tag.ID)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	return nil
+}
+
+func (r *sqliteTagRepository) GetForUser(ctx context.Context, userID, id string) (*models.Tag, error) {
+	query := `SELECT ` + tagColumns + ` FROM tags g WHERE g.user_id = ? AND g.id = ?`
+	tag, err := scanTag(r.db.QueryRowContext(ctx, query, normalizedUserID(userID), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tag, nil
+}
+
+func (r *sqliteTagRepository) ListForUser(ctx context.Context, userID string) ([]models.Tag, error) {
+	query := `SELECT ` + tagColumns + ` FROM tags g WHERE g.user_id = ? ORDER BY g.name, g.id`
+	rows, err := r.db.QueryContext(ctx, query, normalizedUserID(userID))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tags []models.Tag
+	for rows.Next() {
+		tag, err := scanTag(rows)
+		if err != nil {
+			return nil, err
+		}
+		tags = append(tags, tag)
+	}
+	return tags, rows.Err()
+}
+
+func (r *sqliteTagRepository) DeleteForUser(ctx context.Context, userID, id string) error {
+	userID = normalizedUserID(userID)
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM tags WHERE user_id = ? AND id = ?", userID, id)
+	if err != nil {
+		return err
+	}
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affected == 0 {
+		return sql.ErrNoRows
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM transaction_tags WHERE tag_id = ?", id); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func scanTag(scanner rowScanner) (models.Tag, error) {
+	var tag models.Tag
+	err := scanner.Scan(&tag.ID, &tag.UserID, &tag.Name, &tag.Color, &tag.CreatedAt)
+	return tag, err
+}
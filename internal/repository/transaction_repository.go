@@ -1,27 +1,84 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"jarwise-backend/internal/models"
+	"strings"
 	"time"
 )
 
+const defaultTransactionPageSize = 50
+
+// transactionColumns is the select list read by getByQuery and listByQuery. The
+// currency comes from the wallet the transaction belongs to.
+const transactionColumns = `id, user_id, amount, description, date, type, wallet_id, jar_id, related_transaction_id,
+	COALESCE((SELECT currency FROM wallets WHERE wallets.id = transactions.wallet_id), ''), version, updated_at`
+
+// jarLinesSQL yields one row per jar a transaction is filed under: each split
+// line of a split transaction, or the transaction itself otherwise. It has
+// the id, user_id, date, type, wallet_id, jar_id and amount columns.
+// Transactions in the trash are left out.
+const jarLinesSQL = `SELECT t.id, t.user_id, t.date, t.type, t.wallet_id,
+		COALESCE(s.jar_id, t.jar_id) AS jar_id, COALESCE(s.amount, t.amount) AS amount
+	FROM transactions t
+	LEFT JOIN transaction_splits s ON s.transaction_id = t.id
+	WHERE t.deleted_at IS NULL`
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type TransactionRepository interface {
-	Create(tx *models.Transaction) error
-	CreateTransfer(expense, income *models.Transaction) error
+	Create(ctx context.Context, tx *models.Transaction) error
+	Update(ctx context.Context, tx *models.Transaction) error
+	CreateTransfer(ctx context.Context, expense, income, fee *models.Transaction) error
+	// GetTransferForUser resolves either leg or the fee of a transfer to the
+	// whole transfer. It returns nil when id is not part of an intact transfer.
+	GetTransferForUser(userID, id string) (*models.Transfer, error)
+	// UpdateTransfer rewrites both legs and the fee in place in one database
+	// transaction, adding the fee when it is new and removing it when fee is
+	// nil. It returns sql.ErrNoRows when the legs no longer reference each
+	// other, and ErrVersionMismatch unless the outgoing leg is still at
+	// expense.Version (zero skips the check).
+	UpdateTransfer(ctx context.Context, expense, income, fee *models.Transaction) error
+	// DeleteTransferForUser removes both legs and the fee of the transfer whose
+	// outgoing leg is expenseID. It returns sql.ErrNoRows when the legs no
+	// longer reference each other, and ErrVersionMismatch unless the outgoing
+	// leg is at version.
+	DeleteTransferForUser(ctx context.Context, userID, expenseID string, version int64) error
 	GetByID(id string) (*models.Transaction, error)
 	GetByIDForUser(userID, id string) (*models.Transaction, error)
 	ListAll() ([]models.Transaction, error)
 	ListAllForUser(userID string) ([]models.Transaction, error)
+	// ListByIDsForUser returns those of the user's transactions in ids that
+	// exist, in no particular order.
+	ListByIDsForUser(userID string, ids []string) ([]models.Transaction, error)
+	ListPageForUser(userID string, filter models.TransactionListFilter) (*models.TransactionPage, error)
 	ListByDateRange(start, end time.Time) ([]models.Transaction, error)
 	ListByDateRangeForUser(userID string, start, end time.Time) ([]models.Transaction, error)
+	// ListRefundsByExpenseDate lists the refunds of expenses dated between
+	// start and end, whenever the refunds themselves were made.
+	ListRefundsByExpenseDate(start, end time.Time) ([]models.Transaction, error)
+	ListRefundsByExpenseDateForUser(userID string, start, end time.Time) ([]models.Transaction, error)
+	// SetRefundOfForUser links an income to the expense it refunds, or
+	// unlinks it when expenseID is nil, and recomputes its jar allocation.
+	// It returns sql.ErrNoRows when the income does not exist, and
+	// ErrVersionMismatch unless it is at version.
+	SetRefundOfForUser(ctx context.Context, userID, incomeID string, expenseID *string, version int64) error
+	// RefundedAmountForUser totals the refunds of an expense, leaving out
+	// excludeID.
+	RefundedAmountForUser(userID, expenseID, excludeID string) (models.Money, error)
 	Delete(id string) error
-	DeleteForUser(userID, id string) error
+	// DeleteForUser fails with ErrVersionMismatch unless the transaction is
+	// at version.
+	DeleteForUser(ctx context.Context, userID, id string, version int64) error
 	Unlink(id1, id2 string) error
-	UnlinkForUser(userID, id1, id2 string) error
-	GetExpenseGraphData(jarID, period string) ([]models.GraphDataPoint, error)
-	GetExpenseGraphDataForUser(userID, jarID, period string) ([]models.GraphDataPoint, error)
+	UnlinkForUser(ctx context.Context, userID, id1, id2 string) error
+	GetExpenseGraphData(jarID, period string) ([]models.GraphDailyTotal, error)
+	GetExpenseGraphDataForUser(userID, jarID, period string) ([]models.GraphDailyTotal, error)
 }
 
 type sqliteTransactionRepository struct {
@@ -32,21 +89,135 @@ func NewSQLiteTransactionRepository(db *sql.DB) TransactionRepository {
 	return &sqliteTransactionRepository{db: db}
 }
 
-func (r *sqliteTransactionRepository) Create(tx *models.Transaction) error {
+func (r *sqliteTransactionRepository) Create(ctx context.Context, tx *models.Transaction) error {
 	tx.UserID = normalizedUserID(tx.UserID)
+	dbTx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer dbTx.Rollback()
+
+	if err := insertTransaction(dbTx, tx); err != nil {
+		return err
+	}
+
+	return dbTx.Commit()
+}
+
+// insertTransaction writes a new transaction together with its side effects
+// (income allocation and the cached wallet balance) inside dbTx.
+func insertTransaction(dbTx *WriteTx, tx *models.Transaction) error {
 	query := `INSERT INTO transactions 
 		(id, user_id, amount, description, date, type, wallet_id, jar_id, related_transaction_id) 
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
-	_, err := r.db.Exec(query,
+	_, err := dbTx.Exec(query,
 		tx.ID, tx.UserID, tx.Amount, tx.Description, tx.Date.UTC(), tx.Type,
-		tx.WalletID, tx.JarID, tx.RelatedTransactionID)
-	return err
+		tx.WalletID, nullableString(tx.JarID), tx.RelatedTransactionID)
+	if err != nil {
+		return err
+	}
+	if err := readVersion(dbTx, "transactions", tx.ID, &tx.Version, &tx.UpdatedAt); err != nil {
+		return err
+	}
+	if err := replaceSplits(dbTx, tx); err != nil {
+		return fmt.Errorf("failed to store splits: %w", err)
+	}
+	if err := replaceTags(dbTx, tx); err != nil {
+		return fmt.Errorf("failed to store tags: %w", err)
+	}
+
+	if err := allocateIncome(dbTx, tx); err != nil {
+		return fmt.Errorf("failed to allocate income: %w", err)
+	}
+	if err := RefreshWalletBalances(dbTx, tx.UserID, tx.WalletID); err != nil {
+		return fmt.Errorf("failed to refresh wallet balance: %w", err)
+	}
+	return nil
+}
+
+// replaceSplits stores tx.Splits as the transaction's only split lines.
+func replaceSplits(dbTx *WriteTx, tx *models.Transaction) error {
+	if _, err := dbTx.Exec("DELETE FROM transaction_splits WHERE transaction_id = ?", tx.ID); err != nil {
+		return err
+	}
+	for i, split := range tx.Splits {
+		_, err := dbTx.Exec(`INSERT INTO transaction_splits (id, transaction_id, user_id, jar_id, amount, description, position)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			split.ID, tx.ID, tx.UserID, split.JarID, split.Amount, split.Description, i)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (r *sqliteTransactionRepository) CreateTransfer(expense, income *models.Transaction) error {
+// replaceTags links the transaction to exactly the tags in tx.Tags.
+func replaceTags(dbTx *WriteTx, tx *models.Transaction) error {
+	if _, err := dbTx.Exec("DELETE FROM transaction_tags WHERE transaction_id = ?", tx.ID); err != nil {
+		return err
+	}
+	for _, tag := range tx.Tags {
+		if _, err := dbTx.Exec("INSERT INTO transaction_tags (transaction_id, tag_id) VALUES (?, ?)", tx.ID, tag.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Update rewrites a transaction with its splits and tags. It returns
+// ErrVersionMismatch when tx.Version is set but no longer current.
+func (r *sqliteTransactionRepository) Update(ctx context.Context, tx *models.Transaction) error {
+	tx.UserID = normalizedUserID(tx.UserID)
+	dbTx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer dbTx.Rollback()
+
+	if err := checkVersion(dbTx, "transactions", tx.UserID, tx.ID, tx.Version); err != nil {
+		return err
+	}
+	var previousWalletID string
+	err = dbTx.QueryRow("SELECT wallet_id FROM transactions WHERE user_id = ? AND id = ?", tx.UserID, tx.ID).Scan(&previousWalletID)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE transactions
+		SET amount = ?, description = ?, date = ?, type = ?, wallet_id = ?, jar_id = ?
+		WHERE user_id = ? AND id = ?`
+	_, err = dbTx.Exec(query,
+		tx.Amount, tx.Description, tx.Date.UTC(), tx.Type, tx.WalletID, nullableString(tx.JarID),
+		tx.UserID, tx.ID)
+	if err != nil {
+		return err
+	}
+	if err := readVersion(dbTx, "transactions", tx.ID, &tx.Version, &tx.UpdatedAt); err != nil {
+		return err
+	}
+	if err := replaceSplits(dbTx, tx); err != nil {
+		return fmt.Errorf("failed to store splits: %w", err)
+	}
+	if err := replaceTags(dbTx, tx); err != nil {
+		return fmt.Errorf("failed to store tags: %w", err)
+	}
+
+	if err := allocateIncome(dbTx, tx); err != nil {
+		return fmt.Errorf("failed to allocate income: %w", err)
+	}
+	if err := RefreshWalletBalances(dbTx, tx.UserID, previousWalletID, tx.WalletID); err != nil {
+		return fmt.Errorf("failed to refresh wallet balance: %w", err)
+	}
+
+	return dbTx.Commit()
+}
+
+// CreateTransfer writes both legs of a transfer, and the fee when it is not
+// nil, in one database transaction.
+func (r *sqliteTransactionRepository) CreateTransfer(ctx context.Context, expense, income, fee *models.Transaction) error {
 	expense.UserID = normalizedUserID(expense.UserID)
 	income.UserID = normalizedUserID(income.UserID)
-	tx, err := r.db.Begin()
+	tx, err := BeginWrite(ctx, r.db)
 	if err != nil {
 		return err
 	}
@@ -56,10 +227,16 @@ func (r *sqliteTransactionRepository) CreateTransfer(expense, income *models.Tra
 		(id, user_id, amount, description, date, type, wallet_id, jar_id, related_transaction_id) 
 		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`
 
+	// The legs reference each other, so the link can only be checked once
+	// both rows exist.
+	if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		return err
+	}
+
 	// Insert Expense
 	_, err = tx.Exec(query,
 		expense.ID, expense.UserID, expense.Amount, expense.Description, expense.Date.UTC(), expense.Type,
-		expense.WalletID, expense.JarID, expense.RelatedTransactionID)
+		expense.WalletID, nullableString(expense.JarID), expense.RelatedTransactionID)
 	if err != nil {
 		return fmt.Errorf("failed to insert expense: %w", err)
 	}
@@ -67,42 +244,436 @@ func (r *sqliteTransactionRepository) CreateTransfer(expense, income *models.Tra
 	// Insert Income
 	_, err = tx.Exec(query,
 		income.ID, income.UserID, income.Amount, income.Description, income.Date.UTC(), income.Type,
-		income.WalletID, income.JarID, income.RelatedTransactionID)
+		income.WalletID, nullableString(income.JarID), income.RelatedTransactionID)
 	if err != nil {
 		return fmt.Errorf("failed to insert income: %w", err)
 	}
 
+	walletIDs := []string{expense.WalletID, income.WalletID}
+	if fee != nil {
+		fee.UserID = normalizedUserID(fee.UserID)
+		_, err = tx.Exec(query,
+			fee.ID, fee.UserID, fee.Amount, fee.Description, fee.Date.UTC(), fee.Type,
+			fee.WalletID, nullableString(fee.JarID), fee.RelatedTransactionID)
+		if err != nil {
+			return fmt.Errorf("failed to insert fee: %w", err)
+		}
+		walletIDs = append(walletIDs, fee.WalletID)
+	}
+
+	if err := RefreshWalletBalances(tx, expense.UserID, walletIDs...); err != nil {
+		return fmt.Errorf("failed to refresh wallet balances: %w", err)
+	}
+	if err := readTransferVersions(tx, expense, income, fee); err != nil {
+		return err
+	}
+
 	return tx.Commit()
 }
 
+// readTransferVersions loads the versions of the transactions of a transfer
+// just written; fee may be nil.
+func readTransferVersions(tx *WriteTx, legs ...*models.Transaction) error {
+	for _, leg := range legs {
+		if leg == nil {
+			continue
+		}
+		if err := readVersion(tx, "transactions", leg.ID, &leg.Version, &leg.UpdatedAt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *sqliteTransactionRepository) GetTransferForUser(userID, id string) (*models.Transfer, error) {
+	userID = normalizedUserID(userID)
+	tx, err := r.GetByIDForUser(userID, id)
+	if err != nil || tx == nil || tx.RelatedTransactionID == nil {
+		return nil, err
+	}
+	partner, err := r.GetByIDForUser(userID, *tx.RelatedTransactionID)
+	if err != nil || partner == nil || partner.RelatedTransactionID == nil {
+		return nil, err
+	}
+
+	expense, income := tx, partner
+	switch {
+	case *partner.RelatedTransactionID != tx.ID:
+		// tx is a fee pointing at the outgoing leg
+		expense = partner
+		income, err = r.GetByIDForUser(userID, *expense.RelatedTransactionID)
+		if err != nil || income == nil || income.RelatedTransactionID == nil || *income.RelatedTransactionID != expense.ID {
+			return nil, err
+		}
+	case tx.Amount > 0:
+		// tx is the incoming leg
+		expense, income = partner, tx
+	}
+
+	fees, err := r.listByQuery(`SELECT `+transactionColumns+` FROM transactions
+		WHERE user_id = ? AND related_transaction_id = ? AND id != ? AND deleted_at IS NULL
+		ORDER BY date, id`, userID, expense.ID, income.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	transfer := &models.Transfer{
+		ID:                 expense.ID,
+		ExpenseTransaction: expense,
+		IncomeTransaction:  income,
+	}
+	if len(fees) > 0 {
+		transfer.FeeTransaction = &fees[0]
+	}
+	return transfer, nil
+}
+
+func (r *sqliteTransactionRepository) UpdateTransfer(ctx context.Context, expense, income, fee *models.Transaction) error {
+	userID := normalizedUserID(expense.UserID)
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	walletIDs, err := lockTransferLegs(tx, userID, expense.ID, income.ID)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(tx, "transactions", userID, expense.ID, expense.Version); err != nil {
+		return err
+	}
+	// The fee keeps its ID across edits; any other fee on the transfer goes.
+	keepFeeID := ""
+	if fee != nil {
+		keepFeeID = fee.ID
+	}
+	feeWalletIDs, err := deleteTransferFees(tx, userID, expense.ID, income.ID, keepFeeID)
+	if err != nil {
+		return err
+	}
+	walletIDs = append(walletIDs, feeWalletIDs...)
+
+	query := `UPDATE transactions
+		SET amount = ?, description = ?, date = ?, wallet_id = ?, jar_id = ?
+		WHERE user_id = ? AND id = ?`
+	for _, leg := range []*models.Transaction{expense, income} {
+		leg.UserID = userID
+		_, err := tx.Exec(query,
+			leg.Amount, leg.Description, leg.Date.UTC(), leg.WalletID, nullableString(leg.JarID),
+			userID, leg.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update transfer leg: %w", err)
+		}
+		walletIDs = append(walletIDs, leg.WalletID)
+	}
+
+	if fee != nil {
+		fee.UserID = userID
+		result, err := tx.Exec(query+" AND related_transaction_id = ? AND deleted_at IS NULL",
+			fee.Amount, fee.Description, fee.Date.UTC(), fee.WalletID, nullableString(fee.JarID),
+			userID, fee.ID, expense.ID)
+		if err != nil {
+			return fmt.Errorf("failed to update fee: %w", err)
+		}
+		if affected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			// The transfer had no fee yet.
+			_, err = tx.Exec(`INSERT INTO transactions 
+				(id, user_id, amount, description, date, type, wallet_id, jar_id, related_transaction_id) 
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+				fee.ID, fee.UserID, fee.Amount, fee.Description, fee.Date.UTC(), fee.Type,
+				fee.WalletID, nullableString(fee.JarID), fee.RelatedTransactionID)
+			if err != nil {
+				return fmt.Errorf("failed to insert fee: %w", err)
+			}
+		}
+		walletIDs = append(walletIDs, fee.WalletID)
+	}
+
+	if err := RefreshWalletBalances(tx, userID, walletIDs...); err != nil {
+		return fmt.Errorf("failed to refresh wallet balances: %w", err)
+	}
+	if err := readTransferVersions(tx, expense, income, fee); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqliteTransactionRepository) DeleteTransferForUser(ctx context.Context, userID, expenseID string, version int64) error {
+	userID = normalizedUserID(userID)
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var incomeID string
+	err = tx.QueryRow("SELECT related_transaction_id FROM transactions WHERE user_id = ? AND id = ? AND related_transaction_id IS NOT NULL AND deleted_at IS NULL", userID, expenseID).Scan(&incomeID)
+	if err != nil {
+		return err
+	}
+	walletIDs, err := lockTransferLegs(tx, userID, expenseID, incomeID)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(tx, "transactions", userID, expenseID, version); err != nil {
+		return err
+	}
+	feeWalletIDs, err := deleteTransferFees(tx, userID, expenseID, incomeID, "")
+	if err != nil {
+		return err
+	}
+	walletIDs = append(walletIDs, feeWalletIDs...)
+
+	// The legs reference each other, so the link can only be checked once
+	// both rows are gone.
+	if _, err := tx.Exec("PRAGMA defer_foreign_keys = ON"); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM jar_allocations WHERE transaction_id IN (?, ?)", expenseID, incomeID); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM transactions WHERE user_id = ? AND id IN (?, ?)", userID, expenseID, incomeID); err != nil {
+		return err
+	}
+
+	if err := RefreshWalletBalances(tx, userID, walletIDs...); err != nil {
+		return fmt.Errorf("failed to refresh wallet balances: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// lockTransferLegs checks inside tx that the two legs still reference each
+// other and returns the wallets they currently belong to.
+func lockTransferLegs(tx *WriteTx, userID, expenseID, incomeID string) ([]string, error) {
+	rows, err := tx.Query(`SELECT wallet_id FROM transactions
+		WHERE user_id = ? AND deleted_at IS NULL
+			AND ((id = ? AND related_transaction_id = ?) OR (id = ? AND related_transaction_id = ?))`,
+		userID, expenseID, incomeID, incomeID, expenseID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var walletIDs []string
+	for rows.Next() {
+		var walletID string
+		if err := rows.Scan(&walletID); err != nil {
+			return nil, err
+		}
+		walletIDs = append(walletIDs, walletID)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	if len(walletIDs) != 2 {
+		return nil, sql.ErrNoRows
+	}
+	return walletIDs, nil
+}
+
+// deleteTransferFees removes the fees charged on a transfer, except keepID
+// when it is not empty, and returns the wallets they were drawn from.
+func deleteTransferFees(tx *WriteTx, userID, expenseID, incomeID, keepID string) ([]string, error) {
+	rows, err := tx.Query(`DELETE FROM transactions
+		WHERE user_id = ? AND related_transaction_id = ? AND id NOT IN (?, ?)
+		RETURNING wallet_id`, userID, expenseID, incomeID, keepID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var walletIDs []string
+	for rows.Next() {
+		var walletID string
+		if err := rows.Scan(&walletID); err != nil {
+			return nil, err
+		}
+		walletIDs = append(walletIDs, walletID)
+	}
+	return walletIDs, rows.Err()
+}
+
 func (r *sqliteTransactionRepository) GetByID(id string) (*models.Transaction, error) {
-	query := `SELECT id, user_id, amount, description, date, type, wallet_id, jar_id, related_transaction_id 
-		FROM transactions WHERE id = ?`
+	query := `SELECT ` + transactionColumns + `
+		FROM transactions WHERE id = ? AND deleted_at IS NULL`
 
 	return r.getByQuery(query, id)
 }
 
 func (r *sqliteTransactionRepository) GetByIDForUser(userID, id string) (*models.Transaction, error) {
-	query := `SELECT id, user_id, amount, description, date, type, wallet_id, jar_id, related_transaction_id 
-		FROM transactions WHERE user_id = ? AND id = ?`
+	query := `SELECT ` + transactionColumns + `
+		FROM transactions WHERE user_id = ? AND id = ? AND deleted_at IS NULL`
 	return r.getByQuery(query, normalizedUserID(userID), id)
 }
 
 func (r *sqliteTransactionRepository) ListAll() ([]models.Transaction, error) {
-	query := `SELECT id, user_id, amount, description, date, type, wallet_id, jar_id, related_transaction_id
+	query := `SELECT ` + transactionColumns + `
 		FROM transactions
+		WHERE deleted_at IS NULL
 		ORDER BY date DESC`
 	return r.listByQuery(query)
 }
 
 func (r *sqliteTransactionRepository) ListAllForUser(userID string) ([]models.Transaction, error) {
-	query := `SELECT id, user_id, amount, description, date, type, wallet_id, jar_id, related_transaction_id
+	query := `SELECT ` + transactionColumns + `
 		FROM transactions
-		WHERE user_id = ?
+		WHERE user_id = ? AND deleted_at IS NULL
 		ORDER BY date DESC`
 	return r.listByQuery(query, normalizedUserID(userID))
 }
 
+func (r *sqliteTransactionRepository) ListByIDsForUser(userID string, ids []string) ([]models.Transaction, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{normalizedUserID(userID)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	query := `SELECT ` + transactionColumns + `
+		FROM transactions
+		WHERE user_id = ? AND id IN (` + placeholders(len(ids)) + `) AND deleted_at IS NULL`
+	return r.listByQuery(query, args...)
+}
+
+// ListPageForUser returns one keyset-paginated page. The cursor encodes the
+// sort value and ID of the last row so pages stay stable while new rows arrive.
+func (r *sqliteTransactionRepository) ListPageForUser(userID string, filter models.TransactionListFilter) (*models.TransactionPage, error) {
+	where := []string{"user_id = ?", "deleted_at IS NULL"}
+	args := []interface{}{normalizedUserID(userID)}
+
+	if !filter.StartDate.IsZero() {
+		where = append(where, "date >= ?")
+		args = append(args, filter.StartDate.UTC())
+	}
+	if !filter.EndDate.IsZero() {
+		where = append(where, "date <= ?")
+		args = append(args, filter.EndDate.UTC())
+	}
+	if len(filter.WalletIDs) > 0 {
+		where = append(where, "wallet_id IN ("+placeholders(len(filter.WalletIDs))+")")
+		args = appendStrings(args, filter.WalletIDs)
+	}
+	if len(filter.JarIDs) > 0 {
+		where = append(where, "(jar_id IN ("+placeholders(len(filter.JarIDs))+") OR id IN (SELECT transaction_id FROM transaction_splits WHERE jar_id IN ("+placeholders(len(filter.JarIDs))+")))")
+		args = appendStrings(args, filter.JarIDs)
+		args = appendStrings(args, filter.JarIDs)
+	}
+	if len(filter.Types) > 0 {
+		where = append(where, "type IN ("+placeholders(len(filter.Types))+")")
+		args = appendStrings(args, filter.Types)
+	}
+	// Transfer legs store the outgoing side as a negative amount, so range
+	// filters and sorting work on the magnitude.
+	if filter.MinAmount != nil {
+		where = append(where, "ABS(amount) >= ?")
+		args = append(args, *filter.MinAmount)
+	}
+	if filter.MaxAmount != nil {
+		where = append(where, "ABS(amount) <= ?")
+		args = append(args, *filter.MaxAmount)
+	}
+	if filter.Description != "" {
+		where = append(where, `description LIKE ? ESCAPE '\'`)
+		args = append(args, "%"+escapeLike(filter.Description)+"%")
+	}
+
+	sortColumn := "date"
+	if filter.SortBy == "amount" {
+		sortColumn = "ABS(amount)"
+	}
+	direction, comparison := "DESC", "<"
+	if filter.SortOrder == "asc" {
+		direction, comparison = "ASC", ">"
+	}
+
+	if filter.Cursor != "" {
+		cursor, err := decodeTransactionCursor(filter.Cursor)
+		if err != nil {
+			return nil, err
+		}
+		var value interface{} = cursor.Date.UTC()
+		if filter.SortBy == "amount" {
+			value = cursor.Amount
+		}
+		where = append(where, fmt.Sprintf("(%s %s ? OR (%s = ? AND id %s ?))", sortColumn, comparison, sortColumn, comparison))
+		args = append(args, value, value, cursor.ID)
+	}
+
+	limit := filter.Limit
+	if limit <= 0 {
+		limit = defaultTransactionPageSize
+	}
+
+	query := `SELECT ` + transactionColumns + `
+		FROM transactions
+		WHERE ` + strings.Join(where, " AND ") + `
+		ORDER BY ` + sortColumn + ` ` + direction + `, id ` + direction + `
+		LIMIT ?`
+	args = append(args, limit+1)
+
+	results, err := r.listByQuery(query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &models.TransactionPage{Transactions: results}
+	if len(results) > limit {
+		page.Transactions = results[:limit]
+		page.HasMore = true
+		last := page.Transactions[limit-1]
+		page.NextCursor = encodeTransactionCursor(transactionCursor{Date: last.Date, Amount: last.Amount.Abs(), ID: last.ID})
+	}
+	if page.Transactions == nil {
+		page.Transactions = []models.Transaction{}
+	}
+	return page, nil
+}
+
+type transactionCursor struct {
+	Date   time.Time    `json:"d"`
+	Amount models.Money `json:"a"`
+	ID     string       `json:"id"`
+}
+
+func encodeTransactionCursor(cursor transactionCursor) string {
+	raw, _ := json.Marshal(cursor)
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+func decodeTransactionCursor(value string) (transactionCursor, error) {
+	var cursor transactionCursor
+	raw, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return cursor, ErrInvalidCursor
+	}
+	if err := json.Unmarshal(raw, &cursor); err != nil || cursor.ID == "" {
+		return cursor, ErrInvalidCursor
+	}
+	return cursor, nil
+}
+
+func placeholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?,", n), ",")
+}
+
+func appendStrings(args []interface{}, values []string) []interface{} {
+	for _, value := range values {
+		args = append(args, value)
+	}
+	return args
+}
+
+func escapeLike(value string) string {
+	replacer := strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+	return replacer.Replace(value)
+}
+
 func (r *sqliteTransactionRepository) getByQuery(query string, args ...interface{}) (*models.Transaction, error) {
 	row := r.db.QueryRow(query, args...)
 	var tx models.Transaction
@@ -110,7 +681,7 @@ func (r *sqliteTransactionRepository) getByQuery(query string, args ...interface
 	var jarID sql.NullString
 
 	err := row.Scan(&tx.ID, &tx.UserID, &tx.Amount, &tx.Description, &tx.Date, &tx.Type,
-		&tx.WalletID, &jarID, &relatedID)
+		&tx.WalletID, &jarID, &relatedID, &tx.Currency, &tx.Version, &tx.UpdatedAt)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil, nil
@@ -125,26 +696,183 @@ func (r *sqliteTransactionRepository) getByQuery(query string, args ...interface
 		tx.JarID = jarID.String
 	}
 
-	return &tx, nil
+	single := []models.Transaction{tx}
+	if err := r.attachSplits(single); err != nil {
+		return nil, err
+	}
+	if err := r.attachTags(single); err != nil {
+		return nil, err
+	}
+	return &single[0], nil
+}
+
+// detailBatchSize keeps the IN list of attachSplits and attachTags well under
+// SQLite's limit on bound parameters.
+const detailBatchSize = 500
+
+// attachSplits loads the split lines of the given transactions in place.
+func (r *sqliteTransactionRepository) attachSplits(transactions []models.Transaction) error {
+	index := make(map[string]int, len(transactions))
+	ids := make([]string, 0, len(transactions))
+	for i := range transactions {
+		index[transactions[i].ID] = i
+		ids = append(ids, transactions[i].ID)
+	}
+
+	for start := 0; start < len(ids); start += detailBatchSize {
+		end := start + detailBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+		rows, err := r.db.Query(`SELECT transaction_id, id, jar_id, amount, description FROM transaction_splits
+			WHERE transaction_id IN (`+placeholders(len(batch))+`)
+			ORDER BY transaction_id, position`, appendStrings(nil, batch)...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var transactionID string
+			var split models.TransactionSplit
+			if err := rows.Scan(&transactionID, &split.ID, &split.JarID, &split.Amount, &split.Description); err != nil {
+				rows.Close()
+				return err
+			}
+			tx := &transactions[index[transactionID]]
+			tx.Splits = append(tx.Splits, split)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// attachTags loads the tags of the given transactions in place.
+func (r *sqliteTransactionRepository) attachTags(transactions []models.Transaction) error {
+	index := make(map[string]int, len(transactions))
+	ids := make([]string, 0, len(transactions))
+	for i := range transactions {
+		index[transactions[i].ID] = i
+		ids = append(ids, transactions[i].ID)
+	}
+
+	for start := 0; start < len(ids); start += detailBatchSize {
+		end := start + detailBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+		rows, err := r.db.Query(`SELECT tt.transaction_id, `+tagColumns+`
+			FROM transaction_tags tt
+			JOIN tags g ON g.id = tt.tag_id
+			WHERE tt.transaction_id IN (`+placeholders(len(batch))+`)
+			ORDER BY tt.transaction_id, g.name`, appendStrings(nil, batch)...)
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var transactionID string
+			var tag models.Tag
+			if err := rows.Scan(&transactionID, &tag.ID, &tag.UserID, &tag.Name, &tag.Color, &tag.CreatedAt); err != nil {
+				rows.Close()
+				return err
+			}
+			tx := &transactions[index[transactionID]]
+			tx.Tags = append(tx.Tags, tag)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func (r *sqliteTransactionRepository) ListByDateRange(start, end time.Time) ([]models.Transaction, error) {
-	query := `SELECT id, user_id, amount, description, date, type, wallet_id, jar_id, related_transaction_id
+	query := `SELECT ` + transactionColumns + `
 		FROM transactions
-		WHERE date >= ? AND date <= ?
+		WHERE date >= ? AND date <= ? AND deleted_at IS NULL
 		ORDER BY date DESC`
 
 	return r.listByDateRangeQuery(query, start.UTC(), end.UTC())
 }
 
 func (r *sqliteTransactionRepository) ListByDateRangeForUser(userID string, start, end time.Time) ([]models.Transaction, error) {
-	query := `SELECT id, user_id, amount, description, date, type, wallet_id, jar_id, related_transaction_id
+	query := `SELECT ` + transactionColumns + `
 		FROM transactions
-		WHERE user_id = ? AND date >= ? AND date <= ?
+		WHERE user_id = ? AND date >= ? AND date <= ? AND deleted_at IS NULL
 		ORDER BY date DESC`
 	return r.listByDateRangeQuery(query, normalizedUserID(userID), start.UTC(), end.UTC())
 }
 
+func (r *sqliteTransactionRepository) ListRefundsByExpenseDate(start, end time.Time) ([]models.Transaction, error) {
+	query := `SELECT ` + transactionColumns + `
+		FROM transactions
+		WHERE type = 'income' AND deleted_at IS NULL AND related_transaction_id IN (
+			SELECT id FROM transactions WHERE type = 'expense' AND date >= ? AND date <= ? AND deleted_at IS NULL
+		)
+		ORDER BY date DESC`
+	return r.listByQuery(query, start.UTC(), end.UTC())
+}
+
+func (r *sqliteTransactionRepository) ListRefundsByExpenseDateForUser(userID string, start, end time.Time) ([]models.Transaction, error) {
+	userID = normalizedUserID(userID)
+	query := `SELECT ` + transactionColumns + `
+		FROM transactions
+		WHERE user_id = ? AND type = 'income' AND deleted_at IS NULL AND related_transaction_id IN (
+			SELECT id FROM transactions WHERE user_id = ? AND type = 'expense' AND date >= ? AND date <= ? AND deleted_at IS NULL
+		)
+		ORDER BY date DESC`
+	return r.listByQuery(query, userID, userID, start.UTC(), end.UTC())
+}
+
+func (r *sqliteTransactionRepository) SetRefundOfForUser(ctx context.Context, userID, incomeID string, expenseID *string, version int64) error {
+	userID = normalizedUserID(userID)
+	dbTx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer dbTx.Rollback()
+
+	if err := checkVersion(dbTx, "transactions", userID, incomeID, version); err != nil {
+		return err
+	}
+
+	result, err := dbTx.Exec("UPDATE transactions SET related_transaction_id = ? WHERE user_id = ? AND id = ? AND type = 'income' AND deleted_at IS NULL", expenseID, userID, incomeID)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	// A refund is not new money, so it is not allocated to jars.
+	var tx models.Transaction
+	err = dbTx.QueryRow("SELECT id, user_id, amount, date, type FROM transactions WHERE id = ?", incomeID).
+		Scan(&tx.ID, &tx.UserID, &tx.Amount, &tx.Date, &tx.Type)
+	if err != nil {
+		return err
+	}
+	tx.RelatedTransactionID = expenseID
+	if err := allocateIncome(dbTx, &tx); err != nil {
+		return fmt.Errorf("failed to allocate income: %w", err)
+	}
+
+	return dbTx.Commit()
+}
+
+func (r *sqliteTransactionRepository) RefundedAmountForUser(userID, expenseID, excludeID string) (models.Money, error) {
+	var refunded models.Money
+	err := r.db.QueryRow(`SELECT COALESCE(SUM(ABS(amount)), 0) FROM transactions
+		WHERE user_id = ? AND type = 'income' AND related_transaction_id = ? AND id != ? AND deleted_at IS NULL`,
+		normalizedUserID(userID), expenseID, excludeID).Scan(&refunded)
+	return refunded, err
+}
+
 func (r *sqliteTransactionRepository) listByDateRangeQuery(query string, args ...interface{}) ([]models.Transaction, error) {
 	return r.listByQuery(query, args...)
 }
@@ -162,7 +890,7 @@ func (r *sqliteTransactionRepository) listByQuery(query string, args ...interfac
 		var relatedID sql.NullString
 		var jarID sql.NullString
 
-		if err := rows.Scan(&tx.ID, &tx.UserID, &tx.Amount, &tx.Description, &tx.Date, &tx.Type, &tx.WalletID, &jarID, &relatedID); err != nil {
+		if err := rows.Scan(&tx.ID, &tx.UserID, &tx.Amount, &tx.Description, &tx.Date, &tx.Type, &tx.WalletID, &jarID, &relatedID, &tx.Currency, &tx.Version, &tx.UpdatedAt); err != nil {
 			return nil, err
 		}
 		if relatedID.Valid {
@@ -177,35 +905,51 @@ func (r *sqliteTransactionRepository) listByQuery(query string, args ...interfac
 	if err := rows.Err(); err != nil {
 		return nil, err
 	}
+	rows.Close()
 
+	if err := r.attachSplits(results); err != nil {
+		return nil, err
+	}
+	if err := r.attachTags(results); err != nil {
+		return nil, err
+	}
 	return results, nil
 }
 
 func (r *sqliteTransactionRepository) Delete(id string) error {
-	return r.deleteByQuery("SELECT related_transaction_id FROM transactions WHERE id = ?", "DELETE FROM transactions WHERE id = ?", []interface{}{id}, []interface{}{id}, false)
+	return r.deleteByQuery(context.Background(), "SELECT id, related_transaction_id, wallet_id FROM transactions WHERE id = ?", "DELETE FROM transactions WHERE id = ?", []interface{}{id}, []interface{}{id}, 0, false)
 }
 
-func (r *sqliteTransactionRepository) DeleteForUser(userID, id string) error {
+func (r *sqliteTransactionRepository) DeleteForUser(ctx context.Context, userID, id string, version int64) error {
 	normalized := normalizedUserID(userID)
-	return r.deleteByQuery(
-		"SELECT related_transaction_id FROM transactions WHERE user_id = ? AND id = ?",
+	return r.deleteByQuery(ctx,
+		"SELECT id, related_transaction_id, wallet_id FROM transactions WHERE user_id = ? AND id = ? AND deleted_at IS NULL",
 		"DELETE FROM transactions WHERE user_id = ? AND id = ?",
 		[]interface{}{normalized, id},
 		[]interface{}{normalized, id},
+		version,
 		true,
 	)
 }
 
-func (r *sqliteTransactionRepository) deleteByQuery(selectQuery, deleteQuery string, selectArgs, deleteArgs []interface{}, scoped bool) error {
-	tx, err := r.db.Begin()
+func (r *sqliteTransactionRepository) deleteByQuery(ctx context.Context, selectQuery, deleteQuery string, selectArgs, deleteArgs []interface{}, version int64, scoped bool) error {
+	tx, err := BeginWrite(ctx, r.db)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	if version != 0 {
+		err := checkVersion(tx, "transactions", selectArgs[0].(string), selectArgs[1].(string), version)
+		if err != nil && err != sql.ErrNoRows {
+			return err
+		}
+	}
+
 	// 1. Check for link
+	var id, walletID string
 	var relatedID sql.NullString
-	err = tx.QueryRow(selectQuery, selectArgs...).Scan(&relatedID)
+	err = tx.QueryRow(selectQuery, selectArgs...).Scan(&id, &relatedID, &walletID)
 	if err != nil {
 		if err == sql.ErrNoRows {
 			return nil // Already deleted?
@@ -213,42 +957,55 @@ func (r *sqliteTransactionRepository) deleteByQuery(selectQuery, deleteQuery str
 		return err
 	}
 
-	// 2. Unlink pair if exists
-	if relatedID.Valid {
-		if scoped {
-			_, err = tx.Exec("UPDATE transactions SET related_transaction_id = NULL WHERE user_id = ? AND id = ?", selectArgs[0], relatedID.String)
-		} else {
-			_, err = tx.Exec("UPDATE transactions SET related_transaction_id = NULL WHERE id = ?", relatedID.String)
-		}
-		if err != nil {
-			return err
-		}
+	// 2. Unlink every transaction that points at this one: the other leg of
+	// a transfer and any transfer fee
+	if scoped {
+		_, err = tx.Exec("UPDATE transactions SET related_transaction_id = NULL WHERE user_id = ? AND related_transaction_id = ?", selectArgs[0], id)
+	} else {
+		_, err = tx.Exec("UPDATE transactions SET related_transaction_id = NULL WHERE related_transaction_id = ?", id)
+	}
+	if err != nil {
+		return err
 	}
 
 	// 3. Delete
+	if _, err := tx.Exec("DELETE FROM jar_allocations WHERE transaction_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM transaction_splits WHERE transaction_id = ?", id); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM transaction_tags WHERE transaction_id = ?", id); err != nil {
+		return err
+	}
 	_, err = tx.Exec(deleteQuery, deleteArgs...)
 	if err != nil {
 		return err
 	}
 
+	// 4. Keep the cached wallet balance in sync
+	if err := RefreshWalletBalances(tx, "", walletID); err != nil {
+		return fmt.Errorf("failed to refresh wallet balance: %w", err)
+	}
+
 	return tx.Commit()
 }
 
 func (r *sqliteTransactionRepository) Unlink(id1, id2 string) error {
-	return r.unlinkByQuery("UPDATE transactions SET related_transaction_id = NULL WHERE id = ?", []interface{}{id1}, []interface{}{id2})
+	return r.unlinkByQuery(context.Background(), "UPDATE transactions SET related_transaction_id = NULL WHERE id = ?", []interface{}{id1}, []interface{}{id2})
 }
 
-func (r *sqliteTransactionRepository) UnlinkForUser(userID, id1, id2 string) error {
+func (r *sqliteTransactionRepository) UnlinkForUser(ctx context.Context, userID, id1, id2 string) error {
 	normalized := normalizedUserID(userID)
-	return r.unlinkByQuery(
+	return r.unlinkByQuery(ctx,
 		"UPDATE transactions SET related_transaction_id = NULL WHERE user_id = ? AND id = ?",
 		[]interface{}{normalized, id1},
 		[]interface{}{normalized, id2},
 	)
 }
 
-func (r *sqliteTransactionRepository) unlinkByQuery(query string, args1, args2 []interface{}) error {
-	tx, err := r.db.Begin()
+func (r *sqliteTransactionRepository) unlinkByQuery(ctx context.Context, query string, args1, args2 []interface{}) error {
+	tx, err := BeginWrite(ctx, r.db)
 	if err != nil {
 		return err
 	}
@@ -264,15 +1021,17 @@ func (r *sqliteTransactionRepository) unlinkByQuery(query string, args1, args2 [
 	return tx.Commit()
 }
 
-func (r *sqliteTransactionRepository) GetExpenseGraphData(jarID, period string) ([]models.GraphDataPoint, error) {
+func (r *sqliteTransactionRepository) GetExpenseGraphData(jarID, period string) ([]models.GraphDailyTotal, error) {
 	return r.getExpenseGraphDataQuery("", jarID, period)
 }
 
-func (r *sqliteTransactionRepository) GetExpenseGraphDataForUser(userID, jarID, period string) ([]models.GraphDataPoint, error) {
+func (r *sqliteTransactionRepository) GetExpenseGraphDataForUser(userID, jarID, period string) ([]models.GraphDailyTotal, error) {
 	return r.getExpenseGraphDataQuery(normalizedUserID(userID), jarID, period)
 }
 
-func (r *sqliteTransactionRepository) getExpenseGraphDataQuery(userID, jarID, period string) ([]models.GraphDataPoint, error) {
+// getExpenseGraphDataQuery totals a jar's expenses per day and wallet
+// currency so that the caller can convert each day at its own exchange rate.
+func (r *sqliteTransactionRepository) getExpenseGraphDataQuery(userID, jarID, period string) ([]models.GraphDailyTotal, error) {
 	var dateFormat string
 	switch period {
 	case "weekly":
@@ -287,21 +1046,24 @@ func (r *sqliteTransactionRepository) getExpenseGraphDataQuery(userID, jarID, pe
 
 	query := `
 		SELECT 
-			strftime('` + dateFormat + `', date) as period_label, 
-			ABS(SUM(amount)) as total_amount
-		FROM transactions 
+			strftime('` + dateFormat + `', t.date) as period_label, 
+			date(t.date) as day,
+			COALESCE(w.currency, '') as currency,
+			SUM(ABS(t.amount)) as total_amount
+		FROM (` + jarLinesSQL + `) t
+		LEFT JOIN wallets w ON w.id = t.wallet_id
 		WHERE 
-			jar_id = ? 
-			AND type = 'expense'
+			t.jar_id = ? 
+			AND t.type = 'expense'
 	`
 	args := []interface{}{jarID}
 	if userID != "" {
-		query += ` AND user_id = ?`
+		query += ` AND t.user_id = ?`
 		args = append(args, userID)
 	}
 	query += `
-		GROUP BY period_label
-		ORDER BY period_label ASC
+		GROUP BY period_label, day, currency
+		ORDER BY period_label ASC, day ASC, currency ASC
 	`
 
 	rows, err := r.db.Query(query, args...)
@@ -310,18 +1072,18 @@ func (r *sqliteTransactionRepository) getExpenseGraphDataQuery(userID, jarID, pe
 	}
 	defer rows.Close()
 
-	var dataPoints []models.GraphDataPoint
+	var totals []models.GraphDailyTotal
 	for rows.Next() {
-		var label string
-		var amount float64
-		if err := rows.Scan(&label, &amount); err != nil {
+		var total models.GraphDailyTotal
+		var day string
+		if err := rows.Scan(&total.Label, &day, &total.Currency, &total.Amount); err != nil {
+			return nil, err
+		}
+		if total.Date, err = time.Parse("2006-01-02", day); err != nil {
 			return nil, err
 		}
-		dataPoints = append(dataPoints, models.GraphDataPoint{
-			Label:  label,
-			Amount: amount,
-		})
+		totals = append(totals, total)
 	}
 
-	return dataPoints, nil
+	return totals, rows.Err()
 }
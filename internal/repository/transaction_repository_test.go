@@ -1,6 +1,8 @@
 package repository
 
 import (
+	"context"
+	"fmt"
 	"jarwise-backend/internal/db"
 	"jarwise-backend/internal/models"
 	"testing"
@@ -40,7 +42,7 @@ func TestCreateTransfer_Atomic(t *testing.T) {
 
 	// 3. Execute
 	// 3. Execute
-	err = repo.CreateTransfer(txn1, txn2)
+	err = repo.CreateTransfer(context.Background(), txn1, txn2, nil)
 
 	if err != nil {
 		t.Fatalf("CreateTransfer failed: %v", err)
@@ -91,7 +93,7 @@ func TestDeleteTransaction_UnlinksRelated(t *testing.T) {
 		Date:                 time.Now(),
 		RelatedTransactionID: &linkID1,
 	}
-	_ = repo.CreateTransfer(txn1, txn2)
+	_ = repo.CreateTransfer(context.Background(), txn1, txn2, nil)
 
 	// 2. Execute Delete on Tx1
 	err = repo.Delete("tx1")
@@ -119,3 +121,144 @@ func TestDeleteTransaction_UnlinksRelated(t *testing.T) {
 		t.Errorf("Tx2 should be unlinked (RelatedID should be nil), got: %v", *remainingTx.RelatedTransactionID)
 	}
 }
+
+func TestListPageForUser_PaginatesAndFilters(t *testing.T) {
+	database := setupTestDB(t)
+	defer database.Close()
+	repo := NewSQLiteTransactionRepository(database)
+
+	if _, err := database.Exec("INSERT INTO wallets (id, user_id, name, currency) VALUES ('w1', 'user-1', 'Cash', 'THB'), ('w2', 'user-1', 'Bank', 'THB')"); err != nil {
+		t.Fatalf("failed to seed wallets: %v", err)
+	}
+
+	base := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < 7; i++ {
+		walletID := "w1"
+		if i%2 == 1 {
+			walletID = "w2"
+		}
+		tx := &models.Transaction{
+			ID:          fmt.Sprintf("tx-%d", i),
+			UserID:      "user-1",
+			Amount:      models.Money(100 * (i + 1)),
+			Description: fmt.Sprintf("Coffee #%d", i),
+			Date:        base.AddDate(0, 0, i),
+			Type:        "expense",
+			WalletID:    walletID,
+		}
+		if err := repo.Create(context.Background(), tx); err != nil {
+			t.Fatalf("failed to create transaction: %v", err)
+		}
+	}
+	if err := repo.Create(context.Background(), &models.Transaction{ID: "other-user", UserID: "user-2", Amount: 1, Date: base, Type: "expense", WalletID: "w1"}); err != nil {
+		t.Fatalf("failed to create transaction: %v", err)
+	}
+
+	// Walk all pages newest first.
+	var seen []string
+	filter := models.TransactionListFilter{Limit: 3}
+	for {
+		page, err := repo.ListPageForUser("user-1", filter)
+		if err != nil {
+			t.Fatalf("ListPageForUser failed: %v", err)
+		}
+		for _, tx := range page.Transactions {
+			seen = append(seen, tx.ID)
+		}
+		if !page.HasMore {
+			break
+		}
+		filter.Cursor = page.NextCursor
+	}
+	expected := []string{"tx-6", "tx-5", "tx-4", "tx-3", "tx-2", "tx-1", "tx-0"}
+	if fmt.Sprint(seen) != fmt.Sprint(expected) {
+		t.Fatalf("expected %v, got %v", expected, seen)
+	}
+
+	minAmount, maxAmount := models.Money(200), models.Money(500)
+	page, err := repo.ListPageForUser("user-1", models.TransactionListFilter{
+		WalletIDs: []string{"w2"},
+		MinAmount: &minAmount,
+		MaxAmount: &maxAmount,
+		SortBy:    "amount",
+		SortOrder: "asc",
+	})
+	if err != nil {
+		t.Fatalf("ListPageForUser failed: %v", err)
+	}
+	if len(page.Transactions) != 2 || page.Transactions[0].ID != "tx-1" || page.Transactions[1].ID != "tx-3" {
+		t.Fatalf("unexpected filtered page: %+v", page.Transactions)
+	}
+
+	page, err = repo.ListPageForUser("user-1", models.TransactionListFilter{Description: "#4"})
+	if err != nil {
+		t.Fatalf("ListPageForUser failed: %v", err)
+	}
+	if len(page.Transactions) != 1 || page.Transactions[0].ID != "tx-4" {
+		t.Fatalf("expected description match on tx-4, got %+v", page.Transactions)
+	}
+
+	if _, err := repo.ListPageForUser("user-1", models.TransactionListFilter{Cursor: "not-a-cursor"}); err != ErrInvalidCursor {
+		t.Fatalf("expected ErrInvalidCursor, got %v", err)
+	}
+}
+
+func TestTransactionSplits_StoredAndCountedPerJar(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	jarRepo := NewSQLiteJarRepository(dbConn)
+	txRepo := NewSQLiteTransactionRepository(dbConn)
+	budgetRepo := NewSQLiteBudgetRepository(dbConn)
+
+	if err := NewSQLiteWalletRepository(dbConn).Create(ctx, &models.Wallet{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB"}); err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	for _, id := range []string{"groceries", "household", "misc"} {
+		if err := jarRepo.Create(ctx, &models.Jar{ID: id, UserID: "user-1", Name: id, Type: "expense"}); err != nil {
+			t.Fatalf("Failed to create jar: %v", err)
+		}
+	}
+
+	date := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	receipt := &models.Transaction{
+		ID: "receipt", UserID: "user-1", Amount: 1500, Date: date, Type: "expense", WalletID: "wallet-1",
+		Splits: []models.TransactionSplit{
+			{ID: "line-1", JarID: "groceries", Amount: 1000},
+			{ID: "line-2", JarID: "household", Amount: 500, Description: "Soap"},
+		},
+	}
+	if err := txRepo.Create(ctx, receipt); err != nil {
+		t.Fatalf("Failed to create split transaction: %v", err)
+	}
+
+	stored, err := txRepo.GetByIDForUser("user-1", "receipt")
+	if err != nil || stored == nil {
+		t.Fatalf("GetByIDForUser failed: %v", err)
+	}
+	if len(stored.Splits) != 2 || stored.Splits[1] != receipt.Splits[1] {
+		t.Fatalf("Expected splits to round-trip in order, got %+v", stored.Splits)
+	}
+
+	spent, err := budgetRepo.SpentForUser(ctx, "user-1", "household", date.AddDate(0, 0, -1), date.AddDate(0, 0, 1))
+	if err != nil || spent != 500 {
+		t.Errorf("Expected 5.00 spent from the household split, got %s, %v", spent, err)
+	}
+
+	if err := jarRepo.DeleteWithReplacementForUser(ctx, "user-1", "household", "misc", 0); err != nil {
+		t.Fatalf("DeleteWithReplacementForUser failed: %v", err)
+	}
+	page, err := txRepo.ListPageForUser("user-1", models.TransactionListFilter{JarIDs: []string{"misc"}, SortBy: "date", SortOrder: "desc"})
+	if err != nil || len(page.Transactions) != 1 || page.Transactions[0].Splits[1].JarID != "misc" {
+		t.Errorf("Expected the moved split to be found under misc, got %+v, %v", page, err)
+	}
+
+	if err := txRepo.DeleteForUser(ctx, "user-1", "receipt", 0); err != nil {
+		t.Fatalf("DeleteForUser failed: %v", err)
+	}
+	var lines int
+	if err := dbConn.QueryRow("SELECT COUNT(*) FROM transaction_splits").Scan(&lines); err != nil || lines != 0 {
+		t.Errorf("Expected split lines to be deleted, got %d, %v", lines, err)
+	}
+}
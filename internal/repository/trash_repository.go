@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"jarwise-backend/internal/models"
+	"time"
+)
+
+const deletionColumns = `id, user_id, entity, entity_id, name, deleted_at`
+
+// TrashRepository manages the deletion batches in the trash. The rows of a
+// batch carry its id in deletion_id.
+type TrashRepository interface {
+	// ListForUser returns the user's batches, most recently deleted first.
+	ListForUser(ctx context.Context, userID string) ([]models.Deletion, error)
+	GetForUser(ctx context.Context, userID, id string) (*models.Deletion, error)
+	// RestoreForUser brings every row of the batch back and forgets the
+	// batch. It returns sql.ErrNoRows when the user has no such batch.
+	RestoreForUser(ctx context.Context, userID, id string) error
+	// ListExpired returns the batches deleted at or before before.
+	ListExpired(ctx context.Context, before time.Time) ([]models.Deletion, error)
+	// Purge removes the rows of the batch for good, along with the rows
+	// that only make sense with them, and forgets the batch.
+	Purge(ctx context.Context, userID, id string) error
+}
+
+type sqliteTrashRepository struct {
+	db *sql.DB
+}
+
+func NewSQLiteTrashRepository(db *sql.DB) TrashRepository {
+	return &sqliteTrashRepository{db: db}
+}
+
+func (r *sqliteTrashRepository) ListForUser(ctx context.Context, userID string) ([]models.Deletion, error) {
+	return r.listByQuery(ctx, `SELECT `+deletionColumns+` FROM deletions WHERE user_id = ? ORDER BY deleted_at DESC, id`, normalizedUserID(userID))
+}
+
+func (r *sqliteTrashRepository) GetForUser(ctx context.Context, userID, id string) (*models.Deletion, error) {
+	d, err := scanDeletion(r.db.QueryRowContext(ctx, `SELECT `+deletionColumns+` FROM deletions WHERE user_id = ? AND id = ?`, normalizedUserID(userID), id))
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (r *sqliteTrashRepository) ListExpired(ctx context.Context, before time.Time) ([]models.Deletion, error) {
+	return r.listByQuery(ctx, `SELECT `+deletionColumns+` FROM deletions WHERE deleted_at <= ? ORDER BY deleted_at, id`, before.UTC())
+}
+
+func (r *sqliteTrashRepository) listByQuery(ctx context.Context, query string, args ...interface{}) ([]models.Deletion, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var deletions []models.Deletion
+	for rows.Next() {
+		d, err := scanDeletion(rows)
+		if err != nil {
+			return nil, err
+		}
+		deletions = append(deletions, d)
+	}
+	return deletions, rows.Err()
+}
+
+func scanDeletion(scanner rowScanner) (models.Deletion, error) {
+	var d models.Deletion
+	err := scanner.Scan(&d.ID, &d.UserID, &d.Entity, &d.EntityID, &d.Name, &d.DeletedAt)
+	return d, err
+}
+
+func (r *sqliteTrashRepository) RestoreForUser(ctx context.Context, userID, id string) error {
+	userID = normalizedUserID(userID)
+
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "DELETE FROM deletions WHERE user_id = ? AND id = ?", userID, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err != nil {
+		return err
+	} else if affected == 0 {
+		return sql.ErrNoRows
+	}
+
+	// Transfer legs in other wallets come back too, so refresh every wallet
+	// the batch's transactions belong to.
+	rows, err := tx.QueryContext(ctx, "SELECT DISTINCT wallet_id FROM transactions WHERE user_id = ? AND deletion_id = ?", userID, id)
+	if err != nil {
+		return fmt.Errorf("failed to load restored wallets: %w", err)
+	}
+	var walletIDs []string
+	for rows.Next() {
+		var walletID string
+		if err := rows.Scan(&walletID); err != nil {
+			rows.Close()
+			return err
+		}
+		walletIDs = append(walletIDs, walletID)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// Parents come back before the rows that point at them, so the sync
+	// feed replays in an order clients can apply.
+	for _, table := range []string{"wallets", "jars", "recurring_rules", "transactions"} {
+		rows, err := tx.QueryContext(ctx, "UPDATE "+table+" SET deleted_at = NULL, deletion_id = NULL WHERE user_id = ? AND deletion_id = ? RETURNING id", userID, id)
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %w", table, err)
+		}
+		for rows.Next() {
+			var restoredID string
+			if err := rows.Scan(&restoredID); err != nil {
+				rows.Close()
+				return err
+			}
+			if table == "wallets" {
+				walletIDs = append(walletIDs, restoredID)
+			}
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+	}
+
+	if len(walletIDs) > 0 {
+		if err := RefreshWalletBalances(tx, userID, walletIDs...); err != nil {
+			return fmt.Errorf("failed to refresh wallet balances: %w", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *sqliteTrashRepository) Purge(ctx context.Context, userID, id string) error {
+	userID = normalizedUserID(userID)
+
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// Transfer legs and sub-jars in the batch reference each other, so only
+	// check foreign keys at commit.
+	if _, err := tx.ExecContext(ctx, "PRAGMA defer_foreign_keys = ON"); err != nil {
+		return err
+	}
+
+	// A jar still named by a split line outside the batch cannot go, so it
+	// is brought back without a wallet instead.
+	_, err = tx.ExecContext(ctx, `
+		UPDATE jars SET wallet_id = NULL, deleted_at = NULL, deletion_id = NULL
+		WHERE user_id = ? AND deletion_id = ? AND EXISTS (
+			SELECT 1 FROM transaction_splits s JOIN transactions t ON t.id = s.transaction_id
+			WHERE s.jar_id = jars.id AND t.deletion_id IS NOT ?
+		)`, userID, id, id)
+	if err != nil {
+		return fmt.Errorf("failed to keep referenced jars: %w", err)
+	}
+
+	batch := []interface{}{userID, id}
+	batchTransactions := "SELECT id FROM transactions WHERE user_id = ? AND deletion_id = ?"
+	batchJars := "SELECT id FROM jars WHERE user_id = ? AND deletion_id = ?"
+	batchRules := "SELECT id FROM recurring_rules WHERE user_id = ? AND deletion_id = ?"
+	outside := "user_id = ? AND deletion_id IS NOT ?"
+	outsideArgs := []interface{}{userID, id, userID, id}
+
+	// Rows outside the batch let go of it first: the other leg of a transfer
+	// or a refund, and anything filed under one of its jars.
+	for _, step := range []struct{ what, query string }{
+		{"transaction links", "UPDATE transactions SET related_transaction_id = NULL WHERE " + outside + " AND related_transaction_id IN (" + batchTransactions + ")"},
+		{"transaction jars", "UPDATE transactions SET jar_id = NULL WHERE " + outside + " AND jar_id IN (" + batchJars + ")"},
+		{"sub-jars", "UPDATE jars SET parent_id = NULL WHERE " + outside + " AND parent_id IN (" + batchJars + ")"},
+		{"recurring rule jars", "UPDATE recurring_rules SET jar_id = NULL WHERE " + outside + " AND jar_id IN (" + batchJars + ")"},
+	} {
+		if _, err := tx.ExecContext(ctx, step.query, outsideArgs...); err != nil {
+			return fmt.Errorf("failed to unlink %s: %w", step.what, err)
+		}
+	}
+
+	for _, step := range []struct {
+		what, query string
+		args        []interface{}
+	}{
+		{"jar allocations", "DELETE FROM jar_allocations WHERE transaction_id IN (" + batchTransactions + ") OR jar_id IN (" + batchJars + ")", append(append([]interface{}{}, batch...), batch...)},
+		{"transaction splits", "DELETE FROM transaction_splits WHERE transaction_id IN (" + batchTransactions + ")", batch},
+		{"transaction tags", "DELETE FROM transaction_tags WHERE transaction_id IN (" + batchTransactions + ")", batch},
+		{"budgets", "DELETE FROM budgets WHERE jar_id IN (" + batchJars + ")", batch},
+		{"allocation rules", "DELETE FROM allocation_rules WHERE jar_id IN (" + batchJars + ")", batch},
+		{"recurring occurrences", "DELETE FROM recurring_occurrences WHERE rule_id IN (" + batchRules + ")", batch},
+		{"recurring rules", "DELETE FROM recurring_rules WHERE user_id = ? AND deletion_id = ?", batch},
+		{"transactions", "DELETE FROM transactions WHERE user_id = ? AND deletion_id = ?", batch},
+		{"jars", "DELETE FROM jars WHERE user_id = ? AND deletion_id = ?", batch},
+		{"wallets", "DELETE FROM wallets WHERE user_id = ? AND deletion_id = ?", batch},
+		{"deletion", "DELETE FROM deletions WHERE user_id = ? AND id = ?", batch},
+	} {
+		if _, err := tx.ExecContext(ctx, step.query, step.args...); err != nil {
+			return fmt.Errorf("failed to purge %s: %w", step.what, err)
+		}
+	}
+
+	return tx.Commit()
+}
@@ -1,6 +1,9 @@
 package repository
 
-import "jarwise-backend/internal/models"
+import (
+	"jarwise-backend/internal/models"
+	"time"
+)
 
 func normalizedUserID(userID string) string {
 	if userID == "" {
@@ -8,3 +11,17 @@ func normalizedUserID(userID string) string {
 	}
 	return userID
 }
+
+func nullableString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func nullableTime(value *time.Time) interface{} {
+	if value == nil {
+		return nil
+	}
+	return value.UTC()
+}
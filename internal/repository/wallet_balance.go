@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"database/sql"
+	"strings"
+)
+
+// signedAmountSQL turns a transaction row into its effect on the wallet
+// balance. Income and expense are stored as magnitudes (some rows written
+// before transfers had their own type are negative), while transfers and any
+// other type are already signed.
+const signedAmountSQL = `CASE type WHEN 'income' THEN ABS(amount) WHEN 'expense' THEN -ABS(amount) ELSE amount END`
+
+// Executor is satisfied by both *sql.DB and *sql.Tx so balance refreshes can
+// run inside the caller's transaction.
+type Executor interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// RefreshWalletBalances recomputes the cached wallets.balance column from the
+// opening balance and the transaction ledger. When no wallet IDs are given,
+// every wallet owned by userID is refreshed.
+func RefreshWalletBalances(exec Executor, userID string, walletIDs ...string) error {
+	query := `UPDATE wallets
+		SET balance = COALESCE(opening_balance, 0) + COALESCE((
+			SELECT SUM(` + signedAmountSQL + `) FROM transactions
+			WHERE transactions.user_id = wallets.user_id AND transactions.wallet_id = wallets.id AND transactions.deleted_at IS NULL
+		), 0)`
+
+	ids := uniqueNonEmpty(walletIDs)
+	if len(ids) == 0 {
+		_, err := exec.Exec(query+` WHERE user_id = ?`, normalizedUserID(userID))
+		return err
+	}
+
+	args := appendStrings(nil, ids)
+	_, err := exec.Exec(query+` WHERE id IN (`+placeholders(len(ids))+`)`, args...)
+	return err
+}
+
+func uniqueNonEmpty(values []string) []string {
+	seen := make(map[string]struct{}, len(values))
+	results := make([]string, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		if _, ok := seen[value]; ok {
+			continue
+		}
+		seen[value] = struct{}{}
+		results = append(results, value)
+	}
+	return results
+}
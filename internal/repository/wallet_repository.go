@@ -1,24 +1,50 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"jarwise-backend/internal/models"
+	"time"
 )
 
+const walletColumns = `id, user_id, name, currency, COALESCE(opening_balance, 0), COALESCE(balance, 0), COALESCE(type, ''), archived_at, version, updated_at`
+
+// ErrWalletCurrencyLocked is returned when changing the currency of a wallet
+// that already has transactions, which would silently reinterpret them.
+var ErrWalletCurrencyLocked = errors.New("wallet currency cannot change once it has transactions")
+
+// ErrInvalidReplacementWallet is returned when a wallet cannot take over the
+// rows of a deleted one: it is the same wallet, belongs to someone else, is
+// archived or in the trash, or holds another currency.
+var ErrInvalidReplacementWallet = errors.New("invalid replacement wallet")
+
 type WalletRepository interface {
-	Create(wallet *models.Wallet) error
+	Create(ctx context.Context, wallet *models.Wallet) error
+	Update(ctx context.Context, wallet *models.Wallet) error
 	Get(id string) (*models.Wallet, error)
 	GetForUser(userID, id string) (*models.Wallet, error)
 	Delete(id string) error
-	DeleteForUser(userID, id string) error
+	// The ForUser deletes fail with ErrVersionMismatch unless the wallet is
+	// at version.
+	DeleteForUser(ctx context.Context, userID, id string, version int64) error
 	// To satisfy Data Integrity Requirement
 	DeleteWithReplacement(id string, replacementWalletID string) error
-	DeleteWithReplacementForUser(userID, id string, replacementWalletID string) error
+	DeleteWithReplacementForUser(ctx context.Context, userID, id string, replacementWalletID string, version int64) error
 	DeleteCascade(id string) error
-	DeleteCascadeForUser(userID, id string) error
+	// DeleteCascadeForUser moves the wallet to the trash together with its
+	// jars, transactions and recurring rules, all marked with deletion.ID.
+	// Transfers go whole, taking the other leg and the fee along from other
+	// wallets. Jars that other wallets still use stay live without a wallet. It fills in the rest of deletion and returns sql.ErrNoRows when the
+	// wallet does not exist for the user.
+	DeleteCascadeForUser(ctx context.Context, userID, id string, version int64, deletion *models.Deletion) error
 	ListAll() ([]models.Wallet, error)
 	ListAllForUser(userID string) ([]models.Wallet, error)
+	// ListByIDsForUser returns those of the user's wallets in ids that exist,
+	// in no particular order.
+	ListByIDsForUser(userID string, ids []string) ([]models.Wallet, error)
+	BalanceAsOfForUser(userID, id string, asOf time.Time) (*models.WalletBalance, error)
 }
 
 type sqliteWalletRepository struct {
@@ -29,28 +55,142 @@ func NewSQLiteWalletRepository(db *sql.DB) WalletRepository {
 	return &sqliteWalletRepository{db: db}
 }
 
-func (r *sqliteWalletRepository) Create(w *models.Wallet) error {
+// Create stores a new wallet. A fresh wallet has no transactions yet, so its
+// current balance starts at the opening balance.
+func (r *sqliteWalletRepository) Create(ctx context.Context, w *models.Wallet) error {
 	w.UserID = normalizedUserID(w.UserID)
-	query := `INSERT INTO wallets (id, user_id, name, currency, balance, type) VALUES (?, ?, ?, ?, ?, ?)`
-	_, err := r.db.Exec(query, w.ID, w.UserID, w.Name, w.Currency, w.Balance, w.Type)
-	return err
+	w.Balance = w.OpeningBalance
+	query := `INSERT INTO wallets (id, user_id, name, currency, opening_balance, balance, type, archived_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)`
+	if _, err := execWrite(ctx, r.db, query, w.ID, w.UserID, w.Name, w.Currency, w.OpeningBalance, w.Balance, w.Type, archivedAtValue(w)); err != nil {
+		return err
+	}
+	return readVersion(r.db, "wallets", w.ID, &w.Version, &w.UpdatedAt)
+}
+
+// Update saves the editable fields of a wallet owned by w.UserID and
+// recomputes its balance, since the opening balance may have changed.
+// It returns sql.ErrNoRows when the wallet does not exist for that user, and
+// ErrVersionMismatch when w.Version is set but no longer current.
+func (r *sqliteWalletRepository) Update(ctx context.Context, w *models.Wallet) error {
+	w.UserID = normalizedUserID(w.UserID)
+
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkVersion(tx, "wallets", w.UserID, w.ID, w.Version); err != nil {
+		return err
+	}
+
+	var currentCurrency string
+	err = tx.QueryRow("SELECT currency FROM wallets WHERE user_id = ? AND id = ?", w.UserID, w.ID).Scan(&currentCurrency)
+	if err != nil {
+		return err
+	}
+
+	if currentCurrency != w.Currency {
+		var transactionCount int
+		if err := tx.QueryRow("SELECT COUNT(*) FROM transactions WHERE user_id = ? AND wallet_id = ?", w.UserID, w.ID).Scan(&transactionCount); err != nil {
+			return err
+		}
+		if transactionCount > 0 {
+			return ErrWalletCurrencyLocked
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE wallets
+		SET name = ?, currency = ?, type = ?, opening_balance = ?, archived_at = ?
+		WHERE user_id = ? AND id = ?
+	`, w.Name, w.Currency, w.Type, w.OpeningBalance, archivedAtValue(w), w.UserID, w.ID)
+	if err != nil {
+		return err
+	}
+
+	if err := RefreshWalletBalances(tx, w.UserID, w.ID); err != nil {
+		return err
+	}
+	if err := tx.QueryRow("SELECT COALESCE(balance, 0), version, updated_at FROM wallets WHERE id = ?", w.ID).Scan(&w.Balance, &w.Version, &w.UpdatedAt); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func archivedAtValue(w *models.Wallet) interface{} {
+	if !w.Archived {
+		return nil
+	}
+	if w.ArchivedAt == nil {
+		now := time.Now().UTC()
+		w.ArchivedAt = &now
+	}
+	return w.ArchivedAt.UTC()
+}
+
+// rowScanner is satisfied by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func scanWallet(scanner rowScanner) (models.Wallet, error) {
+	var w models.Wallet
+	var archivedAt sql.NullTime
+	if err := scanner.Scan(&w.ID, &w.UserID, &w.Name, &w.Currency, &w.OpeningBalance, &w.Balance, &w.Type, &archivedAt, &w.Version, &w.UpdatedAt); err != nil {
+		return w, err
+	}
+	if archivedAt.Valid {
+		w.Archived = true
+		w.ArchivedAt = &archivedAt.Time
+	}
+	return w, nil
 }
 
 func (r *sqliteWalletRepository) Get(id string) (*models.Wallet, error) {
-	return r.getByQuery(`SELECT id, user_id, name, currency, balance, type FROM wallets WHERE id = ?`, id)
+	return r.getByQuery(`SELECT `+walletColumns+` FROM wallets WHERE id = ? AND deleted_at IS NULL`, id)
 }
 
 func (r *sqliteWalletRepository) GetForUser(userID, id string) (*models.Wallet, error) {
-	return r.getByQuery(`SELECT id, user_id, name, currency, balance, type FROM wallets WHERE user_id = ? AND id = ?`, normalizedUserID(userID), id)
+	return r.getByQuery(`SELECT `+walletColumns+` FROM wallets WHERE user_id = ? AND id = ? AND deleted_at IS NULL`, normalizedUserID(userID), id)
 }
 
 func (r *sqliteWalletRepository) getByQuery(query string, args ...interface{}) (*models.Wallet, error) {
-	w := &models.Wallet{}
-	err := r.db.QueryRow(query, args...).Scan(&w.ID, &w.UserID, &w.Name, &w.Currency, &w.Balance, &w.Type)
+	w, err := scanWallet(r.db.QueryRow(query, args...))
 	if err == sql.ErrNoRows {
 		return nil, nil
 	}
-	return w, err
+	if err != nil {
+		return nil, err
+	}
+	return &w, nil
+}
+
+// BalanceAsOfForUser replays the ledger up to and including asOf.
+func (r *sqliteWalletRepository) BalanceAsOfForUser(userID, id string, asOf time.Time) (*models.WalletBalance, error) {
+	wallet, err := r.GetForUser(userID, id)
+	if err != nil || wallet == nil {
+		return nil, err
+	}
+
+	var movement models.Money
+	err = r.db.QueryRow(`
+		SELECT COALESCE(SUM(`+signedAmountSQL+`), 0)
+		FROM transactions
+		WHERE user_id = ? AND wallet_id = ? AND date <= ? AND deleted_at IS NULL
+	`, wallet.UserID, wallet.ID, asOf.UTC()).Scan(&movement)
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.WalletBalance{
+		WalletID:       wallet.ID,
+		Currency:       wallet.Currency,
+		OpeningBalance: wallet.OpeningBalance,
+		Balance:        wallet.OpeningBalance + movement,
+		AsOf:           asOf,
+	}, nil
 }
 
 // Initial Delete implementation (will fail integrity check in TDD Red)
@@ -59,26 +199,48 @@ func (r *sqliteWalletRepository) Delete(id string) error {
 	return err
 }
 
-func (r *sqliteWalletRepository) DeleteForUser(userID, id string) error {
-	_, err := r.db.Exec("DELETE FROM wallets WHERE user_id = ? AND id = ?", normalizedUserID(userID), id)
-	return err
+func (r *sqliteWalletRepository) DeleteForUser(ctx context.Context, userID, id string, version int64) error {
+	userID = normalizedUserID(userID)
+
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkVersion(tx, "wallets", userID, id, version); err != nil {
+		return err
+	}
+	if _, err := tx.Exec("DELETE FROM wallets WHERE user_id = ? AND id = ?", userID, id); err != nil {
+		return err
+	}
+	return tx.Commit()
 }
 
 func (r *sqliteWalletRepository) DeleteWithReplacement(id string, replacementWalletID string) error {
-	return r.deleteWithReplacement("", id, replacementWalletID, false)
+	return r.deleteWithReplacement(context.Background(), "", id, replacementWalletID, 0, false)
 }
 
-func (r *sqliteWalletRepository) DeleteWithReplacementForUser(userID, id string, replacementWalletID string) error {
-	return r.deleteWithReplacement(normalizedUserID(userID), id, replacementWalletID, true)
+func (r *sqliteWalletRepository) DeleteWithReplacementForUser(ctx context.Context, userID, id string, replacementWalletID string, version int64) error {
+	return r.deleteWithReplacement(ctx, normalizedUserID(userID), id, replacementWalletID, version, true)
 }
 
-func (r *sqliteWalletRepository) deleteWithReplacement(userID, id string, replacementWalletID string, scoped bool) error {
-	tx, err := r.db.Begin()
+func (r *sqliteWalletRepository) deleteWithReplacement(ctx context.Context, userID, id string, replacementWalletID string, version int64, scoped bool) error {
+	tx, err := BeginWrite(ctx, r.db)
 	if err != nil {
 		return err
 	}
 	defer tx.Rollback()
 
+	if scoped {
+		if err := checkVersion(tx, "wallets", userID, id, version); err != nil {
+			return err
+		}
+	}
+	if err := checkReplacementWallet(tx, userID, id, replacementWalletID, scoped); err != nil {
+		return err
+	}
+
 	// 1. Move Jars to the replacement wallet
 	queryJars := "UPDATE jars SET wallet_id = ? WHERE wallet_id = ?"
 	argsJars := []interface{}{replacementWalletID, id}
@@ -102,8 +264,22 @@ func (r *sqliteWalletRepository) deleteWithReplacement(userID, id string, replac
 	if err != nil {
 		return fmt.Errorf("failed to re-assign transactions: %w", err)
 	}
+	if err := RefreshWalletBalances(tx, userID, replacementWalletID); err != nil {
+		return fmt.Errorf("failed to refresh replacement wallet balance: %w", err)
+	}
 
-	// 3. Delete the original wallet
+	// 3. Point recurring rules at the replacement wallet
+	queryRules := "UPDATE recurring_rules SET wallet_id = ? WHERE wallet_id = ?"
+	argsRules := []interface{}{replacementWalletID, id}
+	if scoped {
+		queryRules += " AND user_id = ?"
+		argsRules = append(argsRules, userID)
+	}
+	if _, err = tx.Exec(queryRules, argsRules...); err != nil {
+		return fmt.Errorf("failed to re-assign recurring rules: %w", err)
+	}
+
+	// 4. Delete the original wallet
 	deleteQuery := "DELETE FROM wallets WHERE id = ?"
 	deleteArgs := []interface{}{id}
 	if scoped {
@@ -118,12 +294,166 @@ func (r *sqliteWalletRepository) deleteWithReplacement(userID, id string, replac
 	return tx.Commit()
 }
 
+// checkReplacementWallet makes sure the replacement can take over the
+// wallet's rows. It runs inside the delete's transaction so the replacement
+// cannot change in between.
+func checkReplacementWallet(tx *WriteTx, userID, id, replacementWalletID string, scoped bool) error {
+	if replacementWalletID == id {
+		return fmt.Errorf("%w: a wallet cannot replace itself", ErrInvalidReplacementWallet)
+	}
+
+	query := "SELECT user_id, currency FROM wallets WHERE id = ? AND deleted_at IS NULL"
+	args := []interface{}{id}
+	if scoped {
+		query += " AND user_id = ?"
+		args = append(args, userID)
+	}
+	var owner, currency string
+	if err := tx.QueryRow(query, args...).Scan(&owner, &currency); err != nil {
+		return err
+	}
+
+	var replacementCurrency string
+	var archived, trashed bool
+	err := tx.QueryRow(
+		"SELECT currency, archived_at IS NOT NULL, deleted_at IS NOT NULL FROM wallets WHERE user_id = ? AND id = ?",
+		owner, replacementWalletID,
+	).Scan(&replacementCurrency, &archived, &trashed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("%w: wallet %s not found", ErrInvalidReplacementWallet, replacementWalletID)
+	}
+	if err != nil {
+		return err
+	}
+	switch {
+	case trashed:
+		// Rows moved under a trashed wallet would vanish from every listing.
+		return fmt.Errorf("%w: wallet %s is in the trash", ErrInvalidReplacementWallet, replacementWalletID)
+	case archived:
+		return fmt.Errorf("%w: wallet %s is archived", ErrInvalidReplacementWallet, replacementWalletID)
+	case replacementCurrency != currency:
+		return fmt.Errorf("%w: wallet %s holds %s, not %s", ErrInvalidReplacementWallet, replacementWalletID, replacementCurrency, currency)
+	}
+	return nil
+}
+
+// DeleteCascade removes the wallet with its jars and transactions for good,
+// trashed rows included.
 func (r *sqliteWalletRepository) DeleteCascade(id string) error {
 	return r.deleteCascade("", id, false)
 }
 
-func (r *sqliteWalletRepository) DeleteCascadeForUser(userID, id string) error {
-	return r.deleteCascade(normalizedUserID(userID), id, true)
+func (r *sqliteWalletRepository) DeleteCascadeForUser(ctx context.Context, userID, id string, version int64, deletion *models.Deletion) error {
+	userID = normalizedUserID(userID)
+
+	tx, err := BeginWrite(ctx, r.db)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := checkVersion(tx, "wallets", userID, id, version); err != nil {
+		return err
+	}
+
+	var name string
+	err = tx.QueryRow("SELECT name FROM wallets WHERE user_id = ? AND id = ? AND deleted_at IS NULL", userID, id).Scan(&name)
+	if err != nil {
+		return err
+	}
+
+	deletion.UserID = userID
+	deletion.Entity = "wallet"
+	deletion.EntityID = id
+	deletion.Name = name
+	deletedAt := deletion.DeletedAt.UTC()
+
+	// A jar still used by live rows of another wallet stays out of the batch
+	// and is kept without a wallet, as a purge would do. Keeping one jar can
+	// make its parent referenced in turn, so repeat until nothing changes.
+	for {
+		result, err := tx.ExecContext(ctx, `
+			UPDATE jars SET wallet_id = NULL
+			WHERE user_id = ? AND wallet_id = ? AND deleted_at IS NULL AND (
+				EXISTS (SELECT 1 FROM transactions t WHERE t.user_id = jars.user_id AND t.jar_id = jars.id AND t.deleted_at IS NULL AND t.wallet_id != ?)
+				OR EXISTS (
+					SELECT 1 FROM transaction_splits s JOIN transactions t ON t.id = s.transaction_id
+					WHERE s.jar_id = jars.id AND t.deleted_at IS NULL AND t.wallet_id != ?
+				)
+				OR EXISTS (SELECT 1 FROM jars c WHERE c.user_id = jars.user_id AND c.parent_id = jars.id AND c.deleted_at IS NULL AND c.wallet_id IS NOT ?)
+				OR EXISTS (SELECT 1 FROM recurring_rules r WHERE r.user_id = jars.user_id AND r.jar_id = jars.id AND r.deleted_at IS NULL AND r.wallet_id != ?)
+			)`, userID, id, id, id, id, id)
+		if err != nil {
+			return fmt.Errorf("failed to keep referenced jars: %w", err)
+		}
+		affected, err := result.RowsAffected()
+		if err != nil {
+			return err
+		}
+		if affected == 0 {
+			break
+		}
+	}
+
+	// Rows are trashed rather than deleted so the batch can be restored;
+	// jar allocations, splits and tags stay with their transactions.
+	for _, step := range []struct{ what, query string }{
+		{"transactions", "UPDATE transactions SET deleted_at = ?, deletion_id = ? WHERE user_id = ? AND wallet_id = ? AND deleted_at IS NULL"},
+		{"recurring rules", "UPDATE recurring_rules SET deleted_at = ?, deletion_id = ? WHERE user_id = ? AND wallet_id = ? AND deleted_at IS NULL"},
+		{"jars", "UPDATE jars SET deleted_at = ?, deletion_id = ? WHERE user_id = ? AND wallet_id = ? AND deleted_at IS NULL"},
+		{"wallet", "UPDATE wallets SET deleted_at = ?, deletion_id = ? WHERE user_id = ? AND id = ?"},
+	} {
+		if _, err := tx.Exec(step.query, deletedAt, deletion.ID, userID, id); err != nil {
+			return fmt.Errorf("failed to trash %s: %w", step.what, err)
+		}
+	}
+
+	// A transfer goes to the trash whole: the other leg and the fee follow
+	// the wallet's leg even when they sit in another wallet, so a restore
+	// brings the transfer back together. Fees point at the outgoing leg, so
+	// repeat until no more rows join the batch.
+	var otherWalletIDs []string
+	for {
+		rows, err := tx.QueryContext(ctx, `
+			UPDATE transactions SET deleted_at = ?, deletion_id = ?
+			WHERE user_id = ? AND deleted_at IS NULL AND related_transaction_id IN (
+				SELECT id FROM transactions WHERE user_id = ? AND deletion_id = ? AND type = 'transfer'
+			)
+			RETURNING wallet_id`, deletedAt, deletion.ID, userID, userID, deletion.ID)
+		if err != nil {
+			return fmt.Errorf("failed to trash transfer legs: %w", err)
+		}
+		joined := 0
+		for rows.Next() {
+			var walletID string
+			if err := rows.Scan(&walletID); err != nil {
+				rows.Close()
+				return err
+			}
+			otherWalletIDs = append(otherWalletIDs, walletID)
+			joined++
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if joined == 0 {
+			break
+		}
+	}
+	if len(otherWalletIDs) > 0 {
+		if err := RefreshWalletBalances(tx, userID, otherWalletIDs...); err != nil {
+			return fmt.Errorf("failed to refresh wallet balances: %w", err)
+		}
+	}
+
+	_, err = tx.Exec(`INSERT INTO deletions (id, user_id, entity, entity_id, name, deleted_at) VALUES (?, ?, ?, ?, ?, ?)`,
+		deletion.ID, userID, deletion.Entity, deletion.EntityID, deletion.Name, deletedAt)
+	if err != nil {
+		return fmt.Errorf("failed to record deletion: %w", err)
+	}
+
+	return tx.Commit()
 }
 
 func (r *sqliteWalletRepository) deleteCascade(userID, id string, scoped bool) error {
@@ -133,19 +463,44 @@ func (r *sqliteWalletRepository) deleteCascade(userID, id string, scoped bool) e
 	}
 	defer tx.Rollback()
 
-	// 1. Delete associated Transactions
+	// 1. Delete associated Transactions, their jar allocations, splits and tags
 	txQuery := "DELETE FROM transactions WHERE wallet_id = ?"
 	txArgs := []interface{}{id}
 	if scoped {
 		txQuery += " AND user_id = ?"
 		txArgs = append(txArgs, userID)
 	}
+	walletTransactions := "SELECT id FROM transactions WHERE wallet_id = ?"
+	if scoped {
+		walletTransactions += " AND user_id = ?"
+	}
+	if _, err = tx.Exec("DELETE FROM jar_allocations WHERE transaction_id IN ("+walletTransactions+")", txArgs...); err != nil {
+		return fmt.Errorf("failed to cascade delete jar allocations: %w", err)
+	}
+	if _, err = tx.Exec("DELETE FROM transaction_splits WHERE transaction_id IN ("+walletTransactions+")", txArgs...); err != nil {
+		return fmt.Errorf("failed to cascade delete transaction splits: %w", err)
+	}
+	if _, err = tx.Exec("DELETE FROM transaction_tags WHERE transaction_id IN ("+walletTransactions+")", txArgs...); err != nil {
+		return fmt.Errorf("failed to cascade delete transaction tags: %w", err)
+	}
 	_, err = tx.Exec(txQuery, txArgs...)
 	if err != nil {
 		return fmt.Errorf("failed to cascade delete transactions: %w", err)
 	}
 
-	// 2. Delete associated Jars
+	// 2. Delete recurring rules that post into the wallet
+	ruleFilter := "FROM recurring_rules WHERE wallet_id = ?"
+	if scoped {
+		ruleFilter += " AND user_id = ?"
+	}
+	if _, err = tx.Exec("DELETE FROM recurring_occurrences WHERE rule_id IN (SELECT id "+ruleFilter+")", txArgs...); err != nil {
+		return fmt.Errorf("failed to cascade delete recurring occurrences: %w", err)
+	}
+	if _, err = tx.Exec("DELETE "+ruleFilter, txArgs...); err != nil {
+		return fmt.Errorf("failed to cascade delete recurring rules: %w", err)
+	}
+
+	// 3. Delete associated Jars
 	jarQuery := "DELETE FROM jars WHERE wallet_id = ?"
 	jarArgs := []interface{}{id}
 	if scoped {
@@ -157,7 +512,7 @@ func (r *sqliteWalletRepository) deleteCascade(userID, id string, scoped bool) e
 		return fmt.Errorf("failed to cascade delete jars: %w", err)
 	}
 
-	// 3. Delete the Wallet
+	// 4. Delete the Wallet
 	walletQuery := "DELETE FROM wallets WHERE id = ?"
 	walletArgs := []interface{}{id}
 	if scoped {
@@ -172,7 +527,7 @@ func (r *sqliteWalletRepository) deleteCascade(userID, id string, scoped bool) e
 	return tx.Commit()
 }
 func (r *sqliteWalletRepository) ListAll() ([]models.Wallet, error) {
-	query := `SELECT id, user_id, name, currency, balance, type FROM wallets`
+	query := `SELECT ` + walletColumns + ` FROM wallets WHERE deleted_at IS NULL`
 	rows, err := r.db.Query(query)
 	if err != nil {
 		return nil, err
@@ -181,8 +536,8 @@ func (r *sqliteWalletRepository) ListAll() ([]models.Wallet, error) {
 
 	var wallets []models.Wallet
 	for rows.Next() {
-		var w models.Wallet
-		if err := rows.Scan(&w.ID, &w.UserID, &w.Name, &w.Currency, &w.Balance, &w.Type); err != nil {
+		w, err := scanWallet(rows)
+		if err != nil {
 			return nil, err
 		}
 		wallets = append(wallets, w)
@@ -191,7 +546,7 @@ func (r *sqliteWalletRepository) ListAll() ([]models.Wallet, error) {
 }
 
 func (r *sqliteWalletRepository) ListAllForUser(userID string) ([]models.Wallet, error) {
-	query := `SELECT id, user_id, name, currency, balance, type FROM wallets WHERE user_id = ?`
+	query := `SELECT ` + walletColumns + ` FROM wallets WHERE user_id = ? AND deleted_at IS NULL`
 	rows, err := r.db.Query(query, normalizedUserID(userID))
 	if err != nil {
 		return nil, err
@@ -200,11 +555,37 @@ func (r *sqliteWalletRepository) ListAllForUser(userID string) ([]models.Wallet,
 
 	var wallets []models.Wallet
 	for rows.Next() {
-		var w models.Wallet
-		if err := rows.Scan(&w.ID, &w.UserID, &w.Name, &w.Currency, &w.Balance, &w.Type); err != nil {
+		w, err := scanWallet(rows)
+		if err != nil {
 			return nil, err
 		}
 		wallets = append(wallets, w)
 	}
 	return wallets, nil
 }
+
+func (r *sqliteWalletRepository) ListByIDsForUser(userID string, ids []string) ([]models.Wallet, error) {
+	if len(ids) == 0 {
+		return nil, nil
+	}
+	args := []interface{}{normalizedUserID(userID)}
+	for _, id := range ids {
+		args = append(args, id)
+	}
+	rows, err := r.db.Query(`SELECT `+walletColumns+` FROM wallets
+		WHERE user_id = ? AND id IN (`+placeholders(len(ids))+`) AND deleted_at IS NULL`, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var wallets []models.Wallet
+	for rows.Next() {
+		w, err := scanWallet(rows)
+		if err != nil {
+			return nil, err
+		}
+		wallets = append(wallets, w)
+	}
+	return wallets, rows.Err()
+}
@@ -1,7 +1,9 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"jarwise-backend/internal/db"
 	"jarwise-backend/internal/models"
 	"testing"
@@ -33,7 +35,7 @@ func TestWalletDeletionIntegrity(t *testing.T) {
 
 	// 1. Setup: Create Wallet A and a Jar and a Transaction
 	wA := &models.Wallet{ID: "wallet-a", Name: "Wallet A", Currency: "THB", Balance: 100}
-	if err := repo.Create(wA); err != nil {
+	if err := repo.Create(context.Background(), wA); err != nil {
 		t.Fatalf("Failed to create wallet A: %v", err)
 	}
 
@@ -52,7 +54,7 @@ func TestWalletDeletionIntegrity(t *testing.T) {
 		WalletID:    "wallet-a",
 		JarID:       "jar-1",
 	}
-	if err := txRepo.Create(tx); err != nil {
+	if err := txRepo.Create(context.Background(), tx); err != nil {
 		t.Fatalf("Failed to create transaction: %v", err)
 	}
 
@@ -76,10 +78,10 @@ func TestDeleteWithReplacement(t *testing.T) {
 	// 1. Setup: Create Wallet A, Wallet B, a Jar for A, and a Transaction for A
 	wA := &models.Wallet{ID: "wallet-a", Name: "Wallet A", Currency: "THB"}
 	wB := &models.Wallet{ID: "wallet-b", Name: "Wallet B", Currency: "THB"}
-	if err := repo.Create(wA); err != nil {
+	if err := repo.Create(context.Background(), wA); err != nil {
 		t.Fatalf("Failed to create wallet A: %v", err)
 	}
-	if err := repo.Create(wB); err != nil {
+	if err := repo.Create(context.Background(), wB); err != nil {
 		t.Fatalf("Failed to create wallet B: %v", err)
 	}
 
@@ -92,7 +94,7 @@ func TestDeleteWithReplacement(t *testing.T) {
 	tx := &models.Transaction{
 		ID: "tx-a", Amount: 10, WalletID: "wallet-a", JarID: "jar-1", Date: time.Now(), Type: "expense",
 	}
-	if err := txRepo.Create(tx); err != nil {
+	if err := txRepo.Create(context.Background(), tx); err != nil {
 		t.Fatalf("Failed to create transaction: %v", err)
 	}
 
@@ -124,6 +126,51 @@ func TestDeleteWithReplacement(t *testing.T) {
 	}
 }
 
+func TestDeleteWithReplacement_RejectsUnsuitableReplacements(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	repo := NewSQLiteWalletRepository(dbConn)
+	txRepo := NewSQLiteTransactionRepository(dbConn)
+
+	archivedAt := time.Now()
+	for _, wallet := range []*models.Wallet{
+		{ID: "cash", UserID: "user-1", Name: "Cash", Currency: "THB"},
+		{ID: "archived", UserID: "user-1", Name: "Old", Currency: "THB", Archived: true, ArchivedAt: &archivedAt},
+		{ID: "dollars", UserID: "user-1", Name: "Dollars", Currency: "USD"},
+		{ID: "theirs", UserID: "user-2", Name: "Theirs", Currency: "THB", OpeningBalance: 500},
+	} {
+		if err := repo.Create(ctx, wallet); err != nil {
+			t.Fatalf("Failed to create wallet %s: %v", wallet.ID, err)
+		}
+	}
+	if err := txRepo.Create(ctx, &models.Transaction{ID: "tx-1", UserID: "user-1", Amount: 50, Date: time.Now(), Type: "expense", WalletID: "cash"}); err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+
+	for _, replacement := range []string{"cash", "archived", "dollars", "theirs", "missing"} {
+		err := repo.DeleteWithReplacementForUser(ctx, "user-1", "cash", replacement, 0)
+		if !errors.Is(err, ErrInvalidReplacementWallet) {
+			t.Errorf("Expected replacement %s to be refused, got %v", replacement, err)
+		}
+	}
+	if tx, _ := txRepo.GetByIDForUser("user-1", "tx-1"); tx == nil || tx.WalletID != "cash" {
+		t.Errorf("Expected the transaction to stay in its wallet, got %+v", tx)
+	}
+
+	// A transaction pointing at another user's wallet does not move its balance.
+	if _, err := dbConn.Exec("UPDATE transactions SET wallet_id = 'theirs' WHERE id = 'tx-1'"); err != nil {
+		t.Fatalf("Failed to repoint transaction: %v", err)
+	}
+	if err := RefreshWalletBalances(dbConn, "user-2", "theirs"); err != nil {
+		t.Fatalf("RefreshWalletBalances failed: %v", err)
+	}
+	if wallet, _ := repo.GetForUser("user-2", "theirs"); wallet == nil || wallet.Balance != 500 {
+		t.Errorf("Expected another user's transaction to leave the balance alone, got %+v", wallet)
+	}
+}
+
 func TestDeleteCascade(t *testing.T) {
 	dbConn := setupTestDB(t)
 	defer dbConn.Close()
@@ -133,7 +180,7 @@ func TestDeleteCascade(t *testing.T) {
 
 	// 1. Setup: Create Wallet A, a Jar for A, and a Transaction for A
 	wA := &models.Wallet{ID: "wallet-a", Name: "Wallet A", Currency: "THB"}
-	repo.Create(wA)
+	repo.Create(context.Background(), wA)
 
 	// Create a jar and link it to Wallet A
 	_, err := dbConn.Exec("INSERT INTO jars (id, name, type, wallet_id) VALUES (?, ?, ?, ?)", "jar-1", "Food", "expense", "wallet-a")
@@ -144,7 +191,7 @@ func TestDeleteCascade(t *testing.T) {
 	tx := &models.Transaction{
 		ID: "tx-a", Amount: 10, WalletID: "wallet-a", JarID: "jar-1", Date: time.Now(), Type: "expense",
 	}
-	txRepo.Create(tx)
+	txRepo.Create(context.Background(), tx)
 
 	// 2. Action: Delete A with CASCADE option
 	err = repo.DeleteCascade("wallet-a")
@@ -174,3 +221,188 @@ func TestDeleteCascade(t *testing.T) {
 		t.Errorf("Expected tx-a to be deleted via cascade, but it still exists")
 	}
 }
+
+func TestWalletBalance_TracksLedger(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	repo := NewSQLiteWalletRepository(dbConn)
+	txRepo := NewSQLiteTransactionRepository(dbConn)
+
+	if err := repo.Create(context.Background(), &models.Wallet{ID: "wallet-a", Name: "Wallet A", Currency: "THB", OpeningBalance: 1000}); err != nil {
+		t.Fatalf("Failed to create wallet A: %v", err)
+	}
+	if err := repo.Create(context.Background(), &models.Wallet{ID: "wallet-b", Name: "Wallet B", Currency: "THB"}); err != nil {
+		t.Fatalf("Failed to create wallet B: %v", err)
+	}
+
+	assertBalance := func(id string, expected models.Money) {
+		t.Helper()
+		w, err := repo.Get(id)
+		if err != nil || w == nil {
+			t.Fatalf("Failed to load %s: %v", id, err)
+		}
+		if w.Balance != expected {
+			t.Errorf("Expected %s balance %s, got %s", id, expected, w.Balance)
+		}
+	}
+
+	jan := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+	feb := time.Date(2026, 2, 10, 12, 0, 0, 0, time.UTC)
+	if err := txRepo.Create(context.Background(), &models.Transaction{ID: "tx-income", Amount: 500, Date: jan, Type: "income", WalletID: "wallet-a"}); err != nil {
+		t.Fatalf("Failed to create income: %v", err)
+	}
+	expense := &models.Transaction{ID: "tx-expense", Amount: 200, Date: feb, Type: "expense", WalletID: "wallet-a"}
+	if err := txRepo.Create(context.Background(), expense); err != nil {
+		t.Fatalf("Failed to create expense: %v", err)
+	}
+	assertBalance("wallet-a", 1300)
+
+	// Moving the expense to another wallet updates both sides.
+	expense.Amount = 300
+	expense.WalletID = "wallet-b"
+	if err := txRepo.Update(context.Background(), expense); err != nil {
+		t.Fatalf("Failed to update expense: %v", err)
+	}
+	assertBalance("wallet-a", 1500)
+	assertBalance("wallet-b", -300)
+
+	expenseLegID, incomeLegID := "leg-out", "leg-in"
+	if err := txRepo.CreateTransfer(context.Background(),
+		&models.Transaction{ID: expenseLegID, Amount: -100, Date: feb, Type: "expense", WalletID: "wallet-a", RelatedTransactionID: &incomeLegID},
+		&models.Transaction{ID: incomeLegID, Amount: 100, Date: feb, Type: "income", WalletID: "wallet-b", RelatedTransactionID: &expenseLegID},
+		nil,
+	); err != nil {
+		t.Fatalf("Failed to create transfer: %v", err)
+	}
+	assertBalance("wallet-a", 1400)
+	assertBalance("wallet-b", -200)
+
+	if err := txRepo.Delete("tx-income"); err != nil {
+		t.Fatalf("Failed to delete income: %v", err)
+	}
+	assertBalance("wallet-a", 900)
+
+	asOf, err := repo.BalanceAsOfForUser("", "wallet-a", time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatalf("BalanceAsOfForUser failed: %v", err)
+	}
+	if asOf.OpeningBalance != 1000 || asOf.Balance != 1000 {
+		t.Errorf("Expected January balance 1000 with opening 1000, got %+v", asOf)
+	}
+}
+
+func TestWalletUpdate_RenameArchiveAndCurrencyLock(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	repo := NewSQLiteWalletRepository(dbConn)
+	txRepo := NewSQLiteTransactionRepository(dbConn)
+
+	wallet := &models.Wallet{ID: "wallet-a", UserID: "user-1", Name: "Cash", Currency: "THB", OpeningBalance: 100}
+	if err := repo.Create(context.Background(), wallet); err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+
+	wallet.Name = "Pocket Cash"
+	wallet.Currency = "USD"
+	wallet.OpeningBalance = 250
+	wallet.Archived = true
+	if err := repo.Update(context.Background(), wallet); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+
+	saved, err := repo.GetForUser("user-1", "wallet-a")
+	if err != nil || saved == nil {
+		t.Fatalf("Failed to reload wallet: %v", err)
+	}
+	if saved.Name != "Pocket Cash" || saved.Currency != "USD" || saved.Balance != 250 {
+		t.Errorf("Update not persisted: %+v", saved)
+	}
+	if !saved.Archived || saved.ArchivedAt == nil {
+		t.Errorf("Expected wallet to be archived, got %+v", saved)
+	}
+
+	if err := txRepo.Create(context.Background(), &models.Transaction{ID: "tx-1", UserID: "user-1", Amount: 50, Date: time.Now(), Type: "expense", WalletID: "wallet-a"}); err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+
+	saved.Currency = "EUR"
+	if err := repo.Update(context.Background(), saved); err != ErrWalletCurrencyLocked {
+		t.Errorf("Expected ErrWalletCurrencyLocked, got %v", err)
+	}
+
+	saved.Currency = "USD"
+	saved.Archived = false
+	if err := repo.Update(context.Background(), saved); err != nil {
+		t.Fatalf("Unarchive failed: %v", err)
+	}
+	if saved.Balance != 200 {
+		t.Errorf("Expected balance 200 after update, got %s", saved.Balance)
+	}
+
+	other := &models.Wallet{ID: "wallet-a", UserID: "user-2", Name: "Stolen", Currency: "USD"}
+	if err := repo.Update(context.Background(), other); err != sql.ErrNoRows {
+		t.Errorf("Expected sql.ErrNoRows for another user's wallet, got %v", err)
+	}
+}
+
+func TestWalletUpdate_ChecksVersion(t *testing.T) {
+	dbConn := setupTestDB(t)
+	defer dbConn.Close()
+
+	repo := NewSQLiteWalletRepository(dbConn)
+	txRepo := NewSQLiteTransactionRepository(dbConn)
+
+	wallet := &models.Wallet{ID: "wallet-a", UserID: "user-1", Name: "Cash", Currency: "THB"}
+	if err := repo.Create(context.Background(), wallet); err != nil {
+		t.Fatalf("Failed to create wallet: %v", err)
+	}
+	if wallet.Version != 1 || wallet.UpdatedAt.IsZero() {
+		t.Fatalf("Expected a new wallet at version 1, got %d at %v", wallet.Version, wallet.UpdatedAt)
+	}
+
+	// New transactions move the cached balance, not the wallet's version.
+	tx := &models.Transaction{ID: "tx-1", UserID: "user-1", Amount: 50, Date: time.Now(), Type: "expense", WalletID: "wallet-a"}
+	if err := txRepo.Create(context.Background(), tx); err != nil {
+		t.Fatalf("Failed to create transaction: %v", err)
+	}
+	if tx.Version != 1 {
+		t.Errorf("Expected a new transaction at version 1, got %d", tx.Version)
+	}
+
+	stale := *wallet
+	wallet.Name = "Pocket Cash"
+	if err := repo.Update(context.Background(), wallet); err != nil {
+		t.Fatalf("Update failed: %v", err)
+	}
+	if wallet.Version != 2 {
+		t.Errorf("Expected version 2 after an edit, got %d", wallet.Version)
+	}
+
+	stale.Name = "Wallet"
+	if err := repo.Update(context.Background(), &stale); err != ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch for a stale edit, got %v", err)
+	}
+	saved, err := repo.GetForUser("user-1", "wallet-a")
+	if err != nil || saved == nil || saved.Name != "Pocket Cash" || saved.Version != 2 {
+		t.Fatalf("Expected the first edit to stand, got %+v, %v", saved, err)
+	}
+
+	// Rows written by the importers get a timestamp as well.
+	if _, err := dbConn.Exec("INSERT INTO wallets (id, user_id, name, currency) VALUES ('wallet-b', 'user-1', 'Bank', 'THB')"); err != nil {
+		t.Fatalf("Failed to insert wallet: %v", err)
+	}
+	imported, err := repo.GetForUser("user-1", "wallet-b")
+	if err != nil || imported == nil || imported.Version != 1 || imported.UpdatedAt.IsZero() {
+		t.Errorf("Expected an imported wallet at version 1 with a timestamp, got %+v, %v", imported, err)
+	}
+
+	// Deletes check the version inside their transaction as well.
+	if err := repo.DeleteForUser(context.Background(), "user-1", "wallet-b", imported.Version+1); err != ErrVersionMismatch {
+		t.Errorf("Expected ErrVersionMismatch for a stale delete, got %v", err)
+	}
+	if err := repo.DeleteForUser(context.Background(), "user-1", "wallet-b", imported.Version); err != nil {
+		t.Errorf("Expected the delete to go through, got %v", err)
+	}
+}
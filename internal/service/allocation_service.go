@@ -0,0 +1,126 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"math"
+	"time"
+)
+
+var ErrInvalidAllocationRules = errors.New("invalid allocation rules")
+
+// allocationPercentTolerance absorbs float noise in client-side percentages.
+const allocationPercentTolerance = 0.0001
+
+// AllocationService manages the rules that split recorded income across jars.
+// The split itself happens when the income transaction is written, inside the
+// same database transaction.
+type AllocationService interface {
+	ListRulesForUser(ctx context.Context, userID string) ([]models.AllocationRule, error)
+	ReplaceRulesForUser(ctx context.Context, userID string, rules []models.AllocationRule) ([]models.AllocationRule, error)
+	// JarBalancesForUser reports each jar's accumulated balance up to asOf.
+	JarBalancesForUser(ctx context.Context, userID string, asOf time.Time) ([]models.JarBalance, error)
+}
+
+type allocationService struct {
+	repo    repository.AllocationRepository
+	jarRepo repository.JarRepository
+}
+
+func NewAllocationService(repo repository.AllocationRepository, jarRepo repository.JarRepository) AllocationService {
+	return &allocationService{
+		repo:    repo,
+		jarRepo: jarRepo,
+	}
+}
+
+func (s *allocationService) ListRulesForUser(ctx context.Context, userID string) ([]models.AllocationRule, error) {
+	rules, err := s.repo.ListRulesForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list allocation rules: %w", err)
+	}
+	if rules == nil {
+		rules = []models.AllocationRule{}
+	}
+	return rules, nil
+}
+
+// ReplaceRulesForUser stores a new rule set. The percentages must add up to
+// 100; an empty set turns allocation off.
+func (s *allocationService) ReplaceRulesForUser(ctx context.Context, userID string, rules []models.AllocationRule) ([]models.AllocationRule, error) {
+	userID = normalizedServiceUserID(userID)
+
+	var total float64
+	seen := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		if rule.JarID == "" {
+			return nil, fmt.Errorf("%w: jar_id is required", ErrInvalidAllocationRules)
+		}
+		if seen[rule.JarID] {
+			return nil, fmt.Errorf("%w: jar %s appears more than once", ErrInvalidAllocationRules, rule.JarID)
+		}
+		seen[rule.JarID] = true
+		if rule.Percent <= 0 || rule.Percent > 100 {
+			return nil, fmt.Errorf("%w: percent for jar %s must be between 0 and 100", ErrInvalidAllocationRules, rule.JarID)
+		}
+		total += rule.Percent
+
+		jar, err := s.jarRepo.GetForUser(ctx, userID, rule.JarID)
+		if err != nil {
+			return nil, fmt.Errorf("service: failed to check jar: %w", err)
+		}
+		if jar == nil {
+			return nil, fmt.Errorf("%w: jar %s does not exist", ErrInvalidAllocationRules, rule.JarID)
+		}
+		if jar.Type == "income" {
+			return nil, fmt.Errorf("%w: income jar %s cannot receive allocations", ErrInvalidAllocationRules, rule.JarID)
+		}
+	}
+	if len(rules) > 0 && math.Abs(total-100) > allocationPercentTolerance {
+		return nil, fmt.Errorf("%w: percentages add up to %g, not 100", ErrInvalidAllocationRules, total)
+	}
+
+	if err := s.repo.ReplaceRulesForUser(ctx, userID, rules); err != nil {
+		return nil, fmt.Errorf("service: failed to save allocation rules: %w", err)
+	}
+	return s.ListRulesForUser(ctx, userID)
+}
+
+func (s *allocationService) JarBalancesForUser(ctx context.Context, userID string, asOf time.Time) ([]models.JarBalance, error) {
+	balances, err := s.repo.JarBalancesForUser(ctx, userID, asOf)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to load jar balances: %w", err)
+	}
+
+	// Roll every jar's own figures up into all of its ancestors.
+	index := make(map[string]int, len(balances))
+	for i, b := range balances {
+		index[b.JarID] = i
+	}
+	direct := make([]models.JarBalance, len(balances))
+	copy(direct, balances)
+	for _, b := range direct {
+		seen := map[string]bool{b.JarID: true}
+		for parentID := b.ParentID; parentID != "" && !seen[parentID]; {
+			i, ok := index[parentID]
+			if !ok {
+				break
+			}
+			seen[parentID] = true
+			balances[i].Allocated += b.Allocated
+			balances[i].Spent += b.Spent
+			parentID = balances[i].ParentID
+		}
+	}
+	for i := range balances {
+		balances[i].Balance = balances[i].Allocated - balances[i].Spent
+	}
+
+	if balances == nil {
+		balances = []models.JarBalance{}
+	}
+	return balances, nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestAllocationService_RulesAndBalances(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	jarRepo := repository.NewSQLiteJarRepository(dbConn)
+	if err := walletRepo.Create(ctx, &models.Wallet{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	jars := []models.Jar{
+		{ID: "necessities", Name: "Necessities", Type: "jar"},
+		{ID: "groceries", Name: "Groceries", Type: "jar", ParentID: "necessities"},
+		{ID: "play", Name: "Play", Type: "jar"},
+		{ID: "salary", Name: "Salary", Type: "income"},
+	}
+	for i := range jars {
+		jars[i].UserID = "user-1"
+		if err := jarRepo.Create(ctx, &jars[i]); err != nil {
+			t.Fatalf("failed to create jar: %v", err)
+		}
+	}
+
+	svc := NewAllocationService(repository.NewSQLiteAllocationRepository(dbConn), jarRepo)
+
+	invalid := [][]models.AllocationRule{
+		{{JarID: "necessities", Percent: 60}, {JarID: "play", Percent: 30}},
+		{{JarID: "necessities", Percent: 50}, {JarID: "necessities", Percent: 50}},
+		{{JarID: "salary", Percent: 100}},
+		{{JarID: "missing", Percent: 100}},
+	}
+	for _, rules := range invalid {
+		if _, err := svc.ReplaceRulesForUser(ctx, "user-1", rules); !errors.Is(err, ErrInvalidAllocationRules) {
+			t.Errorf("expected ErrInvalidAllocationRules for %+v, got %v", rules, err)
+		}
+	}
+
+	saved, err := svc.ReplaceRulesForUser(ctx, "user-1", []models.AllocationRule{{JarID: "necessities", Percent: 70}, {JarID: "play", Percent: 30}})
+	if err != nil {
+		t.Fatalf("ReplaceRulesForUser failed: %v", err)
+	}
+	if len(saved) != 2 || saved[0].JarID != "necessities" {
+		t.Errorf("unexpected rules: %+v", saved)
+	}
+
+	txSvc := NewTransactionService(repository.NewSQLiteTransactionRepository(dbConn), walletRepo, jarRepo, repository.NewSQLiteTagRepository(dbConn), nil)
+	date := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := txSvc.CreateForUser(ctx, "user-1", &models.Transaction{Amount: 1000, Date: date, Type: "income", WalletID: "wallet-1", JarID: "salary"}); err != nil {
+		t.Fatalf("failed to record income: %v", err)
+	}
+	if _, err := txSvc.CreateForUser(ctx, "user-1", &models.Transaction{Amount: 120, Date: date, Type: "expense", WalletID: "wallet-1", JarID: "groceries"}); err != nil {
+		t.Fatalf("failed to record expense: %v", err)
+	}
+
+	balances, err := svc.JarBalancesForUser(ctx, "user-1", date.AddDate(0, 0, 1))
+	if err != nil {
+		t.Fatalf("JarBalancesForUser failed: %v", err)
+	}
+	byID := map[string]models.JarBalance{}
+	for _, b := range balances {
+		byID[b.JarID] = b
+	}
+	if _, ok := byID["salary"]; ok {
+		t.Error("income jars should not be listed")
+	}
+	if got := byID["necessities"]; got.Allocated != 700 || got.Spent != 120 || got.Balance != 580 {
+		t.Errorf("expected sub-jar spending rolled up into necessities, got %+v", got)
+	}
+	if got := byID["play"]; got.Balance != 300 || got.Percent != 30 {
+		t.Errorf("unexpected play balance: %+v", got)
+	}
+}
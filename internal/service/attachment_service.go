@@ -0,0 +1,247 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MaxAttachmentSize is the largest file accepted as an attachment.
+const MaxAttachmentSize = 10 << 20
+
+var (
+	ErrAttachmentNotFound = errors.New("attachment not found")
+	ErrInvalidAttachment  = errors.New("invalid attachment")
+	ErrAttachmentTooLarge = fmt.Errorf("attachment is larger than %d MB", MaxAttachmentSize>>20)
+	ErrAttachmentType     = errors.New("unsupported attachment type")
+)
+
+// allowedAttachmentTypes are the content types accepted for attachments, as
+// detected from the file itself.
+var allowedAttachmentTypes = map[string]struct{}{
+	"image/jpeg":      {},
+	"image/png":       {},
+	"image/webp":      {},
+	"image/gif":       {},
+	"application/pdf": {},
+}
+
+type AttachmentService interface {
+	UploadForUser(ctx context.Context, userID, transactionID string, file *multipart.FileHeader) (*models.Attachment, error)
+	ListForTransaction(ctx context.Context, userID, transactionID string) ([]models.Attachment, error)
+	// OpenForUser returns the attachment and its content, which the caller
+	// must close.
+	OpenForUser(ctx context.Context, userID, id string) (*models.Attachment, io.ReadCloser, error)
+	DeleteForUser(ctx context.Context, userID, id string) error
+	// DeleteForTransactions removes the user's attachments of the given
+	// deleted transactions and returns how many were removed.
+	DeleteForTransactions(ctx context.Context, userID string, transactionIDs ...string) (int, error)
+	// DeleteOrphans removes the attachments of deleted transactions and
+	// returns how many were removed.
+	DeleteOrphans(ctx context.Context) (int, error)
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type attachmentService struct {
+	repo    repository.AttachmentRepository
+	txRepo  repository.TransactionRepository
+	storage AttachmentStorage
+	clock   func() time.Time
+}
+
+func NewAttachmentService(repo repository.AttachmentRepository, txRepo repository.TransactionRepository, storage AttachmentStorage) AttachmentService {
+	return &attachmentService{
+		repo:    repo,
+		txRepo:  txRepo,
+		storage: storage,
+		clock: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
+}
+
+func (s *attachmentService) UploadForUser(ctx context.Context, userID, transactionID string, file *multipart.FileHeader) (*models.Attachment, error) {
+	userID = normalizedServiceUserID(userID)
+	if err := s.checkTransaction(userID, transactionID); err != nil {
+		return nil, err
+	}
+	if file.Size <= 0 {
+		return nil, fmt.Errorf("%w: file is empty", ErrInvalidAttachment)
+	}
+	if file.Size > MaxAttachmentSize {
+		return nil, ErrAttachmentTooLarge
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to read attachment: %w", err)
+	}
+	defer src.Close()
+
+	contentType, err := detectAttachmentType(src)
+	if err != nil {
+		return nil, err
+	}
+
+	attachment := &models.Attachment{
+		ID:            uuid.New().String(),
+		UserID:        userID,
+		TransactionID: transactionID,
+		FileName:      sanitizeFileName(file.Filename),
+		ContentType:   contentType,
+		Size:          file.Size,
+		CreatedAt:     s.clock(),
+	}
+	attachment.StorageKey = path.Join(userID, attachment.ID, attachment.FileName)
+
+	if err := s.storage.Save(attachment.StorageKey, src); err != nil {
+		return nil, fmt.Errorf("service: failed to store attachment: %w", err)
+	}
+	if err := s.repo.Create(ctx, attachment); err != nil {
+		s.storage.Delete(attachment.StorageKey)
+		return nil, fmt.Errorf("service: failed to create attachment: %w", err)
+	}
+	return attachment, nil
+}
+
+func (s *attachmentService) ListForTransaction(ctx context.Context, userID, transactionID string) ([]models.Attachment, error) {
+	if err := s.checkTransaction(userID, transactionID); err != nil {
+		return nil, err
+	}
+	attachments, err := s.repo.ListForTransaction(ctx, userID, transactionID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list attachments: %w", err)
+	}
+	if attachments == nil {
+		attachments = []models.Attachment{}
+	}
+	return attachments, nil
+}
+
+func (s *attachmentService) OpenForUser(ctx context.Context, userID, id string) (*models.Attachment, io.ReadCloser, error) {
+	attachment, err := s.getForUser(ctx, userID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+	content, err := s.storage.Open(attachment.StorageKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("service: failed to open attachment: %w", err)
+	}
+	return attachment, content, nil
+}
+
+func (s *attachmentService) DeleteForUser(ctx context.Context, userID, id string) error {
+	attachment, err := s.getForUser(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteForUser(ctx, userID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrAttachmentNotFound
+		}
+		return fmt.Errorf("service: failed to delete attachment: %w", err)
+	}
+	if err := s.storage.Delete(attachment.StorageKey); err != nil {
+		log.Printf("attachments: failed to remove %s: %v", attachment.StorageKey, err)
+	}
+	return nil
+}
+
+func (s *attachmentService) DeleteForTransactions(ctx context.Context, userID string, transactionIDs ...string) (int, error) {
+	keys, err := s.repo.DeleteForTransactions(ctx, userID, transactionIDs)
+	if err != nil {
+		return 0, fmt.Errorf("service: failed to delete attachments: %w", err)
+	}
+	s.removeFiles(keys)
+	return len(keys), nil
+}
+
+func (s *attachmentService) DeleteOrphans(ctx context.Context) (int, error) {
+	keys, err := s.repo.DeleteOrphans(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("service: failed to delete orphaned attachments: %w", err)
+	}
+	s.removeFiles(keys)
+	return len(keys), nil
+}
+
+func (s *attachmentService) removeFiles(keys []string) {
+	for _, key := range keys {
+		if err := s.storage.Delete(key); err != nil {
+			log.Printf("attachments: failed to remove %s: %v", key, err)
+		}
+	}
+}
+
+// Run sweeps attachments left behind by transactions deleted together with
+// their wallet or jar.
+func (s *attachmentService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if removed, err := s.DeleteOrphans(ctx); err != nil {
+			log.Printf("attachments: cleanup failed: %v", err)
+		} else if removed > 0 {
+			log.Printf("attachments: removed %d orphaned attachments", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *attachmentService) getForUser(ctx context.Context, userID, id string) (*models.Attachment, error) {
+	attachment, err := s.repo.GetForUser(ctx, userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to load attachment: %w", err)
+	}
+	if attachment == nil {
+		return nil, ErrAttachmentNotFound
+	}
+	return attachment, nil
+}
+
+func (s *attachmentService) checkTransaction(userID, transactionID string) error {
+	tx, err := s.txRepo.GetByIDForUser(userID, transactionID)
+	if err != nil {
+		return fmt.Errorf("service: failed to load transaction: %w", err)
+	}
+	if tx == nil {
+		return ErrTransactionNotFound
+	}
+	return nil
+}
+
+// detectAttachmentType sniffs the content type from the start of src, so a
+// renamed executable is not accepted as a photo, and rewinds src.
+func detectAttachmentType(src multipart.File) (string, error) {
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return "", fmt.Errorf("service: failed to read attachment: %w", err)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("service: failed to read attachment: %w", err)
+	}
+
+	contentType := http.DetectContentType(head[:n])
+	if _, ok := allowedAttachmentTypes[contentType]; !ok {
+		return "", fmt.Errorf("%w: %s; upload a JPEG, PNG, WebP, GIF or PDF", ErrAttachmentType, contentType)
+	}
+	return contentType, nil
+}
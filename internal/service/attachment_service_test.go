@@ -0,0 +1,143 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// pngHeader is enough of a PNG file for content sniffing.
+var pngHeader = []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+
+func multipartFile(t *testing.T, name string, content []byte) *multipart.FileHeader {
+	t.Helper()
+
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("file", name)
+	if err != nil {
+		t.Fatalf("CreateFormFile failed: %v", err)
+	}
+	part.Write(content)
+	writer.Close()
+
+	form, err := multipart.NewReader(&body, writer.Boundary()).ReadForm(1 << 20)
+	if err != nil {
+		t.Fatalf("ReadForm failed: %v", err)
+	}
+	t.Cleanup(func() { form.RemoveAll() })
+	return form.File["file"][0]
+}
+
+func TestAttachmentService_UploadDownloadAndCleanup(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	if err := walletRepo.Create(context.Background(), &models.Wallet{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
+	root := t.TempDir()
+	attachments := NewAttachmentService(repository.NewSQLiteAttachmentRepository(dbConn), txRepo, NewLocalAttachmentStorage(root))
+	transactions := NewTransactionService(txRepo, walletRepo, repository.NewSQLiteJarRepository(dbConn), repository.NewSQLiteTagRepository(dbConn), attachments)
+	ctx := context.Background()
+
+	receipt, err := transactions.CreateForUser(ctx, "user-1", &models.Transaction{
+		Amount: 4500, Date: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC), Type: "expense", WalletID: "wallet-1",
+	})
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+
+	if _, err := attachments.UploadForUser(ctx, "user-1", receipt.ID, multipartFile(t, "notes.txt", []byte("hello"))); !errors.Is(err, ErrAttachmentType) {
+		t.Errorf("expected a text file to be rejected, got %v", err)
+	}
+	large := multipartFile(t, "huge.png", pngHeader)
+	large.Size = MaxAttachmentSize + 1
+	if _, err := attachments.UploadForUser(ctx, "user-1", receipt.ID, large); !errors.Is(err, ErrAttachmentTooLarge) {
+		t.Errorf("expected an oversized file to be rejected, got %v", err)
+	}
+	if _, err := attachments.UploadForUser(ctx, "user-2", receipt.ID, multipartFile(t, "receipt.png", pngHeader)); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("expected another user's transaction to be hidden, got %v", err)
+	}
+
+	uploaded, err := attachments.UploadForUser(ctx, "user-1", receipt.ID, multipartFile(t, "../../receipt.png", pngHeader))
+	if err != nil {
+		t.Fatalf("UploadForUser failed: %v", err)
+	}
+	if uploaded.FileName != "receipt.png" || uploaded.ContentType != "image/png" {
+		t.Errorf("unexpected attachment %+v", uploaded)
+	}
+	storedPath := filepath.Join(root, "user-1", uploaded.ID, "receipt.png")
+	if _, err := os.Stat(storedPath); err != nil {
+		t.Fatalf("expected the file under the storage root: %v", err)
+	}
+
+	if _, _, err := attachments.OpenForUser(ctx, "user-2", uploaded.ID); !errors.Is(err, ErrAttachmentNotFound) {
+		t.Errorf("expected another user's download to miss, got %v", err)
+	}
+	_, content, err := attachments.OpenForUser(ctx, "user-1", uploaded.ID)
+	if err != nil {
+		t.Fatalf("OpenForUser failed: %v", err)
+	}
+	data, _ := io.ReadAll(content)
+	content.Close()
+	if !bytes.Equal(data, pngHeader) {
+		t.Errorf("expected the uploaded bytes back, got %q", data)
+	}
+
+	// An attachment left by another user's deleted transaction waits for
+	// the sweep.
+	if _, err := dbConn.Exec(`INSERT INTO attachments (id, user_id, transaction_id, file_name, content_type, size, storage_key, created_at)
+		VALUES ('orphan', 'user-2', 'gone', 'old.png', 'image/png', 1, 'user-2/orphan/old.png', ?)`, time.Now().UTC()); err != nil {
+		t.Fatalf("failed to insert orphan: %v", err)
+	}
+
+	// Deleting the transaction removes the attachment and its file.
+	if err := transactions.DeleteForUser(ctx, "user-1", receipt.ID, 0); err != nil {
+		t.Fatalf("DeleteForUser failed: %v", err)
+	}
+	if _, err := os.Stat(storedPath); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("expected the file to be removed, got %v", err)
+	}
+	var ids []string
+	rows, err := dbConn.Query("SELECT id FROM attachments")
+	if err != nil {
+		t.Fatalf("failed to list attachments: %v", err)
+	}
+	for rows.Next() {
+		var id string
+		rows.Scan(&id)
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if len(ids) != 1 || ids[0] != "orphan" {
+		t.Errorf("expected only the other user's attachment to remain, got %v", ids)
+	}
+
+	if removed, err := attachments.DeleteOrphans(ctx); err != nil || removed != 1 {
+		t.Errorf("expected the sweep to remove the orphan, got %d, %v", removed, err)
+	}
+}
+
+func TestLocalAttachmentStorage_RejectsKeysOutsideRoot(t *testing.T) {
+	storage := NewLocalAttachmentStorage(t.TempDir())
+	for _, key := range []string{"../escape.png", "a/../../escape.png", ""} {
+		if err := storage.Save(key, bytes.NewReader(pngHeader)); err == nil {
+			t.Errorf("expected key %q to be rejected", key)
+		}
+	}
+}
@@ -0,0 +1,85 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// AttachmentStorage keeps the content of attachments. Keys are
+// slash-separated relative paths chosen by the attachment service.
+type AttachmentStorage interface {
+	Save(key string, content io.Reader) error
+	Open(key string) (io.ReadCloser, error)
+	// Delete removes the content. Deleting a missing key is not an error.
+	Delete(key string) error
+}
+
+type localAttachmentStorage struct {
+	root string
+}
+
+// NewLocalAttachmentStorage stores attachments as files below root.
+func NewLocalAttachmentStorage(root string) AttachmentStorage {
+	return &localAttachmentStorage{root: root}
+}
+
+func (s *localAttachmentStorage) Save(key string, content io.Reader) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+
+	dst, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(dst, content); err != nil {
+		dst.Close()
+		os.Remove(path)
+		return err
+	}
+	return dst.Close()
+}
+
+func (s *localAttachmentStorage) Open(key string) (io.ReadCloser, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Delete also removes the attachment's directory once it is empty.
+func (s *localAttachmentStorage) Delete(key string) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	// Fails harmlessly while the directory still holds other files.
+	os.Remove(filepath.Dir(path))
+	return nil
+}
+
+// path maps key into root and refuses keys that would escape it.
+func (s *localAttachmentStorage) path(key string) (string, error) {
+	root, err := filepath.Abs(s.root)
+	if err != nil {
+		return "", err
+	}
+	path := filepath.Join(root, filepath.FromSlash(key))
+	rel, err := filepath.Rel(root, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid attachment key %q", key)
+	}
+	return path, nil
+}
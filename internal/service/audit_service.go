@@ -0,0 +1,84 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+var ErrInvalidAuditFilter = errors.New("invalid audit filter")
+
+type AuditService interface {
+	ListForUser(ctx context.Context, userID string, filter models.AuditFilter) (*models.AuditPage, error)
+	// Attribute runs fn with a context whose writes to the user's data are
+	// recorded as made by actor. Each write names its actor inside its own
+	// database transaction, so calls never wait on each other. It returns
+	// fn's error.
+	Attribute(ctx context.Context, userID string, actor models.AuditActor, fn func(ctx context.Context) error) error
+}
+
+type auditService struct {
+	repo repository.AuditRepository
+}
+
+func NewAuditService(repo repository.AuditRepository) AuditService {
+	return &auditService{repo: repo}
+}
+
+func (s *auditService) ListForUser(ctx context.Context, userID string, filter models.AuditFilter) (*models.AuditPage, error) {
+	if filter.Before < 0 {
+		return nil, fmt.Errorf("%w: cursor must not be negative", ErrInvalidAuditFilter)
+	}
+	if filter.Limit < 0 || filter.Limit > maxAuditPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidAuditFilter, maxAuditPageSize)
+	}
+	limit := filter.Limit
+	if limit == 0 {
+		limit = defaultAuditPageSize
+	}
+	filter.Limit = limit + 1
+
+	entries, err := s.repo.ListForUser(ctx, userID, filter)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list audit entries: %w", err)
+	}
+	page := &models.AuditPage{Entries: entries}
+	if len(entries) > limit {
+		page.Entries = entries[:limit]
+		page.Cursor = page.Entries[limit-1].ID
+		page.HasMore = true
+	}
+	return page, nil
+}
+
+func (s *auditService) Attribute(ctx context.Context, userID string, actor models.AuditActor, fn func(ctx context.Context) error) error {
+	return fn(repository.WithAuditActor(ctx, userID, actor))
+}
+
+type auditActorContextKey struct{}
+
+// ContextWithAuditActor remembers who a request is made by, for work it
+// starts in the background.
+func ContextWithAuditActor(ctx context.Context, actor models.AuditActor) context.Context {
+	return context.WithValue(ctx, auditActorContextKey{}, actor)
+}
+
+func AuditActorFromContext(ctx context.Context) (models.AuditActor, bool) {
+	actor, ok := ctx.Value(auditActorContextKey{}).(models.AuditActor)
+	return actor, ok
+}
+
+// attribute runs fn through audit when the service has one.
+func attribute(ctx context.Context, audit AuditService, userID string, actor models.AuditActor, fn func(ctx context.Context) error) error {
+	if audit == nil {
+		return fn(ctx)
+	}
+	return audit.Attribute(ctx, userID, actor, fn)
+}
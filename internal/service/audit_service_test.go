@@ -0,0 +1,201 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAuditService_RecordsWritesWithTheirActor(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	svc := NewAuditService(repository.NewSQLiteAuditRepository(dbConn))
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	jarRepo := repository.NewSQLiteJarRepository(dbConn)
+	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
+	ctx := context.Background()
+	actor := models.AuditActor{UserID: "user-1", SessionID: "session-1", Source: models.AuditSourceAPI}
+
+	err = svc.Attribute(ctx, "user-1", actor, func(ctx context.Context) error {
+		if err := walletRepo.Create(ctx, &models.Wallet{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB", Type: "cash"}); err != nil {
+			return err
+		}
+		if err := jarRepo.Create(ctx, &models.Jar{ID: "jar-1", UserID: "user-1", Name: "Food", Type: "expense", WalletID: "wallet-1"}); err != nil {
+			return err
+		}
+		return txRepo.Create(ctx, &models.Transaction{ID: "tx-1", UserID: "user-1", Amount: 12000, Description: "Lunch",
+			Date: time.Date(2026, 7, 1, 0, 0, 0, 0, time.UTC), Type: "expense", WalletID: "wallet-1", JarID: "jar-1"})
+	})
+	if err != nil {
+		t.Fatalf("Attribute failed: %v", err)
+	}
+
+	// Writes outside Attribute belong to the system.
+	wallet, err := walletRepo.GetForUser("user-1", "wallet-1")
+	if err != nil || wallet == nil {
+		t.Fatalf("failed to load wallet: %v", err)
+	}
+	wallet.Name = "Pocket"
+	if err := walletRepo.Update(ctx, wallet); err != nil {
+		t.Fatalf("failed to update wallet: %v", err)
+	}
+
+	cascade := models.AuditActor{UserID: "user-1", SessionID: "session-2", Source: models.AuditSourceAPI}
+	if err := svc.Attribute(ctx, "user-1", cascade, func(ctx context.Context) error {
+		return walletRepo.DeleteCascadeForUser(ctx, "user-1", "wallet-1", 0, &models.Deletion{ID: "deletion-1", DeletedAt: time.Now()})
+	}); err != nil {
+		t.Fatalf("cascade delete failed: %v", err)
+	}
+
+	page, err := svc.ListForUser(ctx, "user-1", models.AuditFilter{})
+	if err != nil {
+		t.Fatalf("ListForUser failed: %v", err)
+	}
+	var got []string
+	for _, entry := range page.Entries {
+		got = append(got, entry.Op+" "+entry.Entity+" "+entry.Actor.Source+" "+entry.Actor.SessionID)
+	}
+	want := []string{
+		"trash wallet api session-2",
+		"trash jar api session-2",
+		"trash transaction api session-2",
+		"update wallet system ",
+		"create transaction api session-1",
+		"create jar api session-1",
+		"create wallet api session-1",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("expected entries %q, got %q", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+
+	var before, after map[string]interface{}
+	update := page.Entries[3]
+	if err := json.Unmarshal(update.Before, &before); err != nil || before["name"] != "Cash" {
+		t.Errorf("expected the old name before the update, got %s", update.Before)
+	}
+	if err := json.Unmarshal(update.After, &after); err != nil || after["name"] != "Pocket" {
+		t.Errorf("expected the new name after the update, got %s", update.After)
+	}
+	if deleted := page.Entries[2]; deleted.After != nil || deleted.EntityID != "tx-1" {
+		t.Errorf("expected tx-1 to be trashed without an after snapshot, got %+v", deleted)
+	}
+
+	// Pages follow the cursor and filters narrow the log.
+	first, err := svc.ListForUser(ctx, "user-1", models.AuditFilter{Limit: 4})
+	if err != nil || len(first.Entries) != 4 || !first.HasMore {
+		t.Fatalf("expected a first page of 4 with more, got %+v, %v", first, err)
+	}
+	second, err := svc.ListForUser(ctx, "user-1", models.AuditFilter{Limit: 4, Before: first.Cursor})
+	if err != nil || len(second.Entries) != 3 || second.HasMore || second.Entries[0].ID >= first.Cursor {
+		t.Fatalf("expected the remaining 3 entries, got %+v, %v", second, err)
+	}
+	wallets, err := svc.ListForUser(ctx, "user-1", models.AuditFilter{Entity: "wallet", EntityID: "wallet-1"})
+	if err != nil || len(wallets.Entries) != 3 {
+		t.Errorf("expected 3 wallet entries, got %+v, %v", wallets, err)
+	}
+	if other, err := svc.ListForUser(ctx, "user-2", models.AuditFilter{}); err != nil || len(other.Entries) != 0 {
+		t.Errorf("expected no entries for another user, got %+v, %v", other, err)
+	}
+	if _, err := svc.ListForUser(ctx, "user-1", models.AuditFilter{Limit: maxAuditPageSize + 1}); !errors.Is(err, ErrInvalidAuditFilter) {
+		t.Errorf("expected an oversized limit to be rejected, got %v", err)
+	}
+
+	// The log cannot be rewritten.
+	if _, err := dbConn.Exec(`UPDATE audit_log SET source = 'api'`); err == nil {
+		t.Error("expected audit entries to be immutable")
+	}
+	if _, err := dbConn.Exec(`DELETE FROM audit_log`); err == nil {
+		t.Error("expected audit entries not to be deletable")
+	}
+}
+
+func TestAuditService_WritesDuringAnImportAreNotBlocked(t *testing.T) {
+	// A file database, so the import and the write use their own connections.
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "audit.db"))
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	svc := NewAuditService(repository.NewSQLiteAuditRepository(dbConn))
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	ctx := context.Background()
+
+	importing := make(chan struct{})
+	finish := make(chan struct{})
+	imported := make(chan error, 1)
+	importer := models.AuditActor{UserID: "user-1", SessionID: "session-1", Source: models.AuditSourceImport}
+	go func() {
+		imported <- svc.Attribute(ctx, "user-1", importer, func(ctx context.Context) error {
+			if err := walletRepo.Create(ctx, &models.Wallet{ID: "imported-1", UserID: "user-1", Name: "Savings", Currency: "THB"}); err != nil {
+				return err
+			}
+			close(importing)
+			<-finish
+			return walletRepo.Create(ctx, &models.Wallet{ID: "imported-2", UserID: "user-1", Name: "Credit", Currency: "THB"})
+		})
+	}()
+	<-importing
+
+	written := make(chan error, 1)
+	api := models.AuditActor{UserID: "user-1", SessionID: "session-2", Source: models.AuditSourceAPI}
+	go func() {
+		written <- svc.Attribute(ctx, "user-1", api, func(ctx context.Context) error {
+			return walletRepo.Create(ctx, &models.Wallet{ID: "cash", UserID: "user-1", Name: "Cash", Currency: "THB"})
+		})
+	}()
+	select {
+	case err := <-written:
+		if err != nil {
+			t.Fatalf("write during the import failed: %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		close(finish)
+		t.Fatal("expected the write not to wait for the import")
+	}
+	close(finish)
+	if err := <-imported; err != nil {
+		t.Fatalf("import failed: %v", err)
+	}
+
+	page, err := svc.ListForUser(ctx, "user-1", models.AuditFilter{})
+	if err != nil {
+		t.Fatalf("ListForUser failed: %v", err)
+	}
+	var got []string
+	for _, entry := range page.Entries {
+		got = append(got, entry.EntityID+" "+entry.Actor.Source+" "+entry.Actor.SessionID)
+	}
+	want := []string{"imported-2 import session-1", "cash api session-2", "imported-1 import session-1"}
+	if len(got) != len(want) {
+		t.Fatalf("expected entries %q, got %q", want, got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("entry %d: expected %q, got %q", i, want[i], got[i])
+		}
+	}
+
+	// Writes made without an actor belong to the system again.
+	if err := walletRepo.Create(ctx, &models.Wallet{ID: "bank", UserID: "user-1", Name: "Bank", Currency: "THB"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	if page, err := svc.ListForUser(ctx, "user-1", models.AuditFilter{Limit: 1}); err != nil || page.Entries[0].Actor.Source != models.AuditSourceSystem {
+		t.Errorf("expected an unattributed write to be the system's, got %+v, %v", page, err)
+	}
+}
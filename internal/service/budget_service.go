@@ -0,0 +1,293 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrBudgetNotFound = errors.New("budget not found")
+	ErrInvalidBudget  = errors.New("invalid budget")
+	ErrBudgetExists   = errors.New("jar already has a budget for this period")
+)
+
+type BudgetService interface {
+	// ListStatusForUser evaluates every budget for the period containing asOf.
+	// A zero asOf means now.
+	ListStatusForUser(ctx context.Context, userID string, asOf time.Time) ([]models.BudgetStatus, error)
+	GetStatusForUser(ctx context.Context, userID, id string, asOf time.Time) (*models.BudgetStatus, error)
+	CreateForUser(ctx context.Context, userID string, budget *models.Budget) (*models.Budget, error)
+	UpdateForUser(ctx context.Context, userID string, budget *models.Budget) (*models.Budget, error)
+	DeleteForUser(ctx context.Context, userID, id string) error
+}
+
+type budgetService struct {
+	repo    repository.BudgetRepository
+	jarRepo repository.JarRepository
+	rates   exchangeRateSource
+	clock   func() time.Time
+}
+
+func NewBudgetService(repo repository.BudgetRepository, jarRepo repository.JarRepository, rates exchangeRateSource) BudgetService {
+	return &budgetService{
+		repo:    repo,
+		jarRepo: jarRepo,
+		rates:   rates,
+		clock: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
+}
+
+func (s *budgetService) ListStatusForUser(ctx context.Context, userID string, asOf time.Time) ([]models.BudgetStatus, error) {
+	budgets, err := s.repo.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list budgets: %w", err)
+	}
+
+	converter, err := loadCurrencyConverter(ctx, s.rates, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]models.BudgetStatus, 0, len(budgets))
+	for _, budget := range budgets {
+		status, err := s.evaluate(ctx, budget, asOf, converter)
+		if err != nil {
+			return nil, err
+		}
+		statuses = append(statuses, *status)
+	}
+	return statuses, nil
+}
+
+func (s *budgetService) GetStatusForUser(ctx context.Context, userID, id string, asOf time.Time) (*models.BudgetStatus, error) {
+	budget, err := s.getForUser(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	converter, err := loadCurrencyConverter(ctx, s.rates, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.evaluate(ctx, *budget, asOf, converter)
+}
+
+func (s *budgetService) CreateForUser(ctx context.Context, userID string, budget *models.Budget) (*models.Budget, error) {
+	budget.ID = uuid.New().String()
+	budget.UserID = normalizedServiceUserID(userID)
+	budget.CreatedAt = s.clock()
+	if budget.StartDate.IsZero() {
+		budget.StartDate = budget.CreatedAt
+	}
+	if budget.Rollover == "" {
+		budget.Rollover = models.BudgetRolloverNone
+	}
+	if err := s.validateBudget(ctx, budget); err != nil {
+		return nil, err
+	}
+
+	existing, err := s.repo.ListForUser(ctx, budget.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to check budgets: %w", err)
+	}
+	for _, other := range existing {
+		if other.JarID == budget.JarID && other.Period == budget.Period {
+			return nil, ErrBudgetExists
+		}
+	}
+
+	// The check above races with concurrent creates; the unique index
+	// settles it.
+	if err := s.repo.Create(ctx, budget); err != nil {
+		if errors.Is(err, repository.ErrDuplicateBudget) {
+			return nil, ErrBudgetExists
+		}
+		return nil, fmt.Errorf("service: failed to create budget: %w", err)
+	}
+	return budget, nil
+}
+
+// UpdateForUser changes the amount, rollover or start date. The jar and the
+// period identify the budget and cannot change.
+func (s *budgetService) UpdateForUser(ctx context.Context, userID string, budget *models.Budget) (*models.Budget, error) {
+	existing, err := s.getForUser(ctx, userID, budget.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	budget.UserID = existing.UserID
+	budget.JarID = existing.JarID
+	budget.Period = existing.Period
+	budget.CreatedAt = existing.CreatedAt
+	if err := s.validateBudget(ctx, budget); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, budget); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrBudgetNotFound
+		}
+		return nil, fmt.Errorf("service: failed to update budget: %w", err)
+	}
+	return budget, nil
+}
+
+func (s *budgetService) DeleteForUser(ctx context.Context, userID, id string) error {
+	if err := s.repo.DeleteForUser(ctx, userID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrBudgetNotFound
+		}
+		return fmt.Errorf("service: failed to delete budget: %w", err)
+	}
+	return nil
+}
+
+func (s *budgetService) getForUser(ctx context.Context, userID, id string) (*models.Budget, error) {
+	budget, err := s.repo.GetForUser(ctx, userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to load budget: %w", err)
+	}
+	if budget == nil {
+		return nil, ErrBudgetNotFound
+	}
+	return budget, nil
+}
+
+func (s *budgetService) validateBudget(ctx context.Context, budget *models.Budget) error {
+	switch budget.Period {
+	case models.BudgetPeriodWeekly, models.BudgetPeriodMonthly, models.BudgetPeriodYearly:
+	default:
+		return fmt.Errorf("%w: period must be weekly, monthly or yearly", ErrInvalidBudget)
+	}
+	switch budget.Rollover {
+	case models.BudgetRolloverNone, models.BudgetRolloverUnderspend, models.BudgetRolloverFull:
+	default:
+		return fmt.Errorf("%w: rollover must be none, underspend or full", ErrInvalidBudget)
+	}
+	if budget.Amount <= 0 {
+		return fmt.Errorf("%w: amount must be greater than zero", ErrInvalidBudget)
+	}
+	if budget.JarID == "" {
+		return fmt.Errorf("%w: jar_id is required", ErrInvalidBudget)
+	}
+
+	jar, err := s.jarRepo.GetForUser(ctx, budget.UserID, budget.JarID)
+	if err != nil {
+		return fmt.Errorf("service: failed to check jar: %w", err)
+	}
+	if jar == nil {
+		return fmt.Errorf("%w: jar %s does not exist", ErrInvalidBudget, budget.JarID)
+	}
+	if jar.Type == "income" {
+		return fmt.Errorf("%w: income jars cannot have a spending budget", ErrInvalidBudget)
+	}
+	return nil
+}
+
+// evaluate computes spending for the period containing asOf, net of refunds
+// and converted into the base currency. With rollover enabled, every earlier
+// period since the budget's start date contributes its leftover (or, for full
+// rollover, its overspending) to the next one.
+func (s *budgetService) evaluate(ctx context.Context, budget models.Budget, asOf time.Time, converter *currencyConverter) (*models.BudgetStatus, error) {
+	if asOf.IsZero() {
+		asOf = s.clock()
+	}
+	currentStart := budgetPeriodStart(budget.Period, asOf)
+	currentEnd := nextBudgetPeriodStart(budget.Period, currentStart)
+
+	// Spending of every period that counts is summed in one query; the
+	// current period comes last.
+	var bounds []time.Time
+	if budget.Rollover != models.BudgetRolloverNone {
+		for start := budgetPeriodStart(budget.Period, budget.StartDate); start.Before(currentStart); start = nextBudgetPeriodStart(budget.Period, start) {
+			bounds = append(bounds, start)
+		}
+	}
+	bounds = append(bounds, currentStart, currentEnd)
+	spending, err := s.repo.SpentByPeriodForUser(ctx, budget.UserID, budget.JarID, bounds)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to sum budget spending: %w", err)
+	}
+	// The converter is shared by every budget of a listing, so the missing
+	// rates are collected per budget.
+	spentByPeriod := make([]models.Money, len(bounds)-1)
+	missingRates := make(map[string]bool)
+	for _, day := range spending {
+		amount, ok := converter.convert(day.Amount, day.Currency, day.Date)
+		if !ok {
+			missingRates[day.Currency] = true
+			continue
+		}
+		spentByPeriod[day.Period] += amount
+	}
+	var missing []string
+	for currency := range missingRates {
+		missing = append(missing, currency)
+	}
+	sort.Strings(missing)
+
+	var carried models.Money
+	for _, spent := range spentByPeriod[:len(spentByPeriod)-1] {
+		carried = budget.Amount + carried - spent
+		if budget.Rollover == models.BudgetRolloverUnderspend && carried < 0 {
+			carried = 0
+		}
+	}
+	spent := spentByPeriod[len(spentByPeriod)-1]
+
+	available := budget.Amount + carried
+	status := &models.BudgetStatus{
+		Budget:       budget,
+		Currency:     converter.base,
+		PeriodStart:  currentStart,
+		PeriodEnd:    currentEnd,
+		CarriedOver:  carried,
+		Available:    available,
+		Spent:        spent,
+		Remaining:    available - spent,
+		Overspent:    spent > available,
+		MissingRates: missing,
+	}
+	switch {
+	case available > 0:
+		status.Percent = float64(spent) / float64(available) * 100
+	case spent > 0:
+		status.Percent = 100
+	}
+	return status, nil
+}
+
+// budgetPeriodStart returns the UTC start of the period containing t. Weeks
+// start on Monday.
+func budgetPeriodStart(period models.BudgetPeriod, t time.Time) time.Time {
+	t = t.UTC()
+	switch period {
+	case models.BudgetPeriodWeekly:
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+		return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+	case models.BudgetPeriodYearly:
+		return time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+	}
+}
+
+func nextBudgetPeriodStart(period models.BudgetPeriod, start time.Time) time.Time {
+	switch period {
+	case models.BudgetPeriodWeekly:
+		return start.AddDate(0, 0, 7)
+	case models.BudgetPeriodYearly:
+		return start.AddDate(1, 0, 0)
+	default:
+		return start.AddDate(0, 1, 0)
+	}
+}
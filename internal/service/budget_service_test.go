@@ -0,0 +1,264 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"testing"
+	"time"
+)
+
+func setupBudgetService(t *testing.T) (*budgetService, repository.TransactionRepository) {
+	t.Helper()
+
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	t.Cleanup(func() { dbConn.Close() })
+
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	for _, wallet := range []models.Wallet{
+		{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB"},
+		{ID: "wallet-usd", UserID: "user-1", Name: "Travel", Currency: "USD"},
+		{ID: "wallet-eur", UserID: "user-1", Name: "Euro", Currency: "EUR"},
+	} {
+		if err := walletRepo.Create(context.Background(), &wallet); err != nil {
+			t.Fatalf("failed to create wallet: %v", err)
+		}
+	}
+	jars := []struct{ id, jarType, parentID string }{
+		{"food", "expense", ""},
+		{"snacks", "expense", "food"},
+		{"salary", "income", ""},
+		{"other", "expense", ""},
+	}
+	for _, j := range jars {
+		if _, err := dbConn.Exec("INSERT INTO jars (id, user_id, name, type, parent_id) VALUES (?, 'user-1', ?, ?, NULLIF(?, ''))", j.id, j.id, j.jarType, j.parentID); err != nil {
+			t.Fatalf("failed to create jar: %v", err)
+		}
+	}
+
+	svc := NewBudgetService(repository.NewSQLiteBudgetRepository(dbConn), repository.NewSQLiteJarRepository(dbConn), repository.NewSQLiteExchangeRateRepository(dbConn)).(*budgetService)
+	svc.clock = func() time.Time { return time.Date(2026, 3, 15, 9, 0, 0, 0, time.UTC) }
+	return svc, repository.NewSQLiteTransactionRepository(dbConn)
+}
+
+func addBudgetExpense(t *testing.T, txRepo repository.TransactionRepository, id, jarID string, amount models.Money, date time.Time) {
+	t.Helper()
+	err := txRepo.Create(context.Background(), &models.Transaction{ID: id, UserID: "user-1", Amount: amount, Date: date, Type: "expense", WalletID: "wallet-1", JarID: jarID})
+	if err != nil {
+		t.Fatalf("failed to create transaction: %v", err)
+	}
+}
+
+func TestBudgetService_MonthlyStatusIncludesSubJars(t *testing.T) {
+	svc, txRepo := setupBudgetService(t)
+	ctx := context.Background()
+
+	budget, err := svc.CreateForUser(ctx, "user-1", &models.Budget{JarID: "food", Period: models.BudgetPeriodMonthly, Amount: 1000})
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+
+	addBudgetExpense(t, txRepo, "tx-1", "food", 300, time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC))
+	addBudgetExpense(t, txRepo, "tx-2", "snacks", 100, time.Date(2026, 3, 10, 12, 0, 0, 0, time.UTC))
+	addBudgetExpense(t, txRepo, "tx-3", "food", 999, time.Date(2026, 2, 28, 12, 0, 0, 0, time.UTC))
+
+	status, err := svc.GetStatusForUser(ctx, "user-1", budget.ID, time.Time{})
+	if err != nil {
+		t.Fatalf("GetStatusForUser failed: %v", err)
+	}
+	if status.Spent != 400 || status.Remaining != 600 || status.Percent != 40 {
+		t.Errorf("unexpected status: spent=%s remaining=%s percent=%.2f", status.Spent, status.Remaining, status.Percent)
+	}
+	if !status.PeriodStart.Equal(time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)) || !status.PeriodEnd.Equal(time.Date(2026, 4, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected period: %s - %s", status.PeriodStart, status.PeriodEnd)
+	}
+
+	if _, err := svc.CreateForUser(ctx, "user-1", &models.Budget{JarID: "food", Period: models.BudgetPeriodMonthly, Amount: 50}); !errors.Is(err, ErrBudgetExists) {
+		t.Errorf("expected ErrBudgetExists, got %v", err)
+	}
+	if _, err := svc.CreateForUser(ctx, "user-1", &models.Budget{JarID: "salary", Period: models.BudgetPeriodMonthly, Amount: 50}); !errors.Is(err, ErrInvalidBudget) {
+		t.Errorf("expected ErrInvalidBudget for income jar, got %v", err)
+	}
+}
+
+func TestBudgetService_Rollover(t *testing.T) {
+	start := time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)
+	cases := []struct {
+		rollover      models.BudgetRollover
+		wantCarried   models.Money
+		wantAvailable models.Money
+	}{
+		// January underspends by 100, February overspends by 300.
+		{models.BudgetRolloverNone, 0, 500},
+		{models.BudgetRolloverUnderspend, 0, 500},
+		{models.BudgetRolloverFull, -200, 300},
+	}
+
+	for _, tc := range cases {
+		t.Run(string(tc.rollover), func(t *testing.T) {
+			svc, txRepo := setupBudgetService(t)
+			ctx := context.Background()
+
+			budget, err := svc.CreateForUser(ctx, "user-1", &models.Budget{
+				JarID: "food", Period: models.BudgetPeriodMonthly, Amount: 500, Rollover: tc.rollover, StartDate: start,
+			})
+			if err != nil {
+				t.Fatalf("CreateForUser failed: %v", err)
+			}
+			addBudgetExpense(t, txRepo, "jan", "food", 400, time.Date(2026, 1, 20, 12, 0, 0, 0, time.UTC))
+			addBudgetExpense(t, txRepo, "feb", "food", 800, time.Date(2026, 2, 20, 12, 0, 0, 0, time.UTC))
+
+			status, err := svc.GetStatusForUser(ctx, "user-1", budget.ID, time.Time{})
+			if err != nil {
+				t.Fatalf("GetStatusForUser failed: %v", err)
+			}
+			if status.CarriedOver != tc.wantCarried || status.Available != tc.wantAvailable {
+				t.Errorf("expected carried %s / available %s, got %s / %s", tc.wantCarried, tc.wantAvailable, status.CarriedOver, status.Available)
+			}
+
+			feb, err := svc.GetStatusForUser(ctx, "user-1", budget.ID, time.Date(2026, 2, 10, 0, 0, 0, 0, time.UTC))
+			if err != nil {
+				t.Fatalf("GetStatusForUser for February failed: %v", err)
+			}
+			if tc.rollover != models.BudgetRolloverNone && feb.CarriedOver != 100 {
+				t.Errorf("expected February to carry 100, got %s", feb.CarriedOver)
+			}
+		})
+	}
+}
+
+func TestBudgetPeriodStart_WeeksStartOnMonday(t *testing.T) {
+	sunday := time.Date(2026, 3, 15, 23, 0, 0, 0, time.UTC)
+	if got := budgetPeriodStart(models.BudgetPeriodWeekly, sunday); !got.Equal(time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected Monday 2026-03-09, got %s", got)
+	}
+	if got := budgetPeriodStart(models.BudgetPeriodYearly, sunday); !got.Equal(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("expected 2026-01-01, got %s", got)
+	}
+}
+
+func TestBudgetService_RolloverSumsEveryEarlierPeriod(t *testing.T) {
+	svc, txRepo := setupBudgetService(t)
+	ctx := context.Background()
+
+	budget, err := svc.CreateForUser(ctx, "user-1", &models.Budget{
+		JarID: "food", Period: models.BudgetPeriodMonthly, Amount: 500, Rollover: models.BudgetRolloverFull,
+		StartDate: time.Date(2025, 11, 20, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+	// November spends 200, December nothing, January 700 (partly in a
+	// sub-jar), February 500 with one expense right on its first instant.
+	addBudgetExpense(t, txRepo, "nov", "food", 200, time.Date(2025, 11, 3, 12, 0, 0, 0, time.UTC))
+	addBudgetExpense(t, txRepo, "jan-1", "food", 400, time.Date(2026, 1, 31, 23, 59, 0, 0, time.UTC))
+	addBudgetExpense(t, txRepo, "jan-2", "snacks", 300, time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC))
+	addBudgetExpense(t, txRepo, "feb", "food", 500, time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC))
+	addBudgetExpense(t, txRepo, "mar", "food", 50, time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC))
+
+	status, err := svc.GetStatusForUser(ctx, "user-1", budget.ID, time.Time{})
+	if err != nil {
+		t.Fatalf("GetStatusForUser failed: %v", err)
+	}
+	// Carried: 300 after November, 800 after December, 600 after January
+	// and 600 after February.
+	if status.CarriedOver != 600 || status.Available != 1100 || status.Spent != 50 {
+		t.Errorf("expected carried 600 / available 1100 / spent 50, got %s / %s / %s", status.CarriedOver, status.Available, status.Spent)
+	}
+}
+
+func TestBudgetService_ConvertsSpendingAndNetsRefunds(t *testing.T) {
+	svc, txRepo := setupBudgetService(t)
+	ctx := context.Background()
+
+	rate := models.ExchangeRate{ID: "rate-1", FromCurrency: "USD", ToCurrency: "THB", Rate: 35, EffectiveDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)}
+	if err := svc.rates.(repository.ExchangeRateRepository).UpsertForUser(ctx, "user-1", []models.ExchangeRate{rate}); err != nil {
+		t.Fatalf("failed to save exchange rate: %v", err)
+	}
+	budget, err := svc.CreateForUser(ctx, "user-1", &models.Budget{JarID: "food", Period: models.BudgetPeriodMonthly, Amount: 50000})
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+
+	march := time.Date(2026, 3, 2, 12, 0, 0, 0, time.UTC)
+	addBudgetExpense(t, txRepo, "thb", "food", 10000, march)
+	expenses := []models.Transaction{
+		{ID: "usd", UserID: "user-1", Amount: 1000, Date: march, Type: "expense", WalletID: "wallet-usd", JarID: "snacks"},
+		{ID: "eur", UserID: "user-1", Amount: 1000, Date: march, Type: "expense", WalletID: "wallet-eur", JarID: "food"},
+		{ID: "receipt", UserID: "user-1", Amount: 3000, Date: march, Type: "expense", WalletID: "wallet-1", Splits: []models.TransactionSplit{
+			{ID: "line-1", JarID: "food", Amount: 2000},
+			{ID: "line-2", JarID: "other", Amount: 1000},
+		}},
+	}
+	for i := range expenses {
+		if err := txRepo.Create(ctx, &expenses[i]); err != nil {
+			t.Fatalf("failed to create transaction: %v", err)
+		}
+	}
+	// Refunds count against the month of their expense, shared out over its
+	// jars, even when they arrive later.
+	thb, receipt := "thb", "receipt"
+	refunds := []models.Transaction{
+		{ID: "refund-thb", UserID: "user-1", Amount: 4000, Date: march.AddDate(0, 1, 0), Type: "income", WalletID: "wallet-1", JarID: "salary", RelatedTransactionID: &thb},
+		{ID: "refund-receipt", UserID: "user-1", Amount: 1500, Date: march, Type: "income", WalletID: "wallet-1", JarID: "salary", RelatedTransactionID: &receipt},
+	}
+	for i := range refunds {
+		if err := txRepo.Create(ctx, &refunds[i]); err != nil {
+			t.Fatalf("failed to create refund: %v", err)
+		}
+	}
+
+	status, err := svc.GetStatusForUser(ctx, "user-1", budget.ID, time.Time{})
+	if err != nil {
+		t.Fatalf("GetStatusForUser failed: %v", err)
+	}
+	// 100.00 - 40.00 THB, 10.00 USD at 35 and 20.00 - 10.00 THB of the
+	// receipt; the EUR expense has no rate.
+	if status.Spent != 42000 || status.Currency != "THB" || status.Overspent {
+		t.Errorf("expected 420.00 THB spent within the budget, got %s %s, overspent %v", status.Spent, status.Currency, status.Overspent)
+	}
+	if fmt.Sprint(status.MissingRates) != "[EUR]" {
+		t.Errorf("expected EUR to be missing a rate, got %v", status.MissingRates)
+	}
+
+	budget.Amount = 40000
+	if _, err := svc.UpdateForUser(ctx, "user-1", budget); err != nil {
+		t.Fatalf("UpdateForUser failed: %v", err)
+	}
+	status, err = svc.GetStatusForUser(ctx, "user-1", budget.ID, time.Time{})
+	if err != nil {
+		t.Fatalf("GetStatusForUser failed: %v", err)
+	}
+	if !status.Overspent || status.Remaining != -2000 {
+		t.Errorf("expected the budget to be overspent by 20.00, got overspent %v, remaining %s", status.Overspent, status.Remaining)
+	}
+}
+
+// racingBudgetRepository hides existing budgets from the duplicate check, as
+// a create that commits between the check and the insert would.
+type racingBudgetRepository struct {
+	repository.BudgetRepository
+}
+
+func (racingBudgetRepository) ListForUser(context.Context, string) ([]models.Budget, error) {
+	return nil, nil
+}
+
+func TestBudgetService_DuplicateCreateRaceIsAConflict(t *testing.T) {
+	svc, _ := setupBudgetService(t)
+	ctx := context.Background()
+
+	if _, err := svc.CreateForUser(ctx, "user-1", &models.Budget{JarID: "food", Period: models.BudgetPeriodMonthly, Amount: 500}); err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+	svc.repo = racingBudgetRepository{svc.repo}
+	if _, err := svc.CreateForUser(ctx, "user-1", &models.Budget{JarID: "food", Period: models.BudgetPeriodMonthly, Amount: 700}); !errors.Is(err, ErrBudgetExists) {
+		t.Errorf("expected ErrBudgetExists, got %v", err)
+	}
+}
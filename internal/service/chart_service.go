@@ -11,6 +11,7 @@ import (
 type chartTransactionRepository interface {
 	ListByDateRange(start, end time.Time) ([]models.Transaction, error)
 	ListByDateRangeForUser(userID string, start, end time.Time) ([]models.Transaction, error)
+	refundLister
 }
 
 // ChartService interface สำหรับ chart data aggregation
@@ -20,12 +21,13 @@ type ChartService interface {
 }
 
 type chartService struct {
-	repo chartTransactionRepository
+	repo  chartTransactionRepository
+	rates exchangeRateSource
 }
 
 // NewChartService สร้าง ChartService instance ใหม่
-func NewChartService(repo chartTransactionRepository) ChartService {
-	return &chartService{repo: repo}
+func NewChartService(repo chartTransactionRepository, rates exchangeRateSource) ChartService {
+	return &chartService{repo: repo, rates: rates}
 }
 
 // GetChartData ดึงข้อมูล transactions แล้ว aggregate เป็น chart data ทั้งหมดในรอบเดียว
@@ -48,36 +50,55 @@ func (s *chartService) GetChartDataForUser(ctx context.Context, userID string, f
 		return nil, err
 	}
 
-	// 2. Apply jar/wallet filters
+	// 2. Net refunds against their expenses, then apply jar/wallet filters
+	transactions, err = nettedTransactions(s.repo, userID, transactions, filter.StartDate, filter.EndDate)
+	if err != nil {
+		return nil, err
+	}
 	filtered := applyReportFilters(transactions, filter)
 
-	// 3. Aggregate ข้อมูลทั้งหมดใน single pass
-	chart := s.aggregate(filtered)
+	// 3. Aggregate ข้อมูลทั้งหมดใน single pass โดยแปลงเป็น base currency ของ user
+	converter, err := loadCurrencyConverter(ctx, s.rates, userID)
+	if err != nil {
+		return nil, err
+	}
+	chart := s.aggregate(filtered, converter)
 
 	// 4. Comparison: ดึงข้อมูล previous period
-	comparison, err := s.buildComparison(ctx, userID, filter, chart.Summary)
+	comparison, err := s.buildComparison(ctx, userID, filter, chart.Summary, converter)
 	if err != nil {
 		return nil, err
 	}
 	chart.Comparison = comparison
 
+	// 5. ยอดรวมดิบแยกสกุลเงิน และสกุลเงินที่ไม่มีอัตราแลกเปลี่ยน
+	chart.BaseCurrency = converter.base
+	chart.ByCurrency = converter.currencyTotals(filtered)
+	chart.MissingRates = converter.missingRates()
+
 	return chart, nil
 }
 
 // aggregate รวมข้อมูล transactions เป็น summary, trend, byJar ในรอบเดียว
-func (s *chartService) aggregate(transactions []models.Transaction) *models.ChartData {
-	var totalIncome, totalExpense float64
+// transaction ที่ไม่มีอัตราแลกเปลี่ยนจะถูกข้าม
+func (s *chartService) aggregate(transactions []models.Transaction, converter *currencyConverter) *models.ChartData {
+	var totalIncome, totalExpense models.Money
 
 	// Maps สำหรับ grouping
 	trendMap := make(map[string]*models.TrendPoint) // key: "2026-01"
 	jarMap := make(map[string]*models.JarAmount)    // key: jar_id
 
 	for _, tx := range transactions {
+		amount, ok := converter.convertTransaction(tx)
+		if !ok {
+			continue
+		}
+
 		switch tx.Type {
 		case "income":
-			totalIncome += tx.Amount
+			totalIncome += amount
 		case "expense":
-			totalExpense += tx.Amount
+			totalExpense += amount
 		}
 
 		// Trend: group by เดือน (YYYY-MM)
@@ -87,17 +108,22 @@ func (s *chartService) aggregate(transactions []models.Transaction) *models.Char
 		}
 		switch tx.Type {
 		case "income":
-			trendMap[monthKey].Income += tx.Amount
+			trendMap[monthKey].Income += amount
 		case "expense":
-			trendMap[monthKey].Expense += tx.Amount
+			trendMap[monthKey].Expense += amount
 		}
 
-		// ByJar: เฉพาะ expense
-		if tx.Type == "expense" && tx.JarID != "" {
-			if _, ok := jarMap[tx.JarID]; !ok {
-				jarMap[tx.JarID] = &models.JarAmount{ID: tx.JarID, Name: tx.JarID}
+		// ByJar: เฉพาะ expense; a split counts towards each of its jars
+		if tx.Type == "expense" {
+			for _, portion := range tx.JarAmounts(amount) {
+				if portion.JarID == "" {
+					continue
+				}
+				if _, ok := jarMap[portion.JarID]; !ok {
+					jarMap[portion.JarID] = &models.JarAmount{ID: portion.JarID, Name: portion.JarID}
+				}
+				jarMap[portion.JarID].Amount += portion.Amount
 			}
-			jarMap[tx.JarID].Amount += tx.Amount
 		}
 	}
 
@@ -126,7 +152,7 @@ func (s *chartService) aggregate(transactions []models.Transaction) *models.Char
 }
 
 // buildComparison คำนวณ previous period แล้วเปรียบเทียบ
-func (s *chartService) buildComparison(ctx context.Context, userID string, filter models.ReportFilter, currentSummary models.ChartSummary) (*models.ComparisonData, error) {
+func (s *chartService) buildComparison(ctx context.Context, userID string, filter models.ReportFilter, currentSummary models.ChartSummary, converter *currencyConverter) (*models.ComparisonData, error) {
 	duration := filter.EndDate.Sub(filter.StartDate)
 	prevStart := filter.StartDate.Add(-duration - time.Nanosecond)
 	prevEnd := filter.StartDate.Add(-time.Nanosecond)
@@ -143,21 +169,30 @@ func (s *chartService) buildComparison(ctx context.Context, userID string, filte
 	if err != nil {
 		return nil, err
 	}
+	prevTransactions, err = nettedTransactions(s.repo, userID, prevTransactions, prevStart, prevEnd)
+	if err != nil {
+		return nil, err
+	}
 
 	prevFiltered := applyReportFilters(prevTransactions, models.ReportFilter{
 		StartDate: prevStart,
 		EndDate:   prevEnd,
 		JarIDs:    filter.JarIDs,
 		WalletIDs: filter.WalletIDs,
+		TagIDs:    filter.TagIDs,
 	})
 
-	var prevIncome, prevExpense float64
+	var prevIncome, prevExpense models.Money
 	for _, tx := range prevFiltered {
+		amount, ok := converter.convertTransaction(tx)
+		if !ok {
+			continue
+		}
 		switch tx.Type {
 		case "income":
-			prevIncome += tx.Amount
+			prevIncome += amount
 		case "expense":
-			prevExpense += tx.Amount
+			prevExpense += amount
 		}
 	}
 
@@ -26,6 +26,14 @@ func (f *fakeChartRepo) ListByDateRangeForUser(_ string, start, end time.Time) (
 	return f.ListByDateRange(start, end)
 }
 
+func (f *fakeChartRepo) ListRefundsByExpenseDate(start, end time.Time) ([]models.Transaction, error) {
+	return refundsByExpenseDate(f.transactions, start, end), nil
+}
+
+func (f *fakeChartRepo) ListRefundsByExpenseDateForUser(_ string, start, end time.Time) ([]models.Transaction, error) {
+	return f.ListRefundsByExpenseDate(start, end)
+}
+
 func seedChartTransactions() []models.Transaction {
 	return []models.Transaction{
 		{
@@ -54,7 +62,7 @@ func seedChartTransactions() []models.Transaction {
 // --- 🟥 RED: Failing Tests ---
 
 func TestChartService_Summary(t *testing.T) {
-	svc := NewChartService(&fakeChartRepo{transactions: seedChartTransactions()})
+	svc := NewChartService(&fakeChartRepo{transactions: seedChartTransactions()}, &fakeRateSource{})
 	filter := models.ReportFilter{
 		StartDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
 		EndDate:   time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
@@ -67,20 +75,20 @@ func TestChartService_Summary(t *testing.T) {
 
 	// income: 5000 + 3000 = 8000
 	if chart.Summary.Income != 8000 {
-		t.Errorf("expected income 8000, got %f", chart.Summary.Income)
+		t.Errorf("expected income 8000, got %s", chart.Summary.Income)
 	}
 	// expense: 1200 + 800 + 500 = 2500
 	if chart.Summary.Expense != 2500 {
-		t.Errorf("expected expense 2500, got %f", chart.Summary.Expense)
+		t.Errorf("expected expense 2500, got %s", chart.Summary.Expense)
 	}
 	// net: 8000 - 2500 = 5500
 	if chart.Summary.Net != 5500 {
-		t.Errorf("expected net 5500, got %f", chart.Summary.Net)
+		t.Errorf("expected net 5500, got %s", chart.Summary.Net)
 	}
 }
 
 func TestChartService_Trend(t *testing.T) {
-	svc := NewChartService(&fakeChartRepo{transactions: seedChartTransactions()})
+	svc := NewChartService(&fakeChartRepo{transactions: seedChartTransactions()}, &fakeRateSource{})
 	filter := models.ReportFilter{
 		StartDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
 		EndDate:   time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
@@ -100,15 +108,15 @@ func TestChartService_Trend(t *testing.T) {
 		t.Errorf("expected date '2026-01', got '%s'", chart.Trend[0].Date)
 	}
 	if chart.Trend[0].Income != 8000 {
-		t.Errorf("expected trend income 8000, got %f", chart.Trend[0].Income)
+		t.Errorf("expected trend income 8000, got %s", chart.Trend[0].Income)
 	}
 	if chart.Trend[0].Expense != 2500 {
-		t.Errorf("expected trend expense 2500, got %f", chart.Trend[0].Expense)
+		t.Errorf("expected trend expense 2500, got %s", chart.Trend[0].Expense)
 	}
 }
 
 func TestChartService_ByJar(t *testing.T) {
-	svc := NewChartService(&fakeChartRepo{transactions: seedChartTransactions()})
+	svc := NewChartService(&fakeChartRepo{transactions: seedChartTransactions()}, &fakeRateSource{})
 	filter := models.ReportFilter{
 		StartDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
 		EndDate:   time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
@@ -124,21 +132,21 @@ func TestChartService_ByJar(t *testing.T) {
 		t.Fatalf("expected 2 jar entries, got %d", len(chart.ByJar))
 	}
 
-	jarMap := make(map[string]float64)
+	jarMap := make(map[string]models.Money)
 	for _, j := range chart.ByJar {
 		jarMap[j.ID] = j.Amount
 	}
 
 	if jarMap["jar-food"] != 1700 {
-		t.Errorf("expected jar-food amount 1700, got %f", jarMap["jar-food"])
+		t.Errorf("expected jar-food amount 1700, got %s", jarMap["jar-food"])
 	}
 	if jarMap["jar-transport"] != 800 {
-		t.Errorf("expected jar-transport amount 800, got %f", jarMap["jar-transport"])
+		t.Errorf("expected jar-transport amount 800, got %s", jarMap["jar-transport"])
 	}
 }
 
 func TestChartService_EmptyData(t *testing.T) {
-	svc := NewChartService(&fakeChartRepo{transactions: []models.Transaction{}})
+	svc := NewChartService(&fakeChartRepo{transactions: []models.Transaction{}}, &fakeRateSource{})
 	filter := models.ReportFilter{
 		StartDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
 		EndDate:   time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
@@ -161,7 +169,7 @@ func TestChartService_EmptyData(t *testing.T) {
 }
 
 func TestChartService_FilterByJar(t *testing.T) {
-	svc := NewChartService(&fakeChartRepo{transactions: seedChartTransactions()})
+	svc := NewChartService(&fakeChartRepo{transactions: seedChartTransactions()}, &fakeRateSource{})
 	filter := models.ReportFilter{
 		StartDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
 		EndDate:   time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
@@ -175,10 +183,10 @@ func TestChartService_FilterByJar(t *testing.T) {
 
 	// expense ของ jar-food: 1200 + 500 = 1700, income = 0
 	if chart.Summary.Expense != 1700 {
-		t.Errorf("expected expense 1700, got %f", chart.Summary.Expense)
+		t.Errorf("expected expense 1700, got %s", chart.Summary.Expense)
 	}
 	if chart.Summary.Income != 0 {
-		t.Errorf("expected income 0, got %f", chart.Summary.Income)
+		t.Errorf("expected income 0, got %s", chart.Summary.Income)
 	}
 }
 
@@ -193,7 +201,7 @@ func TestChartService_Comparison(t *testing.T) {
 		{ID: "c2", Amount: 2000, Date: time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC), Type: "expense", JarID: "j2", WalletID: "w1"},
 	}
 
-	svc := NewChartService(&fakeChartRepo{transactions: txns})
+	svc := NewChartService(&fakeChartRepo{transactions: txns}, &fakeRateSource{})
 	// filter ม.ค. 2026
 	filter := models.ReportFilter{
 		StartDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
@@ -211,17 +219,17 @@ func TestChartService_Comparison(t *testing.T) {
 
 	// current: income=5000, expense=2000
 	if chart.Comparison.Current.Income != 5000 {
-		t.Errorf("expected current income 5000, got %f", chart.Comparison.Current.Income)
+		t.Errorf("expected current income 5000, got %s", chart.Comparison.Current.Income)
 	}
 	if chart.Comparison.Current.Expense != 2000 {
-		t.Errorf("expected current expense 2000, got %f", chart.Comparison.Current.Expense)
+		t.Errorf("expected current expense 2000, got %s", chart.Comparison.Current.Expense)
 	}
 
 	// previous: income=3000, expense=1000
 	if chart.Comparison.Previous.Income != 3000 {
-		t.Errorf("expected previous income 3000, got %f", chart.Comparison.Previous.Income)
+		t.Errorf("expected previous income 3000, got %s", chart.Comparison.Previous.Income)
 	}
 	if chart.Comparison.Previous.Expense != 1000 {
-		t.Errorf("expected previous expense 1000, got %f", chart.Comparison.Previous.Expense)
+		t.Errorf("expected previous expense 1000, got %s", chart.Comparison.Previous.Expense)
 	}
 }
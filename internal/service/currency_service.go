@@ -0,0 +1,353 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrInvalidCurrency      = errors.New("invalid currency")
+	ErrInvalidExchangeRate  = errors.New("invalid exchange rate")
+	ErrExchangeRateNotFound = errors.New("exchange rate not found")
+)
+
+const (
+	exchangeRateSourceManual = "manual"
+	exchangeRateSourceCSV    = "csv"
+)
+
+// CurrencyService manages a user's base currency and the exchange rates used
+// to convert report, chart and graph figures into it.
+type CurrencyService interface {
+	GetBaseCurrencyForUser(ctx context.Context, userID string) (string, error)
+	SetBaseCurrencyForUser(ctx context.Context, userID, currency string) (string, error)
+	ListRatesForUser(ctx context.Context, userID string) ([]models.ExchangeRate, error)
+	// CreateRateForUser stores a manual rate, replacing any rate for the same
+	// pair and date.
+	CreateRateForUser(ctx context.Context, userID string, rate *models.ExchangeRate) (*models.ExchangeRate, error)
+	DeleteRateForUser(ctx context.Context, userID, id string) error
+	// ImportRatesCSVForUser reads rows of date, from_currency, to_currency
+	// and rate. The whole file is rejected if any row is invalid.
+	ImportRatesCSVForUser(ctx context.Context, userID string, r io.Reader) (*models.ExchangeRateImport, error)
+}
+
+type currencyService struct {
+	repo  repository.ExchangeRateRepository
+	clock func() time.Time
+}
+
+func NewCurrencyService(repo repository.ExchangeRateRepository) CurrencyService {
+	return &currencyService{
+		repo:  repo,
+		clock: func() time.Time { return time.Now().UTC() },
+	}
+}
+
+func (s *currencyService) GetBaseCurrencyForUser(ctx context.Context, userID string) (string, error) {
+	return baseCurrencyForUser(ctx, s.repo, userID)
+}
+
+func (s *currencyService) SetBaseCurrencyForUser(ctx context.Context, userID, currency string) (string, error) {
+	currency, ok := models.NormalizeCurrency(currency)
+	if !ok {
+		return "", fmt.Errorf("%w: base_currency must be a 3-letter ISO 4217 code", ErrInvalidCurrency)
+	}
+	if err := s.repo.SetBaseCurrency(ctx, normalizedServiceUserID(userID), currency); err != nil {
+		return "", fmt.Errorf("service: failed to save base currency: %w", err)
+	}
+	return currency, nil
+}
+
+func (s *currencyService) ListRatesForUser(ctx context.Context, userID string) ([]models.ExchangeRate, error) {
+	rates, err := s.repo.ListForUser(ctx, normalizedServiceUserID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list exchange rates: %w", err)
+	}
+	if rates == nil {
+		rates = []models.ExchangeRate{}
+	}
+	return rates, nil
+}
+
+func (s *currencyService) CreateRateForUser(ctx context.Context, userID string, rate *models.ExchangeRate) (*models.ExchangeRate, error) {
+	userID = normalizedServiceUserID(userID)
+	if rate == nil {
+		return nil, fmt.Errorf("%w: rate is required", ErrInvalidExchangeRate)
+	}
+
+	stored := *rate
+	if err := s.prepareRate(&stored, exchangeRateSourceManual); err != nil {
+		return nil, err
+	}
+	batch := []models.ExchangeRate{stored}
+	if err := s.repo.UpsertForUser(ctx, userID, batch); err != nil {
+		return nil, fmt.Errorf("service: failed to save exchange rate: %w", err)
+	}
+	return &batch[0], nil
+}
+
+func (s *currencyService) DeleteRateForUser(ctx context.Context, userID, id string) error {
+	err := s.repo.DeleteForUser(ctx, normalizedServiceUserID(userID), id)
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrExchangeRateNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("service: failed to delete exchange rate: %w", err)
+	}
+	return nil
+}
+
+func (s *currencyService) ImportRatesCSVForUser(ctx context.Context, userID string, r io.Reader) (*models.ExchangeRateImport, error) {
+	userID = normalizedServiceUserID(userID)
+
+	reader := csv.NewReader(r)
+	reader.TrimLeadingSpace = true
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil, fmt.Errorf("%w: the file is empty", ErrInvalidExchangeRate)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidExchangeRate, err)
+	}
+
+	columns := map[string]int{}
+	for i, name := range header {
+		name = strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))
+		switch name {
+		case "from":
+			name = "from_currency"
+		case "to":
+			name = "to_currency"
+		}
+		columns[name] = i
+	}
+	for _, required := range []string{"date", "from_currency", "to_currency", "rate"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("%w: missing %s column", ErrInvalidExchangeRate, required)
+		}
+	}
+
+	var rates []models.ExchangeRate
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidExchangeRate, err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		date, err := parseRateDate(record[columns["date"]])
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: date must be YYYY-MM-DD", ErrInvalidExchangeRate, line)
+		}
+		value, err := strconv.ParseFloat(strings.TrimSpace(record[columns["rate"]]), 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: line %d: rate must be a number", ErrInvalidExchangeRate, line)
+		}
+		rate := models.ExchangeRate{
+			FromCurrency:  record[columns["from_currency"]],
+			ToCurrency:    record[columns["to_currency"]],
+			Rate:          value,
+			EffectiveDate: date,
+		}
+		if err := s.prepareRate(&rate, exchangeRateSourceCSV); err != nil {
+			return nil, fmt.Errorf("line %d: %w", line, err)
+		}
+		rates = append(rates, rate)
+	}
+	if len(rates) == 0 {
+		return nil, fmt.Errorf("%w: the file has no rates", ErrInvalidExchangeRate)
+	}
+
+	if err := s.repo.UpsertForUser(ctx, userID, rates); err != nil {
+		return nil, fmt.Errorf("service: failed to import exchange rates: %w", err)
+	}
+	return &models.ExchangeRateImport{Imported: len(rates)}, nil
+}
+
+// prepareRate validates a rate and fills in the fields the server owns.
+func (s *currencyService) prepareRate(rate *models.ExchangeRate, source string) error {
+	var ok bool
+	if rate.FromCurrency, ok = models.NormalizeCurrency(rate.FromCurrency); !ok {
+		return fmt.Errorf("%w: from_currency must be a 3-letter ISO 4217 code", ErrInvalidExchangeRate)
+	}
+	if rate.ToCurrency, ok = models.NormalizeCurrency(rate.ToCurrency); !ok {
+		return fmt.Errorf("%w: to_currency must be a 3-letter ISO 4217 code", ErrInvalidExchangeRate)
+	}
+	if rate.FromCurrency == rate.ToCurrency {
+		return fmt.Errorf("%w: from_currency and to_currency must differ", ErrInvalidExchangeRate)
+	}
+	if rate.Rate <= 0 || math.IsInf(rate.Rate, 0) || math.IsNaN(rate.Rate) {
+		return fmt.Errorf("%w: rate must be a positive number", ErrInvalidExchangeRate)
+	}
+	if rate.EffectiveDate.IsZero() {
+		return fmt.Errorf("%w: effective_date is required", ErrInvalidExchangeRate)
+	}
+
+	y, m, d := rate.EffectiveDate.Date()
+	rate.EffectiveDate = time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	rate.ID = uuid.New().String()
+	rate.Source = source
+	rate.CreatedAt = s.clock()
+	return nil
+}
+
+func parseRateDate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// exchangeRateSource is what the analytics services need to convert amounts.
+type exchangeRateSource interface {
+	GetBaseCurrency(ctx context.Context, userID string) (string, error)
+	ListForUser(ctx context.Context, userID string) ([]models.ExchangeRate, error)
+}
+
+func baseCurrencyForUser(ctx context.Context, source exchangeRateSource, userID string) (string, error) {
+	currency, err := source.GetBaseCurrency(ctx, normalizedServiceUserID(userID))
+	if err != nil {
+		return "", fmt.Errorf("service: failed to load base currency: %w", err)
+	}
+	if currency == "" {
+		currency = models.DefaultBaseCurrency
+	}
+	return currency, nil
+}
+
+// currencyConverter converts amounts into a base currency at the rate in
+// effect on the transaction date: the latest rate dated on or before it,
+// taken directly or inverted from the opposite pair.
+type currencyConverter struct {
+	base    string
+	rates   map[[2]string][]models.ExchangeRate // by (from, to), oldest first
+	missing map[string]bool
+}
+
+func loadCurrencyConverter(ctx context.Context, source exchangeRateSource, userID string) (*currencyConverter, error) {
+	base, err := baseCurrencyForUser(ctx, source, userID)
+	if err != nil {
+		return nil, err
+	}
+	rates, err := source.ListForUser(ctx, normalizedServiceUserID(userID))
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to load exchange rates: %w", err)
+	}
+
+	c := &currencyConverter{
+		base:    base,
+		rates:   make(map[[2]string][]models.ExchangeRate),
+		missing: make(map[string]bool),
+	}
+	for _, rate := range rates {
+		pair := [2]string{rate.FromCurrency, rate.ToCurrency}
+		c.rates[pair] = append(c.rates[pair], rate)
+	}
+	for _, pairRates := range c.rates {
+		sort.Slice(pairRates, func(i, j int) bool { return pairRates[i].EffectiveDate.Before(pairRates[j].EffectiveDate) })
+	}
+	return c, nil
+}
+
+// currencyOf returns the currency of a transaction. Transactions whose
+// wallet currency is unknown are taken to be in the base currency.
+func (c *currencyConverter) currencyOf(tx models.Transaction) string {
+	if tx.Currency == "" {
+		return c.base
+	}
+	return tx.Currency
+}
+
+// convert returns the amount in the base currency. When no rate is in effect
+// on the date it returns false and remembers the currency as missing.
+func (c *currencyConverter) convert(amount models.Money, currency string, date time.Time) (models.Money, bool) {
+	if currency == "" || currency == c.base {
+		return amount, true
+	}
+
+	direct, hasDirect := latestRate(c.rates[[2]string{currency, c.base}], date)
+	inverse, hasInverse := latestRate(c.rates[[2]string{c.base, currency}], date)
+	var factor float64
+	switch {
+	case hasDirect && (!hasInverse || !inverse.EffectiveDate.After(direct.EffectiveDate)):
+		factor = direct.Rate
+	case hasInverse:
+		factor = 1 / inverse.Rate
+	default:
+		c.missing[currency] = true
+		return 0, false
+	}
+	return models.Money(math.Round(float64(amount) * factor)), true
+}
+
+func (c *currencyConverter) convertTransaction(tx models.Transaction) (models.Money, bool) {
+	return c.convert(tx.Amount, c.currencyOf(tx), tx.Date)
+}
+
+// missingRates lists the currencies that could not be converted so far.
+func (c *currencyConverter) missingRates() []string {
+	if len(c.missing) == 0 {
+		return nil
+	}
+	currencies := make([]string, 0, len(c.missing))
+	for currency := range c.missing {
+		currencies = append(currencies, currency)
+	}
+	sort.Strings(currencies)
+	return currencies
+}
+
+// currencyTotals sums income and expense per currency without converting.
+func (c *currencyConverter) currencyTotals(transactions []models.Transaction) []models.CurrencyTotal {
+	totals := make(map[string]*models.CurrencyTotal)
+	for _, tx := range transactions {
+		if tx.Type != "income" && tx.Type != "expense" {
+			continue
+		}
+		currency := c.currencyOf(tx)
+		total, ok := totals[currency]
+		if !ok {
+			total = &models.CurrencyTotal{Currency: currency}
+			totals[currency] = total
+		}
+		if tx.Type == "income" {
+			total.Income += tx.Amount
+		} else {
+			total.Expense += tx.Amount
+		}
+		total.Net = total.Income - total.Expense
+	}
+
+	result := make([]models.CurrencyTotal, 0, len(totals))
+	for _, total := range totals {
+		result = append(result, *total)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Currency < result[j].Currency })
+	return result
+}
+
+// latestRate returns the last rate dated on or before date.
+func latestRate(rates []models.ExchangeRate, date time.Time) (models.ExchangeRate, bool) {
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].EffectiveDate.After(date) })
+	if i == 0 {
+		return models.ExchangeRate{}, false
+	}
+	return rates[i-1], true
+}
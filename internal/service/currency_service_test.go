@@ -0,0 +1,150 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportAndChart_ConvertToBaseCurrency(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+	ctx := context.Background()
+
+	if _, err := dbConn.Exec("INSERT INTO users (id, google_sub, email, name, created_at, updated_at) VALUES ('user-1', 'sub-1', 'a@example.com', 'A', ?, ?)", time.Now(), time.Now()); err != nil {
+		t.Fatalf("failed to create user: %v", err)
+	}
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	for _, currency := range []string{"THB", "USD", "EUR"} {
+		if err := walletRepo.Create(ctx, &models.Wallet{ID: strings.ToLower(currency), UserID: "user-1", Name: currency, Currency: currency}); err != nil {
+			t.Fatalf("failed to create wallet: %v", err)
+		}
+	}
+	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
+	expenses := []struct {
+		walletID string
+		amount   models.Money
+		day      int
+	}{
+		{"usd", 1000, 10}, // 10.00 USD at 35.00
+		{"usd", 1000, 20}, // 10.00 USD at 36.50
+		{"thb", 10000, 12},
+		{"eur", 500, 12}, // no EUR rate
+	}
+	for i, e := range expenses {
+		err := txRepo.Create(ctx, &models.Transaction{
+			ID: fmt.Sprintf("tx-%d", i), UserID: "user-1", Amount: e.amount, Type: "expense", WalletID: e.walletID,
+			Date: time.Date(2026, 1, e.day, 12, 0, 0, 0, time.UTC),
+		})
+		if err != nil {
+			t.Fatalf("failed to create transaction: %v", err)
+		}
+	}
+
+	rateRepo := repository.NewSQLiteExchangeRateRepository(dbConn)
+	currencySvc := NewCurrencyService(rateRepo)
+	if base, err := currencySvc.SetBaseCurrencyForUser(ctx, "user-1", "thb"); err != nil || base != "THB" {
+		t.Fatalf("SetBaseCurrencyForUser returned %q, %v", base, err)
+	}
+	csvData := "date,from,to,rate\n2026-01-01,USD,THB,35\n2026-01-15,usd,thb,36.5\n"
+	result, err := currencySvc.ImportRatesCSVForUser(ctx, "user-1", strings.NewReader(csvData))
+	if err != nil || result.Imported != 2 {
+		t.Fatalf("ImportRatesCSVForUser returned %+v, %v", result, err)
+	}
+
+	filter := models.ReportFilter{
+		StartDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
+	}
+	report, err := NewReportService(txRepo, repository.NewSQLiteJarRepository(dbConn), walletRepo, rateRepo).GenerateReportForUser(ctx, "user-1", filter)
+	if err != nil {
+		t.Fatalf("GenerateReportForUser failed: %v", err)
+	}
+	if report.BaseCurrency != "THB" || report.Summary.Expense != 81500 {
+		t.Errorf("expected 815.00 THB of expenses, got %s %s", report.Summary.Expense, report.BaseCurrency)
+	}
+	if fmt.Sprint(report.MissingRates) != "[EUR]" {
+		t.Errorf("expected EUR to be missing a rate, got %v", report.MissingRates)
+	}
+	wantRaw := []models.CurrencyTotal{
+		{Currency: "EUR", Expense: 500, Net: -500},
+		{Currency: "THB", Expense: 10000, Net: -10000},
+		{Currency: "USD", Expense: 2000, Net: -2000},
+	}
+	if fmt.Sprint(report.ByCurrency) != fmt.Sprint(wantRaw) {
+		t.Errorf("expected raw totals %v, got %v", wantRaw, report.ByCurrency)
+	}
+
+	chart, err := NewChartService(txRepo, rateRepo).GetChartDataForUser(ctx, "user-1", filter)
+	if err != nil {
+		t.Fatalf("GetChartDataForUser failed: %v", err)
+	}
+	if chart.Summary.Expense != 81500 || len(chart.ByCurrency) != 3 {
+		t.Errorf("unexpected chart totals: %s %+v", chart.Summary.Expense, chart.ByCurrency)
+	}
+}
+
+func TestCurrencyService_ImportRejectsWholeFile(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	svc := NewCurrencyService(repository.NewSQLiteExchangeRateRepository(dbConn))
+	csvData := "date,from_currency,to_currency,rate\n2026-01-01,USD,THB,35\n2026-01-02,USD,THB,-1\n"
+	_, err = svc.ImportRatesCSVForUser(context.Background(), "user-1", strings.NewReader(csvData))
+	if !errors.Is(err, ErrInvalidExchangeRate) || !strings.Contains(err.Error(), "line 3") {
+		t.Fatalf("expected ErrInvalidExchangeRate on line 3, got %v", err)
+	}
+
+	rates, err := svc.ListRatesForUser(context.Background(), "user-1")
+	if err != nil || len(rates) != 0 {
+		t.Fatalf("expected no rates to be stored, got %v, %v", rates, err)
+	}
+}
+
+func TestCurrencyConverter_UsesRateInEffectOnDate(t *testing.T) {
+	day := func(d int) time.Time { return time.Date(2026, 1, d, 0, 0, 0, 0, time.UTC) }
+	source := &fakeRateSource{base: "USD", rates: []models.ExchangeRate{
+		{FromCurrency: "EUR", ToCurrency: "USD", Rate: 1.1, EffectiveDate: day(1)},
+		{FromCurrency: "USD", ToCurrency: "EUR", Rate: 0.8, EffectiveDate: day(10)},
+		{FromCurrency: "JPY", ToCurrency: "USD", Rate: 0.0067, EffectiveDate: day(5)},
+	}}
+	converter, err := loadCurrencyConverter(context.Background(), source, "user-1")
+	if err != nil {
+		t.Fatalf("loadCurrencyConverter failed: %v", err)
+	}
+
+	cases := []struct {
+		amount   models.Money
+		currency string
+		date     time.Time
+		want     models.Money
+		ok       bool
+	}{
+		{10000, "USD", day(1), 10000, true},
+		{10000, "EUR", day(5), 11000, true},  // direct EUR->USD
+		{10000, "EUR", day(12), 12500, true}, // newer inverted USD->EUR
+		{100000, "JPY", day(4), 0, false},    // before the first JPY rate
+		{100000, "JPY", day(6).Add(time.Hour), 670, true},
+	}
+	for _, tc := range cases {
+		got, ok := converter.convert(tc.amount, tc.currency, tc.date)
+		if got != tc.want || ok != tc.ok {
+			t.Errorf("convert(%s %s on %s) = %s, %v; want %s, %v", tc.amount, tc.currency, tc.date.Format("2006-01-02"), got, ok, tc.want, tc.ok)
+		}
+	}
+	if fmt.Sprint(converter.missingRates()) != "[JPY]" {
+		t.Errorf("expected JPY to be missing, got %v", converter.missingRates())
+	}
+}
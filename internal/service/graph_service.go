@@ -1,34 +1,94 @@
 package service
 
 import (
+	"context"
 	"jarwise-backend/internal/models"
 	"jarwise-backend/internal/repository"
+	"sort"
 )
 
 type GraphService interface {
-	GetExpenseGraphData(jarID, period string) ([]models.GraphDataPoint, error)
-	GetExpenseGraphDataForUser(userID, jarID, period string) ([]models.GraphDataPoint, error)
+	GetExpenseGraphData(jarID, period string) (*models.GraphData, error)
+	GetExpenseGraphDataForUser(userID, jarID, period string) (*models.GraphData, error)
 }
 
 type graphService struct {
-	repo repository.TransactionRepository
+	repo  repository.TransactionRepository
+	rates exchangeRateSource
 }
 
-func NewGraphService(repo repository.TransactionRepository) GraphService {
-	return &graphService{repo: repo}
+func NewGraphService(repo repository.TransactionRepository, rates exchangeRateSource) GraphService {
+	return &graphService{repo: repo, rates: rates}
 }
 
-func (s *graphService) GetExpenseGraphData(jarID, period string) ([]models.GraphDataPoint, error) {
+func (s *graphService) GetExpenseGraphData(jarID, period string) (*models.GraphData, error) {
 	return s.GetExpenseGraphDataForUser("", jarID, period)
 }
 
-func (s *graphService) GetExpenseGraphDataForUser(userID, jarID, period string) ([]models.GraphDataPoint, error) {
+func (s *graphService) GetExpenseGraphDataForUser(userID, jarID, period string) (*models.GraphData, error) {
 	// Basic validation (can be extended)
 	if period != "weekly" && period != "monthly" && period != "yearly" {
 		return nil, models.ErrInvalidPeriod // Needs definition or just return logic?
 	}
+
+	var (
+		totals []models.GraphDailyTotal
+		err    error
+	)
 	if userID != "" {
-		return s.repo.GetExpenseGraphDataForUser(userID, jarID, period)
+		totals, err = s.repo.GetExpenseGraphDataForUser(userID, jarID, period)
+	} else {
+		totals, err = s.repo.GetExpenseGraphData(jarID, period)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	converter, err := loadCurrencyConverter(context.Background(), s.rates, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	// Each day is converted at its own rate, then rolled up into its period.
+	var points []models.GraphDataPoint
+	index := make(map[string]int)
+	for _, total := range totals {
+		i, ok := index[total.Label]
+		if !ok {
+			i = len(points)
+			index[total.Label] = i
+			points = append(points, models.GraphDataPoint{Label: total.Label})
+		}
+		point := &points[i]
+
+		currency := total.Currency
+		if currency == "" {
+			currency = converter.base
+		}
+		if amount, ok := converter.convert(total.Amount, currency, total.Date); ok {
+			point.Amount += amount
+		}
+		point.ByCurrency = addCurrencyAmount(point.ByCurrency, currency, total.Amount)
+	}
+	if points == nil {
+		points = []models.GraphDataPoint{}
+	}
+
+	return &models.GraphData{
+		BaseCurrency: converter.base,
+		Data:         points,
+		MissingRates: converter.missingRates(),
+	}, nil
+}
+
+func addCurrencyAmount(amounts []models.CurrencyAmount, currency string, amount models.Money) []models.CurrencyAmount {
+	for i := range amounts {
+		if amounts[i].Currency == currency {
+			amounts[i].Amount += amount
+			return amounts
+		}
 	}
-	return s.repo.GetExpenseGraphData(jarID, period)
+	amounts = append(amounts, models.CurrencyAmount{Currency: currency, Amount: amount})
+	sort.Slice(amounts, func(i, j int) bool { return amounts[i].Currency < amounts[j].Currency })
+	return amounts
 }
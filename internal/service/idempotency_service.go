@@ -0,0 +1,162 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// IdempotencyKeyTTL is how long a response is kept for replay.
+	IdempotencyKeyTTL = 24 * time.Hour
+	// IdempotencyKeyLease is how long a key stays claimed by a request that
+	// has not finished, so a crash mid-request does not lock it for a day.
+	IdempotencyKeyLease = 5 * time.Minute
+
+	maxIdempotencyKeyLength = 255
+)
+
+var (
+	ErrInvalidIdempotencyKey = errors.New("invalid Idempotency-Key")
+	// ErrIdempotencyKeyInUse is returned while the first request with a key
+	// is still being handled.
+	ErrIdempotencyKeyInUse = errors.New("a request with this Idempotency-Key is still in progress")
+	// ErrIdempotencyKeyReused is returned when a key comes back with a
+	// different request.
+	ErrIdempotencyKeyReused = errors.New("Idempotency-Key was already used with a different request")
+)
+
+type IdempotencyService interface {
+	// Begin claims key for a request identified by requestHash. It returns
+	// the stored response when the request was already handled, or else the
+	// claim, which the caller passes to Complete or Release once it has
+	// handled the request.
+	Begin(ctx context.Context, userID, key, requestHash string) (stored, claim *models.IdempotencyRecord, err error)
+	// Complete stores the response of the request that made the claim and
+	// keeps it for IdempotencyKeyTTL. It fails if the claim's lease lapsed
+	// and another request took the key over.
+	Complete(ctx context.Context, claim *models.IdempotencyRecord) error
+	// Release forgets a claim so the request can be retried. A claim that
+	// was taken over is left alone.
+	Release(ctx context.Context, claim *models.IdempotencyRecord) error
+	// DeleteExpired removes responses past IdempotencyKeyTTL and returns how
+	// many were removed.
+	DeleteExpired(ctx context.Context) (int, error)
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type idempotencyService struct {
+	repo  repository.IdempotencyRepository
+	clock func() time.Time
+}
+
+func NewIdempotencyService(repo repository.IdempotencyRepository) IdempotencyService {
+	return &idempotencyService{
+		repo: repo,
+		clock: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
+}
+
+func (s *idempotencyService) Begin(ctx context.Context, userID, key, requestHash string) (*models.IdempotencyRecord, *models.IdempotencyRecord, error) {
+	if err := validateIdempotencyKey(key); err != nil {
+		return nil, nil, err
+	}
+
+	now := s.clock()
+	claim := &models.IdempotencyRecord{
+		UserID:      userID,
+		Key:         key,
+		RequestHash: requestHash,
+		LeaseToken:  uuid.New().String(),
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(IdempotencyKeyLease),
+	}
+	reserved, err := s.repo.Reserve(ctx, claim)
+	if err != nil {
+		return nil, nil, fmt.Errorf("service: failed to reserve idempotency key: %w", err)
+	}
+	if reserved {
+		return nil, claim, nil
+	}
+
+	existing, err := s.repo.GetForUser(ctx, userID, key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("service: failed to load idempotency key: %w", err)
+	}
+	switch {
+	case existing == nil:
+		// Released between the two statements; the other request failed.
+		return nil, nil, ErrIdempotencyKeyInUse
+	case existing.RequestHash != requestHash:
+		return nil, nil, ErrIdempotencyKeyReused
+	case existing.StatusCode == 0:
+		return nil, nil, ErrIdempotencyKeyInUse
+	}
+	return existing, nil, nil
+}
+
+func (s *idempotencyService) Complete(ctx context.Context, claim *models.IdempotencyRecord) error {
+	claim.ExpiresAt = s.clock().Add(IdempotencyKeyTTL)
+	if err := s.repo.Complete(ctx, claim); err != nil {
+		return fmt.Errorf("service: failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (s *idempotencyService) Release(ctx context.Context, claim *models.IdempotencyRecord) error {
+	if err := s.repo.Release(ctx, claim); err != nil {
+		return fmt.Errorf("service: failed to release idempotency key: %w", err)
+	}
+	return nil
+}
+
+func (s *idempotencyService) DeleteExpired(ctx context.Context) (int, error) {
+	removed, err := s.repo.DeleteExpired(ctx, s.clock())
+	if err != nil {
+		return 0, fmt.Errorf("service: failed to delete expired idempotency keys: %w", err)
+	}
+	return int(removed), nil
+}
+
+// Run removes expired idempotency keys. Begin already ignores them; this
+// only keeps the table small.
+func (s *idempotencyService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if removed, err := s.DeleteExpired(ctx); err != nil {
+			log.Printf("idempotency: cleanup failed: %v", err)
+		} else if removed > 0 {
+			log.Printf("idempotency: removed %d expired keys", removed)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// validateIdempotencyKey accepts up to 255 visible ASCII characters, which
+// covers UUIDs and other client-generated tokens.
+func validateIdempotencyKey(key string) error {
+	if key == "" || len(key) > maxIdempotencyKeyLength {
+		return fmt.Errorf("%w: it must be 1 to %d characters", ErrInvalidIdempotencyKey, maxIdempotencyKeyLength)
+	}
+	for _, r := range key {
+		if r < '!' || r > '~' {
+			return fmt.Errorf("%w: it may only contain visible ASCII characters", ErrInvalidIdempotencyKey)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,101 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestIdempotencyService_ReplayReuseAndExpiry(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	now := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+	svc := NewIdempotencyService(repository.NewSQLiteIdempotencyRepository(dbConn)).(*idempotencyService)
+	svc.clock = func() time.Time { return now }
+	ctx := context.Background()
+
+	stored, claim, err := svc.Begin(ctx, "user-1", "key-1", "hash-a")
+	if err != nil || stored != nil || claim == nil {
+		t.Fatalf("expected a fresh key to be claimed, got %+v, %+v, %v", stored, claim, err)
+	}
+	if _, _, err := svc.Begin(ctx, "user-1", "key-1", "hash-a"); !errors.Is(err, ErrIdempotencyKeyInUse) {
+		t.Errorf("expected a retry during the first request to be refused, got %v", err)
+	}
+	claim.StatusCode = 201
+	claim.ContentType = "application/json"
+	claim.ETag = `"1"`
+	claim.Location = "/api/v1/transactions/t-1"
+	claim.Body = []byte(`{"id":"t-1"}`)
+	if err := svc.Complete(ctx, claim); err != nil {
+		t.Fatalf("Complete failed: %v", err)
+	}
+
+	// A completed response outlives the lease of the pending claim.
+	now = now.Add(IdempotencyKeyLease + time.Minute)
+	stored, _, err = svc.Begin(ctx, "user-1", "key-1", "hash-a")
+	if err != nil || stored == nil || stored.StatusCode != 201 || string(stored.Body) != `{"id":"t-1"}` ||
+		stored.ETag != `"1"` || stored.Location != "/api/v1/transactions/t-1" {
+		t.Fatalf("expected the stored response, got %+v, %v", stored, err)
+	}
+	if _, _, err := svc.Begin(ctx, "user-1", "key-1", "hash-b"); !errors.Is(err, ErrIdempotencyKeyReused) {
+		t.Errorf("expected a different request to be rejected, got %v", err)
+	}
+	var userTwoClaim, abandoned, takenOver *models.IdempotencyRecord
+	if stored, userTwoClaim, err = svc.Begin(ctx, "user-2", "key-1", "hash-b"); err != nil || stored != nil {
+		t.Errorf("expected keys to be scoped to the user, got %+v, %v", stored, err)
+	}
+	if _, _, err := svc.Begin(ctx, "user-1", "has space", "hash-a"); !errors.Is(err, ErrInvalidIdempotencyKey) {
+		t.Errorf("expected an invalid key to be rejected, got %v", err)
+	}
+
+	// A claim whose request never finished lapses after the lease.
+	if stored, abandoned, err = svc.Begin(ctx, "user-3", "key-1", "hash-a"); err != nil || stored != nil {
+		t.Fatalf("expected a fresh key to be claimed, got %+v, %v", stored, err)
+	}
+	now = now.Add(IdempotencyKeyLease)
+	if stored, takenOver, err = svc.Begin(ctx, "user-3", "key-1", "hash-a"); err != nil || stored != nil {
+		t.Errorf("expected an abandoned claim to be claimable again, got %+v, %v", stored, err)
+	}
+	// The request that lost its lease can neither free nor settle the key.
+	if err := svc.Release(ctx, abandoned); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if _, _, err := svc.Begin(ctx, "user-3", "key-1", "hash-a"); !errors.Is(err, ErrIdempotencyKeyInUse) {
+		t.Errorf("expected the new claim to survive the old release, got %v", err)
+	}
+	abandoned.StatusCode = 500
+	if err := svc.Complete(ctx, abandoned); !errors.Is(err, sql.ErrNoRows) {
+		t.Errorf("expected the old claim to be unable to complete, got %v", err)
+	}
+	takenOver.StatusCode = 204
+	if err := svc.Complete(ctx, takenOver); err != nil {
+		t.Errorf("expected the new claim to complete, got %v", err)
+	}
+
+	// A released key can be used again straight away.
+	if err := svc.Release(ctx, userTwoClaim); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+	if stored, _, err := svc.Begin(ctx, "user-2", "key-1", "hash-c"); err != nil || stored != nil {
+		t.Errorf("expected a released key to be claimable, got %+v, %v", stored, err)
+	}
+
+	// After the TTL the key starts over, and the sweep removes the rest.
+	now = now.Add(IdempotencyKeyTTL + time.Minute)
+	if stored, _, err := svc.Begin(ctx, "user-1", "key-1", "hash-b"); err != nil || stored != nil {
+		t.Errorf("expected an expired key to be claimable, got %+v, %v", stored, err)
+	}
+	removed, err := svc.DeleteExpired(ctx)
+	if err != nil || removed != 2 {
+		t.Errorf("expected the expired keys of user-2 and user-3 to be swept, got %d, %v", removed, err)
+	}
+}
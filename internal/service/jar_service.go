@@ -0,0 +1,338 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"sort"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrJarNotFound = errors.New("jar not found")
+	ErrInvalidJar  = errors.New("invalid jar")
+	ErrJarCycle    = errors.New("jar cannot be nested under itself or its descendants")
+	ErrJarInUse    = errors.New("jar is still referenced by transactions or sub-jars")
+)
+
+type JarService interface {
+	ListForUser(ctx context.Context, userID string) ([]models.Jar, error)
+	TreeForUser(ctx context.Context, userID string) ([]models.JarNode, error)
+	GetForUser(ctx context.Context, userID, id string) (*models.Jar, error)
+	CreateForUser(ctx context.Context, userID string, jar *models.Jar) (*models.Jar, error)
+	UpdateForUser(ctx context.Context, userID string, jar *models.Jar) (*models.Jar, error)
+	// MoveForUser fails with repository.ErrVersionMismatch unless the jar is
+	// at version; zero skips the check.
+	MoveForUser(ctx context.Context, userID, id, parentID string, version int64) (*models.Jar, error)
+	DeleteForUser(ctx context.Context, userID, id string, opts JarDeleteOptions) error
+}
+
+// JarDeleteOptions selects how a jar that is still in use gets deleted. With
+// neither option set the delete is refused while anything references the jar.
+type JarDeleteOptions struct {
+	// ReplacementJarID receives the jar's transactions and sub-jars.
+	ReplacementJarID string
+	// Cascade deletes the jar's descendants and every transaction filed under them.
+	Cascade bool
+	// Version, unless zero, is the version the jar must still be at, or the
+	// delete fails with repository.ErrVersionMismatch.
+	Version int64
+}
+
+type jarService struct {
+	repo       repository.JarRepository
+	walletRepo repository.WalletRepository
+}
+
+func NewJarService(repo repository.JarRepository, walletRepo repository.WalletRepository) JarService {
+	return &jarService{
+		repo:       repo,
+		walletRepo: walletRepo,
+	}
+}
+
+func (s *jarService) ListForUser(ctx context.Context, userID string) ([]models.Jar, error) {
+	jars, err := s.repo.ListAllForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list jars: %w", err)
+	}
+	return jars, nil
+}
+
+// TreeForUser nests jars under their parents. Jars whose parent is missing
+// are treated as roots so nothing imported is hidden from the client.
+func (s *jarService) TreeForUser(ctx context.Context, userID string) ([]models.JarNode, error) {
+	jars, err := s.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	return buildJarTree(jars), nil
+}
+
+func buildJarTree(jars []models.Jar) []models.JarNode {
+	known := make(map[string]bool, len(jars))
+	for _, jar := range jars {
+		known[jar.ID] = true
+	}
+
+	children := make(map[string][]models.Jar)
+	var roots []models.Jar
+	for _, jar := range jars {
+		if jar.ParentID == "" || !known[jar.ParentID] || jar.ParentID == jar.ID {
+			roots = append(roots, jar)
+			continue
+		}
+		children[jar.ParentID] = append(children[jar.ParentID], jar)
+	}
+
+	visited := make(map[string]bool, len(jars))
+	var build func(level []models.Jar) []models.JarNode
+	build = func(level []models.Jar) []models.JarNode {
+		sort.Slice(level, func(i, j int) bool {
+			if level[i].Name != level[j].Name {
+				return level[i].Name < level[j].Name
+			}
+			return level[i].ID < level[j].ID
+		})
+
+		nodes := make([]models.JarNode, 0, len(level))
+		for _, jar := range level {
+			if visited[jar.ID] {
+				continue
+			}
+			visited[jar.ID] = true
+			nodes = append(nodes, models.JarNode{Jar: jar, Children: build(children[jar.ID])})
+		}
+		return nodes
+	}
+	return build(roots)
+}
+
+func (s *jarService) GetForUser(ctx context.Context, userID, id string) (*models.Jar, error) {
+	jar, err := s.repo.GetForUser(ctx, userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to load jar: %w", err)
+	}
+	if jar == nil {
+		return nil, ErrJarNotFound
+	}
+	return jar, nil
+}
+
+// CreateForUser stores a new jar, generating its ID unless the caller chose
+// one.
+func (s *jarService) CreateForUser(ctx context.Context, userID string, jar *models.Jar) (*models.Jar, error) {
+	if jar.ID == "" {
+		jar.ID = uuid.New().String()
+	}
+	jar.UserID = normalizedServiceUserID(userID)
+	if err := s.validateJar(ctx, jar, nil); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, jar); err != nil {
+		return nil, fmt.Errorf("service: failed to create jar: %w", err)
+	}
+	return jar, nil
+}
+
+func (s *jarService) UpdateForUser(ctx context.Context, userID string, jar *models.Jar) (*models.Jar, error) {
+	existing, err := s.GetForUser(ctx, userID, jar.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	jar.UserID = existing.UserID
+	if err := s.validateJar(ctx, jar, existing); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, jar); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrJarNotFound
+		}
+		return nil, fmt.Errorf("service: failed to update jar: %w", err)
+	}
+	return jar, nil
+}
+
+// MoveForUser re-parents a jar. An empty parentID moves it to the top level.
+func (s *jarService) MoveForUser(ctx context.Context, userID, id, parentID string, version int64) (*models.Jar, error) {
+	jar, err := s.GetForUser(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 {
+		jar.Version = version
+	}
+	jar.ParentID = parentID
+	return s.UpdateForUser(ctx, userID, jar)
+}
+
+// DeleteForUser removes a jar using one of the three deletion modes. Each
+// mode runs in a single database transaction.
+func (s *jarService) DeleteForUser(ctx context.Context, userID, id string, opts JarDeleteOptions) error {
+	jar, err := s.GetForUser(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case opts.Cascade && opts.ReplacementJarID != "":
+		return fmt.Errorf("%w: cascade and replacement_id cannot be combined", ErrInvalidJar)
+	case opts.Cascade:
+		err = s.repo.DeleteCascadeForUser(ctx, jar.UserID, jar.ID, opts.Version)
+	case opts.ReplacementJarID != "":
+		if err := s.validateReplacementJar(ctx, jar, opts.ReplacementJarID); err != nil {
+			return err
+		}
+		err = s.repo.DeleteWithReplacementForUser(ctx, jar.UserID, jar.ID, opts.ReplacementJarID, opts.Version)
+	default:
+		err = s.repo.DeleteForUser(ctx, jar.UserID, jar.ID, opts.Version)
+		if errors.Is(err, repository.ErrJarReferenced) {
+			childJars, transactions, countErr := s.repo.CountReferencesForUser(ctx, jar.UserID, jar.ID)
+			if countErr != nil {
+				return fmt.Errorf("service: failed to check jar references: %w", countErr)
+			}
+			if childJars > 0 || transactions > 0 {
+				return fmt.Errorf("%w: %d sub-jars, %d transactions", ErrJarInUse, childJars, transactions)
+			}
+		}
+	}
+
+	if errors.Is(err, repository.ErrInvalidReplacementJar) {
+		// The replacement changed after it was validated.
+		return fmt.Errorf("%w: %w", ErrInvalidJar, err)
+	}
+	if errors.Is(err, repository.ErrJarReferenced) {
+		// Only split lines of transactions in the trash are left.
+		return fmt.Errorf("%w: split transactions in the trash still use it", ErrJarInUse)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return ErrJarNotFound
+	}
+	if err != nil {
+		return fmt.Errorf("service: failed to delete jar: %w", err)
+	}
+	return nil
+}
+
+// validateReplacementJar makes sure re-filing a jar's transactions and
+// sub-jars under the replacement keeps the hierarchy consistent.
+func (s *jarService) validateReplacementJar(ctx context.Context, jar *models.Jar, replacementID string) error {
+	if replacementID == jar.ID {
+		return fmt.Errorf("%w: a jar cannot replace itself", ErrInvalidJar)
+	}
+
+	jars, err := s.repo.ListAllForUser(ctx, jar.UserID)
+	if err != nil {
+		return fmt.Errorf("service: failed to load jars: %w", err)
+	}
+	byID := make(map[string]models.Jar, len(jars))
+	for _, j := range jars {
+		byID[j.ID] = j
+	}
+
+	replacement, ok := byID[replacementID]
+	if !ok {
+		return fmt.Errorf("%w: replacement jar %s does not exist", ErrInvalidJar, replacementID)
+	}
+	if replacement.Type != jar.Type {
+		return fmt.Errorf("%w: replacement jar %s is %s, not %s", ErrInvalidJar, replacementID, replacement.Type, jar.Type)
+	}
+
+	seen := map[string]bool{}
+	for current := replacement.ParentID; current != ""; current = byID[current].ParentID {
+		if current == jar.ID {
+			return fmt.Errorf("%w: replacement jar %s is nested under the deleted jar", ErrInvalidJar, replacementID)
+		}
+		if seen[current] {
+			break
+		}
+		seen[current] = true
+	}
+	return nil
+}
+
+// validateJar checks a new or edited jar. existing is nil on create.
+func (s *jarService) validateJar(ctx context.Context, jar *models.Jar, existing *models.Jar) error {
+	jar.Name = strings.TrimSpace(jar.Name)
+	jar.ParentID = strings.TrimSpace(jar.ParentID)
+	jar.WalletID = strings.TrimSpace(jar.WalletID)
+
+	if jar.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidJar)
+	}
+	if jar.Type != "income" && jar.Type != "expense" && jar.Type != "jar" {
+		return fmt.Errorf("%w: type must be income, expense or jar", ErrInvalidJar)
+	}
+
+	if jar.WalletID != "" {
+		wallet, err := s.walletRepo.GetForUser(jar.UserID, jar.WalletID)
+		if err != nil {
+			return fmt.Errorf("service: failed to check wallet: %w", err)
+		}
+		if wallet == nil {
+			return fmt.Errorf("%w: wallet %s does not exist", ErrInvalidJar, jar.WalletID)
+		}
+	}
+
+	needsHierarchy := jar.ParentID != "" || (existing != nil && existing.Type != jar.Type)
+	if !needsHierarchy {
+		return nil
+	}
+
+	jars, err := s.repo.ListAllForUser(ctx, jar.UserID)
+	if err != nil {
+		return fmt.Errorf("service: failed to load jars: %w", err)
+	}
+	byID := make(map[string]models.Jar, len(jars))
+	for _, j := range jars {
+		byID[j.ID] = j
+	}
+
+	if jar.ParentID != "" {
+		parent, ok := byID[jar.ParentID]
+		if !ok {
+			return fmt.Errorf("%w: parent jar %s does not exist", ErrInvalidJar, jar.ParentID)
+		}
+		if parent.Type != jar.Type {
+			return fmt.Errorf("%w: parent jar %s is %s, not %s", ErrInvalidJar, parent.ID, parent.Type, jar.Type)
+		}
+
+		// Walk up from the new parent; meeting the jar itself means a cycle.
+		seen := map[string]bool{}
+		for current := jar.ParentID; current != ""; current = byID[current].ParentID {
+			if current == jar.ID {
+				return ErrJarCycle
+			}
+			if seen[current] {
+				break
+			}
+			seen[current] = true
+		}
+	}
+
+	if existing != nil && existing.Type != jar.Type {
+		for _, j := range jars {
+			if j.ParentID == jar.ID && j.Type != jar.Type {
+				return fmt.Errorf("%w: sub-jar %s is %s, so the type cannot change to %s", ErrInvalidJar, j.ID, j.Type, jar.Type)
+			}
+		}
+		// Transactions and allocated income were filed under the old type
+		// and would otherwise silently count as the new one.
+		_, transactions, err := s.repo.CountReferencesForUser(ctx, jar.UserID, jar.ID)
+		if err != nil {
+			return fmt.Errorf("service: failed to check jar references: %w", err)
+		}
+		if transactions > 0 {
+			return fmt.Errorf("%w: jar holds %d %s transactions, so the type cannot change to %s", ErrInvalidJar, transactions, existing.Type, jar.Type)
+		}
+	}
+	return nil
+}
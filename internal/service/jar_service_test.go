@@ -0,0 +1,166 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"testing"
+	"time"
+)
+
+func setupJarService(t *testing.T) (JarService, repository.TransactionRepository) {
+	t.Helper()
+
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	t.Cleanup(func() { dbConn.Close() })
+
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	if err := walletRepo.Create(context.Background(), &models.Wallet{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+
+	return NewJarService(repository.NewSQLiteJarRepository(dbConn), walletRepo), repository.NewSQLiteTransactionRepository(dbConn)
+}
+
+func TestJarService_HierarchyRules(t *testing.T) {
+	svc, _ := setupJarService(t)
+	ctx := context.Background()
+
+	food, err := svc.CreateForUser(ctx, "user-1", &models.Jar{Name: "Food", Type: "expense"})
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+	dining, err := svc.CreateForUser(ctx, "user-1", &models.Jar{Name: "Dining", Type: "expense", ParentID: food.ID})
+	if err != nil {
+		t.Fatalf("CreateForUser child failed: %v", err)
+	}
+	coffee, err := svc.CreateForUser(ctx, "user-1", &models.Jar{Name: "Coffee", Type: "expense", ParentID: dining.ID})
+	if err != nil {
+		t.Fatalf("CreateForUser grandchild failed: %v", err)
+	}
+
+	if _, err := svc.CreateForUser(ctx, "user-1", &models.Jar{Name: "Bonus", Type: "income", ParentID: food.ID}); !errors.Is(err, ErrInvalidJar) {
+		t.Errorf("expected ErrInvalidJar for mixed types, got %v", err)
+	}
+	if _, err := svc.MoveForUser(ctx, "user-1", food.ID, coffee.ID, 0); !errors.Is(err, ErrJarCycle) {
+		t.Errorf("expected ErrJarCycle moving under a descendant, got %v", err)
+	}
+	if _, err := svc.MoveForUser(ctx, "user-1", food.ID, food.ID, 0); !errors.Is(err, ErrJarCycle) {
+		t.Errorf("expected ErrJarCycle moving under itself, got %v", err)
+	}
+	if _, err := svc.MoveForUser(ctx, "user-2", coffee.ID, "", 0); !errors.Is(err, ErrJarNotFound) {
+		t.Errorf("expected ErrJarNotFound for another user, got %v", err)
+	}
+
+	food.Type = "income"
+	if _, err := svc.UpdateForUser(ctx, "user-1", food); !errors.Is(err, ErrInvalidJar) {
+		t.Errorf("expected ErrInvalidJar changing type with typed children, got %v", err)
+	}
+
+	if _, err := svc.MoveForUser(ctx, "user-1", coffee.ID, food.ID, coffee.Version+1); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Errorf("expected a stale version to be refused, got %v", err)
+	}
+	if _, err := svc.MoveForUser(ctx, "user-1", coffee.ID, food.ID, coffee.Version); err != nil {
+		t.Fatalf("MoveForUser failed: %v", err)
+	}
+
+	tree, err := svc.TreeForUser(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("TreeForUser failed: %v", err)
+	}
+	if len(tree) != 1 || len(tree[0].Children) != 2 {
+		t.Fatalf("expected Food with two children, got %+v", tree)
+	}
+	if tree[0].Children[0].Name != "Coffee" || tree[0].Children[1].Name != "Dining" {
+		t.Errorf("expected children sorted by name, got %+v", tree[0].Children)
+	}
+}
+
+func TestJarService_TypeChangeRefusedOnceUsed(t *testing.T) {
+	svc, txRepo := setupJarService(t)
+	ctx := context.Background()
+
+	salary, err := svc.CreateForUser(ctx, "user-1", &models.Jar{Name: "Salary", Type: "income"})
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+	if err := txRepo.Create(ctx, &models.Transaction{ID: "tx-1", UserID: "user-1", Amount: 5000, Date: time.Now(), Type: "income", WalletID: "wallet-1", JarID: salary.ID}); err != nil {
+		t.Fatalf("failed to create transaction: %v", err)
+	}
+
+	salary.Type = "expense"
+	if _, err := svc.UpdateForUser(ctx, "user-1", salary); !errors.Is(err, ErrInvalidJar) {
+		t.Errorf("expected ErrInvalidJar changing the type of a jar with transactions, got %v", err)
+	}
+
+	if err := txRepo.DeleteForUser(ctx, "user-1", "tx-1", 0); err != nil {
+		t.Fatalf("failed to delete transaction: %v", err)
+	}
+	if _, err := svc.UpdateForUser(ctx, "user-1", salary); err != nil {
+		t.Errorf("expected an unused jar to change type, got %v", err)
+	}
+}
+
+func TestJarService_DeleteRefusesReferencedJar(t *testing.T) {
+	svc, txRepo := setupJarService(t)
+	ctx := context.Background()
+
+	parent, _ := svc.CreateForUser(ctx, "user-1", &models.Jar{Name: "Food", Type: "expense"})
+	child, _ := svc.CreateForUser(ctx, "user-1", &models.Jar{Name: "Snacks", Type: "expense", ParentID: parent.ID})
+
+	if err := svc.DeleteForUser(ctx, "user-1", parent.ID, JarDeleteOptions{}); !errors.Is(err, ErrJarInUse) {
+		t.Errorf("expected ErrJarInUse for jar with sub-jars, got %v", err)
+	}
+
+	if err := txRepo.Create(ctx, &models.Transaction{ID: "tx-1", UserID: "user-1", Amount: 5, Date: time.Now(), Type: "expense", WalletID: "wallet-1", JarID: child.ID}); err != nil {
+		t.Fatalf("failed to create transaction: %v", err)
+	}
+	if err := svc.DeleteForUser(ctx, "user-1", child.ID, JarDeleteOptions{}); !errors.Is(err, ErrJarInUse) {
+		t.Errorf("expected ErrJarInUse for jar with transactions, got %v", err)
+	}
+
+	if err := txRepo.DeleteForUser(ctx, "user-1", "tx-1", 0); err != nil {
+		t.Fatalf("failed to delete transaction: %v", err)
+	}
+	if err := svc.DeleteForUser(ctx, "user-1", child.ID, JarDeleteOptions{}); err != nil {
+		t.Fatalf("DeleteForUser failed: %v", err)
+	}
+	if _, err := svc.GetForUser(ctx, "user-1", child.ID); !errors.Is(err, ErrJarNotFound) {
+		t.Errorf("expected ErrJarNotFound after delete, got %v", err)
+	}
+}
+
+func TestJarService_DeleteWithReplacementValidation(t *testing.T) {
+	svc, _ := setupJarService(t)
+	ctx := context.Background()
+
+	food, _ := svc.CreateForUser(ctx, "user-1", &models.Jar{Name: "Food", Type: "expense"})
+	snacks, _ := svc.CreateForUser(ctx, "user-1", &models.Jar{Name: "Snacks", Type: "expense", ParentID: food.ID})
+	salary, _ := svc.CreateForUser(ctx, "user-1", &models.Jar{Name: "Salary", Type: "income"})
+	misc, _ := svc.CreateForUser(ctx, "user-1", &models.Jar{Name: "Misc", Type: "expense"})
+
+	invalid := []JarDeleteOptions{
+		{ReplacementJarID: snacks.ID},
+		{ReplacementJarID: salary.ID},
+		{ReplacementJarID: food.ID},
+		{ReplacementJarID: misc.ID, Cascade: true},
+	}
+	for _, opts := range invalid {
+		if err := svc.DeleteForUser(ctx, "user-1", food.ID, opts); !errors.Is(err, ErrInvalidJar) {
+			t.Errorf("expected ErrInvalidJar for %+v, got %v", opts, err)
+		}
+	}
+
+	if err := svc.DeleteForUser(ctx, "user-1", food.ID, JarDeleteOptions{ReplacementJarID: misc.ID}); err != nil {
+		t.Fatalf("DeleteForUser with replacement failed: %v", err)
+	}
+	moved, err := svc.GetForUser(ctx, "user-1", snacks.ID)
+	if err != nil || moved.ParentID != misc.ID {
+		t.Errorf("expected Snacks under Misc, got %+v (%v)", moved, err)
+	}
+}
@@ -11,6 +11,7 @@ import (
 	"io"
 	"jarwise-backend/internal/models"
 	"jarwise-backend/internal/parser"
+	"jarwise-backend/internal/repository"
 	"jarwise-backend/internal/validator"
 	"log"
 	"math"
@@ -40,13 +41,17 @@ type MigrationService interface {
 type migrationService struct {
 	db        *sql.DB
 	validator *validator.Validator
-	clock     func() time.Time
+	// audit credits imports to the session that confirmed them; it may be
+	// nil.
+	audit AuditService
+	clock func() time.Time
 }
 
-func NewMigrationService(db *sql.DB) MigrationService {
+func NewMigrationService(db *sql.DB, audit AuditService) MigrationService {
 	return &migrationService{
 		db:        db,
 		validator: validator.NewValidator(),
+		audit:     audit,
 		clock: func() time.Time {
 			return time.Now().UTC()
 		},
@@ -163,7 +168,10 @@ func (s *migrationService) ConfirmJob(ctx context.Context, userID, jobID string)
 		return nil, ErrMigrationJobConflict
 	}
 
-	go s.runImport(jobID, userID)
+	actor, _ := AuditActorFromContext(ctx)
+	actor.UserID = userID
+	actor.Source = models.AuditSourceImport
+	go s.runImport(jobID, userID, actor)
 
 	job.Phase = models.MigrationPhaseImporting
 	job.Message = "Import is in progress."
@@ -206,7 +214,7 @@ func (s *migrationService) runValidation(jobID, userID string) {
 	_ = s.persistJobState(ctx, jobID, userID, models.MigrationPhasePreviewReady, "Validation complete. Ready to import.", counts, nil, duplicateSummary, true)
 }
 
-func (s *migrationService) runImport(jobID, userID string) {
+func (s *migrationService) runImport(jobID, userID string, actor models.AuditActor) {
 	ctx := context.Background()
 	job, err := s.loadJob(ctx, userID, jobID)
 	if err != nil {
@@ -234,7 +242,10 @@ func (s *migrationService) runImport(jobID, userID string) {
 		return
 	}
 
-	if err := s.importParsedData(ctx, userID, parsedData, counts); err != nil {
+	err = attribute(ctx, s.audit, userID, actor, func(ctx context.Context) error {
+		return s.importParsedData(ctx, userID, parsedData, counts)
+	})
+	if err != nil {
 		log.Printf("[migration:%s] import failed: %v", jobID, err)
 		_ = s.persistJobState(ctx, jobID, userID, models.MigrationPhaseFailed, "Import failed.", counts, []models.MigrationValidationError{{
 			Code:    "import_failed",
@@ -256,7 +267,7 @@ func (s *migrationService) validateJobFiles(job *models.MigrationJob) (*models.P
 	}
 
 	log.Printf(
-		"[migration:%s] parsed mmbak accounts=%d categories=%d transactions=%d total_income=%.2f total_expense=%.2f",
+		"[migration:%s] parsed mmbak accounts=%d categories=%d transactions=%d total_income=%s total_expense=%s",
 		job.ID,
 		len(parsedData.Accounts),
 		len(parsedData.Categories),
@@ -272,7 +283,7 @@ func (s *migrationService) validateJobFiles(job *models.MigrationJob) (*models.P
 	}
 
 	log.Printf(
-		"[migration:%s] parsed xls transactions=%d total_income=%.2f total_expense=%.2f",
+		"[migration:%s] parsed xls transactions=%d total_income=%s total_expense=%s",
 		job.ID,
 		len(xlsData.Transactions),
 		xlsData.TotalIncome,
@@ -352,7 +363,7 @@ func (s *migrationService) detectDuplicates(ctx context.Context, userID string,
 	for _, transaction := range data.Transactions {
 		displayName := transaction.Note
 		if displayName == "" {
-			displayName = fmt.Sprintf("%s %.2f", transaction.Date, transaction.Amount)
+			displayName = fmt.Sprintf("%s %s", transaction.Date, transaction.Amount)
 		}
 
 		item, duplicate, err := s.lookupDuplicate(ctx, userID, "transaction", transaction.ID, fingerprintTransaction(transaction), displayName)
@@ -412,7 +423,7 @@ func (s *migrationService) lookupDuplicate(ctx context.Context, userID, entityTy
 }
 
 func (s *migrationService) importParsedData(ctx context.Context, userID string, data *models.ParsedData, counts *models.MigrationJobCounts) error {
-	tx, err := s.db.BeginTx(ctx, nil)
+	tx, err := repository.BeginWrite(ctx, s.db)
 	if err != nil {
 		return err
 	}
@@ -423,9 +434,9 @@ func (s *migrationService) importParsedData(ctx context.Context, userID string,
 		newID := uuid.NewString()
 		walletIDs[account.ID] = newID
 		if _, err := tx.ExecContext(ctx, `
-			INSERT INTO wallets (id, user_id, name, currency, balance, type)
-			VALUES (?, ?, ?, ?, ?, ?)
-		`, newID, userID, account.Name, account.Currency, account.Balance, "general"); err != nil {
+			INSERT INTO wallets (id, user_id, name, currency, opening_balance, balance, type)
+			VALUES (?, ?, ?, ?, ?, ?, ?)
+		`, newID, userID, account.Name, account.Currency, account.Balance, account.Balance, "general"); err != nil {
 			return fmt.Errorf("failed to insert wallet %s: %w", account.ID, err)
 		}
 		if err := s.insertSourceRefTx(ctx, tx, userID, "wallet", account.ID, fingerprintWallet(account), account.Name, newID); err != nil {
@@ -498,6 +509,10 @@ func (s *migrationService) importParsedData(ctx context.Context, userID string,
 		}
 	}
 
+	if err := repository.RefreshWalletBalances(tx, userID); err != nil {
+		return fmt.Errorf("failed to refresh wallet balances: %w", err)
+	}
+
 	if err := tx.Commit(); err != nil {
 		return err
 	}
@@ -514,7 +529,7 @@ func (s *migrationService) importParsedData(ctx context.Context, userID string,
 	return nil
 }
 
-func (s *migrationService) insertSourceRefTx(ctx context.Context, tx *sql.Tx, userID, entityType, sourceID, fingerprint, displayName, importedRecordID string) error {
+func (s *migrationService) insertSourceRefTx(ctx context.Context, tx *repository.WriteTx, userID, entityType, sourceID, fingerprint, displayName, importedRecordID string) error {
 	_, err := tx.ExecContext(ctx, `
 		INSERT INTO migration_source_refs (
 			id, user_id, source_system, entity_type, source_id, fingerprint, display_name, imported_record_id, created_at
@@ -794,7 +809,7 @@ func parseUnixLikeTimestamp(value string) (time.Time, bool) {
 }
 
 func fingerprintWallet(account models.AccountDTO) string {
-	return fingerprintStrings(normalize(account.Name), normalize(account.Currency), fmt.Sprintf("%.2f", account.Balance))
+	return fingerprintStrings(normalize(account.Name), normalize(account.Currency), account.Balance.String())
 }
 
 func fingerprintJar(category models.CategoryDTO) string {
@@ -804,7 +819,7 @@ func fingerprintJar(category models.CategoryDTO) string {
 func fingerprintTransaction(transaction models.TransactionDTO) string {
 	return fingerprintStrings(
 		normalize(transaction.Date),
-		fmt.Sprintf("%.2f", transaction.Amount),
+		transaction.Amount.String(),
 		fmt.Sprintf("%d", transaction.Type),
 		normalize(transaction.CategoryID),
 		normalize(transaction.AccountID),
@@ -0,0 +1,461 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"log"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrRecurringRuleNotFound       = errors.New("recurring rule not found")
+	ErrInvalidRecurringRule        = errors.New("invalid recurring rule")
+	ErrRecurringOccurrenceNotFound = errors.New("recurring rule has no occurrence on that date")
+	ErrRecurringOccurrenceRecorded = errors.New("occurrence has already been recorded")
+)
+
+const (
+	defaultUpcomingWindow = 30 * 24 * time.Hour
+	defaultUpcomingLimit  = 50
+	maxUpcomingLimit      = 500
+
+	// maxMaterializePerRule bounds one run for a rule that has fallen far
+	// behind; the next run picks up where this one stopped.
+	maxMaterializePerRule = 1000
+)
+
+type RecurringService interface {
+	ListForUser(ctx context.Context, userID string) ([]models.RecurringRule, error)
+	GetForUser(ctx context.Context, userID, id string) (*models.RecurringRule, error)
+	CreateForUser(ctx context.Context, userID string, rule *models.RecurringRule) (*models.RecurringRule, error)
+	// UpdateForUser edits the rule. Occurrences that were already recorded
+	// keep their transactions; only future occurrences follow the new rule.
+	UpdateForUser(ctx context.Context, userID string, rule *models.RecurringRule) (*models.RecurringRule, error)
+	DeleteForUser(ctx context.Context, userID, id string) error
+	// UpcomingForUser lists the occurrences that have not been recorded yet,
+	// up to and including until. A zero until means 30 days from now.
+	UpcomingForUser(ctx context.Context, userID string, until time.Time, limit int) ([]models.RecurringOccurrence, error)
+	// SkipForUser marks the rule's occurrence on the given calendar day as
+	// skipped so it is never recorded.
+	SkipForUser(ctx context.Context, userID, ruleID string, day time.Time) (*models.RecurringOccurrence, error)
+	// MaterializeDueForUser records every due occurrence of the user's rules
+	// as a transaction and returns the number of transactions created.
+	MaterializeDueForUser(ctx context.Context, userID string) (int, error)
+	// MaterializeDue does the same for every user.
+	MaterializeDue(ctx context.Context) (int, error)
+	// Run materializes due occurrences every interval until ctx is done.
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type recurringService struct {
+	repo       repository.RecurringRepository
+	walletRepo repository.WalletRepository
+	jarRepo    repository.JarRepository
+	// audit credits background runs to the recurring job; it may be nil.
+	audit AuditService
+	clock func() time.Time
+}
+
+func NewRecurringService(repo repository.RecurringRepository, walletRepo repository.WalletRepository, jarRepo repository.JarRepository, audit AuditService) RecurringService {
+	return &recurringService{
+		repo:       repo,
+		walletRepo: walletRepo,
+		jarRepo:    jarRepo,
+		audit:      audit,
+		clock: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
+}
+
+func (s *recurringService) ListForUser(ctx context.Context, userID string) ([]models.RecurringRule, error) {
+	rules, err := s.repo.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list recurring rules: %w", err)
+	}
+	if rules == nil {
+		rules = []models.RecurringRule{}
+	}
+	return rules, nil
+}
+
+func (s *recurringService) GetForUser(ctx context.Context, userID, id string) (*models.RecurringRule, error) {
+	rule, err := s.repo.GetForUser(ctx, userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to load recurring rule: %w", err)
+	}
+	if rule == nil {
+		return nil, ErrRecurringRuleNotFound
+	}
+	return rule, nil
+}
+
+func (s *recurringService) CreateForUser(ctx context.Context, userID string, rule *models.RecurringRule) (*models.RecurringRule, error) {
+	rule.ID = uuid.New().String()
+	rule.UserID = normalizedServiceUserID(userID)
+	rule.MaterializedThrough = nil
+	rule.CreatedAt = s.clock()
+	rule.UpdatedAt = rule.CreatedAt
+	if err := s.validateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, rule); err != nil {
+		return nil, fmt.Errorf("service: failed to create recurring rule: %w", err)
+	}
+	return rule, nil
+}
+
+func (s *recurringService) UpdateForUser(ctx context.Context, userID string, rule *models.RecurringRule) (*models.RecurringRule, error) {
+	existing, err := s.GetForUser(ctx, userID, rule.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.UserID = existing.UserID
+	rule.CreatedAt = existing.CreatedAt
+	rule.MaterializedThrough = existing.MaterializedThrough
+	rule.UpdatedAt = s.clock()
+	if err := s.validateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, rule); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRecurringRuleNotFound
+		}
+		return nil, fmt.Errorf("service: failed to update recurring rule: %w", err)
+	}
+	return rule, nil
+}
+
+func (s *recurringService) DeleteForUser(ctx context.Context, userID, id string) error {
+	if err := s.repo.DeleteForUser(ctx, userID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRecurringRuleNotFound
+		}
+		return fmt.Errorf("service: failed to delete recurring rule: %w", err)
+	}
+	return nil
+}
+
+func (s *recurringService) UpcomingForUser(ctx context.Context, userID string, until time.Time, limit int) ([]models.RecurringOccurrence, error) {
+	if until.IsZero() {
+		until = s.clock().Add(defaultUpcomingWindow)
+	}
+	if limit <= 0 {
+		limit = defaultUpcomingLimit
+	}
+	if limit > maxUpcomingLimit {
+		limit = maxUpcomingLimit
+	}
+
+	rules, err := s.repo.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list recurring rules: %w", err)
+	}
+
+	occurrences := []models.RecurringOccurrence{}
+	for _, rule := range rules {
+		statuses, err := s.repo.OccurrenceStatuses(ctx, rule.ID)
+		if err != nil {
+			return nil, fmt.Errorf("service: failed to load occurrences: %w", err)
+		}
+
+		emitted := 0
+		forEachOccurrence(rule, until, func(date time.Time) bool {
+			if rule.MaterializedThrough != nil && !date.After(*rule.MaterializedThrough) {
+				return true
+			}
+			occurrence := newOccurrence(rule, date)
+			if recorded, ok := statuses[date.UnixNano()]; ok {
+				occurrence.Status = recorded.Status
+				occurrence.TransactionID = recorded.TransactionID
+			}
+			occurrences = append(occurrences, occurrence)
+			emitted++
+			return emitted < limit
+		})
+	}
+
+	sort.SliceStable(occurrences, func(i, j int) bool {
+		if !occurrences[i].Date.Equal(occurrences[j].Date) {
+			return occurrences[i].Date.Before(occurrences[j].Date)
+		}
+		return occurrences[i].RuleID < occurrences[j].RuleID
+	})
+	if len(occurrences) > limit {
+		occurrences = occurrences[:limit]
+	}
+	return occurrences, nil
+}
+
+func (s *recurringService) SkipForUser(ctx context.Context, userID, ruleID string, day time.Time) (*models.RecurringOccurrence, error) {
+	rule, err := s.GetForUser(ctx, userID, ruleID)
+	if err != nil {
+		return nil, err
+	}
+
+	dayStart := time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, time.UTC)
+	dayEnd := dayStart.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	var match time.Time
+	forEachOccurrence(*rule, dayEnd, func(date time.Time) bool {
+		if !date.Before(dayStart) {
+			match = date
+			return false
+		}
+		return true
+	})
+	if match.IsZero() {
+		return nil, ErrRecurringOccurrenceNotFound
+	}
+
+	if err := s.repo.Skip(ctx, rule, match); err != nil {
+		if errors.Is(err, repository.ErrOccurrenceMaterialized) {
+			return nil, ErrRecurringOccurrenceRecorded
+		}
+		return nil, fmt.Errorf("service: failed to skip occurrence: %w", err)
+	}
+
+	occurrence := newOccurrence(*rule, match)
+	occurrence.Status = models.OccurrenceSkipped
+	return &occurrence, nil
+}
+
+func (s *recurringService) MaterializeDueForUser(ctx context.Context, userID string) (int, error) {
+	rules, err := s.repo.ListForUser(ctx, userID)
+	if err != nil {
+		return 0, fmt.Errorf("service: failed to list recurring rules: %w", err)
+	}
+	return s.materializeRules(ctx, rules)
+}
+
+func (s *recurringService) MaterializeDue(ctx context.Context) (int, error) {
+	rules, err := s.repo.ListAll(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("service: failed to list recurring rules: %w", err)
+	}
+
+	var userIDs []string
+	byUser := make(map[string][]models.RecurringRule)
+	for _, rule := range rules {
+		if _, ok := byUser[rule.UserID]; !ok {
+			userIDs = append(userIDs, rule.UserID)
+		}
+		byUser[rule.UserID] = append(byUser[rule.UserID], rule)
+	}
+
+	created := 0
+	for _, userID := range userIDs {
+		err := attribute(ctx, s.audit, userID, models.AuditActor{Source: models.AuditSourceRecurring}, func(ctx context.Context) error {
+			n, err := s.materializeRules(ctx, byUser[userID])
+			created += n
+			return err
+		})
+		if err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+func (s *recurringService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if created, err := s.MaterializeDue(ctx); err != nil {
+			log.Printf("recurring: materialize failed: %v", err)
+		} else if created > 0 {
+			log.Printf("recurring: recorded %d transactions", created)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *recurringService) materializeRules(ctx context.Context, rules []models.RecurringRule) (int, error) {
+	now := s.clock()
+	created := 0
+	for i := range rules {
+		n, err := s.materializeRule(ctx, &rules[i], now)
+		created += n
+		if err != nil {
+			return created, err
+		}
+	}
+	return created, nil
+}
+
+// materializeRule records the occurrences of one rule that are due at now.
+// Every occurrence is keyed by (rule, date) in the database, so running this
+// twice, or from two processes, never records the same occurrence twice.
+func (s *recurringService) materializeRule(ctx context.Context, rule *models.RecurringRule, now time.Time) (int, error) {
+	statuses, err := s.repo.OccurrenceStatuses(ctx, rule.ID)
+	if err != nil {
+		return 0, fmt.Errorf("service: failed to load occurrences: %w", err)
+	}
+
+	var (
+		created, handled int
+		through          time.Time
+		runErr           error
+	)
+	forEachOccurrence(*rule, now, func(date time.Time) bool {
+		if rule.MaterializedThrough != nil && !date.After(*rule.MaterializedThrough) {
+			return true
+		}
+		if recorded, ok := statuses[date.UnixNano()]; !ok || recorded.Status != models.OccurrenceSkipped {
+			tx := &models.Transaction{
+				ID:          uuid.New().String(),
+				UserID:      rule.UserID,
+				Amount:      rule.Amount,
+				Description: rule.Description,
+				Date:        date,
+				Type:        rule.Type,
+				WalletID:    rule.WalletID,
+				JarID:       rule.JarID,
+			}
+			ok, err := s.repo.Materialize(ctx, rule, date, tx)
+			if err != nil {
+				runErr = fmt.Errorf("service: failed to record occurrence of rule %s: %w", rule.ID, err)
+				return false
+			}
+			if ok {
+				created++
+			}
+		}
+		through = date
+		handled++
+		return handled < maxMaterializePerRule
+	})
+	if runErr != nil {
+		return created, runErr
+	}
+
+	// Skipped occurrences at the end of the run still move the mark forward.
+	if !through.IsZero() {
+		if err := s.repo.AdvanceMaterializedThrough(ctx, rule.ID, through); err != nil {
+			return created, fmt.Errorf("service: failed to advance recurring rule: %w", err)
+		}
+		rule.MaterializedThrough = &through
+	}
+	return created, nil
+}
+
+func (s *recurringService) validateRule(ctx context.Context, rule *models.RecurringRule) error {
+	if rule.Type != "income" && rule.Type != "expense" {
+		return fmt.Errorf("%w: type must be income or expense", ErrInvalidRecurringRule)
+	}
+	if rule.Amount <= 0 {
+		return fmt.Errorf("%w: amount must be greater than zero", ErrInvalidRecurringRule)
+	}
+	switch rule.Frequency {
+	case models.RecurringDaily, models.RecurringWeekly:
+		if rule.DayOfMonth != 0 {
+			return fmt.Errorf("%w: day_of_month only applies to monthly rules", ErrInvalidRecurringRule)
+		}
+	case models.RecurringMonthly:
+		if rule.DayOfMonth < 0 || rule.DayOfMonth > 31 {
+			return fmt.Errorf("%w: day_of_month must be between 1 and 31", ErrInvalidRecurringRule)
+		}
+	default:
+		return fmt.Errorf("%w: frequency must be daily, weekly or monthly", ErrInvalidRecurringRule)
+	}
+	if rule.Interval == 0 {
+		rule.Interval = 1
+	}
+	if rule.Interval < 0 {
+		return fmt.Errorf("%w: interval must be at least 1", ErrInvalidRecurringRule)
+	}
+	if rule.StartDate.IsZero() {
+		return fmt.Errorf("%w: start_date is required", ErrInvalidRecurringRule)
+	}
+	if rule.EndDate != nil && rule.EndDate.Before(rule.StartDate) {
+		return fmt.Errorf("%w: end_date must not be before start_date", ErrInvalidRecurringRule)
+	}
+	if rule.Count != nil && *rule.Count <= 0 {
+		return fmt.Errorf("%w: count must be greater than zero", ErrInvalidRecurringRule)
+	}
+	if rule.WalletID == "" {
+		return fmt.Errorf("%w: wallet_id is required", ErrInvalidRecurringRule)
+	}
+
+	return checkTransactionTarget(ctx, s.walletRepo, s.jarRepo, rule.UserID, rule.WalletID, rule.JarID, rule.Type, ErrInvalidRecurringRule)
+}
+
+func newOccurrence(rule models.RecurringRule, date time.Time) models.RecurringOccurrence {
+	return models.RecurringOccurrence{
+		RuleID:      rule.ID,
+		Date:        date,
+		Type:        rule.Type,
+		Amount:      rule.Amount,
+		Description: rule.Description,
+		WalletID:    rule.WalletID,
+		JarID:       rule.JarID,
+		Status:      models.OccurrenceScheduled,
+	}
+}
+
+// forEachOccurrence calls fn with every occurrence of the rule, in order, up
+// to and including until. It stops early when fn returns false.
+func forEachOccurrence(rule models.RecurringRule, until time.Time, fn func(date time.Time) bool) {
+	start := rule.StartDate.UTC()
+	interval := rule.Interval
+	if interval < 1 {
+		interval = 1
+	}
+
+	emitted := 0
+	for step := 0; ; step++ {
+		date := occurrenceAt(rule, start, step*interval)
+		if date.After(until) || (rule.EndDate != nil && date.After(*rule.EndDate)) {
+			return
+		}
+		if rule.Count != nil && emitted >= *rule.Count {
+			return
+		}
+		// A monthly day before the start date's day is not due until the
+		// following month.
+		if date.Before(start) {
+			continue
+		}
+		emitted++
+		if !fn(date) {
+			return
+		}
+	}
+}
+
+// occurrenceAt returns the occurrence that lies offset days, weeks or months
+// after start. Monthly rules land on DayOfMonth (or the start date's day),
+// clamped to the last day of shorter months, at the start date's time of day.
+func occurrenceAt(rule models.RecurringRule, start time.Time, offset int) time.Time {
+	switch rule.Frequency {
+	case models.RecurringDaily:
+		return start.AddDate(0, 0, offset)
+	case models.RecurringWeekly:
+		return start.AddDate(0, 0, 7*offset)
+	default:
+		day := rule.DayOfMonth
+		if day == 0 {
+			day = start.Day()
+		}
+		first := time.Date(start.Year(), start.Month()+time.Month(offset), 1, start.Hour(), start.Minute(), start.Second(), start.Nanosecond(), time.UTC)
+		if last := first.AddDate(0, 1, -1).Day(); day > last {
+			day = last
+		}
+		return first.AddDate(0, 0, day-1)
+	}
+}
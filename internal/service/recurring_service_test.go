@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"testing"
+	"time"
+)
+
+func setupRecurringService(t *testing.T, now time.Time) (*recurringService, *sql.DB) {
+	t.Helper()
+
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	t.Cleanup(func() { dbConn.Close() })
+	if _, err := dbConn.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		t.Fatalf("failed to enable foreign keys: %v", err)
+	}
+
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	if err := walletRepo.Create(context.Background(), &models.Wallet{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	if _, err := dbConn.Exec("INSERT INTO jars (id, user_id, name, type) VALUES ('rent', 'user-1', 'Rent', 'expense')"); err != nil {
+		t.Fatalf("failed to create jar: %v", err)
+	}
+
+	svc := NewRecurringService(repository.NewSQLiteRecurringRepository(dbConn), walletRepo, repository.NewSQLiteJarRepository(dbConn), nil).(*recurringService)
+	svc.clock = func() time.Time { return now }
+	return svc, dbConn
+}
+
+func countRecurringTransactions(t *testing.T, dbConn *sql.DB) int {
+	t.Helper()
+	var count int
+	if err := dbConn.QueryRow("SELECT COUNT(*) FROM transactions WHERE user_id = 'user-1'").Scan(&count); err != nil {
+		t.Fatalf("failed to count transactions: %v", err)
+	}
+	return count
+}
+
+func TestRecurringService_MaterializeIsIdempotent(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	svc, dbConn := setupRecurringService(t, now)
+	ctx := context.Background()
+
+	rule, err := svc.CreateForUser(ctx, "user-1", &models.RecurringRule{
+		WalletID:   "wallet-1",
+		JarID:      "rent",
+		Type:       "expense",
+		Amount:     12000,
+		Frequency:  models.RecurringMonthly,
+		DayOfMonth: 1,
+		StartDate:  time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+
+	created, err := svc.MaterializeDue(ctx)
+	if err != nil {
+		t.Fatalf("MaterializeDue failed: %v", err)
+	}
+	if created != 3 {
+		t.Fatalf("expected 3 transactions for Jan-Mar, got %d", created)
+	}
+
+	created, err = svc.MaterializeDueForUser(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	if created != 0 || countRecurringTransactions(t, dbConn) != 3 {
+		t.Fatalf("second run must not record anything, created=%d", created)
+	}
+
+	var balance models.Money
+	if err := dbConn.QueryRow("SELECT balance FROM wallets WHERE id = 'wallet-1'").Scan(&balance); err != nil {
+		t.Fatalf("failed to read balance: %v", err)
+	}
+	if balance != -36000 {
+		t.Errorf("expected wallet balance -36000, got %s", balance)
+	}
+
+	stored, err := svc.GetForUser(ctx, "user-1", rule.ID)
+	if err != nil {
+		t.Fatalf("GetForUser failed: %v", err)
+	}
+	if stored.MaterializedThrough == nil || !stored.MaterializedThrough.Equal(time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)) {
+		t.Errorf("unexpected materialized_through: %v", stored.MaterializedThrough)
+	}
+}
+
+func TestRecurringService_SkipAndEditFutureOccurrences(t *testing.T) {
+	now := time.Date(2026, 3, 15, 12, 0, 0, 0, time.UTC)
+	svc, dbConn := setupRecurringService(t, now)
+	ctx := context.Background()
+
+	rule, err := svc.CreateForUser(ctx, "user-1", &models.RecurringRule{
+		WalletID:  "wallet-1",
+		Type:      "expense",
+		Amount:    300,
+		Frequency: models.RecurringWeekly,
+		StartDate: time.Date(2026, 3, 2, 8, 0, 0, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+
+	if _, err := svc.SkipForUser(ctx, "user-1", rule.ID, time.Date(2026, 3, 9, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatalf("SkipForUser failed: %v", err)
+	}
+	if _, err := svc.SkipForUser(ctx, "user-1", rule.ID, time.Date(2026, 3, 10, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrRecurringOccurrenceNotFound) {
+		t.Errorf("expected ErrRecurringOccurrenceNotFound, got %v", err)
+	}
+
+	// Mar 2 and Mar 16 are due once the clock passes them; Mar 9 was skipped.
+	if created, err := svc.MaterializeDue(ctx); err != nil || created != 1 {
+		t.Fatalf("expected only Mar 2, created=%d err=%v", created, err)
+	}
+	if _, err := svc.SkipForUser(ctx, "user-1", rule.ID, time.Date(2026, 3, 2, 0, 0, 0, 0, time.UTC)); !errors.Is(err, ErrRecurringOccurrenceRecorded) {
+		t.Errorf("expected ErrRecurringOccurrenceRecorded, got %v", err)
+	}
+
+	rule, err = svc.GetForUser(ctx, "user-1", rule.ID)
+	if err != nil {
+		t.Fatalf("GetForUser failed: %v", err)
+	}
+	rule.Amount = 450
+	if _, err := svc.UpdateForUser(ctx, "user-1", rule); err != nil {
+		t.Fatalf("UpdateForUser failed: %v", err)
+	}
+
+	upcoming, err := svc.UpcomingForUser(ctx, "user-1", time.Date(2026, 3, 31, 0, 0, 0, 0, time.UTC), 0)
+	if err != nil {
+		t.Fatalf("UpcomingForUser failed: %v", err)
+	}
+	if len(upcoming) != 3 {
+		t.Fatalf("expected Mar 16, 23 and 30, got %d occurrences", len(upcoming))
+	}
+	if !upcoming[0].Date.Equal(time.Date(2026, 3, 16, 8, 0, 0, 0, time.UTC)) || upcoming[0].Amount != 450 || upcoming[0].Status != models.OccurrenceScheduled {
+		t.Errorf("unexpected first upcoming occurrence: %+v", upcoming[0])
+	}
+
+	svc.clock = func() time.Time { return time.Date(2026, 3, 20, 0, 0, 0, 0, time.UTC) }
+	if _, err := svc.MaterializeDue(ctx); err != nil {
+		t.Fatalf("MaterializeDue failed: %v", err)
+	}
+	var amounts []models.Money
+	rows, err := dbConn.Query("SELECT amount FROM transactions ORDER BY date")
+	if err != nil {
+		t.Fatalf("failed to query transactions: %v", err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var amount models.Money
+		if err := rows.Scan(&amount); err != nil {
+			t.Fatalf("failed to scan amount: %v", err)
+		}
+		amounts = append(amounts, amount)
+	}
+	if len(amounts) != 2 || amounts[0] != 300 || amounts[1] != 450 {
+		t.Errorf("expected the edit to apply only to the future occurrence, got %v", amounts)
+	}
+}
+
+func TestForEachOccurrence_MonthlyClampAndLimits(t *testing.T) {
+	count := 4
+	rule := models.RecurringRule{
+		Frequency:  models.RecurringMonthly,
+		Interval:   1,
+		DayOfMonth: 31,
+		StartDate:  time.Date(2026, 1, 31, 0, 0, 0, 0, time.UTC),
+		Count:      &count,
+	}
+
+	var days []string
+	forEachOccurrence(rule, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), func(date time.Time) bool {
+		days = append(days, date.Format("2006-01-02"))
+		return true
+	})
+	want := []string{"2026-01-31", "2026-02-28", "2026-03-31", "2026-04-30"}
+	if len(days) != len(want) {
+		t.Fatalf("expected %v, got %v", want, days)
+	}
+	for i := range want {
+		if days[i] != want[i] {
+			t.Errorf("occurrence %d: expected %s, got %s", i, want[i], days[i])
+		}
+	}
+
+	// A day before the start date's day starts in the following month, and
+	// the end date is inclusive.
+	endDate := time.Date(2026, 4, 5, 0, 0, 0, 0, time.UTC)
+	rule = models.RecurringRule{
+		Frequency:  models.RecurringMonthly,
+		Interval:   1,
+		DayOfMonth: 5,
+		StartDate:  time.Date(2026, 1, 20, 0, 0, 0, 0, time.UTC),
+		EndDate:    &endDate,
+	}
+	days = nil
+	forEachOccurrence(rule, time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC), func(date time.Time) bool {
+		days = append(days, date.Format("2006-01-02"))
+		return true
+	})
+	want = []string{"2026-02-05", "2026-03-05", "2026-04-05"}
+	if len(days) != len(want) || days[0] != want[0] || days[2] != want[2] {
+		t.Errorf("expected %v, got %v", want, days)
+	}
+}
@@ -13,6 +13,13 @@ import (
 type reportTransactionRepository interface {
 	ListByDateRange(start, end time.Time) ([]models.Transaction, error)
 	ListByDateRangeForUser(userID string, start, end time.Time) ([]models.Transaction, error)
+	refundLister
+}
+
+// refundLister finds the refunds that reports net against their expenses.
+type refundLister interface {
+	ListRefundsByExpenseDate(start, end time.Time) ([]models.Transaction, error)
+	ListRefundsByExpenseDateForUser(userID string, start, end time.Time) ([]models.Transaction, error)
 }
 
 type jarRepository interface {
@@ -36,10 +43,11 @@ type reportService struct {
 	repo       reportTransactionRepository
 	jarRepo    jarRepository
 	walletRepo walletRepository
+	rates      exchangeRateSource
 }
 
-func NewReportService(repo reportTransactionRepository, jarRepo jarRepository, walletRepo walletRepository) ReportService {
-	return &reportService{repo: repo, jarRepo: jarRepo, walletRepo: walletRepo}
+func NewReportService(repo reportTransactionRepository, jarRepo jarRepository, walletRepo walletRepository, rates exchangeRateSource) ReportService {
+	return &reportService{repo: repo, jarRepo: jarRepo, walletRepo: walletRepo, rates: rates}
 }
 
 func (s *reportService) GenerateReport(ctx context.Context, filter models.ReportFilter) (*models.Report, error) {
@@ -77,11 +85,21 @@ func (s *reportService) GenerateReportForUser(ctx context.Context, userID string
 		return nil, err
 	}
 
-	// 3. Apply filters (Jar/Wallet)
+	// 3. Apply filters (Jar/Wallet). Figures net refunds against the
+	// expenses they refund; the transaction list keeps them as they are.
 	filtered := applyReportFilters(transactions, filter)
+	netted, err := nettedTransactions(s.repo, userID, transactions, filter.StartDate, filter.EndDate)
+	if err != nil {
+		return nil, err
+	}
+	nettedFiltered := applyReportFilters(netted, filter)
 
-	// 4. Aggregate Current Period
-	report := s.aggregate(filtered, filter, jarNameMap)
+	// 4. Aggregate Current Period in the user's base currency
+	converter, err := loadCurrencyConverter(ctx, s.rates, userID)
+	if err != nil {
+		return nil, err
+	}
+	report := s.aggregate(nettedFiltered, filter, jarNameMap, converter)
 
 	// 5. Calculate Comparison and Category comparisons
 	duration := filter.EndDate.Sub(filter.StartDate)
@@ -95,19 +113,23 @@ func (s *reportService) GenerateReportForUser(ctx context.Context, userID string
 	} else {
 		prevTransactions, err = s.repo.ListByDateRange(prevStart, prevEnd)
 	}
+	if err == nil {
+		prevTransactions, err = nettedTransactions(s.repo, userID, prevTransactions, prevStart, prevEnd)
+	}
 	if err == nil {
 		prevFiltered := applyReportFilters(prevTransactions, models.ReportFilter{
 			StartDate: prevStart,
 			EndDate:   prevEnd,
 			JarIDs:    filter.JarIDs,
 			WalletIDs: filter.WalletIDs,
+			TagIDs:    filter.TagIDs,
 		})
-		prevReport := s.aggregate(prevFiltered, filter, jarNameMap)
+		prevReport := s.aggregate(prevFiltered, filter, jarNameMap, converter)
 
 		// Merge Previous stats into Categories
 		type prevAmount struct {
-			Income  float64
-			Expense float64
+			Income  models.Money
+			Expense models.Money
 		}
 		catPrevMap := make(map[string]prevAmount)
 		for _, cat := range prevReport.ByCategory {
@@ -137,7 +159,11 @@ func (s *reportService) GenerateReportForUser(ctx context.Context, userID string
 		report.Comparison = &models.ComparisonData{Current: report.Summary}
 	}
 
-	// 6. Populate legacy fields
+	// 6. Populate currency and legacy fields
+	report.BaseCurrency = converter.base
+	report.ByCurrency = converter.currencyTotals(nettedFiltered)
+	report.ByWallet = s.walletCashFlows(userID, filtered)
+	report.MissingRates = converter.missingRates()
 	report.FilterUsed = filter
 	report.Transactions = filtered
 	report.TransactionCount = len(filtered)
@@ -196,7 +222,7 @@ func (s *reportService) ExportTransactionsToCSVForUser(ctx context.Context, user
 	writer := csv.NewWriter(&buf)
 
 	// Header
-	if err := writer.Write([]string{"Date", "Description", "Amount", "Type", "Wallet", "Jar"}); err != nil {
+	if err := writer.Write([]string{"Date", "Description", "Amount", "Type", "Wallet", "Jar", "Currency"}); err != nil {
 		return nil, fmt.Errorf("failed to write CSV header: %w", err)
 	}
 
@@ -214,10 +240,11 @@ func (s *reportService) ExportTransactionsToCSVForUser(ctx context.Context, user
 		row := []string{
 			tx.Date.Format("2006-01-02"),
 			tx.Description,
-			fmt.Sprintf("%.2f", tx.Amount),
+			tx.Amount.String(),
 			tx.Type,
 			walletName,
 			jarName,
+			tx.Currency,
 		}
 		if err := writer.Write(row); err != nil {
 			return nil, fmt.Errorf("failed to write CSV row: %w", err)
@@ -228,11 +255,14 @@ func (s *reportService) ExportTransactionsToCSVForUser(ctx context.Context, user
 	return buf.Bytes(), writer.Error()
 }
 
-func (s *reportService) aggregate(transactions []models.Transaction, filter models.ReportFilter, jarNames map[string]string) *models.Report {
+// aggregate sums the transactions in the converter's base currency. Those
+// without a usable rate are left out here and reported as missing rates.
+func (s *reportService) aggregate(transactions []models.Transaction, filter models.ReportFilter, jarNames map[string]string, converter *currencyConverter) *models.Report {
 	var summary models.ChartSummary
 	trendMap := make(map[string]*models.TrendPoint)
 	categoryMap := make(map[string]*models.CategoryAmount)
 	jarMap := make(map[string]*models.JarAmount)
+	tagMap := make(map[string]*models.TagAmount)
 
 	// Determine bucket format (Daily vs Monthly)
 	bucketFormat := "2006-01"
@@ -241,12 +271,17 @@ func (s *reportService) aggregate(transactions []models.Transaction, filter mode
 	}
 
 	for _, tx := range transactions {
+		amount, ok := converter.convertTransaction(tx)
+		if !ok {
+			continue
+		}
+
 		// Update Summary
 		switch tx.Type {
 		case "income":
-			summary.Income += tx.Amount
+			summary.Income += amount
 		case "expense":
-			summary.Expense += tx.Amount
+			summary.Expense += amount
 		}
 		summary.Net = summary.Income - summary.Expense
 
@@ -256,42 +291,47 @@ func (s *reportService) aggregate(transactions []models.Transaction, filter mode
 			trendMap[dateKey] = &models.TrendPoint{Date: dateKey}
 		}
 		if tx.Type == "income" {
-			trendMap[dateKey].Income += tx.Amount
+			trendMap[dateKey].Income += amount
 		} else if tx.Type == "expense" {
-			trendMap[dateKey].Expense += tx.Amount
+			trendMap[dateKey].Expense += amount
 		}
 
-		// Update Category Breakdown (Both Income & Expense)
-		if tx.JarID != "" {
-			if _, ok := categoryMap[tx.JarID]; !ok {
-				name := tx.JarID
-				if n, exists := jarNames[tx.JarID]; exists {
-					name = n
-				}
-				categoryMap[tx.JarID] = &models.CategoryAmount{ID: tx.JarID, Name: name}
+		// Update Category and Jar Breakdowns (Both Income & Expense). A split
+		// transaction counts towards each of its jars.
+		for _, portion := range tx.JarAmounts(amount) {
+			if portion.JarID == "" {
+				continue
+			}
+			name := portion.JarID
+			if n, exists := jarNames[portion.JarID]; exists {
+				name = n
+			}
+			if _, ok := categoryMap[portion.JarID]; !ok {
+				categoryMap[portion.JarID] = &models.CategoryAmount{ID: portion.JarID, Name: name}
+			}
+			if _, ok := jarMap[portion.JarID]; !ok {
+				jarMap[portion.JarID] = &models.JarAmount{ID: portion.JarID, Name: name}
 			}
 			if tx.Type == "income" {
-				categoryMap[tx.JarID].Income += tx.Amount
+				categoryMap[portion.JarID].Income += portion.Amount
+				jarMap[portion.JarID].Income += portion.Amount
 			} else if tx.Type == "expense" {
-				categoryMap[tx.JarID].Expense += tx.Amount
-				categoryMap[tx.JarID].Amount += tx.Amount
+				categoryMap[portion.JarID].Expense += portion.Amount
+				categoryMap[portion.JarID].Amount += portion.Amount
+				jarMap[portion.JarID].Expense += portion.Amount
+				jarMap[portion.JarID].Amount += portion.Amount
 			}
 		}
 
-		// Update Jar Breakdown
-		if tx.JarID != "" {
-			if _, ok := jarMap[tx.JarID]; !ok {
-				name := tx.JarID
-				if n, exists := jarNames[tx.JarID]; exists {
-					name = n
-				}
-				jarMap[tx.JarID] = &models.JarAmount{ID: tx.JarID, Name: name}
+		for _, tag := range tx.Tags {
+			if _, ok := tagMap[tag.ID]; !ok {
+				tagMap[tag.ID] = &models.TagAmount{ID: tag.ID, Name: tag.Name}
 			}
 			if tx.Type == "income" {
-				jarMap[tx.JarID].Income += tx.Amount
+				tagMap[tag.ID].Income += amount
 			} else if tx.Type == "expense" {
-				jarMap[tx.JarID].Expense += tx.Amount
-				jarMap[tx.JarID].Amount += tx.Amount
+				tagMap[tag.ID].Expense += amount
+				tagMap[tag.ID].Amount += amount
 			}
 		}
 	}
@@ -302,7 +342,59 @@ func (s *reportService) aggregate(transactions []models.Transaction, filter mode
 		Trend:      sortTrend(trendMap),
 		ByCategory: sortCategories(categoryMap),
 		ByJar:      sortJars(jarMap),
+		ByTag:      sortTags(tagMap),
+	}
+}
+
+// walletCashFlows totals each wallet's income, expense and transfers in the
+// wallet's own currency.
+func (s *reportService) walletCashFlows(userID string, transactions []models.Transaction) []models.WalletCashFlow {
+	var wallets []models.Wallet
+	if userID != "" {
+		wallets, _ = s.walletRepo.ListAllForUser(userID)
+	} else {
+		wallets, _ = s.walletRepo.ListAll()
+	}
+	walletNames := make(map[string]string)
+	for _, w := range wallets {
+		walletNames[w.ID] = w.Name
 	}
+
+	flowMap := make(map[string]*models.WalletCashFlow)
+	for _, tx := range transactions {
+		flow, ok := flowMap[tx.WalletID]
+		if !ok {
+			name := tx.WalletID
+			if n, exists := walletNames[tx.WalletID]; exists {
+				name = n
+			}
+			flow = &models.WalletCashFlow{ID: tx.WalletID, Name: name, Currency: tx.Currency}
+			flowMap[tx.WalletID] = flow
+		}
+		switch {
+		case tx.Type == "income":
+			flow.Income += tx.Amount.Abs()
+		case tx.Type == "expense":
+			flow.Expense += tx.Amount.Abs()
+		case tx.Amount >= 0:
+			flow.TransfersIn += tx.Amount
+		default:
+			flow.TransfersOut -= tx.Amount
+		}
+		flow.Net = flow.Income + flow.TransfersIn - flow.Expense - flow.TransfersOut
+	}
+
+	flows := make([]models.WalletCashFlow, 0, len(flowMap))
+	for _, flow := range flowMap {
+		flows = append(flows, *flow)
+	}
+	sort.Slice(flows, func(i, j int) bool {
+		if flows[i].Name != flows[j].Name {
+			return flows[i].Name < flows[j].Name
+		}
+		return flows[i].ID < flows[j].ID
+	})
+	return flows
 }
 
 // Helpers for sorting
@@ -338,8 +430,26 @@ func sortJars(m map[string]*models.JarAmount) []models.JarAmount {
 	return res
 }
 
+func sortTags(m map[string]*models.TagAmount) []models.TagAmount {
+	res := make([]models.TagAmount, 0, len(m))
+	for _, v := range m {
+		res = append(res, *v)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Amount != res[j].Amount {
+			return res[i].Amount > res[j].Amount
+		}
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// applyReportFilters keeps the transactions in the selected jars and wallets
+// that carry any of the selected tags. A split transaction matches when any
+// of its lines is in a selected jar; only those lines are kept and its amount
+// is reduced to their total.
 func applyReportFilters(transactions []models.Transaction, filter models.ReportFilter) []models.Transaction {
-	if len(filter.JarIDs) == 0 && len(filter.WalletIDs) == 0 {
+	if len(filter.JarIDs) == 0 && len(filter.WalletIDs) == 0 && len(filter.TagIDs) == 0 {
 		return transactions
 	}
 
@@ -353,11 +463,49 @@ func applyReportFilters(transactions []models.Transaction, filter models.ReportF
 		walletSet[id] = struct{}{}
 	}
 
+	tagSet := make(map[string]struct{}, len(filter.TagIDs))
+	for _, id := range filter.TagIDs {
+		tagSet[id] = struct{}{}
+	}
+
 	var results []models.Transaction
 	for _, tx := range transactions {
-		jarMatch := len(jarSet) == 0 || (tx.JarID != "" && containsKey(jarSet, tx.JarID))
 		walletMatch := len(walletSet) == 0 || containsKey(walletSet, tx.WalletID)
-		if jarMatch && walletMatch {
+		if !walletMatch {
+			continue
+		}
+		if len(tagSet) > 0 && !hasAnyTag(tx, tagSet) {
+			continue
+		}
+		if len(jarSet) == 0 {
+			results = append(results, tx)
+			continue
+		}
+		if len(tx.Splits) == 0 {
+			if tx.JarID != "" && containsKey(jarSet, tx.JarID) {
+				results = append(results, tx)
+			}
+			continue
+		}
+
+		var (
+			splits []models.TransactionSplit
+			amount models.Money
+		)
+		for _, split := range tx.Splits {
+			if containsKey(jarSet, split.JarID) {
+				splits = append(splits, split)
+				amount += split.Amount
+			}
+		}
+		if len(splits) > 0 {
+			var total models.Money
+			for _, split := range tx.Splits {
+				total += split.Amount
+			}
+			// Scale rather than assign so a netted refund stays negative.
+			tx.Amount = tx.Amount * amount / total
+			tx.Splits = splits
 			results = append(results, tx)
 		}
 	}
@@ -365,6 +513,65 @@ func applyReportFilters(transactions []models.Transaction, filter models.ReportF
 	return results
 }
 
+// nettedTransactions loads the refunds of the expenses among transactions,
+// which are dated between start and end, and nets them (see netRefunds).
+func nettedTransactions(repo refundLister, userID string, transactions []models.Transaction, start, end time.Time) ([]models.Transaction, error) {
+	var (
+		refunds []models.Transaction
+		err     error
+	)
+	if userID != "" {
+		refunds, err = repo.ListRefundsByExpenseDateForUser(userID, start, end)
+	} else {
+		refunds, err = repo.ListRefundsByExpenseDate(start, end)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return netRefunds(transactions, refunds), nil
+}
+
+// netRefunds turns each refund of an expense in transactions into a negative
+// expense with the original's jars, wallet, currency and date, so it reduces
+// the spending of that jar and month instead of counting as income. Refunds
+// of expenses outside transactions are dropped; they belong to the period of
+// their expense.
+func netRefunds(transactions, refunds []models.Transaction) []models.Transaction {
+	expenses := make(map[string]models.Transaction)
+	netted := make([]models.Transaction, 0, len(transactions)+len(refunds))
+	for _, tx := range transactions {
+		if tx.IsRefund() {
+			continue
+		}
+		if tx.Type == "expense" {
+			expenses[tx.ID] = tx
+		}
+		netted = append(netted, tx)
+	}
+
+	for _, refund := range refunds {
+		expense, ok := expenses[*refund.RelatedTransactionID]
+		if !ok {
+			continue
+		}
+		expense.ID = refund.ID
+		expense.Description = refund.Description
+		expense.Amount = -refund.Amount.Abs()
+		expense.RelatedTransactionID = refund.RelatedTransactionID
+		netted = append(netted, expense)
+	}
+	return netted
+}
+
+func hasAnyTag(tx models.Transaction, tagSet map[string]struct{}) bool {
+	for _, tag := range tx.Tags {
+		if containsKey(tagSet, tag.ID) {
+			return true
+		}
+	}
+	return false
+}
+
 func containsKey(set map[string]struct{}, key string) bool {
 	_, ok := set[key]
 	return ok
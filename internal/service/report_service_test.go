@@ -26,6 +26,37 @@ func (f *fakeReportRepo) ListByDateRangeForUser(_ string, start, end time.Time)
 	return f.ListByDateRange(start, end)
 }
 
+func (f *fakeReportRepo) ListRefundsByExpenseDate(start, end time.Time) ([]models.Transaction, error) {
+	return refundsByExpenseDate(f.transactions, start, end), nil
+}
+
+func (f *fakeReportRepo) ListRefundsByExpenseDateForUser(_ string, start, end time.Time) ([]models.Transaction, error) {
+	return f.ListRefundsByExpenseDate(start, end)
+}
+
+// refundsByExpenseDate mirrors the repository query: refunds whose expense
+// falls between start and end, wherever the refund itself is dated.
+func refundsByExpenseDate(transactions []models.Transaction, start, end time.Time) []models.Transaction {
+	expenseDates := make(map[string]time.Time)
+	for _, tx := range transactions {
+		if tx.Type == "expense" {
+			expenseDates[tx.ID] = tx.Date
+		}
+	}
+
+	var results []models.Transaction
+	for _, tx := range transactions {
+		if !tx.IsRefund() {
+			continue
+		}
+		date, ok := expenseDates[*tx.RelatedTransactionID]
+		if ok && !date.Before(start) && !date.After(end) {
+			results = append(results, tx)
+		}
+	}
+	return results
+}
+
 type fakeJarRepo struct {
 	jars []models.Jar
 }
@@ -50,8 +81,21 @@ func (f *fakeWalletRepo) ListAllForUser(_ string) ([]models.Wallet, error) {
 	return f.ListAll()
 }
 
+type fakeRateSource struct {
+	base  string
+	rates []models.ExchangeRate
+}
+
+func (f *fakeRateSource) GetBaseCurrency(_ context.Context, _ string) (string, error) {
+	return f.base, nil
+}
+
+func (f *fakeRateSource) ListForUser(_ context.Context, _ string) ([]models.ExchangeRate, error) {
+	return f.rates, nil
+}
+
 func TestGenerateReport_NoFilters(t *testing.T) {
-	service := NewReportService(&fakeReportRepo{transactions: seedReportTransactions()}, &fakeJarRepo{}, &fakeWalletRepo{})
+	service := NewReportService(&fakeReportRepo{transactions: seedReportTransactions()}, &fakeJarRepo{}, &fakeWalletRepo{}, &fakeRateSource{})
 	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
 	end := time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
 
@@ -69,7 +113,7 @@ func TestGenerateReport_NoFilters(t *testing.T) {
 }
 
 func TestGenerateReport_FilterByJar(t *testing.T) {
-	service := NewReportService(&fakeReportRepo{transactions: seedReportTransactions()}, &fakeJarRepo{}, &fakeWalletRepo{})
+	service := NewReportService(&fakeReportRepo{transactions: seedReportTransactions()}, &fakeJarRepo{}, &fakeWalletRepo{}, &fakeRateSource{})
 	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
 	end := time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
 
@@ -93,7 +137,7 @@ func TestGenerateReport_FilterByJar(t *testing.T) {
 }
 
 func TestGenerateReport_FilterByWallet(t *testing.T) {
-	service := NewReportService(&fakeReportRepo{transactions: seedReportTransactions()}, &fakeJarRepo{}, &fakeWalletRepo{})
+	service := NewReportService(&fakeReportRepo{transactions: seedReportTransactions()}, &fakeJarRepo{}, &fakeWalletRepo{}, &fakeRateSource{})
 	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
 	end := time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
 
@@ -117,7 +161,7 @@ func TestGenerateReport_FilterByWallet(t *testing.T) {
 }
 
 func TestGenerateReport_FilterByJarAndWallet(t *testing.T) {
-	service := NewReportService(&fakeReportRepo{transactions: seedReportTransactions()}, &fakeJarRepo{}, &fakeWalletRepo{})
+	service := NewReportService(&fakeReportRepo{transactions: seedReportTransactions()}, &fakeJarRepo{}, &fakeWalletRepo{}, &fakeRateSource{})
 	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
 	end := time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
 
@@ -140,7 +184,7 @@ func TestGenerateReport_FilterByJarAndWallet(t *testing.T) {
 }
 
 func TestGenerateReport_NoResults(t *testing.T) {
-	service := NewReportService(&fakeReportRepo{transactions: seedReportTransactions()}, &fakeJarRepo{}, &fakeWalletRepo{})
+	service := NewReportService(&fakeReportRepo{transactions: seedReportTransactions()}, &fakeJarRepo{}, &fakeWalletRepo{}, &fakeRateSource{})
 	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
 	end := time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
 
@@ -162,7 +206,7 @@ func TestGenerateReport_NoResults(t *testing.T) {
 	}
 
 	if report.TotalAmount != 0 {
-		t.Fatalf("expected total amount 0, got %f", report.TotalAmount)
+		t.Fatalf("expected total amount 0, got %s", report.TotalAmount)
 	}
 
 	if len(report.Transactions) != 0 {
@@ -174,7 +218,7 @@ func TestGenerateReport_FilterByJarExcludesEmptyJarID(t *testing.T) {
 	transactions := []models.Transaction{
 		{
 			ID:       "tx-1",
-			Amount:   50.0,
+			Amount:   50,
 			Date:     time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC),
 			Type:     "expense",
 			JarID:    "",
@@ -182,7 +226,7 @@ func TestGenerateReport_FilterByJarExcludesEmptyJarID(t *testing.T) {
 		},
 		{
 			ID:       "tx-2",
-			Amount:   30.0,
+			Amount:   30,
 			Date:     time.Date(2026, 1, 12, 12, 0, 0, 0, time.UTC),
 			Type:     "expense",
 			JarID:    "jar-1",
@@ -190,7 +234,7 @@ func TestGenerateReport_FilterByJarExcludesEmptyJarID(t *testing.T) {
 		},
 	}
 
-	service := NewReportService(&fakeReportRepo{transactions: transactions}, &fakeJarRepo{}, &fakeWalletRepo{})
+	service := NewReportService(&fakeReportRepo{transactions: transactions}, &fakeJarRepo{}, &fakeWalletRepo{}, &fakeRateSource{})
 	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
 	end := time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC)
 
@@ -219,7 +263,7 @@ func TestGenerateReport_Aggregated(t *testing.T) {
 		{ID: "4", Amount: 200, Type: "income", JarID: "jar-2", Date: time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC)},
 	}
 
-	service := NewReportService(&fakeReportRepo{transactions: transactions}, &fakeJarRepo{}, &fakeWalletRepo{})
+	service := NewReportService(&fakeReportRepo{transactions: transactions}, &fakeJarRepo{}, &fakeWalletRepo{}, &fakeRateSource{})
 	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
 	end := time.Date(2026, 1, 2, 23, 59, 59, 0, time.UTC)
 
@@ -233,13 +277,13 @@ func TestGenerateReport_Aggregated(t *testing.T) {
 
 	// 1. Verify Summary
 	if report.Summary.Income != 300 {
-		t.Errorf("expected income 300, got %f", report.Summary.Income)
+		t.Errorf("expected income 300, got %s", report.Summary.Income)
 	}
 	if report.Summary.Expense != 80 {
-		t.Errorf("expected expense 80, got %f", report.Summary.Expense)
+		t.Errorf("expected expense 80, got %s", report.Summary.Expense)
 	}
 	if report.Summary.Net != 220 {
-		t.Errorf("expected net 220, got %f", report.Summary.Net)
+		t.Errorf("expected net 220, got %s", report.Summary.Net)
 	}
 
 	// 2. Verify Trend (Daily buckets for 2 days)
@@ -251,7 +295,7 @@ func TestGenerateReport_Aggregated(t *testing.T) {
 			t.Errorf("expected label 2026-01-01, got %s", report.Trend[0].Date)
 		}
 		if report.Trend[0].Income != 100 || report.Trend[0].Expense != 50 {
-			t.Errorf("day 1 trend mismatch: income=%f expense=%f", report.Trend[0].Income, report.Trend[0].Expense)
+			t.Errorf("day 1 trend mismatch: income=%s expense=%s", report.Trend[0].Income, report.Trend[0].Expense)
 		}
 	}
 
@@ -278,7 +322,7 @@ func TestGenerateReport_JarNamesAndComparison(t *testing.T) {
 		{ID: "t2", Amount: 80, Type: "expense", JarID: "jar-1", Date: now.AddDate(0, -1, 0)},
 	}
 
-	service := NewReportService(&fakeReportRepo{transactions: transactions}, &fakeJarRepo{jars: jars}, &fakeWalletRepo{})
+	service := NewReportService(&fakeReportRepo{transactions: transactions}, &fakeJarRepo{jars: jars}, &fakeWalletRepo{}, &fakeRateSource{})
 
 	start := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
 	end := time.Date(2026, 3, 31, 23, 59, 59, 0, time.UTC)
@@ -301,10 +345,10 @@ func TestGenerateReport_JarNamesAndComparison(t *testing.T) {
 			}
 			// Verify comparison data
 			if cat.Expense != 100 {
-				t.Errorf("expected current expense 100, got %f", cat.Expense)
+				t.Errorf("expected current expense 100, got %s", cat.Expense)
 			}
 			if cat.PrevExpense != 80 {
-				t.Errorf("expected prev expense 80, got %f", cat.PrevExpense)
+				t.Errorf("expected prev expense 80, got %s", cat.PrevExpense)
 			}
 		}
 	}
@@ -326,7 +370,7 @@ func TestGenerateReport_YearlyComparison(t *testing.T) {
 		{ID: "t2", Amount: 50, Type: "expense", JarID: "jar-1", Date: prev},
 	}
 
-	service := NewReportService(&fakeReportRepo{transactions: transactions}, &fakeJarRepo{}, &fakeWalletRepo{})
+	service := NewReportService(&fakeReportRepo{transactions: transactions}, &fakeJarRepo{}, &fakeWalletRepo{}, &fakeRateSource{})
 
 	// Filter for full year 2026
 	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
@@ -347,14 +391,14 @@ func TestGenerateReport_YearlyComparison(t *testing.T) {
 
 	// Current period (2026) should have 100 expense
 	if report.Comparison.Current.Expense != 100 {
-		t.Errorf("expected current expense 100, got %f", report.Comparison.Current.Expense)
+		t.Errorf("expected current expense 100, got %s", report.Comparison.Current.Expense)
 	}
 
 	// Previous period (2025) should have 50 expense
 	// SUCCESS: If logic is duration-based (365 days back from 2026-01-01 -> 2025-01-01)
 	// FAILURE: If logic is just AddDate(0, -1, 0) -> 2025-12-01 to 2025-12-31
 	if report.Comparison.Previous.Expense != 50 {
-		t.Errorf("expected previous year expense 50, got %f. This confirms the logic error in period calculation.", report.Comparison.Previous.Expense)
+		t.Errorf("expected previous year expense 50, got %s. This confirms the logic error in period calculation.", report.Comparison.Previous.Expense)
 	}
 }
 
@@ -362,7 +406,7 @@ func seedReportTransactions() []models.Transaction {
 	return []models.Transaction{
 		{
 			ID:          "tx-1",
-			Amount:      120.0,
+			Amount:      12000,
 			Date:        time.Date(2026, 1, 2, 12, 0, 0, 0, time.UTC),
 			Type:        "expense",
 			JarID:       "jar-1",
@@ -371,7 +415,7 @@ func seedReportTransactions() []models.Transaction {
 		},
 		{
 			ID:          "tx-2",
-			Amount:      80.0,
+			Amount:      8000,
 			Date:        time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC),
 			Type:        "expense",
 			JarID:       "jar-2",
@@ -380,7 +424,7 @@ func seedReportTransactions() []models.Transaction {
 		},
 		{
 			ID:          "tx-3",
-			Amount:      42.5,
+			Amount:      4250,
 			Date:        time.Date(2026, 1, 8, 12, 0, 0, 0, time.UTC),
 			Type:        "income",
 			JarID:       "jar-1",
@@ -389,7 +433,7 @@ func seedReportTransactions() []models.Transaction {
 		},
 		{
 			ID:          "tx-4",
-			Amount:      200.0,
+			Amount:      20000,
 			Date:        time.Date(2026, 1, 15, 12, 0, 0, 0, time.UTC),
 			Type:        "expense",
 			JarID:       "jar-3",
@@ -413,6 +457,7 @@ func TestExportTransactionsToCSV(t *testing.T) {
 		&fakeReportRepo{transactions: seedReportTransactions()},
 		&fakeJarRepo{jars: jars},
 		&fakeWalletRepo{wallets: wallets},
+		&fakeRateSource{},
 	)
 
 	start := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
@@ -443,3 +488,169 @@ func TestExportTransactionsToCSV(t *testing.T) {
 		t.Errorf("expected row '%s' not found", expectedRow)
 	}
 }
+
+func TestGenerateReport_TransfersOnlyInWalletCashFlow(t *testing.T) {
+	day := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	outID, inID := "leg-out", "leg-in"
+	transactions := []models.Transaction{
+		{ID: "salary", Amount: 100000, Type: "income", WalletID: "bank", Currency: "THB", Date: day},
+		{ID: outID, Amount: -30000, Type: "transfer", WalletID: "bank", Currency: "THB", Date: day, RelatedTransactionID: &inID},
+		{ID: inID, Amount: 30000, Type: "transfer", WalletID: "cash", Currency: "THB", Date: day, RelatedTransactionID: &outID},
+		{ID: "fee", Amount: 500, Type: "expense", JarID: "jar-fees", WalletID: "bank", Currency: "THB", Date: day, RelatedTransactionID: &outID},
+	}
+	wallets := &fakeWalletRepo{wallets: []models.Wallet{{ID: "bank", Name: "Bank"}, {ID: "cash", Name: "Cash"}}}
+
+	service := NewReportService(&fakeReportRepo{transactions: transactions}, &fakeJarRepo{}, wallets, &fakeRateSource{})
+	report, err := service.GenerateReport(context.Background(), models.ReportFilter{
+		StartDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.Summary.Income != 100000 || report.Summary.Expense != 500 {
+		t.Errorf("expected only the salary and the fee in the summary, got %+v", report.Summary)
+	}
+	want := []models.WalletCashFlow{
+		{ID: "bank", Name: "Bank", Currency: "THB", Income: 100000, Expense: 500, TransfersOut: 30000, Net: 69500},
+		{ID: "cash", Name: "Cash", Currency: "THB", TransfersIn: 30000, Net: 30000},
+	}
+	if len(report.ByWallet) != len(want) {
+		t.Fatalf("expected %d wallets, got %+v", len(want), report.ByWallet)
+	}
+	for i := range want {
+		if report.ByWallet[i] != want[i] {
+			t.Errorf("wallet %d: expected %+v, got %+v", i, want[i], report.ByWallet[i])
+		}
+	}
+}
+
+func TestReportAndChart_AttributeSplitsToJars(t *testing.T) {
+	day := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	transactions := []models.Transaction{
+		{ID: "receipt", Amount: 1000, Type: "expense", WalletID: "wallet-usd", Currency: "USD", Date: day, Splits: []models.TransactionSplit{
+			{JarID: "groceries", Amount: 700},
+			{JarID: "household", Amount: 300},
+		}},
+		{ID: "lunch", Amount: 5000, Type: "expense", JarID: "groceries", WalletID: "wallet-thb", Currency: "THB", Date: day},
+	}
+	rates := &fakeRateSource{base: "THB", rates: []models.ExchangeRate{
+		{FromCurrency: "USD", ToCurrency: "THB", Rate: 35, EffectiveDate: day.AddDate(0, 0, -1)},
+	}}
+	filter := models.ReportFilter{
+		StartDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
+	}
+
+	report, err := NewReportService(&fakeReportRepo{transactions: transactions}, &fakeJarRepo{}, &fakeWalletRepo{}, rates).GenerateReport(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	jarTotals := make(map[string]models.Money)
+	for _, jar := range report.ByJar {
+		jarTotals[jar.ID] = jar.Expense
+	}
+	// 10.00 USD at 35 is 350.00 THB, split 70/30.
+	if jarTotals["groceries"] != 29500 || jarTotals["household"] != 10500 {
+		t.Errorf("expected 295.00 groceries and 105.00 household, got %v", jarTotals)
+	}
+
+	chart, err := NewChartService(&fakeReportRepo{transactions: transactions}, rates).GetChartData(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chart.ByJar) != 2 || chart.ByJar[0].ID != "groceries" || chart.ByJar[0].Amount != 29500 {
+		t.Errorf("unexpected chart jars %+v", chart.ByJar)
+	}
+
+	// Filtering by a jar keeps only that jar's share of a split.
+	filter.JarIDs = []string{"household"}
+	report, err = NewReportService(&fakeReportRepo{transactions: transactions}, &fakeJarRepo{}, &fakeWalletRepo{}, rates).GenerateReport(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.TransactionCount != 1 || report.Summary.Expense != 10500 {
+		t.Errorf("expected only the 105.00 household share, got %d transactions and %s", report.TransactionCount, report.Summary.Expense)
+	}
+}
+
+func TestReportAndChart_NetRefundsAgainstExpense(t *testing.T) {
+	expenseID := "shoes"
+	transactions := []models.Transaction{
+		{ID: expenseID, Amount: 1000, Type: "expense", JarID: "play", WalletID: "wallet-thb", Currency: "THB", Date: time.Date(2026, 1, 20, 12, 0, 0, 0, time.UTC)},
+		{ID: "refund", Amount: 300, Type: "income", WalletID: "wallet-thb", Currency: "THB", RelatedTransactionID: &expenseID, Date: time.Date(2026, 2, 3, 12, 0, 0, 0, time.UTC)},
+	}
+	january := models.ReportFilter{
+		StartDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
+	}
+	february := models.ReportFilter{
+		StartDate: time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2026, 2, 28, 23, 59, 59, 0, time.UTC),
+	}
+	reports := NewReportService(&fakeReportRepo{transactions: transactions}, &fakeJarRepo{}, &fakeWalletRepo{}, &fakeRateSource{})
+
+	// The February refund reduces January's spending in the original jar.
+	report, err := reports.GenerateReport(context.Background(), january)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Summary.Expense != 700 || report.Summary.Income != 0 {
+		t.Errorf("expected 7.00 net expense and no income, got %+v", report.Summary)
+	}
+	if len(report.ByJar) != 1 || report.ByJar[0].ID != "play" || report.ByJar[0].Expense != 700 {
+		t.Errorf("expected 7.00 in the play jar, got %+v", report.ByJar)
+	}
+
+	// February does not count the refund as income.
+	report, err = reports.GenerateReport(context.Background(), february)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Summary.Income != 0 || report.TransactionCount != 1 {
+		t.Errorf("expected the refund listed but not counted as income, got %+v (%d transactions)", report.Summary, report.TransactionCount)
+	}
+
+	chart, err := NewChartService(&fakeReportRepo{transactions: transactions}, &fakeRateSource{}).GetChartData(context.Background(), january)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(chart.ByJar) != 1 || chart.ByJar[0].Amount != 700 {
+		t.Errorf("expected 7.00 in the chart's play jar, got %+v", chart.ByJar)
+	}
+}
+
+func TestGenerateReport_FilterAndBreakDownByTag(t *testing.T) {
+	day := time.Date(2026, 1, 5, 12, 0, 0, 0, time.UTC)
+	trip := models.Tag{ID: "trip", Name: "trip-japan-2026"}
+	business := models.Tag{ID: "business", Name: "business"}
+	transactions := []models.Transaction{
+		{ID: "hotel", Amount: 8000, Type: "expense", JarID: "travel", WalletID: "wallet-thb", Currency: "THB", Date: day, Tags: []models.Tag{trip, business}},
+		{ID: "ramen", Amount: 500, Type: "expense", JarID: "food", WalletID: "wallet-thb", Currency: "THB", Date: day, Tags: []models.Tag{trip}},
+		{ID: "groceries", Amount: 1500, Type: "expense", JarID: "food", WalletID: "wallet-thb", Currency: "THB", Date: day},
+	}
+	filter := models.ReportFilter{
+		StartDate: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+		EndDate:   time.Date(2026, 1, 31, 23, 59, 59, 0, time.UTC),
+	}
+	svc := NewReportService(&fakeReportRepo{transactions: transactions}, &fakeJarRepo{}, &fakeWalletRepo{}, &fakeRateSource{})
+
+	report, err := svc.GenerateReport(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(report.ByTag) != 2 || report.ByTag[0].ID != "trip" || report.ByTag[0].Expense != 8500 || report.ByTag[1].Expense != 8000 {
+		t.Errorf("expected 85.00 trip and 80.00 business, got %+v", report.ByTag)
+	}
+
+	filter.TagIDs = []string{"trip"}
+	filter.JarIDs = []string{"food"}
+	report, err = svc.GenerateReport(context.Background(), filter)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.TransactionCount != 1 || report.Summary.Expense != 500 {
+		t.Errorf("expected only the tagged food expense, got %d transactions and %s", report.TransactionCount, report.Summary.Expense)
+	}
+}
@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"strings"
+	"unicode"
+)
+
+const (
+	defaultSearchPageSize = 20
+	maxSearchPageSize     = 100
+	maxSearchTerms        = 10
+)
+
+var ErrInvalidSearch = errors.New("invalid search")
+
+type SearchService interface {
+	SearchForUser(ctx context.Context, userID string, search models.TransactionSearch) (*models.SearchPage, error)
+}
+
+type searchService struct {
+	repo repository.SearchRepository
+}
+
+func NewSearchService(repo repository.SearchRepository) SearchService {
+	return &searchService{repo: repo}
+}
+
+func (s *searchService) SearchForUser(ctx context.Context, userID string, search models.TransactionSearch) (*models.SearchPage, error) {
+	search.Terms = searchTerms(search.Query)
+	if len(search.Terms) == 0 {
+		return nil, fmt.Errorf("%w: q must contain a word to search for", ErrInvalidSearch)
+	}
+	if len(search.Terms) > maxSearchTerms {
+		return nil, fmt.Errorf("%w: q can contain at most %d words", ErrInvalidSearch, maxSearchTerms)
+	}
+	if !search.StartDate.IsZero() && !search.EndDate.IsZero() && search.EndDate.Before(search.StartDate) {
+		return nil, fmt.Errorf("%w: end_date must be after start_date", ErrInvalidSearch)
+	}
+	if search.Limit < 0 || search.Limit > maxSearchPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidSearch, maxSearchPageSize)
+	}
+	if search.Limit == 0 {
+		search.Limit = defaultSearchPageSize
+	}
+	if search.Offset < 0 {
+		return nil, fmt.Errorf("%w: offset cannot be negative", ErrInvalidSearch)
+	}
+
+	results, total, err := s.repo.SearchForUser(ctx, userID, search)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to search transactions: %w", err)
+	}
+	for i := range results {
+		highlights := &results[i].Highlights
+		highlights.Description = highlightTerms(highlights.Description, search.Terms)
+		highlights.JarName = highlightTerms(highlights.JarName, search.Terms)
+		highlights.WalletName = highlightTerms(highlights.WalletName, search.Terms)
+	}
+	if results == nil {
+		results = []models.SearchResult{}
+	}
+
+	page := &models.SearchPage{
+		Results: results,
+		Total:   total,
+		Limit:   search.Limit,
+		Offset:  search.Offset,
+	}
+	if next := search.Offset + len(results); next < total {
+		page.HasMore = true
+		page.NextOffset = next
+	}
+	return page, nil
+}
+
+// isSearchRune reports whether r belongs to a word. Marks are included so
+// Thai vowel and tone marks stay inside their word.
+func isSearchRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || unicode.IsMark(r)
+}
+
+// searchTerms splits a query into distinct lower-case words.
+func searchTerms(query string) []string {
+	var terms []string
+	seen := make(map[string]struct{})
+	for _, word := range strings.FieldsFunc(strings.ToLower(query), func(r rune) bool { return !isSearchRune(r) }) {
+		if _, ok := seen[word]; ok {
+			continue
+		}
+		seen[word] = struct{}{}
+		terms = append(terms, word)
+	}
+	return terms
+}
+
+// highlightTerms HTML-escapes text and wraps each word that starts with one
+// of the terms in <mark> tags.
+func highlightTerms(text string, terms []string) string {
+	var b strings.Builder
+	runes := []rune(text)
+	for start := 0; start < len(runes); {
+		end := start + 1
+		inWord := isSearchRune(runes[start])
+		for end < len(runes) && isSearchRune(runes[end]) == inWord {
+			end++
+		}
+		chunk := string(runes[start:end])
+		if inWord && matchesAnyTerm(strings.ToLower(chunk), terms) {
+			b.WriteString("<mark>" + html.EscapeString(chunk) + "</mark>")
+		} else {
+			b.WriteString(html.EscapeString(chunk))
+		}
+		start = end
+	}
+	return b.String()
+}
+
+func matchesAnyTerm(word string, terms []string) bool {
+	for _, term := range terms {
+		if strings.HasPrefix(word, term) {
+			return true
+		}
+	}
+	return false
+}
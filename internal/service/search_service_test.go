@@ -0,0 +1,179 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestSearchService_SearchForUser(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	jarRepo := repository.NewSQLiteJarRepository(dbConn)
+	for _, wallet := range []*models.Wallet{
+		{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB"},
+		{ID: "wallet-2", UserID: "user-2", Name: "Cash", Currency: "THB"},
+	} {
+		if err := walletRepo.Create(ctx, wallet); err != nil {
+			t.Fatalf("failed to create wallet: %v", err)
+		}
+	}
+	food := &models.Jar{ID: "jar-food", UserID: "user-1", Name: "Food", Type: "expense"}
+	if err := jarRepo.Create(ctx, food); err != nil {
+		t.Fatalf("failed to create jar: %v", err)
+	}
+	if err := jarRepo.Create(ctx, &models.Jar{ID: "jar-fun", UserID: "user-1", Name: "Fun", Type: "expense"}); err != nil {
+		t.Fatalf("failed to create jar: %v", err)
+	}
+
+	transactions := NewTransactionService(repository.NewSQLiteTransactionRepository(dbConn), walletRepo, jarRepo, nil, nil)
+	create := func(userID, walletID, jarID, description string, day int) *models.Transaction {
+		t.Helper()
+		created, err := transactions.CreateForUser(ctx, userID, &models.Transaction{
+			Amount: 1000, Description: description, Date: time.Date(2026, 5, day, 0, 0, 0, 0, time.UTC),
+			Type: "expense", WalletID: walletID, JarID: jarID,
+		})
+		if err != nil {
+			t.Fatalf("CreateForUser failed: %v", err)
+		}
+		return created
+	}
+	latte := create("user-1", "wallet-1", "jar-food", "Coffee <latte> with Ann", 1)
+	beans := create("user-1", "wallet-1", "jar-fun", "Bought coffee beans", 10)
+	create("user-1", "wallet-1", "jar-fun", "Cinema", 12)
+	create("user-2", "wallet-2", "", "Coffee", 3)
+
+	search := NewSearchService(repository.NewSQLiteSearchRepository(dbConn))
+
+	page, err := search.SearchForUser(ctx, "user-1", models.TransactionSearch{Query: "COFF"})
+	if err != nil {
+		t.Fatalf("SearchForUser failed: %v", err)
+	}
+	if page.Total != 2 || len(page.Results) != 2 || page.HasMore {
+		t.Fatalf("expected both coffee transactions of user-1, got %+v", page)
+	}
+	if page.Limit != defaultSearchPageSize {
+		t.Errorf("expected the default limit, got %d", page.Limit)
+	}
+	for _, result := range page.Results {
+		if result.Transaction.ID == latte.ID && result.Highlights.Description != "<mark>Coffee</mark> &lt;latte&gt; with Ann" {
+			t.Errorf("unexpected highlight %q", result.Highlights.Description)
+		}
+	}
+
+	// Every term has to match, in the description or a jar or wallet name.
+	page, err = search.SearchForUser(ctx, "user-1", models.TransactionSearch{Query: "coffee food"})
+	if err != nil || page.Total != 1 || page.Results[0].Transaction.ID != latte.ID {
+		t.Fatalf("expected only the coffee in Food, got %+v, %v", page, err)
+	}
+	if page.Results[0].Highlights.JarName != "<mark>Food</mark>" {
+		t.Errorf("expected the jar name to be highlighted, got %q", page.Results[0].Highlights.JarName)
+	}
+
+	page, err = search.SearchForUser(ctx, "user-1", models.TransactionSearch{
+		Query: "coffee", StartDate: time.Date(2026, 5, 5, 0, 0, 0, 0, time.UTC),
+	})
+	if err != nil || page.Total != 1 || page.Results[0].Transaction.ID != beans.ID {
+		t.Fatalf("expected the date filter to keep the beans, got %+v, %v", page, err)
+	}
+	page, err = search.SearchForUser(ctx, "user-1", models.TransactionSearch{Query: "coffee", JarIDs: []string{"jar-fun"}})
+	if err != nil || page.Total != 1 || page.Results[0].Transaction.ID != beans.ID {
+		t.Fatalf("expected the jar filter to keep the beans, got %+v, %v", page, err)
+	}
+
+	page, err = search.SearchForUser(ctx, "user-1", models.TransactionSearch{Query: "coffee", Limit: 1})
+	if err != nil || len(page.Results) != 1 || !page.HasMore || page.NextOffset != 1 {
+		t.Fatalf("expected a first page of one, got %+v, %v", page, err)
+	}
+	first := page.Results[0].Transaction.ID
+	page, err = search.SearchForUser(ctx, "user-1", models.TransactionSearch{Query: "coffee", Limit: 1, Offset: 1})
+	if err != nil || len(page.Results) != 1 || page.HasMore || page.Results[0].Transaction.ID == first {
+		t.Fatalf("expected the other result on the second page, got %+v, %v", page, err)
+	}
+
+	// Renaming a jar is searchable straight away.
+	food.Name = "Groceries"
+	if err := jarRepo.Update(ctx, food); err != nil {
+		t.Fatalf("failed to rename jar: %v", err)
+	}
+	page, err = search.SearchForUser(ctx, "user-1", models.TransactionSearch{Query: "grocer"})
+	if err != nil || page.Total != 1 || page.Results[0].Transaction.ID != latte.ID {
+		t.Fatalf("expected the renamed jar to match, got %+v, %v", page, err)
+	}
+	page, err = search.SearchForUser(ctx, "user-1", models.TransactionSearch{Query: "food"})
+	if err != nil || page.Total != 0 || len(page.Results) != 0 {
+		t.Fatalf("expected the old jar name to stop matching, got %+v, %v", page, err)
+	}
+
+	// Deleted transactions drop out of the results.
+	if err := transactions.DeleteForUser(ctx, "user-1", beans.ID, 0); err != nil {
+		t.Fatalf("DeleteForUser failed: %v", err)
+	}
+	page, err = search.SearchForUser(ctx, "user-1", models.TransactionSearch{Query: "beans"})
+	if err != nil || page.Total != 0 {
+		t.Fatalf("expected the deleted transaction to be gone, got %+v, %v", page, err)
+	}
+
+	if _, err := search.SearchForUser(ctx, "user-1", models.TransactionSearch{Query: " !? "}); !errors.Is(err, ErrInvalidSearch) {
+		t.Errorf("expected a query without words to be rejected, got %v", err)
+	}
+	if _, err := search.SearchForUser(ctx, "user-1", models.TransactionSearch{Query: "coffee", Limit: maxSearchPageSize + 1}); !errors.Is(err, ErrInvalidSearch) {
+		t.Errorf("expected an oversized limit to be rejected, got %v", err)
+	}
+}
+
+func TestSearchService_RanksDescriptionMatchesFirst(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	jarRepo := repository.NewSQLiteJarRepository(dbConn)
+	if err := walletRepo.Create(ctx, &models.Wallet{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	if err := jarRepo.Create(ctx, &models.Jar{ID: "jar-travel", UserID: "user-1", Name: "Travel", Type: "expense"}); err != nil {
+		t.Fatalf("failed to create jar: %v", err)
+	}
+	transactions := NewTransactionService(repository.NewSQLiteTransactionRepository(dbConn), walletRepo, jarRepo, nil, nil)
+	var ids []string
+	for i, tx := range []models.Transaction{
+		{Description: "Taxi", JarID: "jar-travel", Date: time.Date(2026, 5, 20, 0, 0, 0, 0, time.UTC)},
+		{Description: "Travel insurance", Date: time.Date(2026, 5, 1, 0, 0, 0, 0, time.UTC)},
+	} {
+		tx.Amount, tx.Type, tx.WalletID = models.Money(100*(i+1)), "expense", "wallet-1"
+		created, err := transactions.CreateForUser(ctx, "user-1", &tx)
+		if err != nil {
+			t.Fatalf("CreateForUser failed: %v", err)
+		}
+		ids = append(ids, created.ID)
+	}
+
+	page, err := NewSearchService(repository.NewSQLiteSearchRepository(dbConn)).SearchForUser(ctx, "user-1", models.TransactionSearch{Query: "travel"})
+	if err != nil || len(page.Results) != 2 {
+		t.Fatalf("expected two matches, got %+v, %v", page, err)
+	}
+	// Without a full-text index all scores are equal and newer comes first.
+	want := []string{ids[1], ids[0]}
+	if page.Results[0].Score == 0 {
+		want = []string{ids[0], ids[1]}
+	}
+	for i, id := range want {
+		if page.Results[i].Transaction.ID != id {
+			t.Errorf("result %d: expected %s, got %s", i, id, page.Results[i].Transaction.ID)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package service
 
 import (
+	"context"
 	"jarwise-backend/internal/db"
 	"jarwise-backend/internal/models"
 	"jarwise-backend/internal/repository"
@@ -18,20 +19,22 @@ func TestSyncConflict_TransferToDeletedWallet(t *testing.T) {
 
 	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
 	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
-	svc := NewTransactionService(txRepo, walletRepo)
+	svc := NewTransactionService(txRepo, walletRepo, repository.NewSQLiteJarRepository(dbConn), repository.NewSQLiteTagRepository(dbConn), nil)
 
 	// Create Wallets
 	wA := &models.Wallet{ID: "wallet-a", Name: "Wallet A", Currency: "THB"}
 	wB := &models.Wallet{ID: "wallet-b", Name: "Wallet B", Currency: "THB"}
-	walletRepo.Create(wA)
-	walletRepo.Create(wB)
+	walletRepo.Create(context.Background(), wA)
+	walletRepo.Create(context.Background(), wB)
 
 	// 2. Simulation: Wallet B is DELETED on another device (Mocked here)
 	walletRepo.Delete("wallet-b")
 
 	// 3. Action: Device 1 tries to Transfer to B
 	// This should fail because Wallet B no longer exists!
-	_, _, err := svc.CreateTransfer("wallet-a", "wallet-b", 100, time.Now(), "Conflict Test")
+	_, err := svc.CreateTransfer(context.Background(), models.TransferRequest{
+		FromWalletID: "wallet-a", ToWalletID: "wallet-b", SourceAmount: 100, Date: time.Now(), Notes: "Conflict Test",
+	})
 
 	// 4. Assert: Expecting an error due to missing target wallet (Sync Conflict)
 	if err == nil {
@@ -0,0 +1,424 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+
+	"github.com/google/uuid"
+)
+
+const (
+	defaultSyncPageSize = 500
+	maxSyncPageSize     = 1000
+	maxSyncBatchSize    = 500
+)
+
+var (
+	ErrInvalidSync = errors.New("invalid sync request")
+
+	// errInvalidWallet and errWalletInUse reject pushed wallet mutations.
+	errInvalidWallet = errors.New("invalid wallet")
+	errWalletInUse   = errors.New("wallet still has transactions or jars")
+)
+
+type SyncService interface {
+	// ChangesForUser returns the user's changes after version since. Deleted
+	// entities appear as tombstones.
+	ChangesForUser(ctx context.Context, userID string, since int64, limit int) (*models.SyncChangePage, error)
+	// PushForUser applies mutations made offline, in order. Each is either
+	// applied or rejected with a reason; an error means none past the
+	// failing one were looked at.
+	PushForUser(ctx context.Context, userID string, mutations []models.SyncMutation) (*models.SyncPushResult, error)
+}
+
+type syncService struct {
+	repo         repository.SyncRepository
+	walletRepo   repository.WalletRepository
+	jarRepo      repository.JarRepository
+	txRepo       repository.TransactionRepository
+	jars         JarService
+	transactions TransactionService
+
+	// pushLocks serializes each user's pushes so a mutation's version check
+	// and its write are not interleaved with another push by the same user.
+	pushLocks userLocks
+}
+
+func NewSyncService(repo repository.SyncRepository, walletRepo repository.WalletRepository, jarRepo repository.JarRepository, txRepo repository.TransactionRepository, jars JarService, transactions TransactionService) SyncService {
+	return &syncService{
+		repo:         repo,
+		walletRepo:   walletRepo,
+		jarRepo:      jarRepo,
+		txRepo:       txRepo,
+		jars:         jars,
+		transactions: transactions,
+	}
+}
+
+func (s *syncService) ChangesForUser(ctx context.Context, userID string, since int64, limit int) (*models.SyncChangePage, error) {
+	if since < 0 {
+		return nil, fmt.Errorf("%w: since cannot be negative", ErrInvalidSync)
+	}
+	if limit < 0 || limit > maxSyncPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidSync, maxSyncPageSize)
+	}
+	if limit == 0 {
+		limit = defaultSyncPageSize
+	}
+
+	changes, err := s.repo.ListChangesForUser(ctx, userID, since, limit+1)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list changes: %w", err)
+	}
+	page := &models.SyncChangePage{Changes: make([]models.SyncChange, 0, len(changes)), Cursor: since}
+	if len(changes) > limit {
+		changes = changes[:limit]
+		page.HasMore = true
+	}
+	if len(changes) > 0 {
+		page.Cursor = changes[len(changes)-1].Version
+	}
+	// An entity deleted since the listing is reported by its tombstone on a
+	// later page.
+	page.Changes, err = s.loadEntities(ctx, userID, changes)
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+// PushForUser resolves conflicts the same way every time:
+//   - mutations apply in the order given, and later ones see earlier ones;
+//   - a delete on the server wins over any offline upsert of the entity;
+//   - otherwise the server wins when the entity changed after BaseVersion,
+//     unless an earlier mutation of the same push made that change;
+//   - deleting an entity that is already deleted succeeds.
+func (s *syncService) PushForUser(ctx context.Context, userID string, mutations []models.SyncMutation) (*models.SyncPushResult, error) {
+	if len(mutations) > maxSyncBatchSize {
+		return nil, fmt.Errorf("%w: push at most %d mutations at a time", ErrInvalidSync, maxSyncBatchSize)
+	}
+	userID = normalizedServiceUserID(userID)
+
+	release, err := s.pushLocks.lock(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to wait for push: %w", err)
+	}
+	defer release()
+
+	result := &models.SyncPushResult{Applied: []models.SyncApplied{}, Rejected: []models.SyncRejection{}}
+	pushed := make(map[string]bool)
+	for _, mutation := range mutations {
+		rejection, err := s.push(ctx, userID, mutation, pushed)
+		if err != nil {
+			return nil, err
+		}
+		if rejection != nil {
+			result.Rejected = append(result.Rejected, *rejection)
+			continue
+		}
+
+		key := mutation.Entity + "/" + mutation.ID
+		pushed[key] = true
+		change, err := s.repo.GetChange(ctx, mutation.Entity, mutation.ID)
+		if err != nil {
+			return nil, fmt.Errorf("service: failed to load change: %w", err)
+		}
+		applied := models.SyncApplied{MutationID: mutation.MutationID, Entity: mutation.Entity, ID: mutation.ID}
+		if change != nil {
+			applied.Version = change.Version
+		}
+		result.Applied = append(result.Applied, applied)
+	}
+	return result, nil
+}
+
+// push applies one mutation, returning why it was rejected if it was.
+func (s *syncService) push(ctx context.Context, userID string, mutation models.SyncMutation, pushed map[string]bool) (*models.SyncRejection, error) {
+	reject := func(reason, message string, current *models.SyncChange) *models.SyncRejection {
+		return &models.SyncRejection{
+			MutationID: mutation.MutationID,
+			Entity:     mutation.Entity,
+			ID:         mutation.ID,
+			Reason:     reason,
+			Message:    message,
+			Current:    current,
+		}
+	}
+
+	switch mutation.Entity {
+	case models.SyncEntityWallet, models.SyncEntityJar, models.SyncEntityTransaction:
+	default:
+		return reject(models.SyncReasonInvalid, "entity must be wallet, jar or transaction", nil), nil
+	}
+	if mutation.Op != models.SyncOpUpsert && mutation.Op != models.SyncOpDelete {
+		return reject(models.SyncReasonInvalid, "op must be upsert or delete", nil), nil
+	}
+	if _, err := uuid.Parse(mutation.ID); err != nil {
+		return reject(models.SyncReasonInvalid, "id must be a UUID", nil), nil
+	}
+
+	current, err := s.repo.GetChange(ctx, mutation.Entity, mutation.ID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to load change: %w", err)
+	}
+	if current != nil && current.UserID != userID {
+		return reject(models.SyncReasonInvalid, "id is already in use", nil), nil
+	}
+	if current != nil {
+		loaded, err := s.loadEntities(ctx, userID, []models.SyncChange{*current})
+		if err != nil {
+			return nil, err
+		}
+		if len(loaded) == 1 {
+			current = &loaded[0]
+		}
+	}
+
+	exists := current != nil && current.Op == models.SyncOpUpsert
+	switch {
+	case current == nil && mutation.Op == models.SyncOpDelete:
+		return reject(models.SyncReasonNotFound, mutation.Entity+" not found", nil), nil
+	case current != nil && !exists && mutation.Op == models.SyncOpDelete:
+		return nil, nil
+	case current != nil && !exists:
+		return reject(models.SyncReasonDeleted, mutation.Entity+" was deleted on another device", current), nil
+	case exists && current.Version > mutation.BaseVersion && !pushed[mutation.Entity+"/"+mutation.ID]:
+		return reject(models.SyncReasonConflict, mutation.Entity+" was changed on another device", current), nil
+	}
+
+	// The write only goes through if the record is still the one the
+	// conflict check above was settled against.
+	version := recordVersion(current)
+	if mutation.Op == models.SyncOpDelete {
+		err = s.delete(ctx, userID, mutation, version)
+	} else {
+		err = s.upsert(ctx, userID, mutation, exists, version)
+	}
+	if err == nil {
+		return nil, nil
+	}
+	if reason, ok := syncRejectionReason(err); ok {
+		return reject(reason, err.Error(), current), nil
+	}
+	return nil, err
+}
+
+// recordVersion returns the record version of the entity loaded into
+// change, or zero when there is none.
+func recordVersion(change *models.SyncChange) int64 {
+	switch {
+	case change == nil:
+		return 0
+	case change.Wallet != nil:
+		return change.Wallet.Version
+	case change.Jar != nil:
+		return change.Jar.Version
+	case change.Transaction != nil:
+		return change.Transaction.Version
+	}
+	return 0
+}
+
+func syncRejectionReason(err error) (string, bool) {
+	switch {
+	case errors.Is(err, repository.ErrVersionMismatch):
+		return models.SyncReasonConflict, true
+	case errors.Is(err, ErrJarInUse), errors.Is(err, errWalletInUse):
+		return models.SyncReasonInUse, true
+	case errors.Is(err, ErrJarNotFound), errors.Is(err, ErrTransactionNotFound):
+		return models.SyncReasonNotFound, true
+	case errors.Is(err, ErrInvalidTransaction), errors.Is(err, ErrTransactionLinked),
+		errors.Is(err, ErrInvalidJar), errors.Is(err, ErrJarCycle),
+		errors.Is(err, errInvalidWallet), errors.Is(err, repository.ErrWalletCurrencyLocked):
+		return models.SyncReasonInvalid, true
+	}
+	return "", false
+}
+
+func (s *syncService) upsert(ctx context.Context, userID string, mutation models.SyncMutation, exists bool, version int64) error {
+	switch mutation.Entity {
+	case models.SyncEntityWallet:
+		if mutation.Wallet == nil {
+			return fmt.Errorf("%w: wallet is required", errInvalidWallet)
+		}
+		return s.upsertWallet(ctx, userID, mutation.ID, *mutation.Wallet, exists, version)
+	case models.SyncEntityJar:
+		if mutation.Jar == nil {
+			return fmt.Errorf("%w: jar is required", ErrInvalidJar)
+		}
+		jar := *mutation.Jar
+		jar.ID = mutation.ID
+		jar.Version = version
+		if exists {
+			_, err := s.jars.UpdateForUser(ctx, userID, &jar)
+			return err
+		}
+		_, err := s.jars.CreateForUser(ctx, userID, &jar)
+		return err
+	default:
+		if mutation.Transaction == nil {
+			return fmt.Errorf("%w: transaction is required", ErrInvalidTransaction)
+		}
+		tx := *mutation.Transaction
+		tx.ID = mutation.ID
+		tx.Version = version
+		if exists {
+			_, err := s.transactions.UpdateForUser(ctx, userID, &tx)
+			return err
+		}
+		_, err := s.transactions.CreateForUser(ctx, userID, &tx)
+		return err
+	}
+}
+
+// upsertWallet applies the fields the wallet API lets clients edit.
+func (s *syncService) upsertWallet(ctx context.Context, userID, id string, fields models.Wallet, exists bool, version int64) error {
+	wallet := &models.Wallet{ID: id, UserID: userID}
+	if exists {
+		stored, err := s.walletRepo.GetForUser(userID, id)
+		if err != nil {
+			return fmt.Errorf("service: failed to load wallet: %w", err)
+		}
+		if stored == nil {
+			return fmt.Errorf("%w: wallet not found", errInvalidWallet)
+		}
+		wallet = stored
+		if version != 0 {
+			wallet.Version = version
+		}
+	}
+	wallet.Name = fields.Name
+	wallet.Currency = fields.Currency
+	wallet.Type = fields.Type
+	wallet.OpeningBalance = fields.OpeningBalance
+	if fields.Archived != wallet.Archived {
+		wallet.Archived = fields.Archived
+		wallet.ArchivedAt = nil
+	}
+	if msg := NormalizeWallet(wallet); msg != "" {
+		return fmt.Errorf("%w: %s", errInvalidWallet, msg)
+	}
+
+	if exists {
+		if err := s.walletRepo.Update(ctx, wallet); err != nil {
+			return fmt.Errorf("service: failed to update wallet: %w", err)
+		}
+		return nil
+	}
+	if err := s.walletRepo.Create(ctx, wallet); err != nil {
+		return fmt.Errorf("service: failed to create wallet: %w", err)
+	}
+	return nil
+}
+
+func (s *syncService) delete(ctx context.Context, userID string, mutation models.SyncMutation, version int64) error {
+	switch mutation.Entity {
+	case models.SyncEntityWallet:
+		return s.deleteWallet(ctx, userID, mutation.ID, version)
+	case models.SyncEntityJar:
+		return s.jars.DeleteForUser(ctx, userID, mutation.ID, JarDeleteOptions{Version: version})
+	default:
+		return s.transactions.DeleteForUser(ctx, userID, mutation.ID, version)
+	}
+}
+
+// deleteWallet only deletes an empty wallet, so transactions recorded on
+// another device are never removed as a side effect. Clients delete the
+// wallet's transactions and jars first, in the same push.
+func (s *syncService) deleteWallet(ctx context.Context, userID, id string, version int64) error {
+	page, err := s.txRepo.ListPageForUser(userID, models.TransactionListFilter{WalletIDs: []string{id}, Limit: 1})
+	if err != nil {
+		return fmt.Errorf("service: failed to check wallet transactions: %w", err)
+	}
+	if len(page.Transactions) > 0 {
+		return errWalletInUse
+	}
+	jars, err := s.jarRepo.ListAllForUser(ctx, userID)
+	if err != nil {
+		return fmt.Errorf("service: failed to check wallet jars: %w", err)
+	}
+	for _, jar := range jars {
+		if jar.WalletID == id {
+			return errWalletInUse
+		}
+	}
+
+	if err := s.walletRepo.DeleteForUser(ctx, userID, id, version); err != nil {
+		if errors.Is(err, repository.ErrVersionMismatch) {
+			return err
+		}
+		return fmt.Errorf("service: failed to delete wallet: %w", err)
+	}
+	return nil
+}
+
+// loadEntities fills in the current state of the upserted entities among the
+// user's changes, loading each kind in one query. Changes whose entity no
+// longer exists are left out.
+func (s *syncService) loadEntities(ctx context.Context, userID string, changes []models.SyncChange) ([]models.SyncChange, error) {
+	ids := make(map[string][]string)
+	for _, change := range changes {
+		if change.Op == models.SyncOpUpsert {
+			ids[change.Entity] = append(ids[change.Entity], change.ID)
+		}
+	}
+
+	wallets := make(map[string]*models.Wallet)
+	if len(ids[models.SyncEntityWallet]) > 0 {
+		loaded, err := s.walletRepo.ListByIDsForUser(userID, ids[models.SyncEntityWallet])
+		if err != nil {
+			return nil, fmt.Errorf("service: failed to load wallets: %w", err)
+		}
+		for i := range loaded {
+			wallets[loaded[i].ID] = &loaded[i]
+		}
+	}
+	jars := make(map[string]*models.Jar)
+	if len(ids[models.SyncEntityJar]) > 0 {
+		loaded, err := s.jarRepo.ListByIDsForUser(ctx, userID, ids[models.SyncEntityJar])
+		if err != nil {
+			return nil, fmt.Errorf("service: failed to load jars: %w", err)
+		}
+		for i := range loaded {
+			jars[loaded[i].ID] = &loaded[i]
+		}
+	}
+	transactions := make(map[string]*models.Transaction)
+	if len(ids[models.SyncEntityTransaction]) > 0 {
+		loaded, err := s.txRepo.ListByIDsForUser(userID, ids[models.SyncEntityTransaction])
+		if err != nil {
+			return nil, fmt.Errorf("service: failed to load transactions: %w", err)
+		}
+		for i := range loaded {
+			transactions[loaded[i].ID] = &loaded[i]
+		}
+	}
+
+	found := make([]models.SyncChange, 0, len(changes))
+	for _, change := range changes {
+		if change.Op == models.SyncOpUpsert {
+			switch change.Entity {
+			case models.SyncEntityWallet:
+				change.Wallet = wallets[change.ID]
+				if change.Wallet == nil {
+					continue
+				}
+			case models.SyncEntityJar:
+				change.Jar = jars[change.ID]
+				if change.Jar == nil {
+					continue
+				}
+			default:
+				change.Transaction = transactions[change.ID]
+				if change.Transaction == nil {
+					continue
+				}
+			}
+		}
+		found = append(found, change)
+	}
+	return found, nil
+}
@@ -0,0 +1,213 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func TestSyncService_PushAndPull(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	jarRepo := repository.NewSQLiteJarRepository(dbConn)
+	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
+	jars := NewJarService(jarRepo, walletRepo)
+	transactions := NewTransactionService(txRepo, walletRepo, jarRepo, nil, nil)
+	svc := NewSyncService(repository.NewSQLiteSyncRepository(dbConn), walletRepo, jarRepo, txRepo, jars, transactions)
+
+	walletID, jarID, txID := uuid.New().String(), uuid.New().String(), uuid.New().String()
+	result, err := svc.PushForUser(ctx, "user-1", []models.SyncMutation{
+		{MutationID: "m1", Entity: models.SyncEntityWallet, Op: models.SyncOpUpsert, ID: walletID,
+			Wallet: &models.Wallet{Name: " Cash ", Currency: "thb"}},
+		{MutationID: "m2", Entity: models.SyncEntityJar, Op: models.SyncOpUpsert, ID: jarID,
+			Jar: &models.Jar{Name: "Food", Type: "expense"}},
+		{MutationID: "m3", Entity: models.SyncEntityTransaction, Op: models.SyncOpUpsert, ID: txID,
+			Transaction: &models.Transaction{Amount: 5000, Type: "expense", WalletID: walletID, JarID: jarID, Date: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)}},
+		// A later mutation of the same push builds on the earlier one.
+		{MutationID: "m4", Entity: models.SyncEntityTransaction, Op: models.SyncOpUpsert, ID: txID,
+			Transaction: &models.Transaction{Amount: 4500, Description: "Lunch", Type: "expense", WalletID: walletID, JarID: jarID, Date: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)}},
+	})
+	if err != nil {
+		t.Fatalf("PushForUser failed: %v", err)
+	}
+	if len(result.Rejected) != 0 || len(result.Applied) != 4 {
+		t.Fatalf("expected every mutation to apply, got %+v", result)
+	}
+	txVersion := result.Applied[3].Version
+	stored, err := transactions.GetForUser(ctx, "user-1", txID)
+	if err != nil || stored.Amount != 4500 || stored.Description != "Lunch" {
+		t.Fatalf("expected the client ID to hold the latest edit, got %+v, %v", stored, err)
+	}
+
+	page, err := svc.ChangesForUser(ctx, "user-1", 0, 0)
+	if err != nil {
+		t.Fatalf("ChangesForUser failed: %v", err)
+	}
+	if len(page.Changes) != 3 || page.HasMore || page.Cursor != txVersion {
+		t.Fatalf("expected one change per entity up to the last push, got %+v", page)
+	}
+	if wallet := page.Changes[0].Wallet; wallet == nil || wallet.Name != "Cash" || wallet.Currency != "THB" {
+		t.Errorf("expected the normalized wallet, got %+v", page.Changes[0])
+	}
+	if other, _ := svc.ChangesForUser(ctx, "user-2", 0, 0); len(other.Changes) != 0 {
+		t.Errorf("expected other users to see nothing, got %+v", other.Changes)
+	}
+
+	// Another device edits the transaction, so this device's stale edit
+	// loses and gets the server's copy back.
+	stored.Description = "Team lunch"
+	if _, err := transactions.UpdateForUser(ctx, "user-1", stored); err != nil {
+		t.Fatalf("UpdateForUser failed: %v", err)
+	}
+	result, err = svc.PushForUser(ctx, "user-1", []models.SyncMutation{
+		{MutationID: "stale", Entity: models.SyncEntityTransaction, Op: models.SyncOpUpsert, ID: txID, BaseVersion: txVersion,
+			Transaction: &models.Transaction{Amount: 100, Type: "expense", WalletID: walletID, JarID: jarID, Date: time.Now()}},
+	})
+	if err != nil || len(result.Rejected) != 1 {
+		t.Fatalf("expected the stale edit to be rejected, got %+v, %v", result, err)
+	}
+	rejection := result.Rejected[0]
+	if rejection.Reason != models.SyncReasonConflict || rejection.Current == nil ||
+		rejection.Current.Transaction == nil || rejection.Current.Transaction.Description != "Team lunch" {
+		t.Fatalf("expected a conflict carrying the server copy, got %+v", rejection)
+	}
+
+	page, err = svc.ChangesForUser(ctx, "user-1", txVersion, 0)
+	if err != nil || len(page.Changes) != 1 || page.Changes[0].ID != txID {
+		t.Fatalf("expected only the server edit after the cursor, got %+v, %v", page, err)
+	}
+
+	// The wallet cannot go while it has transactions or jars.
+	result, err = svc.PushForUser(ctx, "user-1", []models.SyncMutation{
+		{MutationID: "w", Entity: models.SyncEntityWallet, Op: models.SyncOpDelete, ID: walletID, BaseVersion: page.Cursor},
+	})
+	if err != nil || len(result.Rejected) != 1 || result.Rejected[0].Reason != models.SyncReasonInUse {
+		t.Fatalf("expected the wallet delete to be refused, got %+v, %v", result, err)
+	}
+
+	// A server delete leaves a tombstone that beats offline edits.
+	if err := transactions.DeleteForUser(ctx, "user-1", txID, 0); err != nil {
+		t.Fatalf("DeleteForUser failed: %v", err)
+	}
+	page, err = svc.ChangesForUser(ctx, "user-1", page.Cursor, 0)
+	if err != nil || len(page.Changes) != 1 || page.Changes[0].Op != models.SyncOpDelete || page.Changes[0].Transaction != nil {
+		t.Fatalf("expected a tombstone, got %+v, %v", page, err)
+	}
+	result, err = svc.PushForUser(ctx, "user-1", []models.SyncMutation{
+		{MutationID: "edit", Entity: models.SyncEntityTransaction, Op: models.SyncOpUpsert, ID: txID, BaseVersion: page.Cursor,
+			Transaction: &models.Transaction{Amount: 100, Type: "expense", WalletID: walletID, JarID: jarID, Date: time.Now()}},
+		{MutationID: "delete", Entity: models.SyncEntityTransaction, Op: models.SyncOpDelete, ID: txID},
+	})
+	if err != nil {
+		t.Fatalf("PushForUser failed: %v", err)
+	}
+	if len(result.Rejected) != 1 || result.Rejected[0].Reason != models.SyncReasonDeleted {
+		t.Errorf("expected the edit of a deleted transaction to be rejected, got %+v", result.Rejected)
+	}
+	if len(result.Applied) != 1 || result.Applied[0].MutationID != "delete" {
+		t.Errorf("expected deleting it again to succeed, got %+v", result.Applied)
+	}
+}
+
+func TestSyncService_RejectsInvalidMutations(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	ctx := context.Background()
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	jarRepo := repository.NewSQLiteJarRepository(dbConn)
+	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
+	svc := NewSyncService(repository.NewSQLiteSyncRepository(dbConn), walletRepo, jarRepo, txRepo,
+		NewJarService(jarRepo, walletRepo), NewTransactionService(txRepo, walletRepo, jarRepo, nil, nil))
+
+	foreignID := uuid.New().String()
+	if err := walletRepo.Create(ctx, &models.Wallet{ID: foreignID, UserID: "user-2", Name: "Bank", Currency: "THB"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+
+	result, err := svc.PushForUser(ctx, "user-1", []models.SyncMutation{
+		{MutationID: "bad-id", Entity: models.SyncEntityWallet, Op: models.SyncOpUpsert, ID: "wallet-1", Wallet: &models.Wallet{Name: "Cash", Currency: "THB"}},
+		{MutationID: "taken", Entity: models.SyncEntityWallet, Op: models.SyncOpUpsert, ID: foreignID, Wallet: &models.Wallet{Name: "Cash", Currency: "THB"}},
+		{MutationID: "currency", Entity: models.SyncEntityWallet, Op: models.SyncOpUpsert, ID: uuid.New().String(), Wallet: &models.Wallet{Name: "Cash", Currency: "baht"}},
+		{MutationID: "missing", Entity: models.SyncEntityJar, Op: models.SyncOpDelete, ID: uuid.New().String()},
+		{MutationID: "entity", Entity: "budget", Op: models.SyncOpUpsert, ID: uuid.New().String()},
+	})
+	if err != nil {
+		t.Fatalf("PushForUser failed: %v", err)
+	}
+	if len(result.Applied) != 0 {
+		t.Errorf("expected nothing to apply, got %+v", result.Applied)
+	}
+	want := map[string]string{
+		"bad-id":   models.SyncReasonInvalid,
+		"taken":    models.SyncReasonInvalid,
+		"currency": models.SyncReasonInvalid,
+		"missing":  models.SyncReasonNotFound,
+		"entity":   models.SyncReasonInvalid,
+	}
+	for _, rejection := range result.Rejected {
+		if want[rejection.MutationID] != rejection.Reason {
+			t.Errorf("%s: expected %s, got %s (%s)", rejection.MutationID, want[rejection.MutationID], rejection.Reason, rejection.Message)
+		}
+		if rejection.Current != nil {
+			t.Errorf("%s: expected no server copy, got %+v", rejection.MutationID, rejection.Current)
+		}
+	}
+	if len(result.Rejected) != len(want) {
+		t.Errorf("expected %d rejections, got %d", len(want), len(result.Rejected))
+	}
+
+	if _, err := svc.ChangesForUser(ctx, "user-1", -1, 0); !errors.Is(err, ErrInvalidSync) {
+		t.Errorf("expected a negative cursor to be rejected, got %v", err)
+	}
+}
+
+func TestSyncService_PushWaitsOnlyForTheSameUser(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	jarRepo := repository.NewSQLiteJarRepository(dbConn)
+	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
+	svc := NewSyncService(repository.NewSQLiteSyncRepository(dbConn), walletRepo, jarRepo, txRepo,
+		NewJarService(jarRepo, walletRepo), NewTransactionService(txRepo, walletRepo, jarRepo, nil, nil)).(*syncService)
+
+	// A push of user-1 is in progress.
+	release, err := svc.pushLocks.lock(context.Background(), "user-1")
+	if err != nil {
+		t.Fatalf("lock failed: %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	mutation := models.SyncMutation{MutationID: "m1", Entity: models.SyncEntityWallet, Op: models.SyncOpUpsert, ID: uuid.New().String(),
+		Wallet: &models.Wallet{Name: "Cash", Currency: "THB"}}
+	if result, err := svc.PushForUser(ctx, "user-2", []models.SyncMutation{mutation}); err != nil || len(result.Applied) != 1 {
+		t.Fatalf("expected another user's push to go ahead, got %+v, %v", result, err)
+	}
+
+	waitCtx, cancelWait := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelWait()
+	if _, err := svc.PushForUser(waitCtx, "user-1", nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the same user's push to wait, got %v", err)
+	}
+}
@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var (
+	ErrTagNotFound = errors.New("tag not found")
+	ErrInvalidTag  = errors.New("invalid tag")
+	ErrTagExists   = errors.New("a tag with this name already exists")
+)
+
+type TagService interface {
+	ListForUser(ctx context.Context, userID string) ([]models.Tag, error)
+	GetForUser(ctx context.Context, userID, id string) (*models.Tag, error)
+	CreateForUser(ctx context.Context, userID string, tag *models.Tag) (*models.Tag, error)
+	UpdateForUser(ctx context.Context, userID string, tag *models.Tag) (*models.Tag, error)
+	// DeleteForUser deletes the tag and removes it from its transactions.
+	DeleteForUser(ctx context.Context, userID, id string) error
+}
+
+type tagService struct {
+	repo repository.TagRepository
+}
+
+func NewTagService(repo repository.TagRepository) TagService {
+	return &tagService{repo: repo}
+}
+
+func (s *tagService) ListForUser(ctx context.Context, userID string) ([]models.Tag, error) {
+	tags, err := s.repo.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list tags: %w", err)
+	}
+	if tags == nil {
+		tags = []models.Tag{}
+	}
+	return tags, nil
+}
+
+func (s *tagService) GetForUser(ctx context.Context, userID, id string) (*models.Tag, error) {
+	tag, err := s.repo.GetForUser(ctx, userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to load tag: %w", err)
+	}
+	if tag == nil {
+		return nil, ErrTagNotFound
+	}
+	return tag, nil
+}
+
+func (s *tagService) CreateForUser(ctx context.Context, userID string, tag *models.Tag) (*models.Tag, error) {
+	tag.ID = uuid.New().String()
+	tag.UserID = normalizedServiceUserID(userID)
+	tag.CreatedAt = time.Now().UTC()
+	if err := s.validateTag(ctx, tag); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, tag); err != nil {
+		return nil, fmt.Errorf("service: failed to create tag: %w", err)
+	}
+	return tag, nil
+}
+
+// UpdateForUser changes the name or colour of a tag. Its transactions keep
+// the tag.
+func (s *tagService) UpdateForUser(ctx context.Context, userID string, tag *models.Tag) (*models.Tag, error) {
+	existing, err := s.GetForUser(ctx, userID, tag.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	tag.UserID = existing.UserID
+	tag.CreatedAt = existing.CreatedAt
+	if err := s.validateTag(ctx, tag); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, tag); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTagNotFound
+		}
+		return nil, fmt.Errorf("service: failed to update tag: %w", err)
+	}
+	return tag, nil
+}
+
+func (s *tagService) DeleteForUser(ctx context.Context, userID, id string) error {
+	if err := s.repo.DeleteForUser(ctx, userID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTagNotFound
+		}
+		return fmt.Errorf("service: failed to delete tag: %w", err)
+	}
+	return nil
+}
+
+// validateTag trims the name and checks that no other tag of the user has
+// it, ignoring case.
+func (s *tagService) validateTag(ctx context.Context, tag *models.Tag) error {
+	tag.Name = strings.TrimSpace(tag.Name)
+	tag.Color = strings.TrimSpace(tag.Color)
+	if tag.Name == "" {
+		return fmt.Errorf("%w: name is required", ErrInvalidTag)
+	}
+	if len(tag.Name) > 64 {
+		return fmt.Errorf("%w: name must be at most 64 characters", ErrInvalidTag)
+	}
+
+	existing, err := s.repo.ListForUser(ctx, tag.UserID)
+	if err != nil {
+		return fmt.Errorf("service: failed to check tags: %w", err)
+	}
+	for _, other := range existing {
+		if other.ID != tag.ID && strings.EqualFold(other.Name, tag.Name) {
+			return ErrTagExists
+		}
+	}
+	return nil
+}
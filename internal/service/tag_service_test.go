@@ -0,0 +1,86 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"testing"
+	"time"
+)
+
+func TestTagService_TagTransactionsAndDelete(t *testing.T) {
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	if err := walletRepo.Create(context.Background(), &models.Wallet{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	tagRepo := repository.NewSQLiteTagRepository(dbConn)
+	tags := NewTagService(tagRepo)
+	transactions := NewTransactionService(repository.NewSQLiteTransactionRepository(dbConn), walletRepo, repository.NewSQLiteJarRepository(dbConn), tagRepo, nil)
+	ctx := context.Background()
+
+	trip, err := tags.CreateForUser(ctx, "user-1", &models.Tag{Name: " trip-japan-2026 "})
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+	if trip.Name != "trip-japan-2026" {
+		t.Errorf("expected the name to be trimmed, got %q", trip.Name)
+	}
+	if _, err := tags.CreateForUser(ctx, "user-1", &models.Tag{Name: "Trip-Japan-2026"}); !errors.Is(err, ErrTagExists) {
+		t.Errorf("expected a duplicate name to be rejected, got %v", err)
+	}
+	if _, err := tags.CreateForUser(ctx, "user-1", &models.Tag{Name: "  "}); !errors.Is(err, ErrInvalidTag) {
+		t.Errorf("expected an empty name to be rejected, got %v", err)
+	}
+	foreign, err := tags.CreateForUser(ctx, "user-2", &models.Tag{Name: "business"})
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+
+	newExpense := func(tagIDs ...string) *models.Transaction {
+		tx := &models.Transaction{Amount: 1200, Date: time.Date(2026, 4, 2, 0, 0, 0, 0, time.UTC), Type: "expense", WalletID: "wallet-1"}
+		for _, id := range tagIDs {
+			tx.Tags = append(tx.Tags, models.Tag{ID: id})
+		}
+		return tx
+	}
+	if _, err := transactions.CreateForUser(ctx, "user-1", newExpense(foreign.ID)); !errors.Is(err, ErrInvalidTransaction) {
+		t.Errorf("expected another user's tag to be rejected, got %v", err)
+	}
+	created, err := transactions.CreateForUser(ctx, "user-1", newExpense(trip.ID, trip.ID))
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+	stored, err := transactions.GetForUser(ctx, "user-1", created.ID)
+	if err != nil || len(stored.Tags) != 1 || stored.Tags[0].Name != "trip-japan-2026" {
+		t.Fatalf("expected one trip tag, got %+v, %v", stored, err)
+	}
+
+	// Renaming shows up on the tagged transaction.
+	trip.Name = "japan"
+	if _, err := tags.UpdateForUser(ctx, "user-1", trip); err != nil {
+		t.Fatalf("UpdateForUser failed: %v", err)
+	}
+	stored, _ = transactions.GetForUser(ctx, "user-1", created.ID)
+	if len(stored.Tags) != 1 || stored.Tags[0].Name != "japan" {
+		t.Errorf("expected the renamed tag, got %+v", stored.Tags)
+	}
+
+	if err := tags.DeleteForUser(ctx, "user-2", trip.ID); !errors.Is(err, ErrTagNotFound) {
+		t.Errorf("expected another user's delete to miss, got %v", err)
+	}
+	if err := tags.DeleteForUser(ctx, "user-1", trip.ID); err != nil {
+		t.Fatalf("DeleteForUser failed: %v", err)
+	}
+	stored, _ = transactions.GetForUser(ctx, "user-1", created.ID)
+	if len(stored.Tags) != 0 {
+		t.Errorf("expected the transaction to be untagged, got %+v", stored.Tags)
+	}
+}
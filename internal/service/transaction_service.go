@@ -1,104 +1,701 @@
 package service
 
 import (
+	"context"
+	"database/sql"
+	"errors"
 	"fmt"
 	"jarwise-backend/internal/models"
 	"jarwise-backend/internal/repository"
-	"time"
+	"log"
 
 	"github.com/google/uuid"
 )
 
+var (
+	ErrTransactionNotFound = errors.New("transaction not found")
+	ErrInvalidTransaction  = errors.New("invalid transaction")
+	ErrTransactionLinked   = errors.New("transaction is part of a transfer")
+	ErrInvalidTransfer     = errors.New("invalid transfer")
+	ErrTransferNotFound    = errors.New("transfer not found")
+
+	ErrInvalidTransactionFilter = errors.New("invalid transaction filter")
+)
+
+const maxTransactionPageSize = 200
+
 type TransactionService interface {
-	CreateTransfer(fromWalletID, toWalletID string, amount float64, date time.Time, notes string) (*models.Transaction, *models.Transaction, error)
-	CreateTransferForUser(userID, fromWalletID, toWalletID string, amount float64, date time.Time, notes string) (*models.Transaction, *models.Transaction, error)
+	CreateTransfer(ctx context.Context, req models.TransferRequest) (*models.Transfer, error)
+	CreateTransferForUser(ctx context.Context, userID string, req models.TransferRequest) (*models.Transfer, error)
+	GetTransferForUser(ctx context.Context, userID, id string) (*models.Transfer, error)
+	// The transfer edits fail with repository.ErrVersionMismatch unless the
+	// outgoing leg is at version; zero skips the check.
+	UpdateTransferForUser(ctx context.Context, userID, id string, patch models.TransferPatch, version int64) (*models.Transfer, error)
+	DeleteTransferForUser(ctx context.Context, userID, id string, version int64) error
 	ListForUser(userID string) ([]models.Transaction, error)
+	ListPageForUser(ctx context.Context, userID string, filter models.TransactionListFilter) (*models.TransactionPage, error)
+	// ListFilteredForUser returns every transaction matching filter, whose
+	// Limit and Cursor are ignored.
+	ListFilteredForUser(ctx context.Context, userID string, filter models.TransactionListFilter) ([]models.Transaction, error)
+	GetForUser(ctx context.Context, userID, id string) (*models.Transaction, error)
+	CreateForUser(ctx context.Context, userID string, tx *models.Transaction) (*models.Transaction, error)
+	UpdateForUser(ctx context.Context, userID string, tx *models.Transaction) (*models.Transaction, error)
+	// DeleteForUser fails with repository.ErrVersionMismatch unless the
+	// transaction is at version; zero skips the check.
+	DeleteForUser(ctx context.Context, userID, id string, version int64) error
+	// The refund edits fail with repository.ErrVersionMismatch unless the
+	// income is at version; zero skips the check.
+	MarkRefundForUser(ctx context.Context, userID, incomeID, expenseID string, version int64) (*models.Transaction, error)
+	UnmarkRefundForUser(ctx context.Context, userID, incomeID string, version int64) (*models.Transaction, error)
 }
 
 type transactionService struct {
 	repo       repository.TransactionRepository
 	walletRepo repository.WalletRepository
+	jarRepo    repository.JarRepository
+	tagRepo    repository.TagRepository
+	// attachments, when set, removes the files of deleted transactions.
+	attachments AttachmentService
 }
 
-func NewTransactionService(repo repository.TransactionRepository, walletRepo repository.WalletRepository) TransactionService {
+func NewTransactionService(repo repository.TransactionRepository, walletRepo repository.WalletRepository, jarRepo repository.JarRepository, tagRepo repository.TagRepository, attachments AttachmentService) TransactionService {
 	return &transactionService{
-		repo:       repo,
-		walletRepo: walletRepo,
+		repo:        repo,
+		walletRepo:  walletRepo,
+		jarRepo:     jarRepo,
+		tagRepo:     tagRepo,
+		attachments: attachments,
+	}
+}
+
+func (s *transactionService) CreateTransfer(ctx context.Context, req models.TransferRequest) (*models.Transfer, error) {
+	return s.CreateTransferForUser(ctx, "", req)
+}
+
+func (s *transactionService) CreateTransferForUser(ctx context.Context, userID string, req models.TransferRequest) (*models.Transfer, error) {
+	if err := s.validateTransfer(userID, &req); err != nil {
+		return nil, err
+	}
+
+	transfer := buildTransfer(userID, req, uuid.New().String(), uuid.New().String(), uuid.New().String())
+	err := s.repo.CreateTransfer(ctx, transfer.ExpenseTransaction, transfer.IncomeTransaction, transfer.FeeTransaction)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to create transfer: %w", err)
 	}
+	return transfer, nil
 }
 
-func (s *transactionService) CreateTransfer(fromWalletID, toWalletID string, amount float64, date time.Time, notes string) (*models.Transaction, *models.Transaction, error) {
-	return s.CreateTransferForUser("", fromWalletID, toWalletID, amount, date, notes)
+// GetTransferForUser accepts the ID of either leg or of the fee.
+func (s *transactionService) GetTransferForUser(ctx context.Context, userID, id string) (*models.Transfer, error) {
+	transfer, err := s.repo.GetTransferForUser(userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to load transfer: %w", err)
+	}
+	if transfer == nil {
+		return nil, ErrTransferNotFound
+	}
+	transfer.Rate = transferRate(transfer.ExpenseTransaction.Amount.Abs(), transfer.IncomeTransaction.Amount.Abs())
+	return transfer, nil
 }
 
-func (s *transactionService) CreateTransferForUser(userID, fromWalletID, toWalletID string, amount float64, date time.Time, notes string) (*models.Transaction, *models.Transaction, error) {
-	// 0. Verify Wallets exist (Handling Sync Conflicts)
+// UpdateTransferForUser applies patch to the current transfer and rewrites
+// both legs and the fee together, so a transfer is never left half-edited.
+func (s *transactionService) UpdateTransferForUser(ctx context.Context, userID, id string, patch models.TransferPatch, version int64) (*models.Transfer, error) {
+	existing, err := s.GetTransferForUser(ctx, userID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	req := models.TransferRequest{
+		FromWalletID:      existing.ExpenseTransaction.WalletID,
+		ToWalletID:        existing.IncomeTransaction.WalletID,
+		SourceAmount:      existing.ExpenseTransaction.Amount.Abs(),
+		DestinationAmount: existing.IncomeTransaction.Amount.Abs(),
+		Date:              existing.ExpenseTransaction.Date,
+		Notes:             existing.ExpenseTransaction.Description,
+	}
+	if existing.FeeTransaction != nil {
+		req.FeeAmount = existing.FeeTransaction.Amount.Abs()
+		req.FeeJarID = existing.FeeTransaction.JarID
+	}
+	if patch.FromWalletID != nil {
+		req.FromWalletID = *patch.FromWalletID
+	}
+	if patch.ToWalletID != nil {
+		req.ToWalletID = *patch.ToWalletID
+	}
+	if patch.SourceAmount != nil {
+		req.SourceAmount = *patch.SourceAmount
+		// A transfer that moved the same amount keeps doing so; otherwise the
+		// destination amount stays and the implied rate changes.
+		if patch.DestinationAmount == nil && req.DestinationAmount == existing.ExpenseTransaction.Amount.Abs() {
+			req.DestinationAmount = 0
+		}
+	}
+	if patch.DestinationAmount != nil {
+		req.DestinationAmount = *patch.DestinationAmount
+	}
+	if patch.Date != nil {
+		req.Date = *patch.Date
+	}
+	if patch.Notes != nil {
+		req.Notes = *patch.Notes
+	}
+	if patch.FeeAmount != nil {
+		req.FeeAmount = *patch.FeeAmount
+	}
+	if patch.FeeJarID != nil {
+		req.FeeJarID = *patch.FeeJarID
+	}
+
+	if err := s.validateTransfer(userID, &req); err != nil {
+		return nil, err
+	}
+
+	// An existing fee is edited in place rather than replaced.
+	feeID := uuid.New().String()
+	if existing.FeeTransaction != nil {
+		feeID = existing.FeeTransaction.ID
+	}
+	transfer := buildTransfer(existing.ExpenseTransaction.UserID, req, existing.ExpenseTransaction.ID, existing.IncomeTransaction.ID, feeID)
+	transfer.ExpenseTransaction.Version = version
+	if err := s.repo.UpdateTransfer(ctx, transfer.ExpenseTransaction, transfer.IncomeTransaction, transfer.FeeTransaction); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTransferNotFound
+		}
+		return nil, fmt.Errorf("service: failed to update transfer: %w", err)
+	}
+	if existing.FeeTransaction != nil && transfer.FeeTransaction == nil {
+		s.deleteAttachments(ctx, userID, existing.FeeTransaction.ID)
+	}
+	return s.GetTransferForUser(ctx, userID, transfer.ID)
+}
+
+// DeleteTransferForUser removes both legs and the fee together.
+func (s *transactionService) DeleteTransferForUser(ctx context.Context, userID, id string, version int64) error {
+	transfer, err := s.GetTransferForUser(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if err := s.repo.DeleteTransferForUser(ctx, userID, transfer.ID, version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTransferNotFound
+		}
+		return fmt.Errorf("service: failed to delete transfer: %w", err)
+	}
+	deleted := []string{transfer.ExpenseTransaction.ID, transfer.IncomeTransaction.ID}
+	if transfer.FeeTransaction != nil {
+		deleted = append(deleted, transfer.FeeTransaction.ID)
+	}
+	s.deleteAttachments(ctx, userID, deleted...)
+	return nil
+}
+
+// validateTransfer checks that both wallets exist (they may have been deleted
+// on another device) and that the amounts and fee are consistent. It fills in
+// a missing destination amount.
+func (s *transactionService) validateTransfer(userID string, req *models.TransferRequest) error {
 	var (
 		fromWallet *models.Wallet
 		toWallet   *models.Wallet
 		err        error
 	)
 
+	// Both legs in one wallet would cancel out and leave only the fee.
+	if req.FromWalletID == req.ToWalletID {
+		return fmt.Errorf("%w: source and target wallets must differ", ErrInvalidTransfer)
+	}
+
 	if userID != "" {
-		fromWallet, err = s.walletRepo.GetForUser(userID, fromWalletID)
+		fromWallet, err = s.walletRepo.GetForUser(userID, req.FromWalletID)
 	} else {
-		fromWallet, err = s.walletRepo.Get(fromWalletID)
+		fromWallet, err = s.walletRepo.Get(req.FromWalletID)
 	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("service: failed to check source wallet: %w", err)
+		return fmt.Errorf("service: failed to check source wallet: %w", err)
 	}
 	if fromWallet == nil {
-		return nil, nil, fmt.Errorf("source wallet %s does not exist", fromWalletID)
+		return fmt.Errorf("%w: source wallet %s does not exist", ErrInvalidTransfer, req.FromWalletID)
 	}
 
 	if userID != "" {
-		toWallet, err = s.walletRepo.GetForUser(userID, toWalletID)
+		toWallet, err = s.walletRepo.GetForUser(userID, req.ToWalletID)
 	} else {
-		toWallet, err = s.walletRepo.Get(toWalletID)
+		toWallet, err = s.walletRepo.Get(req.ToWalletID)
 	}
 	if err != nil {
-		return nil, nil, fmt.Errorf("service: failed to check target wallet: %w", err)
+		return fmt.Errorf("service: failed to check target wallet: %w", err)
 	}
 	if toWallet == nil {
-		return nil, nil, fmt.Errorf("target wallet %s does not exist (it might have been deleted on another device)", toWalletID)
+		return fmt.Errorf("%w: target wallet %s does not exist (it might have been deleted on another device)", ErrInvalidTransfer, req.ToWalletID)
 	}
 
-	// 1. Generate IDs
-	expenseID := uuid.New().String()
-	incomeID := uuid.New().String()
+	// Wallets in the same currency must receive exactly what was sent;
+	// otherwise the destination amount implies the rate.
+	if req.SourceAmount <= 0 {
+		return fmt.Errorf("%w: source amount must be positive", ErrInvalidTransfer)
+	}
+	if req.DestinationAmount == 0 {
+		req.DestinationAmount = req.SourceAmount
+	}
+	if req.DestinationAmount < 0 {
+		return fmt.Errorf("%w: destination amount must be positive", ErrInvalidTransfer)
+	}
+	if walletCurrency(fromWallet) == walletCurrency(toWallet) && req.DestinationAmount != req.SourceAmount {
+		return fmt.Errorf("%w: destination amount must equal source amount for wallets in the same currency", ErrInvalidTransfer)
+	}
+	if req.Date.IsZero() {
+		return fmt.Errorf("%w: date is required", ErrInvalidTransfer)
+	}
+	if req.FeeAmount < 0 {
+		return fmt.Errorf("%w: fee must not be negative", ErrInvalidTransfer)
+	}
+	if req.FeeAmount > 0 {
+		if req.FeeJarID == "" {
+			return fmt.Errorf("%w: fee_jar_id is required when a fee is charged", ErrInvalidTransfer)
+		}
+		if err := checkTransactionTarget(context.Background(), s.walletRepo, s.jarRepo, userID, fromWallet.ID, req.FeeJarID, "expense", ErrInvalidTransfer); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// 2. Create Objects
+// buildTransfer lays out the rows of a validated transfer. The legs are
+// signed "transfer" rows so analytics do not count them as income or expense;
+// they point at each other and the fee, stored under feeID when one is charged
+// and a real expense, points at the outgoing leg.
+func buildTransfer(userID string, req models.TransferRequest, expenseID, incomeID, feeID string) *models.Transfer {
 	expense := &models.Transaction{
 		ID:                   expenseID,
 		UserID:               userID,
-		Amount:               -amount, // Expense is negative
-		Type:                 "expense",
-		WalletID:             fromWalletID,
-		Date:                 date,
-		Description:          notes,
+		Amount:               -req.SourceAmount, // Outgoing leg is negative
+		Type:                 "transfer",
+		WalletID:             req.FromWalletID,
+		Date:                 req.Date,
+		Description:          req.Notes,
 		RelatedTransactionID: &incomeID,
 	}
 
 	income := &models.Transaction{
 		ID:                   incomeID,
 		UserID:               userID,
-		Amount:               amount, // Income is positive
-		Type:                 "income",
-		WalletID:             toWalletID,
-		Date:                 date,
-		Description:          notes,
+		Amount:               req.DestinationAmount, // Incoming leg is positive
+		Type:                 "transfer",
+		WalletID:             req.ToWalletID,
+		Date:                 req.Date,
+		Description:          req.Notes,
 		RelatedTransactionID: &expenseID,
 	}
 
-	// 3. Persist Atomic
-	err = s.repo.CreateTransfer(expense, income)
-	if err != nil {
-		return nil, nil, fmt.Errorf("service: failed to create transfer: %w", err)
+	var fee *models.Transaction
+	if req.FeeAmount > 0 {
+		description := "Transfer fee"
+		if req.Notes != "" {
+			description += ": " + req.Notes
+		}
+		fee = &models.Transaction{
+			ID:                   feeID,
+			UserID:               userID,
+			Amount:               req.FeeAmount,
+			Type:                 "expense",
+			WalletID:             req.FromWalletID,
+			JarID:                req.FeeJarID,
+			Date:                 req.Date,
+			Description:          description,
+			RelatedTransactionID: &expenseID,
+		}
+	}
+
+	return &models.Transfer{
+		ID:                 expenseID,
+		ExpenseTransaction: expense,
+		IncomeTransaction:  income,
+		FeeTransaction:     fee,
+		Rate:               transferRate(req.SourceAmount, req.DestinationAmount),
+	}
+}
+
+// transferRate is the number of destination units received per source unit.
+func transferRate(source, destination models.Money) float64 {
+	if source == 0 {
+		return 0
 	}
+	return float64(destination) / float64(source)
+}
 
-	return expense, income, nil
+// walletCurrency treats wallets created before currencies were tracked as
+// being in the default base currency.
+func walletCurrency(wallet *models.Wallet) string {
+	if wallet.Currency == "" {
+		return models.DefaultBaseCurrency
+	}
+	return wallet.Currency
 }
 
 func (s *transactionService) ListForUser(userID string) ([]models.Transaction, error) {
 	return s.repo.ListAllForUser(userID)
 }
+
+func (s *transactionService) ListPageForUser(ctx context.Context, userID string, filter models.TransactionListFilter) (*models.TransactionPage, error) {
+	switch filter.SortBy {
+	case "":
+		filter.SortBy = "date"
+	case "date", "amount":
+	default:
+		return nil, fmt.Errorf("%w: sort must be date or amount", ErrInvalidTransactionFilter)
+	}
+	switch filter.SortOrder {
+	case "":
+		filter.SortOrder = "desc"
+	case "asc", "desc":
+	default:
+		return nil, fmt.Errorf("%w: order must be asc or desc", ErrInvalidTransactionFilter)
+	}
+	for _, txType := range filter.Types {
+		if txType != "income" && txType != "expense" && txType != "transfer" {
+			return nil, fmt.Errorf("%w: unknown type %q", ErrInvalidTransactionFilter, txType)
+		}
+	}
+	if filter.MinAmount != nil && filter.MaxAmount != nil && *filter.MinAmount > *filter.MaxAmount {
+		return nil, fmt.Errorf("%w: min_amount must not exceed max_amount", ErrInvalidTransactionFilter)
+	}
+	if !filter.StartDate.IsZero() && !filter.EndDate.IsZero() && filter.EndDate.Before(filter.StartDate) {
+		return nil, fmt.Errorf("%w: end_date must be after start_date", ErrInvalidTransactionFilter)
+	}
+	if filter.Limit < 0 || filter.Limit > maxTransactionPageSize {
+		return nil, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidTransactionFilter, maxTransactionPageSize)
+	}
+
+	page, err := s.repo.ListPageForUser(userID, filter)
+	if err != nil {
+		if errors.Is(err, repository.ErrInvalidCursor) {
+			return nil, fmt.Errorf("%w: %v", ErrInvalidTransactionFilter, err)
+		}
+		return nil, fmt.Errorf("service: failed to list transactions: %w", err)
+	}
+	return page, nil
+}
+
+func (s *transactionService) ListFilteredForUser(ctx context.Context, userID string, filter models.TransactionListFilter) ([]models.Transaction, error) {
+	filter.Limit = maxTransactionPageSize
+	filter.Cursor = ""
+	transactions := []models.Transaction{}
+	for {
+		page, err := s.ListPageForUser(ctx, userID, filter)
+		if err != nil {
+			return nil, err
+		}
+		transactions = append(transactions, page.Transactions...)
+		if !page.HasMore {
+			return transactions, nil
+		}
+		filter.Cursor = page.NextCursor
+	}
+}
+
+func (s *transactionService) GetForUser(ctx context.Context, userID, id string) (*models.Transaction, error) {
+	tx, err := s.repo.GetByIDForUser(userID, id)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to load transaction: %w", err)
+	}
+	if tx == nil {
+		return nil, ErrTransactionNotFound
+	}
+	return tx, nil
+}
+
+// CreateForUser records a single income or expense. Amounts are stored as
+// positive magnitudes; the type decides the direction of the money flow. An
+// ID is generated unless the caller, such as an offline client, chose one.
+func (s *transactionService) CreateForUser(ctx context.Context, userID string, tx *models.Transaction) (*models.Transaction, error) {
+	if tx.ID == "" {
+		tx.ID = uuid.New().String()
+	}
+	tx.UserID = normalizedServiceUserID(userID)
+	tx.RelatedTransactionID = nil
+	if err := s.validateTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, tx); err != nil {
+		return nil, fmt.Errorf("service: failed to create transaction: %w", err)
+	}
+	return tx, nil
+}
+
+func (s *transactionService) UpdateForUser(ctx context.Context, userID string, tx *models.Transaction) (*models.Transaction, error) {
+	existing, err := s.GetForUser(ctx, userID, tx.ID)
+	if err != nil {
+		return nil, err
+	}
+	if existing.RelatedTransactionID != nil && !existing.IsRefund() {
+		return nil, ErrTransactionLinked
+	}
+
+	tx.UserID = existing.UserID
+	tx.RelatedTransactionID = nil
+	if err := s.validateTransaction(ctx, tx); err != nil {
+		return nil, err
+	}
+	if existing.IsRefund() {
+		if tx.Type != "income" {
+			return nil, fmt.Errorf("%w: a refund must stay an income", ErrInvalidTransaction)
+		}
+		tx.RelatedTransactionID = existing.RelatedTransactionID
+		if err := s.checkRefund(ctx, tx, *existing.RelatedTransactionID); err != nil {
+			return nil, err
+		}
+	}
+	if existing.Type == "expense" {
+		refunded, err := s.repo.RefundedAmountForUser(tx.UserID, tx.ID, "")
+		if err != nil {
+			return nil, fmt.Errorf("service: failed to load refunds: %w", err)
+		}
+		if refunded > 0 && (tx.Type != "expense" || tx.Amount < refunded) {
+			return nil, fmt.Errorf("%w: %s of this expense has been refunded", ErrInvalidTransaction, refunded)
+		}
+	}
+
+	if err := s.repo.Update(ctx, tx); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTransactionNotFound
+		}
+		return nil, fmt.Errorf("service: failed to update transaction: %w", err)
+	}
+	return tx, nil
+}
+
+// DeleteForUser removes a single income or expense. Transfer legs and fees
+// are deleted through DeleteTransferForUser so both legs go together. Refunds
+// of a deleted expense are kept as plain income.
+func (s *transactionService) DeleteForUser(ctx context.Context, userID, id string, version int64) error {
+	existing, err := s.GetForUser(ctx, userID, id)
+	if err != nil {
+		return err
+	}
+	if existing.RelatedTransactionID != nil && !existing.IsRefund() {
+		return ErrTransactionLinked
+	}
+	if err := s.repo.DeleteForUser(ctx, userID, id, version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrTransactionNotFound
+		}
+		return fmt.Errorf("service: failed to delete transaction: %w", err)
+	}
+	s.deleteAttachments(ctx, userID, id)
+	return nil
+}
+
+// deleteAttachments removes the attachments of the transactions just deleted.
+// They are already gone, so a failure is only logged; the background sweep
+// retries it.
+func (s *transactionService) deleteAttachments(ctx context.Context, userID string, transactionIDs ...string) {
+	if s.attachments == nil {
+		return
+	}
+	if _, err := s.attachments.DeleteForTransactions(ctx, userID, transactionIDs...); err != nil {
+		log.Printf("transactions: %v", err)
+	}
+}
+
+// MarkRefundForUser records an income as a partial or full refund of an
+// expense. Reports then net it against the expense's jar and month.
+func (s *transactionService) MarkRefundForUser(ctx context.Context, userID, incomeID, expenseID string, version int64) (*models.Transaction, error) {
+	income, err := s.GetForUser(ctx, userID, incomeID)
+	if err != nil {
+		return nil, err
+	}
+	if income.Type != "income" {
+		return nil, fmt.Errorf("%w: only an income can be a refund", ErrInvalidTransaction)
+	}
+	if err := s.checkRefund(ctx, income, expenseID); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetRefundOfForUser(ctx, userID, incomeID, &expenseID, version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTransactionNotFound
+		}
+		return nil, fmt.Errorf("service: failed to mark refund: %w", err)
+	}
+	return s.GetForUser(ctx, userID, incomeID)
+}
+
+// UnmarkRefundForUser turns a refund back into a plain income.
+func (s *transactionService) UnmarkRefundForUser(ctx context.Context, userID, incomeID string, version int64) (*models.Transaction, error) {
+	income, err := s.GetForUser(ctx, userID, incomeID)
+	if err != nil {
+		return nil, err
+	}
+	if !income.IsRefund() {
+		return nil, fmt.Errorf("%w: transaction is not a refund", ErrInvalidTransaction)
+	}
+
+	if err := s.repo.SetRefundOfForUser(ctx, userID, incomeID, nil, version); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrTransactionNotFound
+		}
+		return nil, fmt.Errorf("service: failed to unmark refund: %w", err)
+	}
+	return s.GetForUser(ctx, userID, incomeID)
+}
+
+// checkRefund verifies that refund can be linked to expenseID: the target is
+// an expense in the same currency and its refunds do not add up to more than
+// was spent.
+func (s *transactionService) checkRefund(ctx context.Context, refund *models.Transaction, expenseID string) error {
+	expense, err := s.repo.GetByIDForUser(refund.UserID, expenseID)
+	if err != nil {
+		return fmt.Errorf("service: failed to load expense: %w", err)
+	}
+	if expense == nil {
+		return fmt.Errorf("%w: expense %s does not exist", ErrInvalidTransaction, expenseID)
+	}
+	if expense.Type != "expense" {
+		return fmt.Errorf("%w: only an expense can be refunded", ErrInvalidTransaction)
+	}
+
+	wallet, err := s.walletRepo.GetForUser(refund.UserID, refund.WalletID)
+	if err != nil {
+		return fmt.Errorf("service: failed to check wallet: %w", err)
+	}
+	if wallet == nil {
+		return fmt.Errorf("%w: wallet %s does not exist", ErrInvalidTransaction, refund.WalletID)
+	}
+	expenseCurrency := expense.Currency
+	if expenseCurrency == "" {
+		expenseCurrency = models.DefaultBaseCurrency
+	}
+	if walletCurrency(wallet) != expenseCurrency {
+		return fmt.Errorf("%w: a refund must be in the currency of the expense (%s)", ErrInvalidTransaction, expenseCurrency)
+	}
+
+	refunded, err := s.repo.RefundedAmountForUser(refund.UserID, expenseID, refund.ID)
+	if err != nil {
+		return fmt.Errorf("service: failed to load refunds: %w", err)
+	}
+	if refunded+refund.Amount.Abs() > expense.Amount.Abs() {
+		return fmt.Errorf("%w: refunds would exceed the expense of %s (%s already refunded)", ErrInvalidTransaction, expense.Amount.Abs(), refunded)
+	}
+	return nil
+}
+
+func (s *transactionService) validateTransaction(ctx context.Context, tx *models.Transaction) error {
+	if tx.Type != "income" && tx.Type != "expense" {
+		return fmt.Errorf("%w: type must be income or expense", ErrInvalidTransaction)
+	}
+	if tx.Amount <= 0 {
+		return fmt.Errorf("%w: amount must be greater than zero", ErrInvalidTransaction)
+	}
+	if tx.Date.IsZero() {
+		return fmt.Errorf("%w: date is required", ErrInvalidTransaction)
+	}
+	if tx.WalletID == "" {
+		return fmt.Errorf("%w: wallet_id is required", ErrInvalidTransaction)
+	}
+	if len(tx.Splits) > 0 {
+		if err := s.validateSplits(ctx, tx); err != nil {
+			return err
+		}
+	} else if err := checkTransactionTarget(ctx, s.walletRepo, s.jarRepo, tx.UserID, tx.WalletID, tx.JarID, tx.Type, ErrInvalidTransaction); err != nil {
+		return err
+	}
+
+	return s.resolveTags(ctx, tx)
+}
+
+// resolveTags replaces the tag references in tx with the user's stored
+// tags, dropping duplicates. Every tag must belong to the user.
+func (s *transactionService) resolveTags(ctx context.Context, tx *models.Transaction) error {
+	if len(tx.Tags) == 0 {
+		tx.Tags = nil
+		return nil
+	}
+
+	tags, err := s.tagRepo.ListForUser(ctx, tx.UserID)
+	if err != nil {
+		return fmt.Errorf("service: failed to load tags: %w", err)
+	}
+	byID := make(map[string]models.Tag, len(tags))
+	for _, tag := range tags {
+		byID[tag.ID] = tag
+	}
+
+	resolved := make([]models.Tag, 0, len(tx.Tags))
+	seen := make(map[string]struct{}, len(tx.Tags))
+	for _, ref := range tx.Tags {
+		tag, ok := byID[ref.ID]
+		if !ok {
+			return fmt.Errorf("%w: tag %q not found", ErrInvalidTransaction, ref.ID)
+		}
+		if _, dup := seen[tag.ID]; dup {
+			continue
+		}
+		seen[tag.ID] = struct{}{}
+		resolved = append(resolved, tag)
+	}
+	tx.Tags = resolved
+	return nil
+}
+
+// validateSplits checks that a split transaction spreads its whole amount
+// over at least two jars, and gives every line a fresh ID.
+func (s *transactionService) validateSplits(ctx context.Context, tx *models.Transaction) error {
+	if len(tx.Splits) < 2 {
+		return fmt.Errorf("%w: a split needs at least two lines", ErrInvalidTransaction)
+	}
+	if tx.JarID != "" {
+		return fmt.Errorf("%w: jar_id must be empty when splits are given", ErrInvalidTransaction)
+	}
+
+	var total models.Money
+	for i := range tx.Splits {
+		split := &tx.Splits[i]
+		if split.Amount <= 0 {
+			return fmt.Errorf("%w: split amounts must be greater than zero", ErrInvalidTransaction)
+		}
+		if split.JarID == "" {
+			return fmt.Errorf("%w: every split needs a jar_id", ErrInvalidTransaction)
+		}
+		if err := checkTransactionTarget(ctx, s.walletRepo, s.jarRepo, tx.UserID, tx.WalletID, split.JarID, tx.Type, ErrInvalidTransaction); err != nil {
+			return err
+		}
+		split.ID = uuid.New().String()
+		total += split.Amount
+	}
+	if total != tx.Amount {
+		return fmt.Errorf("%w: splits add up to %s but the amount is %s", ErrInvalidTransaction, total, tx.Amount)
+	}
+	return nil
+}
+
+// checkTransactionTarget verifies that the wallet (and optional jar) belong to
+// the user and that the jar accepts transactions of txType. Validation
+// failures are wrapped in invalidErr.
+func checkTransactionTarget(ctx context.Context, walletRepo repository.WalletRepository, jarRepo repository.JarRepository, userID, walletID, jarID, txType string, invalidErr error) error {
+	wallet, err := walletRepo.GetForUser(userID, walletID)
+	if err != nil {
+		return fmt.Errorf("service: failed to check wallet: %w", err)
+	}
+	if wallet == nil {
+		return fmt.Errorf("%w: wallet %s does not exist", invalidErr, walletID)
+	}
+
+	if jarID == "" {
+		return nil
+	}
+	jar, err := jarRepo.GetForUser(ctx, userID, jarID)
+	if err != nil {
+		return fmt.Errorf("service: failed to check jar: %w", err)
+	}
+	if jar == nil {
+		return fmt.Errorf("%w: jar %s does not exist", invalidErr, jarID)
+	}
+	// Jars seeded as generic "jar" accept both directions; typed categories
+	// imported from Money Manager only accept their own type.
+	if (jar.Type == "income" || jar.Type == "expense") && jar.Type != txType {
+		return fmt.Errorf("%w: jar %s only accepts %s transactions", invalidErr, jarID, jar.Type)
+	}
+	return nil
+}
@@ -0,0 +1,425 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"testing"
+	"time"
+)
+
+func setupTransactionService(t *testing.T) (TransactionService, repository.TransactionRepository) {
+	t.Helper()
+
+	dbConn, err := db.InitDB(":memory:")
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	t.Cleanup(func() { dbConn.Close() })
+
+	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	jarRepo := repository.NewSQLiteJarRepository(dbConn)
+
+	if err := walletRepo.Create(context.Background(), &models.Wallet{ID: "wallet-1", UserID: "user-1", Name: "Cash", Currency: "THB"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	if err := walletRepo.Create(context.Background(), &models.Wallet{ID: "wallet-usd", UserID: "user-1", Name: "Travel", Currency: "USD"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	if err := walletRepo.Create(context.Background(), &models.Wallet{ID: "wallet-savings", UserID: "user-1", Name: "Savings", Currency: "THB"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	if err := walletRepo.Create(context.Background(), &models.Wallet{ID: "wallet-other", UserID: "user-2", Name: "Other", Currency: "THB"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	jars := []struct{ id, userID, jarType string }{
+		{"jar-food", "user-1", "expense"},
+		{"jar-salary", "user-1", "income"},
+		{"jar-play", "user-1", "jar"},
+	}
+	for _, j := range jars {
+		if _, err := dbConn.Exec("INSERT INTO jars (id, user_id, name, type) VALUES (?, ?, ?, ?)", j.id, j.userID, j.id, j.jarType); err != nil {
+			t.Fatalf("failed to create jar: %v", err)
+		}
+	}
+
+	return NewTransactionService(txRepo, walletRepo, jarRepo, repository.NewSQLiteTagRepository(dbConn), nil), txRepo
+}
+
+func TestTransactionService_CreateUpdateDelete(t *testing.T) {
+	svc, txRepo := setupTransactionService(t)
+	ctx := context.Background()
+
+	created, err := svc.CreateForUser(ctx, "user-1", &models.Transaction{
+		Amount:      120,
+		Description: "Lunch",
+		Date:        time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC),
+		Type:        "expense",
+		WalletID:    "wallet-1",
+		JarID:       "jar-food",
+	})
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+	if created.ID == "" {
+		t.Fatal("expected generated ID")
+	}
+
+	created.Amount = 150
+	created.Description = "Lunch with friends"
+	if _, err := svc.UpdateForUser(ctx, "user-1", created); err != nil {
+		t.Fatalf("UpdateForUser failed: %v", err)
+	}
+
+	saved, err := txRepo.GetByIDForUser("user-1", created.ID)
+	if err != nil || saved == nil {
+		t.Fatalf("failed to reload transaction: %v", err)
+	}
+	if saved.Amount != 150 || saved.Description != "Lunch with friends" {
+		t.Errorf("update not persisted: %+v", saved)
+	}
+
+	if err := svc.DeleteForUser(ctx, "user-1", created.ID, saved.Version-1); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Errorf("expected a stale version to be refused, got %v", err)
+	}
+	if err := svc.DeleteForUser(ctx, "user-1", created.ID, saved.Version); err != nil {
+		t.Fatalf("DeleteForUser failed: %v", err)
+	}
+	if _, err := svc.GetForUser(ctx, "user-1", created.ID); !errors.Is(err, ErrTransactionNotFound) {
+		t.Errorf("expected ErrTransactionNotFound after delete, got %v", err)
+	}
+}
+
+func TestTransactionService_CreateValidation(t *testing.T) {
+	svc, _ := setupTransactionService(t)
+	date := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	cases := []struct {
+		name string
+		tx   models.Transaction
+	}{
+		{"negative amount", models.Transaction{Amount: -10, Date: date, Type: "expense", WalletID: "wallet-1"}},
+		{"transfer type", models.Transaction{Amount: 10, Date: date, Type: "transfer", WalletID: "wallet-1"}},
+		{"foreign wallet", models.Transaction{Amount: 10, Date: date, Type: "expense", WalletID: "wallet-other"}},
+		{"unknown jar", models.Transaction{Amount: 10, Date: date, Type: "expense", WalletID: "wallet-1", JarID: "jar-missing"}},
+		{"jar type mismatch", models.Transaction{Amount: 10, Date: date, Type: "expense", WalletID: "wallet-1", JarID: "jar-salary"}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tx := tc.tx
+			if _, err := svc.CreateForUser(context.Background(), "user-1", &tx); !errors.Is(err, ErrInvalidTransaction) {
+				t.Errorf("expected ErrInvalidTransaction, got %v", err)
+			}
+		})
+	}
+
+	generic := models.Transaction{Amount: 10, Date: date, Type: "income", WalletID: "wallet-1", JarID: "jar-play"}
+	if _, err := svc.CreateForUser(context.Background(), "user-1", &generic); err != nil {
+		t.Errorf("expected generic jar to accept income, got %v", err)
+	}
+}
+
+func TestTransactionService_UpdateRejectsTransferLeg(t *testing.T) {
+	svc, _ := setupTransactionService(t)
+	ctx := context.Background()
+
+	if _, err := svc.CreateForUser(ctx, "user-1", &models.Transaction{
+		Amount: 1, Date: time.Now(), Type: "income", WalletID: "wallet-1",
+	}); err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+
+	transfer, err := svc.CreateTransferForUser(ctx, "user-1", models.TransferRequest{
+		FromWalletID: "wallet-1", ToWalletID: "wallet-savings", SourceAmount: 50, Date: time.Now(), Notes: "Move",
+	})
+	if err != nil {
+		t.Fatalf("CreateTransferForUser failed: %v", err)
+	}
+	expense := transfer.ExpenseTransaction
+
+	expense.Amount = 60
+	expense.Type = "expense"
+	if _, err := svc.UpdateForUser(ctx, "user-1", expense); !errors.Is(err, ErrTransactionLinked) {
+		t.Errorf("expected ErrTransactionLinked, got %v", err)
+	}
+}
+
+func TestTransactionService_CrossCurrencyTransferWithFee(t *testing.T) {
+	svc, txRepo := setupTransactionService(t)
+	ctx := context.Background()
+
+	transfer, err := svc.CreateTransferForUser(ctx, "user-1", models.TransferRequest{
+		FromWalletID:      "wallet-1",
+		ToWalletID:        "wallet-usd",
+		SourceAmount:      350000, // 3,500.00 THB
+		DestinationAmount: 10000,  // 100.00 USD
+		FeeAmount:         2500,
+		FeeJarID:          "jar-food",
+		Date:              time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC),
+		Notes:             "Trip",
+	})
+	if err != nil {
+		t.Fatalf("CreateTransferForUser failed: %v", err)
+	}
+	if transfer.Rate != float64(10000)/350000 {
+		t.Errorf("unexpected rate %v", transfer.Rate)
+	}
+
+	fee, err := svc.GetForUser(ctx, "user-1", transfer.FeeTransaction.ID)
+	if err != nil || fee == nil {
+		t.Fatalf("expected the fee to be stored, got %v, %v", fee, err)
+	}
+	if fee.Amount != 2500 || fee.JarID != "jar-food" || fee.WalletID != "wallet-1" || fee.RelatedTransactionID == nil || *fee.RelatedTransactionID != transfer.ExpenseTransaction.ID {
+		t.Errorf("unexpected fee transaction %+v", fee)
+	}
+	income, err := txRepo.GetByID(transfer.IncomeTransaction.ID)
+	if err != nil || income == nil || income.Amount != 10000 || income.Type != "transfer" {
+		t.Errorf("expected 100.00 to arrive in the USD wallet, got %+v, %v", income, err)
+	}
+
+	// Deleting the outgoing leg must not trip over the fee's link to it.
+	if err := svc.DeleteTransferForUser(ctx, "user-1", transfer.ExpenseTransaction.ID, 0); err != nil {
+		t.Fatalf("DeleteTransferForUser failed: %v", err)
+	}
+	if fee, _ := txRepo.GetByID(transfer.FeeTransaction.ID); fee != nil {
+		t.Errorf("expected the fee to go with the transfer, got %+v", fee)
+	}
+}
+
+func TestTransactionService_CreateTransferValidation(t *testing.T) {
+	svc, _ := setupTransactionService(t)
+
+	cases := []models.TransferRequest{
+		{FromWalletID: "wallet-1", ToWalletID: "wallet-usd", SourceAmount: 0},
+		{FromWalletID: "wallet-1", ToWalletID: "wallet-savings", SourceAmount: 100, DestinationAmount: 90},
+		{FromWalletID: "wallet-1", ToWalletID: "wallet-1", SourceAmount: 100, Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{FromWalletID: "wallet-1", ToWalletID: "wallet-usd", SourceAmount: 100, FeeAmount: 5},
+		{FromWalletID: "wallet-1", ToWalletID: "wallet-usd", SourceAmount: 100, FeeAmount: 5, FeeJarID: "jar-salary"},
+		{FromWalletID: "wallet-1", ToWalletID: "wallet-other", SourceAmount: 100},
+	}
+	for i, req := range cases {
+		if _, err := svc.CreateTransferForUser(context.Background(), "user-1", req); !errors.Is(err, ErrInvalidTransfer) {
+			t.Errorf("case %d: expected ErrInvalidTransfer, got %v", i, err)
+		}
+	}
+}
+
+func TestTransactionService_UpdateAndDeleteTransfer(t *testing.T) {
+	svc, txRepo := setupTransactionService(t)
+	ctx := context.Background()
+
+	created, err := svc.CreateTransferForUser(ctx, "user-1", models.TransferRequest{
+		FromWalletID: "wallet-1", ToWalletID: "wallet-usd", SourceAmount: 35000, DestinationAmount: 1000,
+		FeeAmount: 100, FeeJarID: "jar-food", Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Notes: "Trip",
+	})
+	if err != nil {
+		t.Fatalf("CreateTransferForUser failed: %v", err)
+	}
+
+	// Any row of the transfer resolves to the whole transfer.
+	for _, id := range []string{created.IncomeTransaction.ID, created.FeeTransaction.ID} {
+		got, err := svc.GetTransferForUser(ctx, "user-1", id)
+		if err != nil || got.ID != created.ID || got.FeeTransaction == nil || got.Rate != created.Rate {
+			t.Fatalf("GetTransferForUser(%s) = %+v, %v", id, got, err)
+		}
+	}
+
+	// Half of a transfer cannot be changed through the single-transaction API.
+	if err := svc.DeleteForUser(ctx, "user-1", created.IncomeTransaction.ID, 0); !errors.Is(err, ErrTransactionLinked) {
+		t.Errorf("expected ErrTransactionLinked, got %v", err)
+	}
+
+	// Editing the fee keeps the fee transaction, so its tags and attachments
+	// stay with it.
+	higherFee := models.Money(150)
+	repriced, err := svc.UpdateTransferForUser(ctx, "user-1", created.ID, models.TransferPatch{FeeAmount: &higherFee}, 0)
+	if err != nil {
+		t.Fatalf("UpdateTransferForUser failed: %v", err)
+	}
+	if repriced.FeeTransaction == nil || repriced.FeeTransaction.ID != created.FeeTransaction.ID || repriced.FeeTransaction.Amount != 150 {
+		t.Errorf("expected the fee to be updated in place, got %+v", repriced.FeeTransaction)
+	}
+
+	destination, noFee, notes := models.Money(1100), models.Money(0), "Trip (rebooked)"
+	updated, err := svc.UpdateTransferForUser(ctx, "user-1", created.IncomeTransaction.ID, models.TransferPatch{
+		DestinationAmount: &destination, FeeAmount: &noFee, Notes: &notes,
+	}, 0)
+	if err != nil {
+		t.Fatalf("UpdateTransferForUser failed: %v", err)
+	}
+	if updated.FeeTransaction != nil || updated.IncomeTransaction.Amount != 1100 || updated.ExpenseTransaction.Description != notes {
+		t.Errorf("unexpected updated transfer %+v", updated)
+	}
+	if fee, _ := txRepo.GetByID(created.FeeTransaction.ID); fee != nil {
+		t.Errorf("expected the fee to be removed, got %+v", fee)
+	}
+	feeJar := "jar-food"
+	readded, err := svc.UpdateTransferForUser(ctx, "user-1", created.ID, models.TransferPatch{FeeAmount: &higherFee, FeeJarID: &feeJar}, 0)
+	if err != nil || readded.FeeTransaction == nil || readded.FeeTransaction.ID == created.FeeTransaction.ID || readded.FeeTransaction.Amount != 150 {
+		t.Errorf("expected a new fee to be added, got %+v, %v", readded, err)
+	}
+
+	sameCurrency := "wallet-savings"
+	if _, err := svc.UpdateTransferForUser(ctx, "user-1", created.ID, models.TransferPatch{ToWalletID: &sameCurrency}, 0); !errors.Is(err, ErrInvalidTransfer) {
+		t.Errorf("expected ErrInvalidTransfer for mismatched amounts, got %v", err)
+	}
+	sameWallet := "wallet-1"
+	if _, err := svc.UpdateTransferForUser(ctx, "user-1", created.ID, models.TransferPatch{ToWalletID: &sameWallet}, 0); !errors.Is(err, ErrInvalidTransfer) {
+		t.Errorf("expected ErrInvalidTransfer for a transfer into its own wallet, got %v", err)
+	}
+
+	if err := svc.DeleteTransferForUser(ctx, "user-1", created.ID, 0); err != nil {
+		t.Fatalf("DeleteTransferForUser failed: %v", err)
+	}
+	for _, id := range []string{created.ExpenseTransaction.ID, created.IncomeTransaction.ID} {
+		if leg, _ := txRepo.GetByID(id); leg != nil {
+			t.Errorf("expected transfer leg %s to be deleted", id)
+		}
+	}
+	if _, err := svc.GetTransferForUser(ctx, "user-1", created.ID); !errors.Is(err, ErrTransferNotFound) {
+		t.Errorf("expected ErrTransferNotFound, got %v", err)
+	}
+}
+
+func TestTransactionService_SplitsMustAddUp(t *testing.T) {
+	svc, _ := setupTransactionService(t)
+	ctx := context.Background()
+
+	newReceipt := func(splits ...models.TransactionSplit) *models.Transaction {
+		return &models.Transaction{
+			Amount: 1500, Date: time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC), Type: "expense", WalletID: "wallet-1", Splits: splits,
+		}
+	}
+
+	invalid := []*models.Transaction{
+		newReceipt(models.TransactionSplit{JarID: "jar-food", Amount: 1500}),
+		newReceipt(models.TransactionSplit{JarID: "jar-food", Amount: 1000}, models.TransactionSplit{JarID: "jar-play", Amount: 400}),
+		newReceipt(models.TransactionSplit{JarID: "jar-food", Amount: 1000}, models.TransactionSplit{JarID: "jar-salary", Amount: 500}),
+		newReceipt(models.TransactionSplit{JarID: "jar-food", Amount: 1600}, models.TransactionSplit{JarID: "jar-play", Amount: -100}),
+	}
+	for i, tx := range invalid {
+		if _, err := svc.CreateForUser(ctx, "user-1", tx); !errors.Is(err, ErrInvalidTransaction) {
+			t.Errorf("case %d: expected ErrInvalidTransaction, got %v", i, err)
+		}
+	}
+
+	created, err := svc.CreateForUser(ctx, "user-1", newReceipt(
+		models.TransactionSplit{JarID: "jar-food", Amount: 1000},
+		models.TransactionSplit{JarID: "jar-play", Amount: 500},
+	))
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+	stored, err := svc.GetForUser(ctx, "user-1", created.ID)
+	if err != nil || len(stored.Splits) != 2 || stored.Splits[0].ID == "" || stored.JarID != "" {
+		t.Fatalf("expected the split lines to be stored, got %+v, %v", stored, err)
+	}
+}
+
+func TestTransactionService_MarkRefund(t *testing.T) {
+	svc, _ := setupTransactionService(t)
+	ctx := context.Background()
+	date := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	create := func(tx *models.Transaction) *models.Transaction {
+		t.Helper()
+		created, err := svc.CreateForUser(ctx, "user-1", tx)
+		if err != nil {
+			t.Fatalf("CreateForUser failed: %v", err)
+		}
+		return created
+	}
+	expense := create(&models.Transaction{Amount: 1000, Date: date, Type: "expense", JarID: "jar-food", WalletID: "wallet-1"})
+	refund := create(&models.Transaction{Amount: 400, Date: date.AddDate(0, 1, 0), Type: "income", WalletID: "wallet-1"})
+	tooMuch := create(&models.Transaction{Amount: 700, Date: date.AddDate(0, 1, 0), Type: "income", WalletID: "wallet-1"})
+	dollars := create(&models.Transaction{Amount: 100, Date: date, Type: "income", WalletID: "wallet-usd"})
+
+	marked, err := svc.MarkRefundForUser(ctx, "user-1", refund.ID, expense.ID, 0)
+	if err != nil {
+		t.Fatalf("MarkRefundForUser failed: %v", err)
+	}
+	if !marked.IsRefund() || *marked.RelatedTransactionID != expense.ID {
+		t.Fatalf("expected a refund of %s, got %+v", expense.ID, marked)
+	}
+
+	// 4.00 + 7.00 refunded would exceed the 10.00 spent.
+	if _, err := svc.MarkRefundForUser(ctx, "user-1", tooMuch.ID, expense.ID, 0); !errors.Is(err, ErrInvalidTransaction) {
+		t.Errorf("expected over-refund to be rejected, got %v", err)
+	}
+	if _, err := svc.MarkRefundForUser(ctx, "user-1", dollars.ID, expense.ID, 0); !errors.Is(err, ErrInvalidTransaction) {
+		t.Errorf("expected a refund in another currency to be rejected, got %v", err)
+	}
+	if _, err := svc.MarkRefundForUser(ctx, "user-1", refund.ID, tooMuch.ID, 0); !errors.Is(err, ErrInvalidTransaction) {
+		t.Errorf("expected an income target to be rejected, got %v", err)
+	}
+
+	// The expense cannot drop below what has been refunded.
+	expense.Amount = 300
+	if _, err := svc.UpdateForUser(ctx, "user-1", expense); !errors.Is(err, ErrInvalidTransaction) {
+		t.Errorf("expected shrinking a refunded expense to be rejected, got %v", err)
+	}
+
+	unmarked, err := svc.UnmarkRefundForUser(ctx, "user-1", refund.ID, 0)
+	if err != nil {
+		t.Fatalf("UnmarkRefundForUser failed: %v", err)
+	}
+	if unmarked.IsRefund() {
+		t.Errorf("expected a plain income, got %+v", unmarked)
+	}
+	if _, err := svc.MarkRefundForUser(ctx, "user-1", tooMuch.ID, expense.ID, 0); err != nil {
+		t.Errorf("expected the refund to fit once the first was unmarked, got %v", err)
+	}
+}
+
+func TestTransactionService_TransferAndRefundEditsCheckVersion(t *testing.T) {
+	svc, _ := setupTransactionService(t)
+	ctx := context.Background()
+	date := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+
+	created, err := svc.CreateTransferForUser(ctx, "user-1", models.TransferRequest{
+		FromWalletID: "wallet-1", ToWalletID: "wallet-usd", SourceAmount: 35000, DestinationAmount: 1000, Date: date,
+	})
+	if err != nil {
+		t.Fatalf("CreateTransferForUser failed: %v", err)
+	}
+	seen := created.ExpenseTransaction.Version
+
+	// Another request edits the transfer after this one checked If-Match.
+	notes := "First edit"
+	if _, err := svc.UpdateTransferForUser(ctx, "user-1", created.ID, models.TransferPatch{Notes: &notes}, seen); err != nil {
+		t.Fatalf("UpdateTransferForUser failed: %v", err)
+	}
+	notes = "Second edit"
+	if _, err := svc.UpdateTransferForUser(ctx, "user-1", created.ID, models.TransferPatch{Notes: &notes}, seen); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Errorf("expected a stale transfer edit to be refused, got %v", err)
+	}
+	if err := svc.DeleteTransferForUser(ctx, "user-1", created.ID, seen); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Errorf("expected a stale transfer delete to be refused, got %v", err)
+	}
+	if got, err := svc.GetTransferForUser(ctx, "user-1", created.ID); err != nil || got.ExpenseTransaction.Description != "First edit" {
+		t.Errorf("expected the first edit to stand, got %+v, %v", got, err)
+	}
+
+	expense, err := svc.CreateForUser(ctx, "user-1", &models.Transaction{Amount: 1000, Date: date, Type: "expense", JarID: "jar-food", WalletID: "wallet-1"})
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+	refund, err := svc.CreateForUser(ctx, "user-1", &models.Transaction{Amount: 400, Date: date, Type: "income", WalletID: "wallet-1"})
+	if err != nil {
+		t.Fatalf("CreateForUser failed: %v", err)
+	}
+	marked, err := svc.MarkRefundForUser(ctx, "user-1", refund.ID, expense.ID, refund.Version)
+	if err != nil {
+		t.Fatalf("MarkRefundForUser failed: %v", err)
+	}
+	if _, err := svc.UnmarkRefundForUser(ctx, "user-1", refund.ID, refund.Version); !errors.Is(err, repository.ErrVersionMismatch) {
+		t.Errorf("expected a stale unmark to be refused, got %v", err)
+	}
+	if _, err := svc.UnmarkRefundForUser(ctx, "user-1", refund.ID, marked.Version); err != nil {
+		t.Errorf("expected the current version to unmark the refund, got %v", err)
+	}
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"log"
+	"time"
+)
+
+// TrashRetention is how long a deleted wallet can be restored before it is
+// purged.
+const TrashRetention = 30 * 24 * time.Hour
+
+var (
+	ErrDeletionNotFound = errors.New("deletion not found")
+	// ErrDeletionExpired is returned when restoring a batch past
+	// TrashRetention that the purge job has not removed yet.
+	ErrDeletionExpired = errors.New("deletion can no longer be restored")
+)
+
+type TrashService interface {
+	ListForUser(ctx context.Context, userID string) ([]models.Deletion, error)
+	RestoreForUser(ctx context.Context, userID, id string) error
+	// PurgeExpired removes the batches past TrashRetention for good and
+	// returns how many were removed.
+	PurgeExpired(ctx context.Context) (int, error)
+	Run(ctx context.Context, interval time.Duration)
+}
+
+type trashService struct {
+	repo  repository.TrashRepository
+	audit AuditService
+	clock func() time.Time
+}
+
+// NewTrashService creates the trash service. audit may be nil; otherwise
+// purges are recorded in the audit log as made by the system.
+func NewTrashService(repo repository.TrashRepository, audit AuditService) TrashService {
+	return &trashService{
+		repo:  repo,
+		audit: audit,
+		clock: func() time.Time {
+			return time.Now().UTC()
+		},
+	}
+}
+
+func (s *trashService) ListForUser(ctx context.Context, userID string) ([]models.Deletion, error) {
+	deletions, err := s.repo.ListForUser(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("service: failed to list trash: %w", err)
+	}
+	for i := range deletions {
+		deletions[i].RestorableUntil = deletions[i].DeletedAt.Add(TrashRetention)
+	}
+	if deletions == nil {
+		deletions = []models.Deletion{}
+	}
+	return deletions, nil
+}
+
+func (s *trashService) RestoreForUser(ctx context.Context, userID, id string) error {
+	deletion, err := s.repo.GetForUser(ctx, userID, id)
+	if err != nil {
+		return fmt.Errorf("service: failed to load deletion: %w", err)
+	}
+	if deletion == nil {
+		return ErrDeletionNotFound
+	}
+	if !s.clock().Before(deletion.DeletedAt.Add(TrashRetention)) {
+		return ErrDeletionExpired
+	}
+
+	if err := s.repo.RestoreForUser(ctx, userID, id); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrDeletionNotFound
+		}
+		return fmt.Errorf("service: failed to restore deletion: %w", err)
+	}
+	return nil
+}
+
+func (s *trashService) PurgeExpired(ctx context.Context) (int, error) {
+	expired, err := s.repo.ListExpired(ctx, s.clock().Add(-TrashRetention))
+	if err != nil {
+		return 0, fmt.Errorf("service: failed to list expired deletions: %w", err)
+	}
+
+	purged := 0
+	for _, deletion := range expired {
+		actor := models.AuditActor{Source: models.AuditSourceSystem}
+		err := attribute(ctx, s.audit, deletion.UserID, actor, func(ctx context.Context) error {
+			return s.repo.Purge(ctx, deletion.UserID, deletion.ID)
+		})
+		if err != nil {
+			return purged, fmt.Errorf("service: failed to purge deletion %s: %w", deletion.ID, err)
+		}
+		purged++
+	}
+	return purged, nil
+}
+
+// Run purges batches once they can no longer be restored.
+func (s *trashService) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		if purged, err := s.PurgeExpired(ctx); err != nil {
+			log.Printf("trash: purge failed: %v", err)
+		} else if purged > 0 {
+			log.Printf("trash: purged %d expired deletions", purged)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
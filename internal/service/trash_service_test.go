@@ -0,0 +1,409 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"jarwise-backend/internal/db"
+	"jarwise-backend/internal/models"
+	"jarwise-backend/internal/repository"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTrashService_RestoreAndPurgeDeletedWallet(t *testing.T) {
+	// A file database, so foreign keys are enforced during the purge.
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "trash.db"))
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	now := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+	svc := NewTrashService(repository.NewSQLiteTrashRepository(dbConn), nil).(*trashService)
+	svc.clock = func() time.Time { return now }
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	jarRepo := repository.NewSQLiteJarRepository(dbConn)
+	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
+	ctx := context.Background()
+
+	for _, wallet := range []*models.Wallet{
+		{ID: "cash", UserID: "user-1", Name: "Cash", Currency: "THB", Type: "cash", OpeningBalance: 100000},
+		{ID: "bank", UserID: "user-1", Name: "Bank", Currency: "THB", Type: "bank"},
+	} {
+		if err := walletRepo.Create(ctx, wallet); err != nil {
+			t.Fatalf("failed to create wallet: %v", err)
+		}
+	}
+	if err := jarRepo.Create(ctx, &models.Jar{ID: "food", UserID: "user-1", Name: "Food", Type: "expense", WalletID: "cash"}); err != nil {
+		t.Fatalf("failed to create jar: %v", err)
+	}
+	date := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	if err := txRepo.Create(ctx, &models.Transaction{ID: "lunch", UserID: "user-1", Amount: 12000, Description: "Lunch",
+		Date: date, Type: "expense", WalletID: "cash", JarID: "food"}); err != nil {
+		t.Fatalf("failed to create transaction: %v", err)
+	}
+	out, in := "t-out", "t-in"
+	if err := txRepo.CreateTransfer(ctx,
+		&models.Transaction{ID: out, UserID: "user-1", Amount: -30000, Date: date, Type: "transfer", WalletID: "cash", RelatedTransactionID: &in},
+		&models.Transaction{ID: in, UserID: "user-1", Amount: 30000, Date: date, Type: "transfer", WalletID: "bank", RelatedTransactionID: &out},
+		nil,
+	); err != nil {
+		t.Fatalf("failed to create transfer: %v", err)
+	}
+
+	trash := func() *models.Deletion {
+		t.Helper()
+		deletion := &models.Deletion{ID: "deletion-" + now.Format("0102"), DeletedAt: now}
+		if err := walletRepo.DeleteCascadeForUser(ctx, "user-1", "cash", 0, deletion); err != nil {
+			t.Fatalf("DeleteCascadeForUser failed: %v", err)
+		}
+		return deletion
+	}
+	deletion := trash()
+	if deletion.Name != "Cash" || deletion.Entity != "wallet" || deletion.EntityID != "cash" {
+		t.Errorf("expected the deletion to describe the wallet, got %+v", deletion)
+	}
+
+	// Trashed rows are hidden everywhere; the transfer goes whole, so the
+	// bank loses its incoming leg too.
+	if wallets, _ := walletRepo.ListAllForUser("user-1"); len(wallets) != 1 || wallets[0].ID != "bank" || wallets[0].Balance != 0 {
+		t.Errorf("expected only the bank wallet without the transfer, got %+v", wallets)
+	}
+	if jars, _ := jarRepo.ListAllForUser(ctx, "user-1"); len(jars) != 0 {
+		t.Errorf("expected the jar to be hidden, got %+v", jars)
+	}
+	if txs, _ := txRepo.ListAllForUser("user-1"); len(txs) != 0 {
+		t.Errorf("expected every transaction to be hidden, got %+v", txs)
+	}
+	if tx, _ := txRepo.GetByIDForUser("user-1", "lunch"); tx != nil {
+		t.Errorf("expected the trashed transaction to be hidden, got %+v", tx)
+	}
+	if err := walletRepo.DeleteCascadeForUser(ctx, "user-1", "cash", 0, &models.Deletion{ID: "again", DeletedAt: now}); err == nil {
+		t.Error("expected a trashed wallet not to be deleted again")
+	}
+
+	deletions, err := svc.ListForUser(ctx, "user-1")
+	if err != nil || len(deletions) != 1 || !deletions[0].RestorableUntil.Equal(now.Add(TrashRetention)) {
+		t.Fatalf("expected one deletion restorable for the retention window, got %+v, %v", deletions, err)
+	}
+	if err := svc.RestoreForUser(ctx, "user-2", deletion.ID); !errors.Is(err, ErrDeletionNotFound) {
+		t.Errorf("expected another user's deletion to be hidden, got %v", err)
+	}
+
+	if err := svc.RestoreForUser(ctx, "user-1", deletion.ID); err != nil {
+		t.Fatalf("RestoreForUser failed: %v", err)
+	}
+	if wallet, _ := walletRepo.GetForUser("user-1", "cash"); wallet == nil || wallet.Balance != 100000-12000-30000 {
+		t.Errorf("expected the wallet back with its balance, got %+v", wallet)
+	}
+	if wallet, _ := walletRepo.GetForUser("user-1", "bank"); wallet == nil || wallet.Balance != 30000 {
+		t.Errorf("expected the bank balance back with the transfer, got %+v", wallet)
+	}
+	if txs, _ := txRepo.ListAllForUser("user-1"); len(txs) != 3 {
+		t.Errorf("expected all transactions back, got %+v", txs)
+	}
+	if deletions, _ := svc.ListForUser(ctx, "user-1"); len(deletions) != 0 {
+		t.Errorf("expected the trash to be empty after a restore, got %+v", deletions)
+	}
+
+	// Past the retention window the deletion can only be purged.
+	deletion = trash()
+	now = now.Add(TrashRetention)
+	if err := svc.RestoreForUser(ctx, "user-1", deletion.ID); !errors.Is(err, ErrDeletionExpired) {
+		t.Errorf("expected an expired deletion to be refused, got %v", err)
+	}
+	purged, err := svc.PurgeExpired(ctx)
+	if err != nil || purged != 1 {
+		t.Fatalf("expected one deletion to be purged, got %d, %v", purged, err)
+	}
+
+	for _, table := range []string{"wallets", "jars", "transactions"} {
+		var count int
+		if err := dbConn.QueryRow("SELECT COUNT(*) FROM " + table + " WHERE deletion_id IS NOT NULL").Scan(&count); err != nil || count != 0 {
+			t.Errorf("expected no trashed %s after the purge, got %d, %v", table, count, err)
+		}
+	}
+	if wallet, _ := walletRepo.GetForUser("user-1", "cash"); wallet != nil {
+		t.Errorf("expected the wallet to be purged, got %+v", wallet)
+	}
+	if tx, _ := txRepo.GetByIDForUser("user-1", "t-in"); tx != nil {
+		t.Errorf("expected the incoming leg to be purged with the transfer, got %+v", tx)
+	}
+	if err := svc.RestoreForUser(ctx, "user-1", deletion.ID); !errors.Is(err, ErrDeletionNotFound) {
+		t.Errorf("expected a purged deletion to be gone, got %v", err)
+	}
+}
+
+func TestTrashService_TrashingWalletKeepsJarsOtherWalletsUse(t *testing.T) {
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "trash.db"))
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	now := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+	svc := NewTrashService(repository.NewSQLiteTrashRepository(dbConn), nil).(*trashService)
+	svc.clock = func() time.Time { return now }
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	jarRepo := repository.NewSQLiteJarRepository(dbConn)
+	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
+	ctx := context.Background()
+
+	for _, wallet := range []*models.Wallet{
+		{ID: "cash", UserID: "user-1", Name: "Cash", Currency: "THB", Type: "cash"},
+		{ID: "bank", UserID: "user-1", Name: "Bank", Currency: "THB", Type: "bank"},
+	} {
+		if err := walletRepo.Create(ctx, wallet); err != nil {
+			t.Fatalf("failed to create wallet: %v", err)
+		}
+	}
+	for _, jar := range []*models.Jar{
+		{ID: "living", UserID: "user-1", Name: "Living", Type: "expense", WalletID: "cash"},
+		{ID: "food", UserID: "user-1", Name: "Food", Type: "expense", WalletID: "cash", ParentID: "living"},
+		{ID: "petty", UserID: "user-1", Name: "Petty", Type: "expense", WalletID: "cash"},
+	} {
+		if err := jarRepo.Create(ctx, jar); err != nil {
+			t.Fatalf("failed to create jar: %v", err)
+		}
+	}
+	// The bank pays for food out of a jar that belongs to the cash wallet.
+	if err := txRepo.Create(ctx, &models.Transaction{ID: "groceries", UserID: "user-1", Amount: 5000, Description: "Groceries",
+		Date: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC), Type: "expense", WalletID: "bank", JarID: "food"}); err != nil {
+		t.Fatalf("failed to create transaction: %v", err)
+	}
+
+	deletion := &models.Deletion{ID: "deletion-cash", DeletedAt: now}
+	if err := walletRepo.DeleteCascadeForUser(ctx, "user-1", "cash", 0, deletion); err != nil {
+		t.Fatalf("DeleteCascadeForUser failed: %v", err)
+	}
+
+	// The jar in use and its parent stay live without a wallet; the unused
+	// jar goes to the trash with the wallet.
+	jars, err := jarRepo.ListAllForUser(ctx, "user-1")
+	if err != nil || len(jars) != 2 {
+		t.Fatalf("expected the food jar and its parent to stay, got %+v, %v", jars, err)
+	}
+	for _, jar := range jars {
+		if jar.ID == "petty" || jar.WalletID != "" {
+			t.Errorf("expected only used jars to stay, without a wallet, got %+v", jar)
+		}
+	}
+
+	now = now.Add(TrashRetention)
+	if purged, err := svc.PurgeExpired(ctx); err != nil || purged != 1 {
+		t.Fatalf("expected the deletion to be purged, got %d, %v", purged, err)
+	}
+	if tx, _ := txRepo.GetByIDForUser("user-1", "groceries"); tx == nil || tx.JarID != "food" {
+		t.Errorf("expected the bank transaction to keep its jar, got %+v", tx)
+	}
+	if jar, _ := jarRepo.GetForUser(ctx, "user-1", "petty"); jar != nil {
+		t.Errorf("expected the unused jar to be purged, got %+v", jar)
+	}
+}
+
+func TestTrashService_JarDeletesLeaveTrashedRowsAlone(t *testing.T) {
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "trash.db"))
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	now := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+	svc := NewTrashService(repository.NewSQLiteTrashRepository(dbConn), nil).(*trashService)
+	svc.clock = func() time.Time { return now }
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	jarRepo := repository.NewSQLiteJarRepository(dbConn)
+	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
+	jarSvc := NewJarService(jarRepo, walletRepo)
+	ctx := context.Background()
+
+	if err := walletRepo.Create(ctx, &models.Wallet{ID: "cash", UserID: "user-1", Name: "Cash", Currency: "THB", Type: "cash"}); err != nil {
+		t.Fatalf("failed to create wallet: %v", err)
+	}
+	for _, jar := range []*models.Jar{
+		{ID: "misc", UserID: "user-1", Name: "Misc", Type: "expense"},
+		{ID: "home", UserID: "user-1", Name: "Home", Type: "expense"},
+		{ID: "repairs", UserID: "user-1", Name: "Repairs", Type: "expense", ParentID: "home"},
+		{ID: "groceries", UserID: "user-1", Name: "Groceries", Type: "expense"},
+		{ID: "household", UserID: "user-1", Name: "Household", Type: "expense"},
+	} {
+		if err := jarRepo.Create(ctx, jar); err != nil {
+			t.Fatalf("failed to create jar: %v", err)
+		}
+	}
+	date := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	for _, tx := range []*models.Transaction{
+		{ID: "stamps", UserID: "user-1", Amount: 300, Date: date, Type: "expense", WalletID: "cash", JarID: "misc"},
+		{ID: "plumber", UserID: "user-1", Amount: 8000, Date: date, Type: "expense", WalletID: "cash", JarID: "repairs"},
+		{ID: "receipt", UserID: "user-1", Amount: 1500, Date: date, Type: "expense", WalletID: "cash", Splits: []models.TransactionSplit{
+			{ID: "line-1", JarID: "groceries", Amount: 1000},
+			{ID: "line-2", JarID: "household", Amount: 500},
+		}},
+	} {
+		if err := txRepo.Create(ctx, tx); err != nil {
+			t.Fatalf("failed to create transaction %s: %v", tx.ID, err)
+		}
+	}
+
+	deletion := &models.Deletion{ID: "deletion-cash", DeletedAt: now}
+	if err := walletRepo.DeleteCascadeForUser(ctx, "user-1", "cash", 0, deletion); err != nil {
+		t.Fatalf("DeleteCascadeForUser failed: %v", err)
+	}
+
+	// Trashed transactions no longer count as references.
+	if childJars, transactions, err := jarRepo.CountReferencesForUser(ctx, "user-1", "misc"); err != nil || childJars != 0 || transactions != 0 {
+		t.Errorf("expected no live references, got %d sub-jars, %d transactions, %v", childJars, transactions, err)
+	}
+	if err := jarSvc.DeleteForUser(ctx, "user-1", "misc", JarDeleteOptions{}); err != nil {
+		t.Errorf("expected a jar used only by trashed transactions to be deleted, got %v", err)
+	}
+	if err := jarSvc.DeleteForUser(ctx, "user-1", "home", JarDeleteOptions{Cascade: true}); err != nil {
+		t.Errorf("expected the cascade delete to succeed, got %v", err)
+	}
+	// A trashed split line cannot let go of its jar.
+	if err := jarSvc.DeleteForUser(ctx, "user-1", "groceries", JarDeleteOptions{}); !errors.Is(err, ErrJarInUse) {
+		t.Errorf("expected a jar named by a trashed split line to be refused, got %v", err)
+	}
+	if err := jarSvc.DeleteForUser(ctx, "user-1", "household", JarDeleteOptions{Cascade: true}); !errors.Is(err, ErrJarInUse) {
+		t.Errorf("expected the cascade to refuse a jar named by a trashed split line, got %v", err)
+	}
+
+	if err := svc.RestoreForUser(ctx, "user-1", deletion.ID); err != nil {
+		t.Fatalf("RestoreForUser failed: %v", err)
+	}
+	for _, id := range []string{"stamps", "plumber"} {
+		if tx, _ := txRepo.GetByIDForUser("user-1", id); tx == nil || tx.JarID != "" {
+			t.Errorf("expected %s back without its deleted jar, got %+v", id, tx)
+		}
+	}
+	if tx, _ := txRepo.GetByIDForUser("user-1", "receipt"); tx == nil || len(tx.Splits) != 2 {
+		t.Errorf("expected the split transaction back with its lines, got %+v", tx)
+	}
+}
+
+func TestTrashService_TrashedWalletCannotReplaceAnother(t *testing.T) {
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "trash.db"))
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	now := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+	svc := NewTrashService(repository.NewSQLiteTrashRepository(dbConn), nil).(*trashService)
+	svc.clock = func() time.Time { return now }
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
+	ctx := context.Background()
+
+	for _, wallet := range []*models.Wallet{
+		{ID: "cash", UserID: "user-1", Name: "Cash", Currency: "THB"},
+		{ID: "bank", UserID: "user-1", Name: "Bank", Currency: "THB"},
+	} {
+		if err := walletRepo.Create(ctx, wallet); err != nil {
+			t.Fatalf("failed to create wallet: %v", err)
+		}
+	}
+	if err := txRepo.Create(ctx, &models.Transaction{ID: "lunch", UserID: "user-1", Amount: 12000,
+		Date: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC), Type: "expense", WalletID: "cash"}); err != nil {
+		t.Fatalf("failed to create transaction: %v", err)
+	}
+	if err := walletRepo.DeleteCascadeForUser(ctx, "user-1", "bank", 0, &models.Deletion{ID: "deletion-bank", DeletedAt: now}); err != nil {
+		t.Fatalf("DeleteCascadeForUser failed: %v", err)
+	}
+
+	err = walletRepo.DeleteWithReplacementForUser(ctx, "user-1", "cash", "bank", 0)
+	if !errors.Is(err, repository.ErrInvalidReplacementWallet) {
+		t.Fatalf("expected a trashed replacement to be refused, got %v", err)
+	}
+	if tx, _ := txRepo.GetByIDForUser("user-1", "lunch"); tx == nil || tx.WalletID != "cash" {
+		t.Errorf("expected the transaction to stay visible in its wallet, got %+v", tx)
+	}
+
+	// The purge still goes through since nothing was moved under the wallet.
+	now = now.Add(TrashRetention)
+	if purged, err := svc.PurgeExpired(ctx); err != nil || purged != 1 {
+		t.Errorf("expected the trashed wallet to be purged, got %d, %v", purged, err)
+	}
+}
+
+func TestTrashService_TrashingWalletTakesCrossWalletTransfersWhole(t *testing.T) {
+	dbConn, err := db.InitDB(filepath.Join(t.TempDir(), "trash.db"))
+	if err != nil {
+		t.Fatalf("failed to init DB: %v", err)
+	}
+	defer dbConn.Close()
+
+	now := time.Date(2026, 7, 1, 9, 0, 0, 0, time.UTC)
+	svc := NewTrashService(repository.NewSQLiteTrashRepository(dbConn), nil).(*trashService)
+	svc.clock = func() time.Time { return now }
+	walletRepo := repository.NewSQLiteWalletRepository(dbConn)
+	jarRepo := repository.NewSQLiteJarRepository(dbConn)
+	txRepo := repository.NewSQLiteTransactionRepository(dbConn)
+	ctx := context.Background()
+
+	for _, wallet := range []*models.Wallet{
+		{ID: "cash", UserID: "user-1", Name: "Cash", Currency: "THB", OpeningBalance: 100000},
+		{ID: "bank", UserID: "user-1", Name: "Bank", Currency: "THB"},
+	} {
+		if err := walletRepo.Create(ctx, wallet); err != nil {
+			t.Fatalf("failed to create wallet: %v", err)
+		}
+	}
+	if err := jarRepo.Create(ctx, &models.Jar{ID: "fees", UserID: "user-1", Name: "Fees", Type: "expense"}); err != nil {
+		t.Fatalf("failed to create jar: %v", err)
+	}
+	date := time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC)
+	out, in := "t-out", "t-in"
+	if err := txRepo.CreateTransfer(ctx,
+		&models.Transaction{ID: out, UserID: "user-1", Amount: -30000, Date: date, Type: "transfer", WalletID: "cash", RelatedTransactionID: &in},
+		&models.Transaction{ID: in, UserID: "user-1", Amount: 30000, Date: date, Type: "transfer", WalletID: "bank", RelatedTransactionID: &out},
+		&models.Transaction{ID: "t-fee", UserID: "user-1", Amount: 500, Date: date, Type: "expense", WalletID: "cash", JarID: "fees", RelatedTransactionID: &out},
+	); err != nil {
+		t.Fatalf("failed to create transfer: %v", err)
+	}
+
+	// Trashing the receiving wallet takes the outgoing leg and its fee out of
+	// the cash wallet, so nothing there points at a trashed row.
+	deletion := &models.Deletion{ID: "deletion-bank", DeletedAt: now}
+	if err := walletRepo.DeleteCascadeForUser(ctx, "user-1", "bank", 0, deletion); err != nil {
+		t.Fatalf("DeleteCascadeForUser failed: %v", err)
+	}
+	if txs, _ := txRepo.ListAllForUser("user-1"); len(txs) != 0 {
+		t.Errorf("expected the whole transfer in the trash, got %+v", txs)
+	}
+	if wallet, _ := walletRepo.GetForUser("user-1", "cash"); wallet == nil || wallet.Balance != 100000 {
+		t.Errorf("expected the cash balance without the transfer, got %+v", wallet)
+	}
+
+	if err := svc.RestoreForUser(ctx, "user-1", deletion.ID); err != nil {
+		t.Fatalf("RestoreForUser failed: %v", err)
+	}
+	transfer, err := txRepo.GetTransferForUser("user-1", in)
+	if err != nil || transfer == nil || transfer.ID != out || transfer.FeeTransaction == nil {
+		t.Fatalf("expected the transfer back with its fee, got %+v, %v", transfer, err)
+	}
+	if wallet, _ := walletRepo.GetForUser("user-1", "cash"); wallet == nil || wallet.Balance != 100000-30000-500 {
+		t.Errorf("expected the cash balance back with the transfer, got %+v", wallet)
+	}
+	if wallet, _ := walletRepo.GetForUser("user-1", "bank"); wallet == nil || wallet.Balance != 30000 {
+		t.Errorf("expected the bank balance back, got %+v", wallet)
+	}
+
+	// Purging takes the whole transfer away with the wallet.
+	deletion = &models.Deletion{ID: "deletion-bank-again", DeletedAt: now}
+	if err := walletRepo.DeleteCascadeForUser(ctx, "user-1", "bank", 0, deletion); err != nil {
+		t.Fatalf("DeleteCascadeForUser failed: %v", err)
+	}
+	now = now.Add(TrashRetention)
+	if purged, err := svc.PurgeExpired(ctx); err != nil || purged != 1 {
+		t.Fatalf("expected the deletion to be purged, got %d, %v", purged, err)
+	}
+	var left int
+	if err := dbConn.QueryRow("SELECT COUNT(*) FROM transactions").Scan(&left); err != nil || left != 0 {
+		t.Errorf("expected the transfer to be purged, got %d rows, %v", left, err)
+	}
+	if wallet, _ := walletRepo.GetForUser("user-1", "cash"); wallet == nil || wallet.Balance != 100000 {
+		t.Errorf("expected the cash wallet to stay without the transfer, got %+v", wallet)
+	}
+}
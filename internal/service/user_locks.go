@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// userLocks hands out one lock per user, so work for one user never waits on
+// another's. The zero value is ready to use.
+type userLocks struct {
+	mu    sync.Mutex
+	locks map[string]*userLock
+}
+
+// userLock is held by whoever sent to held. refs counts the holder and the
+// waiters, so the lock is forgotten once nobody needs it.
+type userLock struct {
+	held chan struct{}
+	refs int
+}
+
+// lock waits until userID's lock is free and returns its release.
+func (l *userLocks) lock(ctx context.Context, userID string) (func(), error) {
+	l.mu.Lock()
+	if l.locks == nil {
+		l.locks = make(map[string]*userLock)
+	}
+	lock := l.locks[userID]
+	if lock == nil {
+		lock = &userLock{held: make(chan struct{}, 1)}
+		l.locks[userID] = lock
+	}
+	lock.refs++
+	l.mu.Unlock()
+
+	select {
+	case lock.held <- struct{}{}:
+		return func() {
+			<-lock.held
+			l.unref(userID, lock)
+		}, nil
+	case <-ctx.Done():
+		l.unref(userID, lock)
+		return nil, ctx.Err()
+	}
+}
+
+func (l *userLocks) unref(userID string, lock *userLock) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	lock.refs--
+	if lock.refs == 0 {
+		delete(l.locks, userID)
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import (
+	"jarwise-backend/internal/models"
+	"strings"
+)
+
+const defaultWalletType = "general"
+
+// NormalizeWallet trims and defaults wallet fields, returning a message
+// describing the first invalid field or "" when the wallet is valid.
+func NormalizeWallet(wallet *models.Wallet) string {
+	wallet.Name = strings.TrimSpace(wallet.Name)
+	wallet.Type = strings.TrimSpace(wallet.Type)
+
+	if wallet.Name == "" {
+		return "name is required"
+	}
+	currency, ok := models.NormalizeCurrency(wallet.Currency)
+	wallet.Currency = currency
+	if !ok {
+		return "currency must be a 3-letter ISO 4217 code"
+	}
+	if wallet.Type == "" {
+		wallet.Type = defaultWalletType
+	}
+	return ""
+}
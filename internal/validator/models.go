@@ -11,5 +11,5 @@ type ValidationResult struct {
 	DBStats  models.MigrationStats `json:"db_stats"`
 	XLSStats models.MigrationStats `json:"xls_stats"`
 
-	DiffBalance float64 `json:"diff_balance"`
+	DiffBalance models.Money `json:"diff_balance"`
 }
@@ -34,14 +34,14 @@ func (v *Validator) ValidateIntegrity(data *models.ParsedData) []string {
 
 	for _, tx := range data.Transactions {
 		if !walletIDs[tx.AccountID] {
-			errors = append(errors, fmt.Sprintf("Tx %s (%.2f) references unknown Account %s", tx.ID, tx.Amount, tx.AccountID))
+			errors = append(errors, fmt.Sprintf("Tx %s (%s) references unknown Account %s", tx.ID, tx.Amount, tx.AccountID))
 		}
 		if tx.Type != 2 && !isAcceptedCategoryReference(tx.CategoryID, jarIDs) {
-			errors = append(errors, fmt.Sprintf("Tx %s (%.2f) references unknown Category %s", tx.ID, tx.Amount, tx.CategoryID))
+			errors = append(errors, fmt.Sprintf("Tx %s (%s) references unknown Category %s", tx.ID, tx.Amount, tx.CategoryID))
 		}
 		// Check transfer target
 		if tx.Type == 2 && tx.ToAccountID != "" && !walletIDs[tx.ToAccountID] {
-			errors = append(errors, fmt.Sprintf("Transfer %s (%.2f) references unknown ToAccount %s", tx.ID, tx.Amount, tx.ToAccountID))
+			errors = append(errors, fmt.Sprintf("Transfer %s (%s) references unknown ToAccount %s", tx.ID, tx.Amount, tx.ToAccountID))
 		}
 	}
 
@@ -77,18 +77,17 @@ func (v *Validator) Validate(dbData, xlsData *models.ParsedData) *ValidationResu
 	}
 
 	// 3. Compare Totals (Income)
-	// Using epsilon for float comparison
-	epsilon := 0.01
-	if math.Abs(result.DBStats.TotalIncome-result.XLSStats.TotalIncome) > epsilon {
+	// Totals are summed in minor units, so they must match to the cent.
+	if result.DBStats.TotalIncome != result.XLSStats.TotalIncome {
 		result.IsValid = false
-		result.Errors = append(result.Errors, fmt.Sprintf("Total Income mismatch: DB=%.2f, XLS=%.2f",
+		result.Errors = append(result.Errors, fmt.Sprintf("Total Income mismatch: DB=%s, XLS=%s",
 			result.DBStats.TotalIncome, result.XLSStats.TotalIncome))
 	}
 
 	// 4. Compare Totals (Expense)
-	if math.Abs(result.DBStats.TotalExpense-result.XLSStats.TotalExpense) > epsilon {
+	if result.DBStats.TotalExpense != result.XLSStats.TotalExpense {
 		result.IsValid = false
-		result.Errors = append(result.Errors, fmt.Sprintf("Total Expense mismatch: DB=%.2f, XLS=%.2f",
+		result.Errors = append(result.Errors, fmt.Sprintf("Total Expense mismatch: DB=%s, XLS=%s",
 			result.DBStats.TotalExpense, result.XLSStats.TotalExpense))
 	}
 